@@ -0,0 +1,184 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const ackPrefix = "/ack/"
+
+// maxAcknowledgement is the furthest in the future an acknowledgement may
+// end, so that a problem can't be silenced and forgotten.
+const maxAcknowledgement = 365 * 24 * time.Hour
+
+// Acknowledgement silences the warnings and errors for a host until a
+// date, e.g. because its certificate expires next week but renewal is
+// already scheduled. Acknowledged hosts are left out of quiet output, and
+// don't affect the status code.
+type Acknowledgement struct {
+	Host    string    `json:"host"`
+	Until   time.Time `json:"until"`
+	Reason  string    `json:"reason,omitempty"`
+	By      string    `json:"by,omitempty"` // the name of the API key used
+	Created time.Time `json:"created"`
+}
+
+// Active returns true if the acknowledgement hasn't ended at now.
+func (a Acknowledgement) Active(now time.Time) bool {
+	return now.Before(a.Until)
+}
+
+func ackKey(host string) string {
+	return "ack/" + strings.ToLower(host)
+}
+
+// acknowledgement returns the acknowledgement for host that is active at
+// now, or nil if there isn't one.
+func (s *Server) acknowledgement(host string, now time.Time) (*Acknowledgement, error) {
+	buf, ok, err := s.store.Get(ackKey(host))
+	if err != nil || !ok {
+		return nil, err
+	}
+	ack := Acknowledgement{}
+	if err := json.Unmarshal(buf, &ack); err != nil {
+		return nil, err
+	}
+	if !ack.Active(now) {
+		return nil, nil
+	}
+	return &ack, nil
+}
+
+// applyAcknowledgements attaches the acknowledgements active at now to
+// expirations.
+func (s *Server) applyAcknowledgements(expirations []Expiration, now time.Time) {
+	for i := range expirations {
+		ack, err := s.acknowledgement(expirations[i].Name, now)
+		if err != nil {
+			log.Printf("cannot load acknowledgement for %s: %s", logName(expirations[i].Name), err)
+			continue
+		}
+		expirations[i].Acknowledgement = ack
+	}
+}
+
+// parseAckUntil parses the end of an acknowledgement, which is either a
+// date, taken to mean the end of that day in UTC, or an RFC 3339 time.
+func parseAckUntil(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t.Add(24 * time.Hour), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Cannot parse until parameter: expected a date like 2006-01-02")
+	}
+	return t, nil
+}
+
+// ackFormToken returns the token that the acknowledgement form on the
+// detail page for host sends along with key, so that the form can only be
+// submitted from a page that was served to the key's holder.
+func ackFormToken(key, host string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte("ack\x00" + strings.ToLower(host)))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// serveAck serves the acknowledgement for host. GET responds with it,
+// POST creates it from the until and reason parameters, and DELETE (or
+// POST with the clear parameter) ends it. Changing an acknowledgement
+// requires an admin key, so servers without API keys don't allow it, since
+// anyone could silence anyone else's host.
+func (s *Server) serveAck(w http.ResponseWriter, r *http.Request, host string) {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if host == "" || strings.Contains(host, ",") {
+		http.NotFound(w, r)
+		return
+	}
+	now := time.Now()
+
+	switch r.Method {
+	case "GET", "HEAD":
+		ack, err := s.acknowledgement(host, now)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if ack == nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ack)
+		return
+	case "POST", "DELETE":
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.authorizeAdmin(w, r, "acknowledging "+host) {
+		return
+	}
+	key := s.apiKey(r)
+	if r.FormValue("redirect") != "" && !hmac.Equal([]byte(r.FormValue("token")), []byte(ackFormToken(key.Key, host))) {
+		http.Error(w, "the form has expired, reload the page", http.StatusForbidden)
+		return
+	}
+
+	ack := Acknowledgement{
+		Host:    host,
+		Until:   now,
+		Reason:  r.FormValue("reason"),
+		Created: now,
+		By:      key.Name,
+	}
+	if r.Method == "POST" && r.Form["clear"] == nil {
+		until, err := parseAckUntil(r.FormValue("until"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !until.After(now) {
+			http.Error(w, "until must be in the future", http.StatusBadRequest)
+			return
+		}
+		if until.Sub(now) > maxAcknowledgement {
+			http.Error(w, "until must be within a year", http.StatusBadRequest)
+			return
+		}
+		ack.Until = until
+	}
+
+	buf, err := json.Marshal(ack)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.store.Put(ackKey(host), buf); err != nil {
+		http.Error(w, "cannot save acknowledgement: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// the form on the detail page goes back to it
+	if r.FormValue("redirect") != "" {
+		http.Redirect(w, r, detailPrefix+url.PathEscape(host)+"?"+url.Values{"key": {key.Key}}.Encode(), http.StatusSeeOther)
+		return
+	}
+	if !ack.Active(now) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ack)
+}
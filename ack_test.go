@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcknowledgedStatus(t *testing.T) {
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	th := thresholds{Now: now, Soon: now.Add(30 * 24 * time.Hour)}
+	ack := &Acknowledgement{Host: "example.com", Until: now.Add(7 * 24 * time.Hour)}
+
+	for _, tt := range []struct {
+		exp  Expiration
+		want string
+	}{
+		{Expiration{CertificateExpires: now.Add(7 * 24 * time.Hour), Acknowledgement: ack}, StatusAcknowledged},
+		{Expiration{CertificateError: fmt.Errorf("dial failed"), Acknowledgement: ack}, StatusAcknowledged},
+		{Expiration{CertificateExpires: now.Add(365 * 24 * time.Hour), Acknowledgement: ack}, StatusOK},
+		{Expiration{CertificateExpires: now.Add(7 * 24 * time.Hour)}, StatusExpiring},
+	} {
+		if got := tt.exp.Status(th); got != tt.want {
+			t.Errorf("%+v: expected %s, got %s", tt.exp, tt.want, got)
+		}
+		if got, want := tt.exp.OK(th), tt.want != StatusExpiring; got != want {
+			t.Errorf("%+v: expected OK to be %v", tt.exp, want)
+		}
+	}
+}
+
+func TestServeAck(t *testing.T) {
+	s := NewServer(&Config{APIKeys: []APIKey{{Name: "ops", Key: "secret", Admin: true}}})
+	until := time.Now().Add(7 * 24 * time.Hour).UTC().Format("2006-01-02")
+
+	r := httptest.NewRequest("GET", "/ack/example.com", nil)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 before acknowledging, got %d", w.Code)
+	}
+
+	r = httptest.NewRequest("POST", "/ack/Example.com", strings.NewReader(url.Values{
+		"until":  {until},
+		"reason": {"renewal scheduled"},
+	}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	r = httptest.NewRequest("GET", "/ack/example.com", nil)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	ack := Acknowledgement{}
+	if err := json.NewDecoder(w.Body).Decode(&ack); err != nil {
+		t.Fatal(err)
+	}
+	if ack.Host != "example.com" || ack.Reason != "renewal scheduled" || ack.Until.Format("2006-01-02") <= until {
+		t.Errorf("unexpected acknowledgement %+v", ack)
+	}
+
+	expirations := []Expiration{{Name: "example.com"}, {Name: "example.net"}}
+	s.applyAcknowledgements(expirations, time.Now())
+	if expirations[0].Acknowledgement == nil || expirations[1].Acknowledgement != nil {
+		t.Errorf("expected only example.com to be acknowledged, got %+v", expirations)
+	}
+
+	r = httptest.NewRequest("DELETE", "/ack/example.com", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if ack, _ := s.acknowledgement("example.com", time.Now()); ack != nil {
+		t.Errorf("expected the acknowledgement to have ended, got %+v", ack)
+	}
+
+	for _, until := range []string{"", "yesterday", "2001-01-01", time.Now().Add(400 * 24 * time.Hour).Format("2006-01-02")} {
+		r = httptest.NewRequest("POST", "/ack/example.com?until="+until, nil)
+		r.Header.Set("Authorization", "Bearer secret")
+		w = httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", until, w.Code)
+		}
+	}
+}
+
+func TestServeAckRequiresAdmin(t *testing.T) {
+	s := NewServer(&Config{APIKeys: []APIKey{
+		{Name: "ops", Key: "secret", Admin: true},
+		{Name: "web", Key: "public"},
+	}})
+	until := time.Now().Add(7 * 24 * time.Hour).Format("2006-01-02")
+
+	for key, want := range map[string]int{
+		"":       http.StatusUnauthorized,
+		"public": http.StatusForbidden,
+		"secret": http.StatusCreated,
+	} {
+		r := httptest.NewRequest("POST", "/ack/example.com?until="+until, nil)
+		if key != "" {
+			r.Header.Set("Authorization", "Bearer "+key)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("%q: expected %d, got %d", key, want, w.Code)
+		}
+	}
+
+	ack, err := s.acknowledgement("example.com", time.Now())
+	if err != nil || ack == nil || ack.By != "ops" {
+		t.Errorf("expected an acknowledgement by ops, got %+v, %v", ack, err)
+	}
+}
+
+func TestServeAckWithoutAPIKeys(t *testing.T) {
+	s := NewServer(&Config{})
+	until := time.Now().Add(7 * 24 * time.Hour).Format("2006-01-02")
+	for _, method := range []string{"POST", "DELETE"} {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(method, "/ack/example.com?until="+until, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", method, w.Code)
+		}
+	}
+	if ack, _ := s.acknowledgement("example.com", time.Now()); ack != nil {
+		t.Errorf("expected no acknowledgement, got %+v", ack)
+	}
+}
+
+func TestAckForm(t *testing.T) {
+	s := NewServer(&Config{APIKeys: []APIKey{{Name: "ops", Key: "secret", Admin: true}}})
+	until := time.Now().Add(7 * 24 * time.Hour).Format("2006-01-02")
+
+	for token, want := range map[string]int{
+		"":                                    http.StatusForbidden,
+		ackFormToken("other", "example.com"):  http.StatusForbidden,
+		ackFormToken("secret", "example.org"): http.StatusForbidden,
+		ackFormToken("secret", "Example.com"): http.StatusSeeOther,
+	} {
+		r := httptest.NewRequest("POST", "/ack/example.com?key=secret", strings.NewReader(url.Values{
+			"until":    {until},
+			"redirect": {"1"},
+			"token":    {token},
+		}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("%q: expected %d, got %d", token, want, w.Code)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+// AddressExpiration is the result of checking the certificate served at
+// one of the addresses a host resolves to.
+type AddressExpiration struct {
+	Address            string    `json:"address"`
+	CertificateExpires time.Time `json:"certificateExpires"`
+	Fingerprint        string    `json:"fingerprint,omitempty"` // of the leaf, SHA-256
+	Error              string    `json:"error,omitempty"`
+
+	notBefore time.Time
+}
+
+// lookupIPAddrs resolves a host to its addresses. It is a variable so that
+// tests can replace it.
+var lookupIPAddrs = net.DefaultResolver.LookupIPAddr
+
+// pinnedDialer connects to ip whatever host is dialed, so that the
+// certificate served at ip is checked with the host's name for SNI.
+type pinnedDialer struct {
+	expire.Dialer
+	ip string
+}
+
+func (d pinnedDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	dialer := d.Dialer
+	if dialer == nil {
+		dialer = expire.DefaultDialer
+	}
+	return dialer.DialContext(ctx, network, net.JoinHostPort(d.ip, port))
+}
+
+// checkAllAddresses checks the certificate served at each address that
+// hostname resolves to, for hosts behind round-robin DNS whose backends
+// may serve different certificates. The earliest expiration becomes the
+// host's, and addresses that can't be checked or serve a different
+// certificate than the others are warned about.
+func checkAllAddresses(ctx context.Context, hostname string, opts checkOptions, exp *Expiration) {
+	host, _ := expire.SplitHostPort(hostname)
+	if net.ParseIP(host) != nil {
+		return
+	}
+	asciiHost, err := expire.ToASCII(host)
+	if err != nil {
+		return
+	}
+	addrs, err := lookupIPAddrs(ctx, asciiHost)
+	if err != nil {
+		exp.Warnings = append(exp.Warnings, fmt.Sprintf("cannot resolve the addresses of %s: %s", host, err))
+		return
+	}
+
+	exp.Addresses = make([]AddressExpiration, len(addrs))
+	expire.ForEach(len(addrs), opts.Concurrency, func(i int) {
+		ip := addrs[i].String()
+		addrOpts := opts.Options
+		addrOpts.Dialer = pinnedDialer{Dialer: opts.Dialer, ip: ip}
+		result, err := expire.CheckCertificate(ctx, hostname, addrOpts)
+		exp.Addresses[i].Address = ip
+		if err != nil {
+			exp.Addresses[i].Error = err.Error()
+			return
+		}
+		exp.Addresses[i].CertificateExpires = result.Expires
+		exp.Addresses[i].notBefore = result.NotBefore
+		if len(result.Chain) > 0 {
+			exp.Addresses[i].Fingerprint = result.Chain[0].Fingerprint
+		}
+	})
+
+	fingerprints := map[string]bool{}
+	for _, addr := range exp.Addresses {
+		if addr.Error != "" {
+			exp.Warnings = append(exp.Warnings, fmt.Sprintf("cannot check the certificate at %s: %s", addr.Address, addr.Error))
+			continue
+		}
+		fingerprints[addr.Fingerprint] = true
+		if exp.CertificateError == nil && addr.CertificateExpires.Before(exp.CertificateExpires) {
+			exp.CertificateExpires = addr.CertificateExpires
+			exp.CertificateNotBefore = addr.notBefore
+		}
+	}
+	if len(fingerprints) > 1 {
+		exp.Warnings = append(exp.Warnings, fmt.Sprintf("%d different certificates are served at the addresses of %s", len(fingerprints), host))
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+// testTLSServer returns a server with a self signed certificate for name
+// that expires at notAfter.
+func testTLSServer(t *testing.T, name string, notAfter time.Time) *httptest.Server {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	server.StartTLS()
+	return server
+}
+
+// addressDialer connects to the listener for each address dialed.
+type addressDialer map[string]string
+
+func (d addressDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	target, ok := d[address]
+	if !ok {
+		return nil, fmt.Errorf("connection refused")
+	}
+	return (&net.Dialer{}).DialContext(ctx, network, target)
+}
+
+func TestCheckAllAddresses(t *testing.T) {
+	defer func(f func(context.Context, string) ([]net.IPAddr, error)) { lookupIPAddrs = f }(lookupIPAddrs)
+	lookupIPAddrs = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		if host != "www.example.com" {
+			return nil, fmt.Errorf("no such host")
+		}
+		return []net.IPAddr{{IP: net.ParseIP("192.0.2.1")}, {IP: net.ParseIP("192.0.2.2")}, {IP: net.ParseIP("2001:db8::1")}}, nil
+	}
+
+	now := time.Now().Truncate(time.Second)
+	a := testTLSServer(t, "www.example.com", now.AddDate(0, 2, 0))
+	defer a.Close()
+	b := testTLSServer(t, "www.example.com", now.AddDate(0, 1, 0))
+	defer b.Close()
+
+	opts := NewServer(&Config{}).defaultCheckOptions()
+	pool := x509.NewCertPool()
+	pool.AddCert(a.Certificate())
+	pool.AddCert(b.Certificate())
+	expire.AddTrustStore("test", pool) // so that the self signed certificates can be checked
+	opts.TrustStores = []string{"test"}
+	opts.Dialer = addressDialer{
+		"192.0.2.1:443": a.Listener.Addr().String(),
+		"192.0.2.2:443": b.Listener.Addr().String(),
+	}
+	exp := Expiration{Name: "www.example.com", CertificateExpires: now.AddDate(0, 2, 0)}
+	checkAllAddresses(context.Background(), "www.example.com", opts, &exp)
+
+	if len(exp.Addresses) != 3 {
+		t.Fatalf("expected 3 addresses, got %+v", exp.Addresses)
+	}
+	if addr := exp.Addresses[1]; addr.Address != "192.0.2.2" || !addr.CertificateExpires.Equal(now.AddDate(0, 1, 0)) || addr.Fingerprint == "" {
+		t.Errorf("unexpected address %+v", addr)
+	}
+	if addr := exp.Addresses[2]; addr.Address != "2001:db8::1" || addr.Error == "" {
+		t.Errorf("expected an error for %+v", addr)
+	}
+	if !exp.CertificateExpires.Equal(now.AddDate(0, 1, 0)) {
+		t.Errorf("expected the earliest expiration, got %s", exp.CertificateExpires)
+	}
+	warnings := strings.Join(exp.Warnings, "\n")
+	if !strings.Contains(warnings, "2 different certificates are served at the addresses of www.example.com") {
+		t.Errorf("expected a warning about the different certificates, got %q", warnings)
+	}
+	if !strings.Contains(warnings, "cannot check the certificate at 2001:db8::1") {
+		t.Errorf("expected a warning about the failed address, got %q", warnings)
+	}
+
+	// addresses aren't looked up for hosts given as one
+	exp = Expiration{Name: "192.0.2.1"}
+	checkAllAddresses(context.Background(), "192.0.2.1", opts, &exp)
+	if exp.Addresses != nil || exp.Warnings != nil {
+		t.Errorf("unexpected result %+v", exp)
+	}
+}
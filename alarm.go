@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// parseAlarms parses a comma separated list of how long before each
+// expiration to remind calendar subscribers, like 30d,7d,1d.
+func parseAlarms(s string) ([]time.Duration, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var rv []time.Duration
+	for _, part := range strings.Split(s, ",") {
+		d, err := parseDuration(part)
+		if err != nil {
+			return nil, err
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("%q is not before the expiration", part)
+		}
+		rv = append(rv, d)
+	}
+	return rv, nil
+}
+
+// formatAlarmTrigger returns the iCal TRIGGER for an alarm before the
+// start of an event, e.g. -P7D or -P1DT12H.
+func formatAlarmTrigger(before time.Duration) string {
+	before = before.Truncate(time.Second)
+	days := before / (24 * time.Hour)
+	before -= days * 24 * time.Hour
+
+	rv := "-P"
+	if days > 0 {
+		rv += fmt.Sprintf("%dD", days)
+	}
+	if before > 0 {
+		rv += "T"
+		for _, unit := range []struct {
+			d      time.Duration
+			suffix string
+		}{{time.Hour, "H"}, {time.Minute, "M"}, {time.Second, "S"}} {
+			if n := before / unit.d; n > 0 {
+				rv += fmt.Sprintf("%d%s", n, unit.suffix)
+				before -= n * unit.d
+			}
+		}
+	}
+	if rv == "-P" {
+		rv += "T0S"
+	}
+	return rv
+}
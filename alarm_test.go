@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jordic/goics"
+)
+
+func TestParseAlarms(t *testing.T) {
+	alarms, err := parseAlarms("30d,7d,1d,12h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := 24 * time.Hour
+	if want := []time.Duration{30 * day, 7 * day, day, 12 * time.Hour}; !reflect.DeepEqual(alarms, want) {
+		t.Errorf("got %v, want %v", alarms, want)
+	}
+	if alarms, err := parseAlarms(""); err != nil || alarms != nil {
+		t.Errorf("got %v, %v, want no alarms", alarms, err)
+	}
+	for _, s := range []string{"7", "d", "-1d", "0d", "7d,", "1x"} {
+		if _, err := parseAlarms(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+func TestFormatAlarmTrigger(t *testing.T) {
+	for _, tt := range []struct {
+		before time.Duration
+		want   string
+	}{
+		{7 * 24 * time.Hour, "-P7D"},
+		{36 * time.Hour, "-P1DT12H"},
+		{90 * time.Minute, "-PT1H30M"},
+		{0, "-PT0S"},
+	} {
+		if got := formatAlarmTrigger(tt.before); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.before, got, tt.want)
+		}
+	}
+}
+
+func TestCalendarAlarms(t *testing.T) {
+	cal := Calendar{
+		Expirations: []Expiration{{
+			Name:               "example.com",
+			CertificateExpires: time.Now().Add(60 * 24 * time.Hour),
+			Domain:             "example.com",
+			DomainError:        fmt.Errorf("no expiration"),
+		}},
+		Alarms: []time.Duration{30 * 24 * time.Hour, 24 * time.Hour},
+	}
+	buf := &bytes.Buffer{}
+	goics.NewICalEncode(buf).Encode(cal)
+	ics := buf.String()
+
+	// only the certificate expiration has reminders, not the failure to
+	// check the domain
+	if n := strings.Count(ics, "BEGIN:VALARM"); n != 2 {
+		t.Errorf("expected 2 alarms, got %d in\n%s", n, ics)
+	}
+	for _, want := range []string{"TRIGGER:-P30D", "TRIGGER:-P1D", "ACTION:DISPLAY"} {
+		if !strings.Contains(ics, want) {
+			t.Errorf("expected %s in\n%s", want, ics)
+		}
+	}
+}
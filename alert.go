@@ -0,0 +1,428 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+// defaultAlertInterval is how often watchlists with an escalation policy
+// are checked when the configuration doesn't say.
+const defaultAlertInterval = time.Hour
+
+// Checks that alerts are raised for
+const (
+	CheckCertificate = "certificate"
+	CheckDomain      = "domain"
+)
+
+// EscalationStage is a step in a watchlist's escalation policy: once an
+// expiration is at most Days away, the notifiers named in Notify are
+// alerted. For example, a policy might post to Slack at 30 days and page
+// someone at 7.
+type EscalationStage struct {
+	Days   int      `yaml:"days"`
+	Notify []string `yaml:"notify"`
+}
+
+// Alert is an expiration, or a failure to check one, that someone should
+// be told about.
+type Alert struct {
+	Watchlist     string    `json:"watchlist"`
+	Host          string    `json:"host"`
+	Check         string    `json:"check"` // certificate or domain
+	Expires       time.Time `json:"expires,omitempty"`
+	DaysRemaining int       `json:"daysRemaining"`
+	Error         string    `json:"error,omitempty"`
+
+	// Stage is the index of the escalation stage that was reached.
+	Stage int `json:"stage"`
+
+	// Resolved is true if the alert is to say that an earlier alert no
+	// longer applies, e.g. because the certificate was renewed.
+	Resolved bool `json:"resolved,omitempty"`
+
+	// Change is set if the alert is to say that the expiration changed,
+	// rather than that it is near.
+	Change *HistoryChange `json:"change,omitempty"`
+}
+
+// Key identifies the check that the alert is for.
+func (a Alert) Key() string {
+	return a.Watchlist + "/" + a.Host + "/" + a.Check
+}
+
+func (a Alert) String() string {
+	if a.Change != nil {
+		return fmt.Sprintf("%s: %s", a.Host, a.Change)
+	}
+	if a.Resolved {
+		if a.Expires.IsZero() {
+			return fmt.Sprintf("%s: %s resolved", a.Host, a.Check)
+		}
+		return fmt.Sprintf("%s: %s resolved, now expires in %d days, on %s", a.Host, a.Check,
+			a.DaysRemaining, a.Expires.Format("2006-01-02"))
+	}
+	if a.Error != "" {
+		return fmt.Sprintf("%s: cannot check %s: %s", a.Host, a.Check, a.Error)
+	}
+	return fmt.Sprintf("%s: %s expires in %d days, on %s", a.Host, a.Check,
+		a.DaysRemaining, a.Expires.Format("2006-01-02"))
+}
+
+// escalationStage returns the index of the latest stage of stages that
+// an expiration days away has reached, i.e. the one with the fewest days
+// that are at least days. The second return value is false if no stage
+// has been reached.
+func escalationStage(stages []EscalationStage, days int) (int, bool) {
+	rv, ok := 0, false
+	for i, stage := range stages {
+		if days <= stage.Days && (!ok || stage.Days < stages[rv].Days) {
+			rv, ok = i, true
+		}
+	}
+	return rv, ok
+}
+
+// firstEscalationStage returns the index of the stage that is reached
+// first, which failed checks are reported to.
+func firstEscalationStage(stages []EscalationStage) int {
+	rv := 0
+	for i, stage := range stages {
+		if stage.Days > stages[rv].Days {
+			rv = i
+		}
+	}
+	return rv
+}
+
+// escalate returns the alerts for expirations under the escalation policy
+// of wl, as of now. Acknowledged hosts aren't alerted, nor are domains
+// whose expiration the registry withholds.
+func escalate(wl Watchlist, expirations []Expiration, now time.Time) []Alert {
+	if len(wl.Escalation) == 0 {
+		return nil
+	}
+	var rv []Alert
+	add := func(exp Expiration, check string, expires time.Time, err error) {
+		alert := Alert{
+			Watchlist: wl.Name,
+			Host:      exp.Name,
+			Check:     check,
+		}
+		if err != nil {
+			alert.Error = err.Error()
+			alert.Stage = firstEscalationStage(wl.Escalation)
+			rv = append(rv, alert)
+			return
+		}
+		if expires.IsZero() {
+			return // doesn't expire, e.g. a PGP key without an expiration
+		}
+		alert.Expires = expires
+		alert.DaysRemaining = daysUntil(now, expires)
+		stage, ok := escalationStage(wl.Escalation, alert.DaysRemaining)
+		if !ok {
+			return
+		}
+		alert.Stage = stage
+		rv = append(rv, alert)
+	}
+
+	for _, exp := range expirations {
+		if exp.Acknowledgement != nil {
+			continue
+		}
+		add(exp, CheckCertificate, exp.CertificateExpires, exp.CertificateError)
+		if exp.Domain == "" && exp.DomainError == nil {
+			continue // targets that aren't TLS hosts don't have a domain
+		}
+		if expire.IsExpiryWithheld(exp.DomainError) {
+			continue
+		}
+		add(exp, CheckDomain, exp.DomainExpires, exp.DomainError)
+	}
+	return rv
+}
+
+// changeAlerts returns the alerts for the changes recorded in the history
+// of expirations after since, up to and including until.
+func changeAlerts(wl Watchlist, expirations []Expiration, since, until time.Time) []Alert {
+	var rv []Alert
+	for _, exp := range expirations {
+		for _, change := range historyChanges(checkHistory.Entries(exp.Name)) {
+			if !change.Time.After(since) || change.Time.After(until) {
+				continue
+			}
+			change := change
+			rv = append(rv, Alert{
+				Watchlist:     wl.Name,
+				Host:          exp.Name,
+				Check:         change.Check,
+				Expires:       change.Expires,
+				DaysRemaining: daysUntil(until, change.Expires),
+				Stage:         firstEscalationStage(wl.Escalation),
+				Change:        &change,
+			})
+		}
+	}
+	return rv
+}
+
+// alertState is what was last sent for a check, so that each threshold
+// crossing is only notified once.
+type alertState struct {
+	Stage   int       `json:"stage"`
+	Expires time.Time `json:"expires,omitempty"`
+	Failed  bool      `json:"failed,omitempty"`
+}
+
+func newAlertState(alert Alert) alertState {
+	return alertState{Stage: alert.Stage, Expires: alert.Expires, Failed: alert.Error != ""}
+}
+
+// same returns true if alert says nothing that state doesn't.
+func (state alertState) same(alert Alert) bool {
+	if state.Stage != alert.Stage || state.Failed != (alert.Error != "") {
+		return false
+	}
+	return state.Failed || state.Expires.Equal(alert.Expires)
+}
+
+// planAlerts returns which of alerts, the current alerts for wl, need to
+// be sent given the state of earlier alerts in prev: those that are new,
+// or whose check has reached another stage or expiration. It also returns
+// resolutions for the alerts in prev that no longer apply, and the new
+// state. Alerts for hosts that are now acknowledged, or no longer in wl,
+// are forgotten without a resolution.
+func planAlerts(wl Watchlist, prev map[string]alertState, alerts []Alert, expirations []Expiration, now time.Time) ([]Alert, map[string]alertState) {
+	var send []Alert
+	next := map[string]alertState{}
+	for _, alert := range alerts {
+		key := alert.Key()
+		next[key] = newAlertState(alert)
+		if state, ok := prev[key]; ok && state.same(alert) {
+			continue
+		}
+		send = append(send, alert)
+	}
+
+	// the checks that no longer have an alert, and what they found
+	resolved := map[string]Alert{}
+	for _, exp := range expirations {
+		if exp.Acknowledgement != nil {
+			continue
+		}
+		for _, alert := range []Alert{
+			{Watchlist: wl.Name, Host: exp.Name, Check: CheckCertificate, Expires: exp.CertificateExpires},
+			{Watchlist: wl.Name, Host: exp.Name, Check: CheckDomain, Expires: exp.DomainExpires},
+		} {
+			if _, ok := next[alert.Key()]; ok {
+				continue
+			}
+			if !alert.Expires.IsZero() {
+				alert.DaysRemaining = daysUntil(now, alert.Expires)
+			}
+			alert.Resolved = true
+			resolved[alert.Key()] = alert
+		}
+	}
+	keys := make([]string, 0, len(prev))
+	for key := range prev {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		alert, ok := resolved[key]
+		if !ok {
+			continue
+		}
+		alert.Stage = prev[key].Stage
+		send = append(send, alert)
+	}
+	return send, next
+}
+
+const alertStateKeyPrefix = "alerts/"
+
+// loadAlertState returns the state of the alerts last sent for the
+// watchlist named name.
+func (s *Server) loadAlertState(name string) (map[string]alertState, error) {
+	rv := map[string]alertState{}
+	buf, ok, err := s.store.Get(alertStateKeyPrefix + name)
+	if err != nil || !ok {
+		return rv, err
+	}
+	if err := json.Unmarshal(buf, &rv); err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+func (s *Server) saveAlertState(name string, state map[string]alertState) error {
+	buf, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return s.store.Put(alertStateKeyPrefix+name, buf)
+}
+
+const changesSentKeyPrefix = "changes/"
+
+// loadChangesSent returns when the changes for the watchlist named name
+// were last sent. The second return value is false if they never were.
+func (s *Server) loadChangesSent(name string) (time.Time, bool, error) {
+	var rv time.Time
+	buf, ok, err := s.store.Get(changesSentKeyPrefix + name)
+	if err != nil || !ok {
+		return rv, false, err
+	}
+	if err := rv.UnmarshalText(buf); err != nil {
+		return rv, false, err
+	}
+	return rv, true, nil
+}
+
+func (s *Server) saveChangesSent(name string, t time.Time) error {
+	buf, err := t.MarshalText()
+	if err != nil {
+		return err
+	}
+	return s.store.Put(changesSentKeyPrefix+name, buf)
+}
+
+// checkEscalation returns an error if the escalation policy of wl refers
+// to a notifier that doesn't exist.
+func checkEscalation(wl Watchlist, notifiers map[string]Notifier) error {
+	for _, stage := range wl.Escalation {
+		if len(stage.Notify) == 0 {
+			return fmt.Errorf("watchlist %s: escalation stage at %d days has no notifiers", wl.Name, stage.Days)
+		}
+		for _, name := range stage.Notify {
+			if _, ok := notifiers[name]; !ok {
+				return fmt.Errorf("watchlist %s: no such notifier %q", wl.Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+// sendAlerts checks the watchlist named name and alerts the notifiers of
+// the escalation stage that each expiration has reached, once per stage,
+// and again when the alert is resolved. With NotifyChanges, the changes
+// seen since the last time are sent too. The checks run, and are recorded
+// in the history, even during a maintenance window, but nobody is
+// alerted, and the changes seen during it are never sent.
+func (s *Server) sendAlerts(ctx context.Context, name string) error {
+	wl := s.watchlist(name)
+	if wl == nil {
+		return fmt.Errorf("no such watchlist %q", name)
+	}
+	now := time.Now()
+	expirations := getExpirations(ctx, wl.Hostnames(), s.defaultCheckOptions())
+	checked := time.Now()
+	s.applyAcknowledgements(expirations, now)
+	if wl.InMaintenance(now) {
+		log.Printf("watchlist %s: in a maintenance window, not sending alerts", wl.Name)
+		if wl.NotifyChanges {
+			if err := s.saveChangesSent(wl.Name, checked); err != nil {
+				return fmt.Errorf("cannot save changes sent: %s", err)
+			}
+		}
+		return nil
+	}
+
+	prev, err := s.loadAlertState(wl.Name)
+	if err != nil {
+		return fmt.Errorf("cannot load alert state: %s", err)
+	}
+	alerts, next := planAlerts(*wl, prev, escalate(*wl, expirations, now), expirations, now)
+	if wl.NotifyChanges {
+		// changes from before the first time are old news
+		since, ok, err := s.loadChangesSent(wl.Name)
+		if err != nil {
+			return fmt.Errorf("cannot load changes sent: %s", err)
+		}
+		if !ok {
+			since = now
+		}
+		alerts = append(alerts, changeAlerts(*wl, expirations, since, checked)...)
+	}
+
+	byNotifier := map[string][]Alert{}
+	for _, alert := range alerts {
+		for _, notifier := range wl.Escalation[alert.Stage].Notify {
+			byNotifier[notifier] = append(byNotifier[notifier], alert)
+		}
+	}
+	names := make([]string, 0, len(byNotifier))
+	for notifier := range byNotifier {
+		names = append(names, notifier)
+	}
+	sort.Strings(names)
+
+	var failed []string
+	for _, notifier := range names {
+		err := s.notifiers[notifier].Notify(ctx, byNotifier[notifier])
+		if err == nil {
+			continue
+		}
+		failed = append(failed, fmt.Sprintf("%s: %s", notifier, err))
+
+		// try again next time
+		for _, alert := range byNotifier[notifier] {
+			if alert.Change != nil {
+				continue // changes aren't in the state
+			}
+			if state, ok := prev[alert.Key()]; ok {
+				next[alert.Key()] = state
+			} else {
+				delete(next, alert.Key())
+			}
+		}
+	}
+	if err := s.saveAlertState(wl.Name, next); err != nil {
+		return fmt.Errorf("cannot save alert state: %s", err)
+	}
+	if wl.NotifyChanges && len(failed) == 0 {
+		if err := s.saveChangesSent(wl.Name, checked); err != nil {
+			return fmt.Errorf("cannot save changes sent: %s", err)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("cannot notify %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// runAlerts sends the alerts for wl every interval, forever.
+func (s *Server) runAlerts(wl Watchlist) {
+	interval := wl.AlertInterval
+	if interval == 0 {
+		interval = defaultAlertInterval
+	}
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		if err := s.sendAlerts(ctx, wl.Name); err != nil {
+			log.Printf("watchlist %s: %s", wl.Name, err)
+		}
+		cancel()
+		time.Sleep(interval)
+	}
+}
+
+// StartAlerting starts checking each watchlist with an escalation policy
+// on a schedule.
+func (s *Server) StartAlerting() {
+	for _, wl := range s.Config.Watchlists {
+		if len(wl.Escalation) > 0 {
+			go s.runAlerts(wl)
+		}
+	}
+}
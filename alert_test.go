@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+func TestEscalationStage(t *testing.T) {
+	stages := []EscalationStage{{Days: 7}, {Days: 30}, {Days: 2}, {Days: 14}}
+	for days, want := range map[int]int{-1: 2, 0: 2, 2: 2, 3: 0, 7: 0, 10: 3, 14: 3, 30: 1} {
+		got, ok := escalationStage(stages, days)
+		if !ok || got != want {
+			t.Errorf("%d days: expected stage %d, got %d, %v", days, want, got, ok)
+		}
+	}
+	if _, ok := escalationStage(stages, 31); ok {
+		t.Errorf("31 days: expected no stage")
+	}
+	if got := firstEscalationStage(stages); got != 1 {
+		t.Errorf("expected the first stage to be 1, got %d", got)
+	}
+}
+
+func TestEscalate(t *testing.T) {
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	days := func(n int) time.Time { return now.Add(time.Duration(n) * 24 * time.Hour) }
+	wl := Watchlist{
+		Name:       "prod",
+		Escalation: []EscalationStage{{Days: 30, Notify: []string{"slack"}}, {Days: 7, Notify: []string{"pager"}}},
+	}
+	expirations := []Expiration{
+		{Name: "a.example.com", CertificateExpires: days(20), Domain: "example.com", DomainExpires: days(365)},
+		{Name: "b.example.com", CertificateExpires: days(5), Domain: "example.com", DomainExpires: days(3)},
+		{Name: "c.example.com", CertificateError: fmt.Errorf("dial failed"), Domain: "example.com", DomainError: expire.ExpiryWithheldError{}},
+		{Name: "d.example.com", CertificateExpires: days(1), Acknowledgement: &Acknowledgement{}},
+		{Name: "pgp:alice@example.com"},
+	}
+
+	var got []string
+	for _, alert := range escalate(wl, expirations, now) {
+		got = append(got, fmt.Sprintf("%s %s %d %s", alert.Host, alert.Check, alert.Stage, alert))
+	}
+	want := []string{
+		"a.example.com certificate 0 a.example.com: certificate expires in 20 days, on 2019-01-21",
+		"b.example.com certificate 1 b.example.com: certificate expires in 5 days, on 2019-01-06",
+		"b.example.com domain 1 b.example.com: domain expires in 3 days, on 2019-01-04",
+		"c.example.com certificate 0 c.example.com: cannot check certificate: dial failed",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	if alerts := escalate(Watchlist{}, expirations, now); alerts != nil {
+		t.Errorf("expected no alerts without a policy, got %+v", alerts)
+	}
+}
+
+func TestChangeAlerts(t *testing.T) {
+	defer func(h History) { checkHistory = h }(checkHistory)
+	checkHistory = newMemoryHistory()
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	exp := Expiration{Name: "example.com", CertificateExpires: now.AddDate(0, 0, 5)}
+	checkHistory.Record(exp, now)
+	exp.CertificateExpires = now.AddDate(0, 3, 0)
+	checkHistory.Record(exp, now.Add(time.Hour))
+
+	wl := Watchlist{Name: "prod", Escalation: []EscalationStage{{Days: 7}, {Days: 30}}}
+	alerts := changeAlerts(wl, []Expiration{exp}, now, now.Add(time.Hour))
+	if len(alerts) != 1 || alerts[0].Stage != 1 || alerts[0].Change == nil || alerts[0].Change.Kind != ChangeRenewed {
+		t.Fatalf("expected a renewal alert, got %+v", alerts)
+	}
+	if got, want := alerts[0].String(), "example.com: certificate renewed, expired 2020-01-06, now expires 2020-04-01"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	// changes that were already sent aren't sent again
+	if alerts := changeAlerts(wl, []Expiration{exp}, now.Add(time.Hour), now.Add(2*time.Hour)); len(alerts) != 0 {
+		t.Errorf("expected no alerts, got %+v", alerts)
+	}
+}
+
+func TestCheckEscalation(t *testing.T) {
+	notifiers := map[string]Notifier{"slack": slackNotifier{}}
+	if err := checkEscalation(Watchlist{Escalation: []EscalationStage{{Days: 30, Notify: []string{"slack"}}}}, notifiers); err != nil {
+		t.Error(err)
+	}
+	if err := checkEscalation(Watchlist{Escalation: []EscalationStage{{Days: 30, Notify: []string{"email"}}}}, notifiers); err == nil {
+		t.Error("expected an error for an unknown notifier")
+	}
+	if err := checkEscalation(Watchlist{Escalation: []EscalationStage{{Days: 30}}}, notifiers); err == nil {
+		t.Error("expected an error for a stage without notifiers")
+	}
+}
+
+func TestPlanAlerts(t *testing.T) {
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	days := func(n int) time.Time { return now.Add(time.Duration(n) * 24 * time.Hour) }
+	wl := Watchlist{
+		Name:       "prod",
+		Escalation: []EscalationStage{{Days: 30, Notify: []string{"slack"}}, {Days: 7, Notify: []string{"pager"}}},
+	}
+
+	var state map[string]alertState
+	run := func(expirations []Expiration) []string {
+		var alerts []Alert
+		alerts, state = planAlerts(wl, state, escalate(wl, expirations, now), expirations, now)
+
+		// as if loaded from the store
+		buf, _ := json.Marshal(state)
+		state = map[string]alertState{}
+		json.Unmarshal(buf, &state)
+
+		var rv []string
+		for _, alert := range alerts {
+			rv = append(rv, fmt.Sprintf("%d %s", alert.Stage, alert))
+		}
+		return rv
+	}
+	check := func(step string, got []string, want ...string) {
+		t.Helper()
+		if len(got) != len(want) || (len(got) > 0 && !reflect.DeepEqual(got, want)) {
+			t.Errorf("%s: expected %q, got %q", step, want, got)
+		}
+	}
+
+	host := Expiration{Name: "a.example.com", CertificateExpires: days(20), Domain: "example.com", DomainExpires: days(365)}
+	check("first", run([]Expiration{host}),
+		"0 a.example.com: certificate expires in 20 days, on 2019-01-21")
+	check("again", run([]Expiration{host}))
+
+	host.CertificateExpires = days(6)
+	check("escalated", run([]Expiration{host}),
+		"1 a.example.com: certificate expires in 6 days, on 2019-01-07")
+	check("escalated again", run([]Expiration{host}))
+
+	failed := host
+	failed.CertificateError = fmt.Errorf("dial failed")
+	check("failed", run([]Expiration{failed}),
+		"0 a.example.com: cannot check certificate: dial failed")
+	failed.CertificateError = fmt.Errorf("timeout")
+	check("still failing", run([]Expiration{failed}))
+
+	host.CertificateExpires = days(90)
+	check("renewed", run([]Expiration{host}),
+		"0 a.example.com: certificate resolved, now expires in 90 days, on 2019-04-01")
+	check("renewed again", run([]Expiration{host}))
+
+	host.CertificateExpires = days(3)
+	check("expiring", run([]Expiration{host}),
+		"1 a.example.com: certificate expires in 3 days, on 2019-01-04")
+	acknowledged := host
+	acknowledged.Acknowledgement = &Acknowledgement{}
+	check("acknowledged", run([]Expiration{acknowledged}))
+	check("removed", run(nil))
+	if len(state) != 0 {
+		t.Errorf("expected no state, got %v", state)
+	}
+}
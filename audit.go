@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxAuditEntries is how many audit entries are kept in memory and served
+// by /audit.
+const maxAuditEntries = 1000
+
+// AuditConfig controls where the audit log is kept. The audit log is only
+// recorded when API keys are configured.
+type AuditConfig struct {
+	// Path is a file that each entry is appended to as a line of JSON, so
+	// that the log outlives the server. Otherwise only the most recent
+	// entries are kept, in memory.
+	Path string `yaml:"path"`
+}
+
+// AuditEntry records a request made to the server: who made it, what it
+// was for, and how it turned out.
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	Key    string    `json:"key,omitempty"` // the name of the API key used, if any
+	Client string    `json:"client"`
+	Method string    `json:"method"`
+	URL    string    `json:"url"`
+	Status int       `json:"status"`
+}
+
+// auditLog keeps the most recent audit entries, and writes every entry to
+// w if it is not nil.
+type auditLog struct {
+	mu      sync.Mutex
+	entries []AuditEntry
+	w       io.Writer
+}
+
+// openAuditLog returns the audit log described by config.
+func openAuditLog(config AuditConfig) (*auditLog, error) {
+	l := &auditLog{}
+	if config.Path != "" {
+		f, err := os.OpenFile(config.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, err
+		}
+		l.w = f
+	}
+	return l, nil
+}
+
+// Record adds entry to the log.
+func (l *auditLog) Record(entry AuditEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+	if len(l.entries) > maxAuditEntries {
+		l.entries = l.entries[len(l.entries)-maxAuditEntries:]
+	}
+	if l.w != nil {
+		if err := json.NewEncoder(l.w).Encode(entry); err != nil {
+			log.Printf("cannot write audit log: %s", err)
+		}
+	}
+}
+
+// Entries returns the entries in the log, oldest first.
+func (l *auditLog) Entries() []AuditEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]AuditEntry{}, l.entries...)
+}
+
+// statusRecorder remembers the status code written to a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Flush sends any buffered data to the client, if the underlying
+// ResponseWriter can, so that streamed responses aren't held up.
+func (w *statusRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// recordAudit records r, which was answered with status, in the audit log.
+func (s *Server) recordAudit(r *http.Request, status int) {
+	entry := AuditEntry{
+		Time:   time.Now(),
+		Client: clientAddress(r),
+		Method: r.Method,
+		URL:    redactKey(r.URL),
+		Status: status,
+	}
+	if key := s.apiKey(r); key != nil {
+		entry.Key = key.Name
+	}
+	s.audit.Record(entry)
+}
+
+// serveAuditLog responds with the recent entries in the audit log. Only
+// admin keys may read it.
+func (s *Server) serveAuditLog(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r, "/audit") {
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Entries []AuditEntry `json:"entries"`
+	}{
+		Entries: s.audit.Entries(),
+	})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	s := NewServer(&Config{
+		Watchlists: []Watchlist{{Name: "prod"}},
+		APIKeys: []APIKey{
+			{Name: "web", Key: "web-key"},
+			{Name: "ops", Key: "ops-key", Admin: true},
+		},
+	})
+
+	r := httptest.NewRequest("GET", "/status/prod.json", nil)
+	r.Header.Set("Authorization", "Bearer web-key")
+	s.ServeHTTP(httptest.NewRecorder(), r)
+
+	r = httptest.NewRequest("GET", "/audit", nil)
+	r.Header.Set("Authorization", "Bearer web-key")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a key that isn't an admin, got %d", w.Code)
+	}
+
+	r = httptest.NewRequest("GET", "/audit", nil)
+	r.Header.Set("Authorization", "Bearer ops-key")
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		Entries []AuditEntry `json:"entries"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %#v", response.Entries)
+	}
+	entry := response.Entries[0]
+	if entry.Key != "web" || entry.URL != "/status/prod.json" || entry.Status != http.StatusOK {
+		t.Errorf("unexpected entry %#v", entry)
+	}
+	if entry := response.Entries[1]; entry.Key != "web" || entry.Status != http.StatusForbidden {
+		t.Errorf("unexpected entry %#v", entry)
+	}
+
+	s = NewServer(&Config{})
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/audit", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without API keys, got %d", w.Code)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// APIKey identifies a client of the server. When any keys are configured,
+// requests made with one are attributed to its name, e.g. in the audit
+// log.
+type APIKey struct {
+	Name string `yaml:"name"` // who the key belongs to, e.g. a team
+	Key  string `yaml:"key"`
+
+	// Admin keys may also read the audit log and usage statistics.
+	Admin bool `yaml:"admin"`
+
+	// HourlyQuota and DailyQuota are how many hosts may be checked with
+	// the key each hour and each day (default: unlimited).
+	HourlyQuota int `yaml:"hourlyQuota"`
+	DailyQuota  int `yaml:"dailyQuota"`
+}
+
+// authEnabled returns true if the server has API keys configured.
+func (s *Server) authEnabled() bool {
+	return len(s.Config.APIKeys) > 0
+}
+
+// envAPIKeys parses API keys given in the environment, as a comma
+// separated list of name:key pairs.
+func envAPIKeys(s string) ([]APIKey, error) {
+	var rv []APIKey
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		i := strings.Index(pair, ":")
+		if i <= 0 || i == len(pair)-1 {
+			return nil, fmt.Errorf("expected name:key pairs separated by commas")
+		}
+		rv = append(rv, APIKey{Name: pair[:i], Key: pair[i+1:]})
+	}
+	return rv, nil
+}
+
+// apiKey returns the configured key that r was made with, as a bearer
+// token or the key parameter, or nil if there isn't one. The parameter is
+// for clients that can't set headers, like calendar programs.
+func (s *Server) apiKey(r *http.Request) *APIKey {
+	given := r.URL.Query().Get("key")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		given = strings.TrimPrefix(auth, "Bearer ")
+	}
+	if given == "" {
+		return nil
+	}
+	for i := range s.Config.APIKeys {
+		key := &s.Config.APIKeys[i]
+		if key.Key != "" && subtle.ConstantTimeCompare([]byte(given), []byte(key.Key)) == 1 {
+			return key
+		}
+	}
+	return nil
+}
+
+// authorizeAdmin returns true if r was made with an admin API key.
+// Otherwise it responds with an error saying that what, e.g. "/audit",
+// requires one, and returns false. Without API keys there are no
+// admins, so the resource doesn't exist.
+func (s *Server) authorizeAdmin(w http.ResponseWriter, r *http.Request, what string) bool {
+	if !s.authEnabled() {
+		http.NotFound(w, r)
+		return false
+	}
+	key := s.apiKey(r)
+	if key == nil {
+		http.Error(w, what+" requires an API key", http.StatusUnauthorized)
+		return false
+	}
+	if !key.Admin {
+		http.Error(w, what+" requires an admin API key", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// redactKey returns the request URI of u without the key parameter, or the
+// value of the debug parameter, which may be the debug token, so that
+// secrets aren't written to logs.
+func redactKey(u *url.URL) string {
+	query := u.Query()
+	_, hasKey := query["key"]
+	hasToken := query.Get("debug") != ""
+	if !hasKey && !hasToken {
+		return u.RequestURI()
+	}
+	if hasKey {
+		query.Set("key", "REDACTED")
+	}
+	if hasToken {
+		query.Set("debug", "REDACTED")
+	}
+	redacted := *u
+	redacted.RawQuery = query.Encode()
+	return redacted.RequestURI()
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestEnvAPIKeys(t *testing.T) {
+	keys, err := envAPIKeys("ci:abc, ops:d:e,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] != (APIKey{Name: "ci", Key: "abc"}) || keys[1] != (APIKey{Name: "ops", Key: "d:e"}) {
+		t.Errorf("unexpected keys %+v", keys)
+	}
+	if keys, err := envAPIKeys(""); err != nil || len(keys) != 0 {
+		t.Errorf("expected no keys, got %+v %v", keys, err)
+	}
+	for _, s := range []string{"abc", ":abc", "ci:"} {
+		if _, err := envAPIKeys(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+func TestRequireAPIKey(t *testing.T) {
+	now := time.Now()
+	s := NewServer(&Config{
+		APIKeys:       []APIKey{{Name: "ci", Key: "ci-key"}},
+		RequireAPIKey: true,
+	})
+	opts := s.defaultCheckOptions()
+	results.Set(opts.cacheKey("auth.example.com"), Expiration{
+		Name:               "auth.example.com",
+		CertificateExpires: now.AddDate(1, 0, 0),
+		Details:            &Details{},
+	}, now)
+
+	for _, tt := range []struct {
+		url, auth string
+		code      int
+	}{
+		{"/json/auth.example.com", "", http.StatusUnauthorized},
+		{"/json/auth.example.com", "Bearer nope", http.StatusUnauthorized},
+		{"/json/auth.example.com", "Bearer ci-key", http.StatusOK},
+		{"/json/auth.example.com?key=ci-key", "", http.StatusOK},
+		{"/", "", http.StatusOK},
+	} {
+		r := httptest.NewRequest("GET", tt.url, nil)
+		if tt.auth != "" {
+			r.Header.Set("Authorization", tt.auth)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != tt.code {
+			t.Errorf("%s %q: expected %d, got %d", tt.url, tt.auth, tt.code, w.Code)
+		}
+	}
+	for _, entry := range s.audit.Entries() {
+		if entry.URL == "/json/auth.example.com?key=ci-key" {
+			t.Errorf("expected the key to be redacted from the audit log")
+		}
+	}
+}
+
+func TestRedactKey(t *testing.T) {
+	for in, want := range map[string]string{
+		"/json/example.com":                  "/json/example.com",
+		"/json/example.com?key=secret&quiet": "/json/example.com?key=REDACTED&quiet=",
+		"/json/example.com?debug":            "/json/example.com?debug",
+		"/json/example.com?debug=token":      "/json/example.com?debug=REDACTED",
+	} {
+		u, _ := url.Parse(in)
+		if got := redactKey(u); got != want {
+			t.Errorf("%s: got %s, want %s", in, got, want)
+		}
+	}
+}
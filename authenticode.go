@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"debug/pe"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/golang/gddo/httputil"
+)
+
+// maxSignedFileSize is the largest file that may be uploaded to
+// /authenticode
+const maxSignedFileSize = 100 * 1024 * 1024
+
+var (
+	oidSignedData       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidCounterSignature = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 6}
+	oidRFC3161Timestamp = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 3, 3, 1}
+)
+
+// The PKCS#7 structures we need, from RFC 2315
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type signedData struct {
+	Version                    int
+	DigestAlgorithmIdentifiers []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo                asn1.RawValue
+	Certificates               asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs                       asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos                []signerInfo  `asn1:"set"`
+}
+
+type issuerAndSerial struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type attribute struct {
+	Type  asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+type signerInfo struct {
+	Version                   int
+	IssuerAndSerialNumber     issuerAndSerial
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   []attribute `asn1:"optional,tag:0"`
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+	UnauthenticatedAttributes []attribute `asn1:"optional,tag:1"`
+}
+
+// CertificateInfo describes a certificate found in a signature.
+type CertificateInfo struct {
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	NotBefore time.Time `json:"notBefore"`
+	NotAfter  time.Time `json:"notAfter"`
+}
+
+func newCertificateInfo(cert *x509.Certificate) *CertificateInfo {
+	if cert == nil {
+		return nil
+	}
+	return &CertificateInfo{
+		Subject:   cert.Subject.String(),
+		Issuer:    cert.Issuer.String(),
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+	}
+}
+
+// SignatureReport describes an Authenticode signature.
+type SignatureReport struct {
+	Signer       *CertificateInfo  `json:"signer"`
+	Timestamper  *CertificateInfo  `json:"timestamper,omitempty"`
+	Certificates []CertificateInfo `json:"certificates"`
+}
+
+// parseSignature parses a DER encoded PKCS#7 signature and reports on the
+// signing certificate and, if the signature is timestamped, the
+// certificate that signed the timestamp.
+func parseSignature(der []byte) (*SignatureReport, error) {
+	sd, certs, err := parseSignedData(der)
+	if err != nil {
+		return nil, err
+	}
+	if len(sd.SignerInfos) == 0 {
+		return nil, fmt.Errorf("signature has no signers")
+	}
+
+	rv := &SignatureReport{}
+	for _, cert := range certs {
+		rv.Certificates = append(rv.Certificates, *newCertificateInfo(cert))
+	}
+
+	signer := sd.SignerInfos[0]
+	rv.Signer = newCertificateInfo(findCertificate(certs, signer.IssuerAndSerialNumber))
+	if rv.Signer == nil {
+		return nil, fmt.Errorf("signing certificate is not included in the signature")
+	}
+
+	for _, attr := range signer.UnauthenticatedAttributes {
+		switch {
+		case attr.Type.Equal(oidCounterSignature):
+			// a legacy timestamp, signed by a certificate included with the
+			// others in the outer signature
+			var counterSigner signerInfo
+			if _, err := asn1.Unmarshal(attr.Value.Bytes, &counterSigner); err != nil {
+				return nil, fmt.Errorf("cannot parse countersignature: %s", err)
+			}
+			rv.Timestamper = newCertificateInfo(findCertificate(certs, counterSigner.IssuerAndSerialNumber))
+
+		case attr.Type.Equal(oidRFC3161Timestamp):
+			// an RFC 3161 timestamp token, which is itself a signature
+			tsData, tsCerts, err := parseSignedData(attr.Value.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse timestamp: %s", err)
+			}
+			if len(tsData.SignerInfos) > 0 {
+				rv.Timestamper = newCertificateInfo(findCertificate(tsCerts, tsData.SignerInfos[0].IssuerAndSerialNumber))
+			}
+			for _, cert := range tsCerts {
+				rv.Certificates = append(rv.Certificates, *newCertificateInfo(cert))
+			}
+		}
+	}
+	return rv, nil
+}
+
+func parseSignedData(der []byte) (*signedData, []*x509.Certificate, error) {
+	ci := contentInfo{}
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, nil, err
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, nil, fmt.Errorf("expected signed data, got content type %s", ci.ContentType)
+	}
+	sd := &signedData{}
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, sd); err != nil {
+		return nil, nil, err
+	}
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sd, certs, nil
+}
+
+func findCertificate(certs []*x509.Certificate, id issuerAndSerial) *x509.Certificate {
+	for _, cert := range certs {
+		if bytes.Equal(cert.RawIssuer, id.Issuer.FullBytes) && cert.SerialNumber.Cmp(id.SerialNumber) == 0 {
+			return cert
+		}
+	}
+	return nil
+}
+
+// winCertificateTypePKCSSignedData is the WIN_CERTIFICATE type that holds
+// an Authenticode signature.
+const winCertificateTypePKCSSignedData = 2
+
+// peSignature returns the Authenticode signature from the certificate
+// table of a PE file.
+func peSignature(buf []byte) ([]byte, error) {
+	f, err := pe.NewFile(bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+
+	var dir pe.DataDirectory
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		dir = oh.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_SECURITY]
+	case *pe.OptionalHeader64:
+		dir = oh.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_SECURITY]
+	default:
+		return nil, fmt.Errorf("PE file has no optional header")
+	}
+	if dir.Size == 0 {
+		return nil, fmt.Errorf("file is not signed")
+	}
+
+	// the certificate table is addressed by file offset, not by RVA
+	start, end := uint64(dir.VirtualAddress), uint64(dir.VirtualAddress)+uint64(dir.Size)
+	if end > uint64(len(buf)) {
+		return nil, fmt.Errorf("certificate table extends past the end of the file")
+	}
+	table := buf[start:end]
+	for len(table) >= 8 {
+		length := binary.LittleEndian.Uint32(table[0:4])
+		certType := binary.LittleEndian.Uint16(table[6:8])
+		if length < 8 || uint64(length) > uint64(len(table)) {
+			break
+		}
+		if certType == winCertificateTypePKCSSignedData {
+			return table[8:length], nil
+		}
+		// entries are aligned to 8 bytes
+		next := (uint64(length) + 7) &^ 7
+		if next > uint64(len(table)) {
+			break
+		}
+		table = table[next:]
+	}
+	return nil, fmt.Errorf("certificate table does not contain an Authenticode signature")
+}
+
+// scanSignature looks for a DER encoded PKCS#7 ContentInfo anywhere in
+// buf. This is how we find the signature in MSI files, which store it in
+// the \x05DigitalSignature stream of an OLE compound file. (This doesn't
+// handle streams that are fragmented within the compound file, but in
+// practice the signature stream is written contiguously.)
+func scanSignature(buf []byte) ([]byte, error) {
+	oid, _ := asn1.Marshal(oidSignedData)
+	for i := bytes.Index(buf, oid); i >= 0; {
+		// the OID is preceded by a SEQUENCE header with a two byte length
+		if start := i - 4; start >= 0 && buf[start] == 0x30 && buf[start+1] == 0x82 {
+			var raw asn1.RawValue
+			if _, err := asn1.Unmarshal(buf[start:], &raw); err == nil {
+				return raw.FullBytes, nil
+			}
+		}
+		next := bytes.Index(buf[i+1:], oid)
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return nil, fmt.Errorf("no Authenticode signature found")
+}
+
+// checkSignedFile reports on the Authenticode signature of a signed PE
+// (.exe, .dll, .sys) or MSI file.
+func checkSignedFile(buf []byte) (*SignatureReport, error) {
+	der, err := peSignature(buf)
+	if err != nil {
+		var scanErr error
+		if der, scanErr = scanSignature(buf); scanErr != nil {
+			return nil, err
+		}
+	}
+	return parseSignature(der)
+}
+
+func writeSignatureReport(w io.Writer, name string, report *SignatureReport) {
+	fmt.Fprintf(w, "%s\tsigner\t%s\t%s\n", name, report.Signer.NotAfter, report.Signer.Subject)
+	if report.Timestamper != nil {
+		fmt.Fprintf(w, "%s\ttimestamp\t%s\t%s\n", name, report.Timestamper.NotAfter, report.Timestamper.Subject)
+	}
+}
+
+func (s *Server) serveAuthenticode(w http.ResponseWriter, r *http.Request) {
+	buf, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxSignedFileSize))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, "Cannot read file:", err.Error())
+		return
+	}
+
+	report, err := checkSignedFile(buf)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, "Cannot check signature:", err.Error())
+		return
+	}
+
+	contentType := httputil.NegotiateContentType(r, []string{
+		"application/json",
+		"text/plain",
+	}, "text/plain")
+	if contentType == "application/json" {
+		w.Header().Add("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+	w.Header().Add("Content-Type", "text/plain")
+	writeSignatureReport(w, "-", report)
+}
+
+// authenticodeCommand implements the authenticode subcommand and returns
+// the exit code.
+func authenticodeCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "usage: %s authenticode FILE ...\n", os.Args[0])
+		return 2
+	}
+
+	rv := 0
+	for _, path := range args {
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			rv = 1
+			continue
+		}
+		report, err := checkSignedFile(buf)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+			rv = 1
+			continue
+		}
+		writeSignatureReport(os.Stdout, path, report)
+	}
+	return rv
+}
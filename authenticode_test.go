@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func testCertificate(t *testing.T, name string, serial int64, notAfter time.Time) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func testSignerInfo(cert *x509.Certificate) signerInfo {
+	return signerInfo{
+		Version: 1,
+		IssuerAndSerialNumber: issuerAndSerial{
+			Issuer:       asn1.RawValue{FullBytes: cert.RawIssuer},
+			SerialNumber: cert.SerialNumber,
+		},
+		DigestAlgorithm:           pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}},
+		DigestEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+		EncryptedDigest:           []byte{1, 2, 3},
+	}
+}
+
+func TestParseSignature(t *testing.T) {
+	signerExpires := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	timestamperExpires := time.Date(2029, 1, 1, 0, 0, 0, 0, time.UTC)
+	signerCert := testCertificate(t, "Example Code Signing", 1, signerExpires)
+	timestamperCert := testCertificate(t, "Example Timestamping", 2, timestamperExpires)
+
+	counterSignature, err := asn1.Marshal(testSignerInfo(timestamperCert))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := testSignerInfo(signerCert)
+	signer.UnauthenticatedAttributes = []attribute{{
+		Type:  oidCounterSignature,
+		Value: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: counterSignature},
+	}}
+
+	sd, err := asn1.Marshal(signedData{
+		Version:                    1,
+		DigestAlgorithmIdentifiers: []pkix.AlgorithmIdentifier{signer.DigestAlgorithm},
+		ContentInfo:                asn1.RawValue{Tag: asn1.TagSequence, IsCompound: true},
+		Certificates: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      append(append([]byte{}, signerCert.Raw...), timestamperCert.Raw...),
+		},
+		SignerInfos: []signerInfo{signer},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sd},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the signature should be found even when surrounded by other data,
+	// which is how it appears in an MSI file
+	found, err := scanSignature(append(append([]byte("garbage"), der...), "more garbage"...))
+	if err != nil {
+		t.Fatalf("scanSignature: %s", err)
+	}
+
+	report, err := parseSignature(found)
+	if err != nil {
+		t.Fatalf("parseSignature: %s", err)
+	}
+	if report.Signer == nil || !report.Signer.NotAfter.Equal(signerExpires) {
+		t.Errorf("expected signer to expire %s, got %#v", signerExpires, report.Signer)
+	}
+	if report.Timestamper == nil || !report.Timestamper.NotAfter.Equal(timestamperExpires) {
+		t.Errorf("expected timestamper to expire %s, got %#v", timestamperExpires, report.Timestamper)
+	}
+	if len(report.Certificates) != 2 {
+		t.Errorf("expected 2 certificates, got %d", len(report.Certificates))
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/crewjam/expire-sh/expire"
+	"github.com/miekg/dns"
+)
+
+// caaIssuers are the names that well known CAs use in their certificates'
+// issuer, by the identifier that CAA records authorize them with. CAs that
+// aren't listed can't be matched with their certificates, so nothing is
+// said about CAA records that authorize them.
+var caaIssuers = map[string][]string{
+	"letsencrypt.org":   {"Let's Encrypt"},
+	"pki.goog":          {"Google Trust Services"},
+	"digicert.com":      {"DigiCert"},
+	"symantec.com":      {"DigiCert", "Symantec"},
+	"geotrust.com":      {"DigiCert", "GeoTrust"},
+	"rapidssl.com":      {"DigiCert", "RapidSSL"},
+	"sectigo.com":       {"Sectigo", "COMODO"},
+	"comodoca.com":      {"Sectigo", "COMODO"},
+	"amazon.com":        {"Amazon"},
+	"amazontrust.com":   {"Amazon"},
+	"awstrust.com":      {"Amazon"},
+	"amazonaws.com":     {"Amazon"},
+	"globalsign.com":    {"GlobalSign"},
+	"godaddy.com":       {"GoDaddy", "Starfield"},
+	"starfieldtech.com": {"Starfield"},
+	"zerossl.com":       {"ZeroSSL"},
+	"buypass.com":       {"Buypass"},
+	"entrust.net":       {"Entrust"},
+	"ssl.com":           {"SSL.com", "SSL Corporation"},
+}
+
+// lookupCAA returns the CAA records that apply to host: those at the
+// closest of host and its parent domains that has any, as described in
+// RFC 8659.
+func lookupCAA(ctx context.Context, host string) ([]*dns.CAA, error) {
+	host, err := expire.ToASCII(host)
+	if err != nil {
+		return nil, err
+	}
+	for name := strings.TrimSuffix(host, "."); strings.Contains(name, "."); name = name[strings.Index(name, ".")+1:] {
+		records, err := lookupDNS(ctx, name, dns.TypeCAA)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			continue
+		}
+		rv := make([]*dns.CAA, len(records))
+		for i, rr := range records {
+			rv[i] = rr.(*dns.CAA)
+		}
+		return rv, nil
+	}
+	return nil, nil
+}
+
+// formatCAA returns records as they would appear in a zone file, e.g.
+// 0 issue "letsencrypt.org".
+func formatCAA(records []*dns.CAA) []string {
+	rv := make([]string, len(records))
+	for i, record := range records {
+		rv[i] = fmt.Sprintf("%d %s %q", record.Flag, record.Tag, record.Value)
+	}
+	return rv
+}
+
+// caaPermits returns false if records authorize CAs, none of which
+// issued a certificate whose issuer is issuer. It returns true if the
+// records don't restrict issuance, or authorize a CA that isn't in
+// caaIssuers, since we can't tell whether that CA issued it. Both issue
+// and issuewild records are considered, because we don't know whether
+// the certificate is a wildcard.
+func caaPermits(records []*dns.CAA, issuer string) bool {
+	restricted := false
+	for _, record := range records {
+		if record.Tag != "issue" && record.Tag != "issuewild" {
+			continue
+		}
+		restricted = true
+		id := strings.ToLower(strings.TrimSpace(strings.SplitN(record.Value, ";", 2)[0]))
+		if id == "" {
+			continue // no CA is authorized
+		}
+		names, ok := caaIssuers[id]
+		if !ok {
+			return true
+		}
+		for _, name := range names {
+			if strings.Contains(strings.ToLower(issuer), strings.ToLower(name)) {
+				return true
+			}
+		}
+	}
+	return !restricted
+}
+
+// checkCAA looks up the CAA records for host and adds them to exp, with a
+// warning if they don't authorize the CA that issued its certificate.
+func checkCAA(ctx context.Context, host string, exp *Expiration) {
+	records, err := lookupCAA(ctx, host)
+	if err != nil {
+		exp.Details.CAAError = err.Error()
+		return
+	}
+	exp.CAA = formatCAA(records)
+	if exp.CertificateError != nil || len(exp.CertificateChain) == 0 {
+		return
+	}
+	if issuer := exp.CertificateChain[0].Issuer; !caaPermits(records, issuer) {
+		exp.Warnings = append(exp.Warnings, fmt.Sprintf("certificate issuer %s is not authorized by the CAA records for %s", issuer, host))
+	}
+}
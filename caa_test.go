@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestCAAPermits(t *testing.T) {
+	caa := func(tag, value string) *dns.CAA {
+		return &dns.CAA{Tag: tag, Value: value}
+	}
+	letsEncrypt := "CN=R3,O=Let's Encrypt,C=US"
+	for _, tt := range []struct {
+		records []*dns.CAA
+		want    bool
+	}{
+		{nil, true},
+		{[]*dns.CAA{caa("iodef", "mailto:security@example.com")}, true},
+		{[]*dns.CAA{caa("issue", "letsencrypt.org")}, true},
+		{[]*dns.CAA{caa("issue", "digicert.com"), caa("issue", "LetsEncrypt.org; validationmethods=dns-01")}, true},
+		{[]*dns.CAA{caa("issue", "digicert.com")}, false},
+		{[]*dns.CAA{caa("issue", ";")}, false},
+		{[]*dns.CAA{caa("issue", "digicert.com"), caa("issuewild", "letsencrypt.org")}, true},
+		{[]*dns.CAA{caa("issue", "digicert.com"), caa("issue", "ca.example.net")}, true}, // unknown CA
+	} {
+		if got := caaPermits(tt.records, letsEncrypt); got != tt.want {
+			t.Errorf("%v: got %v, want %v", formatCAA(tt.records), got, tt.want)
+		}
+	}
+}
+
+func TestFormatCAA(t *testing.T) {
+	got := formatCAA([]*dns.CAA{{Flag: 0, Tag: "issue", Value: "letsencrypt.org"}, {Flag: 128, Tag: "iodef", Value: "mailto:a@example.com"}})
+	want := `[0 issue "letsencrypt.org" 128 iodef "mailto:a@example.com"]`
+	if fmt.Sprint(got) != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+// SharedCache holds check results where all the replicas of a server can
+// see them, so that a host checked by one isn't checked again by the
+// others.
+type SharedCache interface {
+	// Get returns the value for key. The second return value is false if
+	// there is no such key, or it has expired.
+	Get(key string) ([]byte, bool, error)
+
+	// Set sets the value for key until ttl has passed.
+	Set(key string, value []byte, ttl time.Duration) error
+}
+
+// CacheConfig selects where check results are cached.
+type CacheConfig struct {
+	// Type is memory (the default), which caches results in each
+	// replica, or redis, which also shares them between replicas through
+	// the Redis server at Address, e.g. localhost:6379.
+	Type     string `yaml:"type"`
+	Address  string `yaml:"address"`
+	Password string `yaml:"password"`
+	DB       int    `yaml:"db"`
+
+	// Prefix is prepended to the keys in Redis (default: expire-sh:)
+	Prefix string `yaml:"prefix"`
+}
+
+// openSharedCache returns the shared cache described by config, or nil if
+// results aren't shared.
+func openSharedCache(config CacheConfig) (SharedCache, error) {
+	switch config.Type {
+	case "", "memory":
+		return nil, nil
+	case "redis":
+		if config.Address == "" {
+			return nil, fmt.Errorf("a redis cache requires an address")
+		}
+		prefix := config.Prefix
+		if prefix == "" {
+			prefix = "expire-sh:"
+		}
+		return &redisCache{
+			address:  config.Address,
+			password: config.Password,
+			db:       config.DB,
+			prefix:   prefix,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown cache type %q, expected memory or redis", config.Type)
+	}
+}
+
+// Prefixes of the keys in the shared cache
+const (
+	sharedResultPrefix = "result:"
+	sharedDomainPrefix = "domain:"
+)
+
+// sharedError is an error as it is kept in the shared cache, which
+// remembers whether a domain's expiration was withheld, since the checks
+// treat that differently from other failures.
+type sharedError struct {
+	Message  string `json:"message"`
+	Withheld string `json:"withheld,omitempty"` // the domain
+}
+
+func newSharedError(err error) *sharedError {
+	if err == nil {
+		return nil
+	}
+	if withheld, ok := err.(expire.ExpiryWithheldError); ok {
+		return &sharedError{Message: err.Error(), Withheld: withheld.Domain}
+	}
+	return &sharedError{Message: err.Error()}
+}
+
+func (e *sharedError) err() error {
+	switch {
+	case e == nil:
+		return nil
+	case e.Withheld != "":
+		return expire.ExpiryWithheldError{Domain: e.Withheld}
+	default:
+		return errors.New(e.Message)
+	}
+}
+
+// sharedResult is a result in the shared cache. The errors of the
+// Expiration don't survive encoding, so they are kept alongside.
+type sharedResult struct {
+	Expiration       Expiration   `json:"expiration"`
+	CertificateError *sharedError `json:"certificateError,omitempty"`
+	DomainError      *sharedError `json:"domainError,omitempty"`
+	Expires          time.Time    `json:"expires"`
+}
+
+// defaultResultCacheTTL is how long the result of checking a host is
+// reused, unless the configuration says otherwise.
+const defaultResultCacheTTL = 15 * time.Minute
+
+// resultCache holds recent check results by host, so that a calendar
+// refresh, or a request after a prefetch, doesn't repeat the checks.
+type resultCache struct {
+	ttl    time.Duration
+	shared SharedCache // if not nil, results are also kept here
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	exp     Expiration
+	expires time.Time
+}
+
+func newResultCache(ttl time.Duration) *resultCache {
+	return &resultCache{ttl: ttl, entries: map[string]cacheEntry{}}
+}
+
+// results is the cache used by getExpirations, configured at startup.
+var results = newResultCache(defaultResultCacheTTL)
+
+// cacheKey returns the key for the result of checking hostname with opts.
+// Only the options that change the result of checking a single host are
+// included.
+func (opts checkOptions) cacheKey(hostname string) string {
+	return strings.Join([]string{
+		hostname,
+		opts.Protocol,
+		strings.Join(opts.TrustStores, ","),
+		opts.MaxCertificateLifetime.String(),
+		opts.PGPKeyserver,
+		strconv.FormatBool(opts.CAA),
+		strconv.FormatBool(opts.AllIPs),
+		strconv.FormatBool(opts.NoSNI),
+		strconv.FormatBool(opts.TLSPolicy),
+	}, "|")
+}
+
+// Get returns the cached result for key, if there is one that hasn't
+// expired at now. Results that aren't cached here are looked for in the
+// shared cache.
+func (c *resultCache) Get(key string, now time.Time) (Expiration, bool) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if !ok || !now.Before(entry.expires) {
+		if entry, ok = c.getShared(key, now); !ok {
+			return Expiration{}, false
+		}
+		c.mu.Lock()
+		c.entries[key] = entry
+		c.mu.Unlock()
+	}
+	exp := entry.exp
+	if exp.Details != nil {
+		// the caller may modify the details, e.g. to remove the whois record
+		details := *exp.Details
+		exp.Details = &details
+	}
+	return exp, true
+}
+
+// getShared returns the result for key from the shared cache, if there is
+// one that hasn't expired at now. A shared cache that can't be reached is
+// treated as empty.
+func (c *resultCache) getShared(key string, now time.Time) (cacheEntry, bool) {
+	if c.shared == nil {
+		return cacheEntry{}, false
+	}
+	buf, ok, err := c.shared.Get(sharedResultPrefix + key)
+	if err != nil {
+		log.Printf("cannot get cached result: %s", err)
+		return cacheEntry{}, false
+	}
+	if !ok {
+		return cacheEntry{}, false
+	}
+	var result sharedResult
+	if err := json.Unmarshal(buf, &result); err != nil {
+		log.Printf("cannot decode cached result: %s", err)
+		return cacheEntry{}, false
+	}
+	if !now.Before(result.Expires) {
+		return cacheEntry{}, false
+	}
+	exp := result.Expiration
+	exp.CertificateError = result.CertificateError.err()
+	exp.DomainError = result.DomainError.err()
+	return cacheEntry{exp: exp, expires: result.Expires}, true
+}
+
+// Set stores exp as the result for key at now, here and in the shared
+// cache.
+func (c *resultCache) Set(key string, exp Expiration, now time.Time) {
+	if exp.Details != nil {
+		details := *exp.Details
+		exp.Details = &details
+	}
+	entry := cacheEntry{exp: exp, expires: now.Add(c.ttl)}
+
+	c.mu.Lock()
+	for k, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = entry
+	c.mu.Unlock()
+
+	if c.shared == nil {
+		return
+	}
+	result := sharedResult{
+		Expiration:       exp,
+		CertificateError: newSharedError(exp.CertificateError),
+		DomainError:      newSharedError(exp.DomainError),
+		Expires:          entry.expires,
+	}
+	result.Expiration.CertificateError, result.Expiration.DomainError = nil, nil
+	buf, err := json.Marshal(result)
+	if err == nil {
+		err = c.shared.Set(sharedResultPrefix+key, buf, c.ttl)
+	}
+	if err != nil {
+		log.Printf("cannot cache result: %s", err)
+	}
+}
+
+// defaultDomainCacheTTL is how long domain expirations are reused. Whois
+// servers rate limit aggressively, and registrations change rarely.
+const defaultDomainCacheTTL = 12 * time.Hour
+
+// domainCache holds recent domain expiration lookups by domain, so that
+// the many hosts in a domain, and repeated calendar refreshes, don't each
+// query the registry. It outlives resultCache, which also covers the
+// certificates.
+type domainCache struct {
+	ttl    time.Duration
+	shared SharedCache // if not nil, lookups are also kept here
+
+	mu      sync.Mutex
+	entries map[string]domainCacheEntry
+}
+
+type domainCacheEntry struct {
+	result  expire.DomainResult
+	err     error
+	expires time.Time
+}
+
+func newDomainCache(ttl time.Duration) *domainCache {
+	return &domainCache{ttl: ttl, entries: map[string]domainCacheEntry{}}
+}
+
+// domainResults is the cache used by getExpirations, configured at
+// startup.
+var domainResults = newDomainCache(defaultDomainCacheTTL)
+
+// sharedDomainResult is a domain lookup in the shared cache.
+type sharedDomainResult struct {
+	Result  expire.DomainResult `json:"result"`
+	Error   *sharedError        `json:"error,omitempty"`
+	Expires time.Time           `json:"expires"`
+}
+
+// Get returns the cached lookup for domain, if there is one that hasn't
+// expired at now. Lookups that aren't cached here are looked for in the
+// shared cache.
+func (c *domainCache) Get(domain string, now time.Time) (domainCacheEntry, bool) {
+	c.mu.Lock()
+	entry, ok := c.entries[domain]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry, true
+	}
+	if c.shared == nil {
+		return domainCacheEntry{}, false
+	}
+	buf, ok, err := c.shared.Get(sharedDomainPrefix + domain)
+	if err != nil {
+		log.Printf("cannot get cached domain: %s", err)
+		return domainCacheEntry{}, false
+	}
+	if !ok {
+		return domainCacheEntry{}, false
+	}
+	var result sharedDomainResult
+	if err := json.Unmarshal(buf, &result); err != nil {
+		log.Printf("cannot decode cached domain: %s", err)
+		return domainCacheEntry{}, false
+	}
+	if !now.Before(result.Expires) {
+		return domainCacheEntry{}, false
+	}
+	entry = domainCacheEntry{result: result.Result, err: result.Error.err(), expires: result.Expires}
+	c.mu.Lock()
+	c.entries[domain] = entry
+	c.mu.Unlock()
+	return entry, true
+}
+
+// Set stores the lookup for domain at now. Only successful lookups, and
+// those whose expiration is withheld, are stored; other failures are
+// usually transient.
+func (c *domainCache) Set(domain string, result expire.DomainResult, err error, now time.Time) {
+	if c.ttl <= 0 || (err != nil && !expire.IsExpiryWithheld(err)) {
+		return
+	}
+	expires := now.Add(c.ttl)
+	c.mu.Lock()
+	for k, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[domain] = domainCacheEntry{result: result, err: err, expires: expires}
+	c.mu.Unlock()
+
+	if c.shared == nil {
+		return
+	}
+	buf, err := json.Marshal(sharedDomainResult{Result: result, Error: newSharedError(err), Expires: expires})
+	if err == nil {
+		err = c.shared.Set(sharedDomainPrefix+domain, buf, c.ttl)
+	}
+	if err != nil {
+		log.Printf("cannot cache domain: %s", err)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+func TestResultCache(t *testing.T) {
+	c := newResultCache(time.Minute)
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	opts := checkOptions{Options: expire.Options{MaxCertificateLifetime: expire.DefaultMaxCertificateLifetime}}
+
+	c.Set(opts.cacheKey("example.com"), Expiration{
+		Name:    "example.com",
+		Details: &Details{Whois: "record"},
+	}, now)
+
+	exp, ok := c.Get(opts.cacheKey("example.com"), now.Add(30*time.Second))
+	if !ok || exp.Name != "example.com" {
+		t.Fatalf("expected cached result, got %v %v", exp, ok)
+	}
+	exp.Details.Whois = ""
+	if exp, _ := c.Get(opts.cacheKey("example.com"), now); exp.Details.Whois != "record" {
+		t.Errorf("expected the cached details not to be modified")
+	}
+
+	opts.TrustStores = []string{"mozilla"}
+	if _, ok := c.Get(opts.cacheKey("example.com"), now); ok {
+		t.Errorf("expected different options to miss the cache")
+	}
+	if _, ok := c.Get(checkOptions{Options: expire.Options{MaxCertificateLifetime: expire.DefaultMaxCertificateLifetime}}.cacheKey("example.com"), now.Add(time.Minute)); ok {
+		t.Errorf("expected result to have expired")
+	}
+}
+
+func TestOpenSharedCache(t *testing.T) {
+	for _, config := range []CacheConfig{{}, {Type: "memory"}} {
+		if c, err := openSharedCache(config); c != nil || err != nil {
+			t.Errorf("%+v: expected no shared cache, got %v %v", config, c, err)
+		}
+	}
+	for _, config := range []CacheConfig{{Type: "redis"}, {Type: "memcached"}} {
+		if _, err := openSharedCache(config); err == nil {
+			t.Errorf("%+v: expected an error", config)
+		}
+	}
+}
+
+func TestDomainCache(t *testing.T) {
+	c := newDomainCache(12 * time.Hour)
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	expires := now.AddDate(1, 0, 0)
+
+	c.Set("example.com", expire.DomainResult{Expires: expires}, nil, now)
+	c.Set("example.net", expire.DomainResult{}, fmt.Errorf("connection refused"), now)
+	c.Set("example.org", expire.DomainResult{}, expire.ExpiryWithheldError{Domain: "example.org"}, now)
+
+	if entry, ok := c.Get("example.com", now.Add(11*time.Hour)); !ok || !entry.result.Expires.Equal(expires) {
+		t.Errorf("expected cached expiration, got %v %v", entry, ok)
+	}
+	if _, ok := c.Get("example.net", now); ok {
+		t.Errorf("expected failed lookup not to be cached")
+	}
+	if entry, ok := c.Get("example.org", now); !ok || !expire.IsExpiryWithheld(entry.err) {
+		t.Errorf("expected withheld expiration to be cached, got %v %v", entry, ok)
+	}
+	if _, ok := c.Get("example.com", now.Add(12*time.Hour)); ok {
+		t.Errorf("expected lookup to have expired")
+	}
+}
+
+func TestResultCacheTTL(t *testing.T) {
+	defer func(r *resultCache) { results = r }(results)
+	if err := configureChecks(&Config{ResultCacheTTL: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	results.Set("example.com", Expiration{Name: "example.com"}, now)
+	if _, ok := results.Get("example.com", now.Add(59*time.Minute)); !ok {
+		t.Errorf("expected the result to be cached for an hour")
+	}
+	if err := configureChecks(&Config{ResultCacheTTL: -time.Hour}); err == nil {
+		t.Errorf("expected an error for a negative ttl")
+	}
+}
+
+func TestServePrefetchLimits(t *testing.T) {
+	s := NewServer(&Config{})
+
+	hosts := make([]string, maxPrefetchHosts/2+1)
+	for i := range hosts {
+		hosts[i] = fmt.Sprintf("h%d.example.com", i)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/prefetch?follow", strings.NewReader(strings.Join(hosts, "\n"))))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %d %s", w.Code, w.Body.String())
+	}
+
+	for i := 0; i < maxPrefetchesInFlight; i++ {
+		s.prefetches <- struct{}{}
+	}
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/prefetch", strings.NewReader("example.com")))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("expected 503 with Retry-After, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPrefetchHostnames(t *testing.T) {
+	got := prefetchHostnames("example.com,example.net\nexample.org\r\n\n")
+	want := []string{"example.com", "example.net", "example.org"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultCacheMaxAge is how long responses may be cached, by format.
+// Calendar programs poll on their own schedule, and expirations don't
+// change quickly, so calendars may be cached for much longer than the
+// formats scripts use.
+var defaultCacheMaxAge = map[string]time.Duration{
+	"text/calendar":    6 * time.Hour,
+	"application/json": 5 * time.Minute,
+	"text/plain":       5 * time.Minute,
+	"text/csv":         5 * time.Minute,
+	"text/prometheus":  time.Minute,
+	"text/html":        5 * time.Minute,
+}
+
+// cacheFormats maps the format names used in the configuration to content
+// types.
+var cacheFormats = map[string]string{
+	"ical":       "text/calendar",
+	"json":       "application/json",
+	"text":       "text/plain",
+	"csv":        "text/csv",
+	"prometheus": "text/prometheus",
+	"html":       "text/html",
+}
+
+// maxErrorCacheMaxAge limits how long a response that includes a failed
+// check may be cached, because most failures are transient.
+const maxErrorCacheMaxAge = time.Minute
+
+// cacheMaxAge returns how long a response of contentType may be cached,
+// from the maxage query parameter if it is given, otherwise from the
+// server's configuration or the default.
+func (s *Server) cacheMaxAge(r *http.Request, contentType string) (time.Duration, error) {
+	if maxAgeStr := r.FormValue("maxage"); maxAgeStr != "" {
+		if seconds, err := strconv.Atoi(maxAgeStr); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second, nil
+		}
+		maxAge, err := time.ParseDuration(maxAgeStr)
+		if err != nil || maxAge < 0 {
+			return 0, fmt.Errorf("Cannot parse maxage parameter: expected a number of seconds or a duration like 1h")
+		}
+		return maxAge, nil
+	}
+	for format, maxAge := range s.Config.CacheControl {
+		if cacheFormats[format] == contentType {
+			return maxAge, nil
+		}
+	}
+	return defaultCacheMaxAge[contentType], nil
+}
+
+// setCacheHeaders sets the Cache-Control and Expires headers for a
+// response that will be cached for maxAge.
+func setCacheHeaders(w http.ResponseWriter, now time.Time, maxAge time.Duration) {
+	if maxAge <= 0 {
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Expires", now.UTC().Format(http.TimeFormat))
+		return
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	w.Header().Set("Expires", now.Add(maxAge).UTC().Format(http.TimeFormat))
+}
+
+// lastChecked returns when the most recently checked of expirations was
+// checked, or the zero time if that isn't known.
+func lastChecked(expirations []Expiration) time.Time {
+	var rv time.Time
+	for _, exp := range expirations {
+		if exp.Details != nil && exp.Details.Checked.After(rv) {
+			rv = exp.Details.Checked
+		}
+	}
+	return rv
+}
+
+// etagExpiration is what the ETag of a response covers of each result:
+// everything but how and when it was checked, with errors as their
+// messages, and its status.
+type etagExpiration struct {
+	Expiration
+	CertificateError string
+	DomainError      string
+	Details          *Details
+	Status           string
+}
+
+// resultsETag returns the ETag of the response to r, of contentType, with
+// expirations. Responses also say how long is left until each expiration,
+// which changes every second, so the ETag is computed from the results
+// rather than the body, and changes only when they do. Except in calendars,
+// which give only dates, that includes the number of days left, so the ETag
+// also changes each day.
+func resultsETag(r *http.Request, contentType string, expirations []Expiration, t thresholds) string {
+	h := sha256.New()
+	io.WriteString(h, contentType+"\x00"+r.URL.Path+"?"+r.URL.RawQuery+"\x00")
+	if contentType != "text/calendar" {
+		io.WriteString(h, t.Now.UTC().Format("2006-01-02")+"\x00")
+	}
+	enc := json.NewEncoder(h)
+	for _, exp := range expirations {
+		e := etagExpiration{Expiration: exp, Status: exp.Status(t)}
+		if exp.CertificateError != nil {
+			e.CertificateError = exp.CertificateError.Error()
+		}
+		if exp.DomainError != nil {
+			e.DomainError = exp.DomainError.Error()
+		}
+		if exp.Details != nil {
+			details := *exp.Details
+			details.Cached, details.Checked, details.DomainCached = false, time.Time{}, false
+			details.CertificateCheckMillis, details.DomainCheckMillis = 0, 0
+			details.CertificateLifetimeElapsed = 0
+			e.Details = &details
+		}
+		enc.Encode(e)
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// bufferedResponse holds the body of a response until it is complete, so
+// that a 304 Not Modified can be sent instead. Headers are set on the
+// underlying ResponseWriter.
+type bufferedResponse struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
+
+// send writes the response. If it is 200 OK, it has etag and, if
+// modified isn't zero, a Last-Modified time, and it is 304 Not Modified
+// instead if r shows that the client already has it.
+func (b *bufferedResponse) send(r *http.Request, etag string, modified time.Time) {
+	w := b.ResponseWriter
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	if b.status == http.StatusOK {
+		w.Header().Set("ETag", etag)
+		if !modified.IsZero() {
+			w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+		}
+		if notModified(r, etag, modified) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	w.WriteHeader(b.status)
+	if r.Method != "HEAD" {
+		w.Write(b.body.Bytes())
+	}
+}
+
+// notModified returns true if the conditional headers of r match etag or
+// show that the client has had the response since modified. If-None-Match
+// takes precedence over If-Modified-Since, as RFC 7232 requires.
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == etag {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modified.IsZero() {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(since)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCacheMaxAge(t *testing.T) {
+	s := NewServer(&Config{CacheControl: map[string]time.Duration{"json": time.Minute}})
+	for _, tc := range []struct {
+		url, contentType string
+		want             time.Duration
+	}{
+		{"/example.com", "text/calendar", 6 * time.Hour},
+		{"/example.com", "text/plain", 5 * time.Minute},
+		{"/example.com", "application/json", time.Minute},
+		{"/example.com?maxage=30", "text/calendar", 30 * time.Second},
+		{"/example.com?maxage=2h", "text/plain", 2 * time.Hour},
+		{"/example.com?maxage=0", "text/plain", 0},
+	} {
+		r := httptest.NewRequest("GET", tc.url, nil)
+		got, err := s.cacheMaxAge(r, tc.contentType)
+		if err != nil {
+			t.Errorf("%s: %s", tc.url, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s %s: expected %s, got %s", tc.url, tc.contentType, tc.want, got)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/example.com?maxage=soon", nil)
+	if _, err := s.cacheMaxAge(r, "text/plain"); err == nil {
+		t.Errorf("expected error for invalid maxage")
+	}
+}
+
+func TestSetCacheHeaders(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	w := httptest.NewRecorder()
+	setCacheHeaders(w, now, time.Hour)
+	if got, want := w.Header().Get("Cache-Control"), "public, max-age=3600"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if got, want := w.Header().Get("Expires"), "Thu, 02 Jan 2020 04:04:05 GMT"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	w = httptest.NewRecorder()
+	setCacheHeaders(w, now, 0)
+	if got, want := w.Header().Get("Cache-Control"), "no-cache"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestConditionalResponses(t *testing.T) {
+	checked := time.Now().Add(-time.Hour)
+	s := NewServer(&Config{})
+	opts := s.defaultCheckOptions()
+	results.Set(opts.cacheKey("etag1.example.com"), Expiration{Name: "etag1.example.com", CertificateExpires: checked.AddDate(0, 0, 10), Details: &Details{Checked: checked}}, time.Now())
+
+	get := func(url string, header map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", url, nil)
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("/ical/etag1.example.com", nil)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected 200 with an ETag, got %d %q", w.Code, etag)
+	}
+	if got, want := w.Header().Get("Last-Modified"), checked.UTC().Format(http.TimeFormat); got != want {
+		t.Errorf("expected Last-Modified %q, got %q", want, got)
+	}
+
+	for _, tc := range []struct {
+		header map[string]string
+		code   int
+	}{
+		{map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{map[string]string{"If-None-Match": `"other", W/` + etag}, http.StatusNotModified},
+		{map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+		{map[string]string{"If-Modified-Since": checked.UTC().Format(http.TimeFormat)}, http.StatusNotModified},
+		{map[string]string{"If-Modified-Since": checked.Add(-time.Minute).UTC().Format(http.TimeFormat)}, http.StatusOK},
+		{map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": checked.UTC().Format(http.TimeFormat)}, http.StatusOK},
+	} {
+		w := get("/ical/etag1.example.com", tc.header)
+		if w.Code != tc.code {
+			t.Errorf("%v: expected %d, got %d", tc.header, tc.code, w.Code)
+		}
+		if tc.code == http.StatusNotModified && w.Body.Len() != 0 {
+			t.Errorf("%v: expected no body, got %q", tc.header, w.Body.String())
+		}
+	}
+
+	results.Set(opts.cacheKey("etag2.example.com"), Expiration{Name: "etag2.example.com", CertificateExpires: checked.AddDate(1, 0, 0), Details: &Details{Checked: checked}}, time.Now())
+	w = get("/json/etag2.example.com", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if w = get("/json/etag2.example.com", map[string]string{"If-None-Match": w.Header().Get("ETag")}); w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for JSON, got %d", w.Code)
+	}
+
+	// the status says something is wrong, so there is nothing to revalidate
+	w = get("/json/etag1.example.com", map[string]string{"If-Modified-Since": checked.UTC().Format(http.TimeFormat)})
+	if w.Code != http.StatusExpectationFailed || w.Header().Get("ETag") != "" {
+		t.Errorf("expected 417 without an ETag, got %d %q", w.Code, w.Header().Get("ETag"))
+	}
+}
+
+func TestResultsETag(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	r := httptest.NewRequest("GET", "/json/example.com", nil)
+	exp := Expiration{Name: "example.com", CertificateExpires: now.AddDate(1, 0, 0), Details: &Details{Checked: now}}
+	th := thresholds{Now: now, Soon: now.AddDate(0, 0, 30)}
+	etag := resultsETag(r, "application/json", []Expiration{exp}, th)
+
+	// checking again, later, without finding anything new
+	later := exp
+	later.Details = &Details{Cached: true, Checked: now.Add(time.Hour), CertificateCheckMillis: 10}
+	if got := resultsETag(r, "application/json", []Expiration{later}, thresholds{Now: now.Add(time.Hour), Soon: now.Add(time.Hour).AddDate(0, 0, 30)}); got != etag {
+		t.Errorf("expected the ETag not to change, got %s and %s", etag, got)
+	}
+
+	renewed := exp
+	renewed.CertificateExpires = now.AddDate(2, 0, 0)
+	if resultsETag(r, "application/json", []Expiration{renewed}, th) == etag {
+		t.Errorf("expected a new expiration to change the ETag")
+	}
+	if resultsETag(r, "text/calendar", []Expiration{exp}, th) == etag {
+		t.Errorf("expected another format to change the ETag")
+	}
+	failed := exp
+	failed.DomainError = fmt.Errorf("whois failed")
+	if resultsETag(r, "application/json", []Expiration{failed}, th) == etag {
+		t.Errorf("expected an error to change the ETag")
+	}
+
+	// the number of days left changes, except in calendars
+	tomorrow := thresholds{Now: now.AddDate(0, 0, 1), Soon: now.AddDate(0, 0, 31)}
+	if resultsETag(r, "application/json", []Expiration{exp}, tomorrow) == etag {
+		t.Errorf("expected the next day to change the ETag")
+	}
+	calendar := resultsETag(r, "text/calendar", []Expiration{exp}, th)
+	if got := resultsETag(r, "text/calendar", []Expiration{exp}, tomorrow); got != calendar {
+		t.Errorf("expected the calendar ETag not to change, got %s and %s", calendar, got)
+	}
+}
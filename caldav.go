@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jordic/goics"
+)
+
+// The CalDAV server exposes each watchlist as a read-only calendar
+// collection at /caldav/<watchlist>/, for clients that sync calendars with
+// CalDAV rather than subscribing to an iCal URL. Each certificate and domain
+// expiration is a separate calendar object resource.
+
+const caldavPrefix = "/caldav/"
+
+// maxCaldavRequestSize is the largest PROPFIND or REPORT body we'll read
+const maxCaldavRequestSize = 64 * 1024
+
+type davMultistatus struct {
+	XMLName   xml.Name      `xml:"DAV: multistatus"`
+	Responses []davResponse `xml:"response"`
+}
+
+type davResponse struct {
+	Href     string      `xml:"href"`
+	Propstat davPropstat `xml:"propstat"`
+}
+
+type davPropstat struct {
+	Prop   davProp `xml:"prop"`
+	Status string  `xml:"status"`
+}
+
+type davProp struct {
+	ResourceType *davResourceType `xml:"resourcetype,omitempty"`
+	DisplayName  string           `xml:"displayname,omitempty"`
+	ContentType  string           `xml:"getcontenttype,omitempty"`
+	ETag         string           `xml:"getetag,omitempty"`
+	CTag         string           `xml:"http://calendarserver.org/ns/ getctag,omitempty"`
+	Principal    *davHref         `xml:"current-user-principal,omitempty"`
+	CalendarHome *davHref         `xml:"urn:ietf:params:xml:ns:caldav calendar-home-set,omitempty"`
+	ComponentSet *davComponentSet `xml:"urn:ietf:params:xml:ns:caldav supported-calendar-component-set,omitempty"`
+	CalendarData string           `xml:"urn:ietf:params:xml:ns:caldav calendar-data,omitempty"`
+	Privileges   *davPrivilegeSet `xml:"current-user-privilege-set,omitempty"`
+}
+
+type davResourceType struct {
+	Collection *struct{} `xml:"collection,omitempty"`
+	Calendar   *struct{} `xml:"urn:ietf:params:xml:ns:caldav calendar,omitempty"`
+}
+
+type davHref struct {
+	Href string `xml:"href"`
+}
+
+type davComponentSet struct {
+	Components []davComponent `xml:"urn:ietf:params:xml:ns:caldav comp"`
+}
+
+type davComponent struct {
+	Name string `xml:"name,attr"`
+}
+
+type davPrivilegeSet struct {
+	Privileges []davPrivilege `xml:"privilege"`
+}
+
+type davPrivilege struct {
+	Read *struct{} `xml:"read"`
+}
+
+// caldavObject is a calendar object resource: a single event.
+type caldavObject struct {
+	Name string // e.g. example.com@certificates.ics
+	Data string
+}
+
+func (o caldavObject) ETag() string {
+	hash := sha256.Sum256([]byte(o.Data))
+	return `"` + hex.EncodeToString(hash[:16]) + `"`
+}
+
+// caldavObjects returns a calendar object resource for each event in the
+// iCal rendering of expirations.
+func caldavObjects(expirations []Expiration, baseURL string) []caldavObject {
+	var rv []caldavObject
+	for _, exp := range expirations {
+		// split each result into a certificate part and a domain part,
+		// since each resource may only contain one event
+		certificate := Expiration{
+			Name:                 exp.Name,
+			CertificateExpires:   exp.CertificateExpires,
+			CertificateNotBefore: exp.CertificateNotBefore,
+			CertificateError:     exp.CertificateError,
+		}
+		if certificate.CertificateError != nil || !certificate.CertificateExpires.IsZero() {
+			rv = append(rv, caldavObject{
+				Name: exp.Name + "@certificates.ics",
+				Data: encodeICal(Calendar{Expirations: []Expiration{certificate}, BaseURL: baseURL}),
+			})
+		}
+
+		domain := Expiration{
+			Name:          exp.Name,
+			Domain:        exp.Domain,
+			DomainExpires: exp.DomainExpires,
+			DomainError:   exp.DomainError,
+		}
+		if domain.Domain != "" || domain.DomainError != nil {
+			rv = append(rv, caldavObject{
+				Name: exp.Name + "@domain.ics",
+				Data: encodeICal(Calendar{Expirations: []Expiration{domain}, BaseURL: baseURL}),
+			})
+		}
+	}
+	return rv
+}
+
+func encodeICal(cal Calendar) string {
+	buf := &bytes.Buffer{}
+	goics.NewICalEncode(buf).Encode(cal)
+	return buf.String()
+}
+
+// caldavCTag returns a tag that changes whenever any of objects changes.
+func caldavCTag(objects []caldavObject) string {
+	h := sha256.New()
+	for _, o := range objects {
+		io.WriteString(h, o.Name+"\x00"+o.ETag()+"\x00")
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+func calendarCollectionProp(name string, objects []caldavObject) davProp {
+	return davProp{
+		ResourceType: &davResourceType{Collection: &struct{}{}, Calendar: &struct{}{}},
+		DisplayName:  name,
+		CTag:         caldavCTag(objects),
+		ComponentSet: &davComponentSet{Components: []davComponent{{Name: "VEVENT"}}},
+		Privileges:   &davPrivilegeSet{Privileges: []davPrivilege{{Read: &struct{}{}}}},
+	}
+}
+
+func objectProp(o caldavObject, withData bool) davProp {
+	prop := davProp{
+		ResourceType: &davResourceType{},
+		ContentType:  "text/calendar; component=vevent",
+		ETag:         o.ETag(),
+	}
+	if withData {
+		prop.CalendarData = o.Data
+	}
+	return prop
+}
+
+func okResponse(href string, prop davProp) davResponse {
+	return davResponse{
+		Href:     href,
+		Propstat: davPropstat{Prop: prop, Status: "HTTP/1.1 200 OK"},
+	}
+}
+
+func writeMultistatus(w http.ResponseWriter, responses []davResponse) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(davMultistatus{Responses: responses})
+}
+
+// reportHrefs returns the hrefs requested by a calendar-multiget REPORT,
+// or nil for any other kind of report, e.g. calendar-query, in which case
+// every object is returned.
+func reportHrefs(body []byte) []string {
+	report := struct {
+		XMLName xml.Name
+		Hrefs   []string `xml:"DAV: href"`
+	}{}
+	if err := xml.Unmarshal(body, &report); err != nil || report.XMLName.Local != "calendar-multiget" {
+		return nil
+	}
+	return report.Hrefs
+}
+
+// serveCaldav handles CalDAV requests under /caldav/.
+func (s *Server) serveCaldav(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("DAV", "1, calendar-access")
+
+	path := strings.TrimPrefix(r.URL.Path, caldavPrefix)
+	parts := strings.SplitN(path, "/", 2)
+	name := parts[0]
+	resource := ""
+	if len(parts) > 1 {
+		resource = parts[1]
+	}
+
+	switch r.Method {
+	case "OPTIONS":
+		w.Header().Set("Allow", "OPTIONS, GET, HEAD, PROPFIND, REPORT")
+		return
+	case "GET", "HEAD", "PROPFIND", "REPORT":
+	default:
+		w.Header().Set("Allow", "OPTIONS, GET, HEAD, PROPFIND, REPORT")
+		http.Error(w, "this calendar is read only", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(&io.LimitedReader{R: r.Body, N: maxCaldavRequestSize})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// the root is both the principal and the calendar home, and contains
+	// a calendar for each watchlist
+	if name == "" {
+		if r.Method != "PROPFIND" {
+			http.Error(w, "not a calendar", http.StatusMethodNotAllowed)
+			return
+		}
+		responses := []davResponse{okResponse(caldavPrefix, davProp{
+			ResourceType: &davResourceType{Collection: &struct{}{}},
+			DisplayName:  "expire.sh",
+			Principal:    &davHref{Href: caldavPrefix},
+			CalendarHome: &davHref{Href: caldavPrefix},
+		})}
+		if r.Header.Get("Depth") != "0" {
+			for _, cwl := range s.Config.Watchlists {
+				responses = append(responses, okResponse(caldavPrefix+cwl.Name+"/", davProp{
+					ResourceType: &davResourceType{Collection: &struct{}{}, Calendar: &struct{}{}},
+					DisplayName:  cwl.Name,
+				}))
+			}
+		}
+		writeMultistatus(w, responses)
+		return
+	}
+
+	wl := s.watchlist(name)
+	if wl == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if !s.chargeQuota(w, r, len(wl.Hosts)) {
+		return
+	}
+	expirations := getExpirations(r.Context(), wl.Hostnames(), s.defaultCheckOptions())
+	objects := caldavObjects(expirations, s.baseURL(r))
+	collection := caldavPrefix + wl.Name + "/"
+
+	if resource != "" {
+		for _, o := range objects {
+			if o.Name != resource {
+				continue
+			}
+			if r.Method == "PROPFIND" {
+				writeMultistatus(w, []davResponse{okResponse(collection+o.Name, objectProp(o, false))})
+				return
+			}
+			w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+			w.Header().Set("ETag", o.ETag())
+			io.WriteString(w, o.Data)
+			return
+		}
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case "GET", "HEAD":
+		s.serveExpirationsIcal(w, r, expirations)
+	case "PROPFIND":
+		responses := []davResponse{okResponse(collection, calendarCollectionProp(wl.Name, objects))}
+		if r.Header.Get("Depth") != "0" {
+			for _, o := range objects {
+				responses = append(responses, okResponse(collection+o.Name, objectProp(o, false)))
+			}
+		}
+		writeMultistatus(w, responses)
+	case "REPORT":
+		hrefs := reportHrefs(body)
+		wanted := map[string]bool{}
+		for _, href := range hrefs {
+			if unescaped, err := url.PathUnescape(href); err == nil {
+				href = unescaped
+			}
+			wanted[href] = true
+		}
+		var responses []davResponse
+		for _, o := range objects {
+			if hrefs != nil && !wanted[collection+o.Name] {
+				continue
+			}
+			responses = append(responses, okResponse(collection+o.Name, objectProp(o, true)))
+		}
+		writeMultistatus(w, responses)
+	}
+}
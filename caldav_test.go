@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCaldavObjects(t *testing.T) {
+	objects := caldavObjects([]Expiration{
+		{
+			Name:               "example.com",
+			CertificateExpires: time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC),
+			Domain:             "example.com",
+			DomainError:        errors.New("whois failed"),
+		},
+		{
+			Name:             "pgp:alice@example.com",
+			CertificateError: errors.New("no key"),
+		},
+	}, "")
+	var names []string
+	for _, o := range objects {
+		names = append(names, o.Name)
+	}
+	want := "example.com@certificates.ics example.com@domain.ics pgp:alice@example.com@certificates.ics"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestReportHrefs(t *testing.T) {
+	hrefs := reportHrefs([]byte(`<?xml version="1.0" encoding="utf-8" ?>
+<C:calendar-multiget xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><D:getetag/><C:calendar-data/></D:prop>
+  <D:href>/caldav/prod/example.com@certificates.ics</D:href>
+  <D:href>/caldav/prod/example.com@domain.ics</D:href>
+</C:calendar-multiget>`))
+	if len(hrefs) != 2 || hrefs[1] != "/caldav/prod/example.com@domain.ics" {
+		t.Errorf("unexpected hrefs %q", hrefs)
+	}
+
+	if hrefs := reportHrefs([]byte(`<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav"/>`)); hrefs != nil {
+		t.Errorf("expected every object for a calendar-query, got %q", hrefs)
+	}
+}
+
+func TestCaldavRoot(t *testing.T) {
+	s := NewServer(&Config{Watchlists: []Watchlist{{Name: "prod"}}})
+	r := httptest.NewRequest("PROPFIND", "/caldav/", nil)
+	r.Header.Set("Depth", "1")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusMultiStatus {
+		t.Fatalf("expected 207, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "<href>/caldav/prod/</href>") {
+		t.Errorf("expected watchlist in %s", w.Body.String())
+	}
+
+	r = httptest.NewRequest("PUT", "/caldav/prod/x.ics", nil)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", w.Code)
+	}
+}
@@ -4,30 +4,186 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/crewjam/expire-sh/expire"
 	"github.com/golang/gddo/httputil"
 	"github.com/jordic/goics"
-	"golang.org/x/net/publicsuffix"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
-func NewServer() http.Handler {
-	return &Server{}
+func NewServer(config *Config) *Server {
+	return &Server{
+		Config:       config,
+		Dialer:       expire.DefaultDialer,
+		store:        newMemoryStore(),
+		audit:        &auditLog{},
+		quotas:       newQuotaTracker(),
+		debugLimiter: newRateLimiter(debugRequestsPerMinute, time.Minute),
+		linkLimiter:  newRateLimiter(linksPerMinute, time.Minute),
+		prefetches:   make(chan struct{}, maxPrefetchesInFlight),
+	}
 }
 
 type Server struct {
+	Config *Config
+
+	// Dialer makes the connections to the hosts being checked.
+	Dialer expire.Dialer
+
+	mu         sync.Mutex
+	discovered map[string][]WatchlistHost // by watchlist name
+
+	store        Store
+	audit        *auditLog
+	quotas       *quotaTracker
+	notifiers    map[string]Notifier
+	debugLimiter *rateLimiter
+	linkLimiter  *rateLimiter
+	prefetches   chan struct{} // one for each prefetch running
+	monitor      monitorStatus // guarded by mu
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path == "/" {
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	s.route(rec, r)
+	stats.CountResponse(rec.status)
+	if s.authEnabled() {
+		s.recordAudit(r, rec.status)
+	}
+}
+
+// route serves r with the handler for its path.
+func (s *Server) route(w http.ResponseWriter, r *http.Request) {
+	if isGRPC(r) {
+		s.serveGRPC(w, r)
+		return
+	}
+
+	if r.URL.Path == "/" && r.Method != "POST" {
 		s.serveIndex(w, r)
 		return
 	}
 
+	if r.URL.Path == "/selftest" {
+		s.serveSelfTest(w, r)
+		return
+	}
+
+	if r.URL.Path == "/audit" {
+		s.serveAuditLog(w, r)
+		return
+	}
+
+	if r.URL.Path == "/stats" {
+		s.serveStats(w, r)
+		return
+	}
+
+	if r.URL.Path == "/metrics" {
+		s.serveMetrics(w, r)
+		return
+	}
+
+	if r.URL.Path == openAPIPath {
+		s.serveOpenAPI(w, r)
+		return
+	}
+
+	if r.URL.Path == graphqlPath {
+		s.serveGraphQL(w, r)
+		return
+	}
+
+	if r.URL.Path == "/monitor" {
+		s.serveMonitor(w, r)
+		return
+	}
+
+	if r.URL.Path == "/quota" {
+		s.serveQuota(w, r)
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, reportPrefix) {
+		s.serveReport(w, r, strings.TrimPrefix(r.URL.Path, reportPrefix))
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, grafanaPrefix) {
+		s.serveGrafana(w, r, strings.TrimPrefix(r.URL.Path, grafanaPrefix))
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/status/") {
+		s.serveStatusPage(w, r, strings.TrimPrefix(r.URL.Path, "/status/"))
+		return
+	}
+
+	if r.URL.Path == "/.well-known/caldav" {
+		http.Redirect(w, r, caldavPrefix, http.StatusMovedPermanently)
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, caldavPrefix) {
+		s.serveCaldav(w, r)
+		return
+	}
+
+	if r.URL.Path == "/l" && r.Method == "POST" {
+		s.serveCreateLink(w, r)
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, linkPrefix) {
+		s.serveLink(w, r, strings.TrimPrefix(r.URL.Path, linkPrefix))
+		return
+	}
+
+	if r.URL.Path == "/t" && r.Method == "POST" {
+		s.serveCreateToken(w, r)
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, tokenPrefix) {
+		s.serveToken(w, r, strings.TrimPrefix(r.URL.Path, tokenPrefix))
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, detailPrefix) {
+		s.serveDetail(w, r, strings.TrimPrefix(r.URL.Path, detailPrefix))
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, historyPrefix) {
+		s.serveHistory(w, r, strings.TrimPrefix(r.URL.Path, historyPrefix))
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, ackPrefix) {
+		s.serveAck(w, r, strings.TrimPrefix(r.URL.Path, ackPrefix))
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/days/") {
+		s.serveDays(w, r, strings.TrimPrefix(r.URL.Path, "/days/"))
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/shield/") {
+		s.serveShield(w, r, strings.TrimPrefix(r.URL.Path, "/shield/"))
+		return
+	}
+
 	if strings.HasPrefix(r.URL.Path, "/ical/") {
 		r.URL.Path = strings.TrimPrefix(r.URL.Path, "/ical")
 		r.Header.Set("Accept", "text/calendar")
@@ -37,6 +193,40 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	} else if strings.HasPrefix(r.URL.Path, "/text/") {
 		r.URL.Path = strings.TrimPrefix(r.URL.Path, "/text")
 		r.Header.Set("Accept", "text/plain")
+	} else if strings.HasPrefix(r.URL.Path, "/csv/") {
+		r.URL.Path = strings.TrimPrefix(r.URL.Path, "/csv")
+		r.Header.Set("Accept", "text/csv")
+	} else if strings.HasPrefix(r.URL.Path, "/html/") {
+		r.URL.Path = strings.TrimPrefix(r.URL.Path, "/html")
+		r.Header.Set("Accept", "text/html")
+	} else if strings.HasPrefix(r.URL.Path, metricsPrefix) {
+		r.URL.Path = strings.TrimPrefix(r.URL.Path, "/metrics")
+		r.Header.Set("Accept", "text/prometheus")
+	}
+
+	if strings.HasPrefix(r.URL.Path, groupPrefix) {
+		s.serveGroup(w, r, strings.TrimPrefix(r.URL.Path, groupPrefix))
+		return
+	}
+
+	if r.URL.Path == "/" && r.Method == "POST" {
+		s.servePostHostnames(w, r)
+		return
+	}
+
+	if r.URL.Path == "/zone" && r.Method == "POST" {
+		s.serveZone(w, r)
+		return
+	}
+
+	if r.URL.Path == "/prefetch" && r.Method == "POST" {
+		s.servePrefetch(w, r)
+		return
+	}
+
+	if r.URL.Path == "/authenticode" && r.Method == "POST" {
+		s.serveAuthenticode(w, r)
+		return
 	}
 
 	s.serveExpirations(w, r)
@@ -51,16 +241,74 @@ calendar:
 
 https://expire.sh/example.com,example.net
 
+Certificates are checked on port 443. For other services, add the port to the
+host name, e.g. https://expire.sh/example.com,mail.example.com:8443
+
+Internationalized domain names may be given in Unicode or punycode, e.g.
+https://expire.sh/bücher.example or https://expire.sh/xn--bcher-kva.example,
+and are shown in Unicode either way.
+
+Certificates that aren't valid for their host are reported as errors, but their
+expiration is still shown, and the JSON output lists each problem found in
+CertificateProblems: hostname-mismatch, self-signed, unknown-authority,
+expired, not-yet-valid, expired-intermediate, or invalid for anything else.
+A certificate isn't valid before its CertificateNotBefore, so one deployed
+early, or served by a host whose clock is wrong, is an error just like an
+expired one, whichever trust stores it is checked against.
+
+To check a mail server that starts TLS with STARTTLS, add "proto=smtp". The
+port is 25 unless you give another, such as 587 for submission:
+
+https://expire.sh/smtp.example.com:587?proto=smtp
+
+The "caa" parameter looks up the CAA records of each host, which say which CAs
+may issue its certificates, and warns if they don't include the CA that issued
+the certificate it has. Only well known CAs can be recognized from their
+certificates, so records that authorize others are shown but not checked.
+
+$ curl https://expire.sh/json/example.com?caa
+
+The "allips" parameter checks the certificate served at every address a host
+resolves to, for hosts behind round-robin DNS whose backends may serve
+different certificates. Each address is reported, the earliest expiration is
+the host's, and addresses that serve a different certificate or can't be
+checked are warned about.
+
+$ curl https://expire.sh/json/www.example.com?allips
+
+The "nosni" parameter makes a second handshake with each host without SNI, and
+reports the default certificate the server presents to clients that don't say
+which host they want. It is warned about if it has expired, or expires before
+the host's own certificate.
+
+$ curl https://expire.sh/json/example.com?nosni
+
+The TLS version and cipher suite negotiated with each host are reported in the
+JSON output. The "tlspolicy" parameter also checks whether each host still
+accepts TLS 1.0 or 1.1, or a cipher suite with known weaknesses, and warns if
+it does.
+
+$ curl https://expire.sh/json/example.com?tlspolicy
+
 Formats
 -------
 
-Responses are available in text, JSON, or iCal formats. You can specify which 
+Responses are available in text, JSON, CSV, or iCal formats. You can specify which 
 format you want with the Accept header (with one of 'text/plain',
-'application/json', or 'text/calendar')
+'application/json', 'text/csv', or 'text/calendar')
 
 $ curl -H "Accept: application/json" https://expire.sh/example.com
 {"expirations":[{"Name":"example.com","CertificateExpires":"2020-12-02T12:00:00Z","CertificateError":null,"Domain":"example.com","DomainExpires":"2019-08-13T04:00:00Z","DomainError":null}]}
 
+When a check fails, CertificateError or DomainError says why, with a code that
+programs can branch on, and whether checking again later might succeed:
+
+{"code":"DIAL_TIMEOUT","message":"dial tcp 192.0.2.1:443: i/o timeout","retryable":true}
+
+The codes are DIAL_TIMEOUT, TIMEOUT, DNS_NOT_FOUND, DNS_FAILED,
+CONNECTION_REFUSED, CONNECTION_FAILED, TLS_HANDSHAKE_FAILED, CERTIFICATE_INVALID,
+EXPIRY_WITHHELD, WHOIS_PARSE_FAILED, and CHECK_FAILED for anything else.
+
 If this is inconvenient, you can also add the format you want to the front of the URL:
 
 $ curl -v https://expire.sh/ical/example.com
@@ -70,6 +318,368 @@ BEGIN:VCALENDAR
 ...
 END:VCALENDAR
 
+Calendar events fall on the day something expires, which is too late to do
+anything about it. To be reminded beforehand, list how long before with the
+alarm parameter, with the same units as ttl:
+
+https://expire.sh/ical/example.com?alarm=30d,7d,1d
+
+A certificate expires when the first certificate in its chain does, which is
+not always the leaf. JSON results list the chain in CertificateChain, each with
+its subject, issuer and expiration, and calendars have a separate event for
+each intermediate certificate.
+
+In a browser, or with 'text/html' in the Accept header or /html/ at the front of
+the URL, the results are a table that sorts by any column you click on:
+
+https://expire.sh/html/example.com,example.net
+
+A long list of hosts can take a while to check. With 'text/event-stream' in the
+Accept header, as an EventSource sends, each host is sent as a server-sent
+"expiration" event, with the same JSON as in a JSON response, as soon as it has
+been checked, and a "done" event follows the last.
+
+$ curl -H "Accept: text/event-stream" https://expire.sh/example.com,example.net
+
+With the "stream" parameter, text responses for more than one host are also
+written a line at a time, as each host is checked, in the order they finish, so
+the status code is always '200 OK'. The parameter is ignored with "quiet",
+"summary" or "sort".
+
+$ curl "https://expire.sh/text/example.com,example.net?stream"
+
+Results are in the order the hosts were given, unless the "sort" parameter says
+otherwise: expiry puts whatever expires soonest first, name sorts by host name,
+and status puts errors first, then expiring hosts, then the rest. Event streams
+are always in the order the checks finish.
+
+$ curl "https://expire.sh/text/example.com,example.net,example.org?sort=expiry"
+
+Prometheus
+----------
+
+To alert from Prometheus, scrape /metrics/<hosts>, or ask for 'text/prometheus'
+with the Accept header. Each host has a gauge of when its certificate and domain
+expire, in seconds since the epoch, and one of whether each could be checked.
+The response is always '200 OK', since Prometheus treats anything else as a
+failed scrape.
+
+$ curl https://expire.sh/metrics/example.com
+certexp_certificate_expiry_timestamp_seconds{host="example.com"} 1.6069104e+09
+certexp_certificate_check_success{host="example.com"} 1
+certexp_domain_expiry_timestamp_seconds{host="example.com"} 1.5656688e+09
+certexp_domain_check_success{host="example.com"} 1
+
+For example, to alert when a certificate expires within two weeks:
+
+certexp_certificate_expiry_timestamp_seconds - time() < 14 * 86400
+
+gRPC
+----
+
+For internal tooling that wants typed results, the same port serves gRPC. The
+Expire service in expire.proto (https://github.com/crewjam/expire-sh) has a
+single method, Check, which streams the result for each host as soon as it has
+been checked rather than waiting for them all. Give an API key as the
+"authorization" metadata, as a bearer token.
+
+$ grpcurl -proto expire.proto -d '{"hosts":["example.com"]}' expire.sh:443 expiresh.Expire/Check
+
+
+Zone Files
+----------
+
+To check more hosts than fit in a URL, POST them to / as JSON, with the ttl and
+lifetime parameters if you like. The format is chosen as for any other request:
+
+$ curl --data '{"hosts":["example.com","example.net"],"ttl":"60d"}' https://expire.sh/json/
+
+To check every host in a DNS zone, POST a BIND format zone file to /zone. The
+owner names of the A, AAAA, and CNAME records are checked. If the zone file 
+doesn't have an $ORIGIN directive, specify one with the "origin" parameter. Any
+of the formats and parameters described above may be used.
+
+$ curl --data-binary @example.com.zone https://expire.sh/json/zone?origin=example.com
+
+A request may check at most 100 hosts, however they are given, or the response
+is '413 Request Entity Too Large'. With "www" each bare domain counts twice, and
+with "follow" every host does, since it may redirect to another. To check more,
+check them in batches, after POSTing them to /prefetch (see below) so that each
+batch is answered from the cache, or add them to a watchlist, whose status page
+and alerts aren't limited.
+
+Prefetching
+-----------
+
+Results are cached for 15 minutes, and domain expirations, which change
+rarely and come from registries that limit how often they may be asked, for 12
+hours. A server can be configured to cache them for longer, e.g. an hour, so
+that calendar programs that refresh often don't cause a check each time. To
+make the first request for a long list of hosts fast, for example before
+showing a dashboard, POST the list to /prefetch (separated by commas or
+newlines). The checks run in the background and the response, 202 Accepted,
+is immediate. A prefetch may list at most 1000 hosts, and while 4 are running
+the response is '503 Service Unavailable'.
+
+$ curl --data-binary @hosts.txt https://expire.sh/prefetch
+
+Each server caches what it checked. When several run behind a load balancer,
+configure a Redis cache so that they share results, and a host checked by one
+isn't checked again by the others.
+
+Code Signing Certificates
+-------------------------
+
+To check when the certificates used to sign a Windows executable or installer
+expire, POST the file to /authenticode. The result includes the signing
+certificate and, if the signature is timestamped, the timestamping certificate.
+
+$ curl --data-binary @setup.exe https://expire.sh/json/authenticode
+
+Details
+-------
+
+The "details" parameter adds a Details object to each JSON result with more
+information about how the checks were performed. Adding the "raw" parameter
+includes the RDAP response or whois record the domain expiration was parsed
+from, which is useful if the expiration can't be determined. The details also
+include the certificate's validity period and its validation level (DV, OV, IV,
+or EV), how long each check took, where the domain expiration came from, and
+how many times the lookup was retried, so that a slow response can be blamed
+on the right server.
+When a whois record contains more than one expiration date, each candidate is
+listed with where it was found, along with the date chosen (the first found in a
+known format, unless the server is configured to choose the earliest, the latest,
+or the registry's rather than the registrar's) and a confidence level:
+high if it matched a known format and all the candidates agree, low if it was
+found only by keyword and they don't, and medium otherwise. Dates that can't be
+right, such as the year 9999 or one decades in the past, are ignored; if those
+are the only dates found, the domain is reported as an error.
+
+$ curl https://expire.sh/json/example.com?details&raw
+
+When a check fails only from expire.sh, the "debug" parameter adds a trace of
+each step to the details: DNS resolution, the address dialed, the negotiated TLS
+parameters and certificates, the whois server queried, and the line the
+expiration was found in. Debug requests are limited to 10 per minute.
+
+$ curl https://expire.sh/text/example.com?debug
+
+The "follow" parameter makes an HTTP request to each host and, if it redirects to
+a different host, checks that host too. This catches, for example, an apex domain 
+that redirects to a www host with a different certificate.
+
+$ curl https://expire.sh/json/example.com?follow
+
+Similarly, the "www" parameter checks www.example.com alongside each bare domain
+like example.com, since these are often served with different certificates.
+
+$ curl https://expire.sh/text/example.com,example.net?www
+
+Certificate chains are normally verified against the system trust store. The 
+"truststores" parameter verifies the chain against each of the listed trust stores
+instead, and reports the result for each in the details. The "system" and 
+"mozilla" stores are always available; others may be configured by the server.
+
+$ curl https://expire.sh/json/example.com?details&truststores=system,mozilla
+
+Health Checks
+-------------
+
+/selftest checks a known-good host (example.com, unless the server is configured
+otherwise) from start to finish, bypassing the cache, and reports whether it
+passed and how long each check took. It responds '503 Service Unavailable' if
+the checks failed, so it can be used as a deep health check by load balancers
+and uptime monitors.
+
+$ curl https://expire.sh/selftest
+
+Badges
+------
+
+A badge showing how many days remain until a host's certificate or domain expires
+is available in the shields.io endpoint format:
+
+https://img.shields.io/endpoint?url=https://expire.sh/shield/example.com
+
+Countdown
+---------
+
+The number of days until the soonest certificate or domain expiration for a host
+is available as plain text, which is handy for dashboards and shell prompts:
+
+$ curl https://expire.sh/days/example.com
+42
+
+If nothing about the host expires, like a PGP key without an expiration, the
+response is '204 No Content', and the badge says "no expiry".
+
+Short Links
+-----------
+
+Some calendar programs truncate long subscription URLs. POST a URL to /l to get
+a short link that serves the same thing. Add the "redirect" parameter to a short
+link to be redirected to the original URL instead.
+
+$ curl --data '/ical/example.com,example.net,example.org?ttl=60d' https://expire.sh/l
+https://expire.sh/l/mfrggzdfmz
+
+Each client may create 10 short links a minute, and if the server requires an
+API key to check hosts, creating them requires one too.
+
+If you'd rather not reveal which hosts you are checking, POST the URL to /t
+instead. The result is a link containing the URL encrypted with a key only the
+server knows, so nothing is stored and the link can't be guessed.
+
+$ curl --data '/ical/internal.example.com' https://expire.sh/t
+
+Status Pages
+------------
+
+If the server is configured with watchlists, a status page for each is
+available at /status/<watchlist>, grouped by team and refreshed automatically.
+The same data is available as JSON at /status/<watchlist>.json.
+
+Each watchlist can also be checked like a list of hosts at /group/<watchlist>,
+in any format, so a calendar or a script doesn't need the whole list in its URL.
+A watchlist may set its own ttl and lifetime, which apply unless the request
+gives them.
+
+https://expire.sh/ical/group/prod
+
+Watchlists may have maintenance windows, given as a cron schedule and a
+duration, during which notifications are suppressed, e.g. while certificates are
+being renewed. Hosts are still checked during a window, and the status page
+shows when one is in progress.
+
+Alerts
+------
+
+Watchlists may have an escalation policy, which is checked hourly: a list of
+stages, each with a number of days and the notifiers to alert once an expiration
+is that close. For example, post to Slack at 30 days and trigger a PagerDuty
+incident at 7. Only the notifiers of the latest stage reached are alerted.
+Failed checks are sent to the first stage, and acknowledged hosts aren't
+alerted at all.
+
+Each check is alerted once per stage it reaches, not every hour, and the
+notifiers are told when the alert is resolved, e.g. because the certificate was
+renewed. Use a file store to remember what was sent across restarts.
+
+With notifyChanges set, the first stage is also told when a certificate or
+domain is renewed, moves earlier, or the certificate is replaced by another
+one, even one that expires at the same time. Changes are not PagerDuty
+incidents, so PagerDuty notifiers are not told about them.
+
+To push alerts to your own service, use a webhook notifier. Each alert is
+posted to its URL as JSON:
+
+{"watchlist":"prod","host":"example.com","type":"certificate","expires":"2020-12-02T12:00:00Z","daysRemaining":7,"message":"example.com: certificate expires in 7 days, on 2020-12-02"}
+
+Email notifiers send alerts through an SMTP server, with one message to each
+recipient. A recipient may list the hosts they look after, to be sent only the
+alerts for those. The subject and body can be changed with Go templates.
+
+Monitoring
+----------
+
+A server can check a list of hosts, and the hosts of any watchlists, on an
+interval (every 15 minutes by default) in the background. Requests for those
+hosts are then answered immediately from the latest results rather than by
+checking, with Cached set in the details. /monitor says when the last round
+of checks ran and how many failed.
+
+History
+-------
+
+The result of each check is recorded, and /history/<host> is what was found
+each time, as JSON, with the renewals (an expiration moving later),
+regressions (moving earlier, e.g. because an old certificate was deployed
+again) and replacements (a different certificate that expires at the same
+time) among them. The history is kept in memory unless the server is
+configured with a SQLite database, which records every check.
+
+$ curl https://expire.sh/history/example.com
+
+With the "changes" parameter, each host in a JSON response includes its
+changes, and a calendar has an event on the day each was seen.
+
+$ curl -H "Accept: application/json" "https://expire.sh/example.com?changes"
+
+To plot the history on a Grafana dashboard, add a JSON data source (the
+simple-json-datasource plugin) with the URL https://expire.sh/grafana/<watchlist>.
+Its targets are "<host> certificate" and "<host> domain", the days remaining at
+each check, and "expirations", a table of the watchlist's current results.
+Annotations mark renewals, regressions and expirations, for every host in the
+watchlist or just the one named in the annotation's query.
+
+Reports
+-------
+
+For management and compliance reviews, /report/<watchlist> is an HTML report of
+everything in the watchlist that expires in the next 90 days, what was renewed
+in the last 30, and checks that have been failing for over a day. The "horizon"
+and "period" parameters change the 90 and 30 days. Reports can also be generated
+on a cron schedule, e.g. weekly or monthly, and uploaded to object storage.
+
+Watchlists are also available as read-only CalDAV calendars, for calendar
+programs that sync with CalDAV rather than subscribing to a URL. Add a CalDAV
+account with the server https://expire.sh/caldav/ and each watchlist appears as
+a calendar.
+
+API Keys
+--------
+
+If the server is configured with API keys, give yours as a bearer token with
+each request, or as the "key" parameter for programs that can't send headers,
+like calendars. A server may require a key to check any hosts. Requests are
+then recorded in an audit log: who made each request, when, and for which
+hosts or watchlist. Admin keys can read the log at /audit.
+
+$ curl -H "Authorization: Bearer $KEY" https://expire.sh/audit
+
+Admin keys can also read usage statistics at /stats: requests by format, hosts
+checked, the cache hit rate, and whois failures by top level domain. The failure
+rate and latency of whois lookups for each top level domain, and of TLS checks
+for each network, show when a registry or network is the problem rather than
+expire.sh. The same statistics are available for Prometheus at /metrics, along
+with responses by status code and the number of checks in flight.
+
+Keys may have a quota of hosts checked per hour and per day. Requests that would
+exceed it get '429 Too Many Requests', with a Retry-After header saying when the
+quota resets. Your usage is available at /quota.
+
+$ curl -H "Authorization: Bearer $KEY" https://expire.sh/quota
+
+Detail Pages
+------------
+
+Each calendar event links to a page at /detail/<host> showing everything known
+about the host: the certificate chain, the whois record and the expiration
+candidates found in it, and the results of recent checks.
+
+Acknowledging Problems
+----------------------
+
+When a problem is already being dealt with, e.g. a certificate expires next week
+but its renewal is scheduled, acknowledge it until a date to stop it being
+reported. Until then the host is reported as "acknowledged": it is left out of
+quiet output and doesn't affect the status code. Acknowledging requires an admin
+API key, so servers without API keys don't accept acknowledgements. Detail pages
+opened with an admin key have a form for this too.
+
+$ curl -H "Authorization: Bearer $KEY" -d until=2019-06-30 -d reason="renewal scheduled" \
+    https://expire.sh/ack/example.com
+$ curl https://expire.sh/ack/example.com
+$ curl -H "Authorization: Bearer $KEY" -X DELETE https://expire.sh/ack/example.com
+
+Warnings
+--------
+
+Problems that don't cause a certificate or domain to fail, but which you probably 
+want to know about, are reported as warnings. For example, a warning is reported for 
+certificates valid for longer than the 398 days permitted by the CA/Browser Forum,
+which are rejected by many modern clients.
 
 Status Code
 -----------
@@ -80,29 +690,148 @@ means that we were not able to check at least one of the domains you specified.
 code '417 Expectation Failed' means that at least one of the domains or certificates
 you provided expires soon (default: within 30 days, modify with the ttl query parameter)
 
+Some registries, and many privacy services, redact the expiration date from whois
+records. These domains are reported as "withheld" rather than as an error, since
+there's nothing wrong with them. You'll have to check with your registrar to find
+out when they expire.
+
 Note: The status code never changes for iCal responses because that would mess up
 calendar programs.
 
+Some HTTP clients treat anything but 200 as a failure and throw the response
+away. For them, "status=always200" always responds '200 OK', and the status is
+in the body instead: overallStatus in the summary of a JSON response is error,
+expiring or ok. "status=strict" is the default, unless the server is configured
+otherwise.
+
+$ curl "https://expire.sh/json/example.com?status=always200"
+
+For health checks that only need the status, a HEAD request checks the hosts
+without sending the results. The X-Expires-Soon and X-Check-Errors headers
+count the hosts that expire soon and that couldn't be checked:
+
+$ curl -I https://expire.sh/example.com,example.net
+HTTP/1.1 417 Expectation Failed
+X-Check-Errors: 0
+X-Expires-Soon: 1
+
+Responses that are '200 OK' have an ETag, and a Last-Modified time from when
+the hosts were last checked. A client that polls, like a calendar program, can
+send them back in If-None-Match or If-Modified-Since, and is told '304 Not
+Modified' instead of downloading the same results again. The ETag changes when
+the results do, not as the time left until each expiration counts down.
+
 Parameters
 ----------
 
 You can all the "ttl" parameter to redefine what "soon" means with respect to 
-expiration.
+expiration. It is a number with a unit: d for days, w for weeks, mo for months
+(of 30 days) or y for years (of 365), or h, m or s. Units may be combined, as in
+1y6mo.
 
 $ curl -v https://expire.sh/text/example.com?ttl=1y
 
 You can also use the "quiet" parameter to suppress results for any domain or 
 certificate that doesn't expire soon, which can be useful for use with a cron job.
+For calendars, it leaves out the events for anything that doesn't expire soon,
+so only imminent expirations and failures are shown.
 
 $ curl -v https://expire.sh/text/example.com?ttl=60d&quiet
 
+A fixed window doesn't work well for short lived certificates, such as those 
+issued by Let's Encrypt. The "lifetime" parameter also considers a certificate to 
+be expiring soon once the given percentage of its validity period has elapsed.
+
+$ curl -v https://expire.sh/text/example.com?lifetime=80&quiet
+
+For JSON and CSV responses, the "fields" parameter selects which fields are
+returned, which keeps responses small when checking many hosts. Available fields
+are name, certExpires, certNotBefore, certLifetimeElapsed, certError,
+certErrorCode, tlsVersion, cipherSuite, domain, domainExpires, domainError,
+domainErrorCode, and daysRemaining.
+
+$ curl https://expire.sh/csv/example.com,example.net?fields=name,daysRemaining
+
+Dashboards that poll often can ask /graphql for just what they show. The
+expirations query takes hosts or a watchlist, and optionally ttl, lifetime,
+quiet and status (a list of ok, expiring, error, withheld and acknowledged) to
+filter the results. Any of the fields above, and status, may be selected.
+
+$ curl --data '{"query":"{ expirations(hosts: [\"example.com\"]) { name domainExpires } }"}' https://expire.sh/graphql
+
+An OpenAPI 3 description of the JSON API, from which clients can be generated,
+is at /openapi.json. JSON responses include its version as schemaVersion, which
+changes only when fields are removed or change meaning.
+
+JSON responses also say how long remains until each expiration, in
+CertificateExpiresIn and DomainExpiresIn (seconds) and CertificateDaysRemaining
+and DomainDaysRemaining, and give each as a Unix timestamp in
+CertificateExpiresUnix and DomainExpiresUnix.
+
+JSON responses begin with a summary of every host checked, so that a script can
+look at one field rather than every result: the number of hosts, how many are
+failing or expiring soon, the soonest expiration, and overallStatus, which is
+error, expiring or ok as the status code is 502, 417 or 200.
+
+{"schemaVersion":"2","summary":{"total":2,"status":{...},"failing":0,"expiringSoon":1,"overallStatus":"expiring",...},"expirations":[...]}
+
+The "summary" parameter replaces the per-host results with just the summary.
+
+$ curl https://expire.sh/json/example.com,example.net?summary
+
+Responses include Cache-Control and Expires headers so that CDNs and calendar
+programs cache them appropriately: 6 hours for iCal, and 5 minutes for the other
+formats. Responses that include an error are cached for at most a minute. The
+"maxage" parameter overrides this, in seconds or as a duration; use maxage=0 to
+disable caching.
+
+$ curl -v https://expire.sh/ical/example.com?maxage=24h
+
+The "concurrency" parameter sets how many checks run at once, up to 16 (default:
+8). Lower it to go easy on a fragile environment when checking a long list of
+hosts. Checks that haven't started by the time the request is cancelled or times
+out are reported as errors rather than run.
+
+$ curl https://expire.sh/text/example.com,example.net?concurrency=1
+
+Each certificate and domain check gives up after 15 seconds. The "timeout"
+parameter changes this, up to a minute, e.g. to give a slow whois server longer:
+
+$ curl https://expire.sh/text/example.com?timeout=45s
+
+PGP Keys and S/MIME Certificates
+--------------------------------
+
+Signing keys expire too. Prefix an email address or key fingerprint with "pgp:" to
+check when the key, or any of its subkeys, expires. Keys for email addresses are
+found with WKD, falling back to the keys.openpgp.org keyserver.
+
+$ curl https://expire.sh/text/pgp:alice@example.com,example.com
+
+Similarly, prefix an email address with "smimea:" to check the S/MIME certificate
+published for it in DNS with an SMIMEA record.
+
+$ curl https://expire.sh/text/smimea:alice@example.com
+
+TUF Repositories
+----------------
+
+Updaters that use TUF stop working when the repository's metadata expires. Prefix
+the URL of a repository's metadata with "tuf:" to check when the first of its root,
+timestamp, snapshot and targets metadata expires.
+
+$ curl https://expire.sh/text/tuf:example.com/metadata
+
 Issues
 ------
 
 Source and issue tracker at https://github.com/crewjam/expire-sh. 
 
-* We have to do a bit of hacky text parsing to figure out when a domain expires,
-  which is certainly incomplete. If you encounter domains whose expiration dates
+* Domain expirations come from RDAP where the registry has an RDAP server. For
+  the rest, we have to do a bit of hacky text parsing of whois records to figure
+  out when a domain expires, which is certainly incomplete. When a registry's
+  whois record refers to the registrar's whois server, the referral is
+  followed. If you encounter domains whose expiration dates
   don't parse correctly, please file a bug (or better yet submit a PR!)
 
 `
@@ -114,13 +843,27 @@ func (s *Server) serveIndex(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, indexText)
 }
 
+// Expiration is the result of checking a host, as served: an
+// expire.Expiration with the server's acknowledgements and details.
 type Expiration struct {
-	Name               string
-	CertificateExpires time.Time
-	CertificateError   error
-	Domain             string
-	DomainExpires      time.Time
-	DomainError        error
+	Name                 string
+	CertificateExpires   time.Time
+	CertificateNotBefore time.Time
+	CertificateError     error
+	CertificateChain     []expire.ChainCertificate  `json:",omitempty"` // leaf first
+	CertificateProblems  []expire.ValidationProblem `json:",omitempty"` // why the certificate isn't valid
+	CAA                  []string                   `json:",omitempty"` // with the caa parameter
+	Addresses            []AddressExpiration        `json:",omitempty"` // with the allips parameter
+	DefaultCertificate   *DefaultCertificate        `json:",omitempty"` // with the nosni parameter
+	TLSVersion           string                     `json:",omitempty"` // negotiated, e.g. TLS 1.3
+	CipherSuite          string                     `json:",omitempty"`
+	Domain               string
+	DomainExpires        time.Time
+	DomainError          error
+	Warnings             []string         `json:",omitempty"`
+	Acknowledgement      *Acknowledgement `json:",omitempty"`
+	Changes              []HistoryChange  `json:",omitempty"` // with the changes parameter
+	Details              *Details         `json:",omitempty"`
 }
 
 func (e Expiration) Text() string {
@@ -133,122 +876,578 @@ func (e Expiration) Text() string {
 	if e.DomainError != nil {
 		domainStr = e.DomainError.Error()
 	}
-	return strings.Join([]string{
+	return strings.Join(append([]string{
 		e.Name,
 		certStr,
 		e.Domain,
 		domainStr,
-	}, "\t")
+	}, e.Warnings...), "\t")
 }
 
-func (e Expiration) OK(soon time.Time) bool {
+func (e Expiration) OK(t thresholds) bool {
+	if e.Acknowledgement != nil {
+		return true
+	}
 	if e.CertificateError != nil {
 		return false
 	}
-	if e.CertificateExpires.Before(soon) {
+	if t.CertificateSoon(e) {
 		return false
 	}
 	if e.DomainError != nil {
-		return false
+		// there's nothing the user can do about a registry that doesn't
+		// publish expiration dates
+		return expire.IsExpiryWithheld(e.DomainError)
 	}
-	if e.DomainExpires.Before(soon) {
+	if t.DomainSoon(e) {
 		return false
 	}
 	return true
 }
 
-func getExpirations(ctx context.Context, hostnames []string) []Expiration {
+// Failed returns true if the certificate could not be checked, or if the
+// domain could not be checked for a reason other than the registry
+// withholding the expiration date.
+func (e Expiration) Failed() bool {
+	if e.CertificateError != nil {
+		return true
+	}
+	return e.DomainError != nil && !expire.IsExpiryWithheld(e.DomainError)
+}
+
+// Status returns StatusError if either check failed, StatusExpiring if
+// either the certificate or domain expires soon, StatusWithheld if the
+// domain expiration is not published, and StatusOK otherwise. Errors and
+// expirations that have been acknowledged are StatusAcknowledged instead.
+func (e Expiration) Status(t thresholds) string {
+	if e.Acknowledgement != nil {
+		unacknowledged := e
+		unacknowledged.Acknowledgement = nil
+		status := unacknowledged.Status(t)
+		if status == StatusError || status == StatusExpiring {
+			return StatusAcknowledged
+		}
+		return status
+	}
+	if e.Failed() {
+		return StatusError
+	}
+	if !e.OK(t) {
+		return StatusExpiring
+	}
+	if e.DomainError != nil {
+		return StatusWithheld
+	}
+	return StatusOK
+}
+
+// LifetimeElapsed returns the fraction of the certificate's validity
+// period that has elapsed at now. The second return value is false if the
+// validity period is not known.
+func (e Expiration) LifetimeElapsed(now time.Time) (float64, bool) {
+	if e.CertificateError != nil || e.CertificateNotBefore.IsZero() {
+		return 0, false
+	}
+	lifetime := e.CertificateExpires.Sub(e.CertificateNotBefore)
+	if lifetime <= 0 {
+		return 0, false
+	}
+	return float64(now.Sub(e.CertificateNotBefore)) / float64(lifetime), true
+}
+
+// Soonest returns the earliest of the certificate and domain expiration
+// times, ignoring any that could not be determined. The second return
+// value is false if neither is known.
+func (e Expiration) Soonest() (time.Time, bool) {
+	var soonest time.Time
+	if e.CertificateError == nil && !e.CertificateExpires.IsZero() {
+		soonest = e.CertificateExpires
+	}
+	if e.DomainError == nil && !e.DomainExpires.IsZero() {
+		if soonest.IsZero() || e.DomainExpires.Before(soonest) {
+			soonest = e.DomainExpires
+		}
+	}
+	return soonest, !soonest.IsZero()
+}
+
+// streamExpirations checks hostnames as getExpirations does, but calls
+// found with the results for each host as soon as they are known, rather
+// than once every host has been checked. found is never called
+// concurrently.
+func streamExpirations(ctx context.Context, hostnames []string, opts checkOptions, found func(Expiration)) {
+	var mu sync.Mutex
+	concurrency := opts.Concurrency
+	opts.Concurrency = 1
+	expire.ForEach(len(hostnames), concurrency, func(i int) {
+		expirations := getExpirations(ctx, hostnames[i:i+1], opts)
+		mu.Lock()
+		defer mu.Unlock()
+		for _, exp := range expirations {
+			found(exp)
+		}
+	})
+}
+
+// getExpirations checks hostnames as expire.Check does, and also answers
+// from the cache, records the results in the history and statistics, and
+// checks targets that aren't TLS hosts, like PGP keys.
+func getExpirations(ctx context.Context, hostnames []string, opts checkOptions) []Expiration {
+	hostnames = unicodeHostnames(hostnames)
+	if opts.WWW {
+		hostnames = addWWWHosts(hostnames)
+	}
+
+	var redirectedFrom map[string]string
+	if opts.Follow {
+		hostnames, redirectedFrom = addRedirectTargets(ctx, hostnames)
+	}
+
+	now := time.Now()
 	rv := make([]Expiration, len(hostnames))
+	ctxs := make([]context.Context, len(hostnames))
+	traces := make([]*expire.Trace, len(hostnames))
+	cached := make([]bool, len(hostnames))
 	for i, hostname := range hostnames {
+		// traced checks are never answered from the cache, since the
+		// point is to see what happens
+		if !opts.Debug && !opts.Refresh {
+			exp, ok := results.Get(opts.cacheKey(hostname), now)
+			if !ok {
+				exp, ok = monitored.Get(opts.cacheKey(hostname), now)
+			}
+			if ok {
+				stats.CountHost(true)
+				rv[i] = exp
+				rv[i].Details.RedirectedFrom = redirectedFrom[hostname]
+				rv[i].Details.Cached = true
+				cached[i] = true
+				continue
+			}
+		}
+
+		stats.CountHost(false)
 		rv[i].Name = hostname
+		rv[i].Details = &Details{
+			RedirectedFrom: redirectedFrom[hostname],
+			Checked:        now,
+		}
+		ctxs[i] = ctx
+		if opts.Debug {
+			ctxs[i], traces[i] = expire.WithTrace(ctx)
+		}
 	}
 
-	for i, hostname := range hostnames {
-		rv[i].CertificateExpires, rv[i].CertificateError = getCertExpiration(ctx, hostname)
-	}
+	expire.ForEach(len(hostnames), opts.Concurrency, func(i int) {
+		if cached[i] {
+			return
+		}
+		// don't start checks that the request no longer has time for
+		if err := ctx.Err(); err != nil {
+			rv[i].CertificateError = err
+			return
+		}
+		stats.CheckStarted()
+		defer stats.CheckFinished()
+		hostname := hostnames[i]
+		start := time.Now()
+		if checker, target, ok := lookupTargetChecker(hostname); ok {
+			checkCtx, cancel := context.WithTimeout(ctxs[i], opts.Timeout)
+			defer cancel()
+			checker(checkCtx, target, opts, &rv[i])
+			rv[i].Details.CertificateCheckMillis = millisSince(start)
+			return
+		}
+
+		result, err := expire.CheckCertificate(ctxs[i], hostname, opts.Options)
+		rv[i].Details.CertificateCheckMillis = millisSince(start)
+		stats.CountTLS(tlsNetwork(result.Address, err), time.Since(start), err != nil)
+		rv[i].CertificateExpires = result.Expires
+		rv[i].CertificateNotBefore = result.NotBefore
+		rv[i].CertificateError = err
+		rv[i].Warnings = append(rv[i].Warnings, result.Warnings...)
+		rv[i].Details.TrustStores = result.TrustStores
+		rv[i].Details.CertificateValidationLevel = result.ValidationLevel
+		rv[i].CertificateChain = result.Chain
+		rv[i].TLSVersion = result.TLSVersion
+		rv[i].CipherSuite = result.CipherSuite
+		if verr, ok := err.(expire.ValidationError); ok {
+			rv[i].CertificateProblems = verr.Problems
+		}
+		if opts.AllIPs {
+			checkAllAddresses(ctxs[i], hostname, opts, &rv[i])
+		}
+		if opts.NoSNI {
+			checkDefaultCertificate(ctxs[i], hostname, opts, &rv[i], time.Now())
+		}
+		if opts.TLSPolicy && (err == nil || expire.IsValidationError(err)) {
+			warnings, _ := expire.CheckTLSPolicy(ctxs[i], hostname, opts.Options)
+			rv[i].Warnings = append(rv[i].Warnings, warnings...)
+		}
+		if err == nil {
+			rv[i].Details.CertificateLifetimeDays = int(rv[i].CertificateExpires.Sub(rv[i].CertificateNotBefore).Hours() / 24)
+			rv[i].Details.CertificateLifetimeElapsed, _ = rv[i].LifetimeElapsed(time.Now())
+		}
+		if opts.CAA {
+			host, _ := expire.SplitHostPort(hostname)
+			checkCAA(ctxs[i], host, &rv[i])
+		}
+	})
 
 	// figure out the unique domains domains
-	domains := map[string]bool{}
+	var domains []string
+	seen := map[string]bool{}
 	for i, hostname := range hostnames {
-		domain, err := publicsuffix.EffectiveTLDPlusOne(hostname)
+		if cached[i] || !isHost(hostname) {
+			continue
+		}
+		host, _ := expire.SplitHostPort(hostname)
+		domain, err := expire.EffectiveTLDPlusOne(host)
 		if err != nil {
 			continue
 		}
-		domains[domain] = true
+		if !seen[domain] {
+			seen[domain] = true
+			domains = append(domains, domain)
+		}
 		rv[i].Domain = domain
 	}
 
-	for domain := range domains {
-		domainExpires, err := getDomainExpiration(ctx, domain)
+	// each host has only one domain, so the checks for different domains
+	// never update the same result
+	expire.ForEach(len(domains), opts.Concurrency, func(d int) {
+		domain := domains[d]
+		domainCtx, domainTrace := ctx, (*expire.Trace)(nil)
+		if opts.Debug {
+			domainCtx, domainTrace = expire.WithTrace(ctx)
+		}
+		start := time.Now()
+		result, err, domainCached := expire.DomainResult{}, ctx.Err(), false
+		if entry, ok := domainResults.Get(domain, now); ok && err == nil && !opts.Debug {
+			result, err, domainCached = entry.result, entry.err, true
+		}
+		if err == nil && !domainCached {
+			checkCtx, cancel := context.WithTimeout(domainCtx, opts.Timeout)
+			result, err = expire.CheckDomain(checkCtx, domain)
+			cancel()
+			stats.CountWhois(domain, time.Since(start), err != nil && !expire.IsExpiryWithheld(err))
+			if err == expire.ErrNoExpiration {
+				logNoExpiration(domain, result.Whois)
+			}
+			if !opts.Debug {
+				domainResults.Set(domain, result, err, now)
+			}
+		}
+		elapsed := millisSince(start)
 		for i := range rv {
-			if rv[i].Domain == domain {
+			if !cached[i] && rv[i].Domain == domain {
+				if domainTrace != nil {
+					traces[i].Add(domainTrace.Lines())
+				}
 				rv[i].DomainError = err
-				rv[i].DomainExpires = domainExpires
+				rv[i].DomainExpires = result.Expires
+				rv[i].Details.Whois = truncateRaw(result.Whois)
+				rv[i].Details.DomainCheckMillis = elapsed
+				rv[i].Details.DomainCached = domainCached
+				rv[i].Details.DomainSource = result.Source
+				rv[i].Details.DomainRetries = result.Retries
+				rv[i].Details.DomainCandidates = result.Candidates
+				rv[i].Details.DomainConfidence = result.Confidence
 			}
 		}
+	})
+
+	for i := range rv {
+		if traces[i] != nil {
+			rv[i].Details.Trace = traces[i].Lines()
+		}
+		if cached[i] {
+			continue
+		}
+		checkHistory.Record(rv[i], now)
+
+		// failures are usually transient, so they are checked again next time
+		if !opts.Debug && !rv[i].Failed() {
+			results.Set(opts.cacheKey(hostnames[i]), rv[i], now)
+		}
 	}
 	return rv
 }
 
-func (s *Server) serveExpirationsJSON(w http.ResponseWriter, r *http.Request, expirations []Expiration) {
+// serveExpirationsJSON writes expirations as JSON, preceded by summary
+// unless it is nil.
+func (s *Server) serveExpirationsJSON(w http.ResponseWriter, r *http.Request, expirations []Expiration, fields []field, summary *Summary) {
 	w.Header().Add("Content-Type", "application/json")
+	now := time.Now()
+	rows := make([]interface{}, len(expirations))
+	for i, exp := range expirations {
+		rows[i] = jsonRow(exp, fields, now)
+	}
 	json.NewEncoder(w).Encode(struct {
-		Expirations []Expiration `json:"expirations"`
+		SchemaVersion string        `json:"schemaVersion"`
+		Summary       *Summary      `json:"summary,omitempty"`
+		Expirations   []interface{} `json:"expirations"`
 	}{
-		Expirations: expirations,
+		SchemaVersion: schemaVersion,
+		Summary:       summary,
+		Expirations:   rows,
 	})
 }
 
+// jsonRow returns what is encoded for exp in JSON responses: the selected
+// fields, or all of them if fields is nil.
+func jsonRow(exp Expiration, fields []field, now time.Time) interface{} {
+	if fields == nil {
+		return newJSONExpiration(exp, now)
+	}
+	row := map[string]interface{}{}
+	for _, f := range fields {
+		row[f.Name] = f.Value(exp, now)
+	}
+	return row
+}
+
+// jsonExpiration is an Expiration as served in JSON, with how long
+// remains until each expiration and when it is as a Unix timestamp, so
+// that consumers don't have to do date arithmetic. They are left out when
+// the expiration isn't known. Errors, which don't encode, are replaced by
+// a CheckError.
+type jsonExpiration struct {
+	Expiration
+	CertificateError         *CheckError
+	DomainError              *CheckError
+	CertificateExpiresIn     *int64 `json:",omitempty"` // seconds, negative once expired
+	CertificateDaysRemaining *int   `json:",omitempty"`
+	CertificateExpiresUnix   *int64 `json:",omitempty"`
+	DomainExpiresIn          *int64 `json:",omitempty"`
+	DomainDaysRemaining      *int   `json:",omitempty"`
+	DomainExpiresUnix        *int64 `json:",omitempty"`
+}
+
+func newJSONExpiration(exp Expiration, now time.Time) jsonExpiration {
+	rv := jsonExpiration{
+		Expiration:       exp,
+		CertificateError: newCheckError(exp.CertificateError),
+		DomainError:      newCheckError(exp.DomainError),
+	}
+	if !exp.CertificateExpires.IsZero() {
+		rv.CertificateExpiresIn, rv.CertificateDaysRemaining, rv.CertificateExpiresUnix = remaining(now, exp.CertificateExpires)
+	}
+	if exp.DomainError == nil && !exp.DomainExpires.IsZero() {
+		rv.DomainExpiresIn, rv.DomainDaysRemaining, rv.DomainExpiresUnix = remaining(now, exp.DomainExpires)
+	}
+	return rv
+}
+
+// remaining returns the seconds and whole days from now until t, and t as
+// a Unix timestamp.
+func remaining(now, t time.Time) (seconds *int64, days *int, unix *int64) {
+	s := int64(t.Sub(now) / time.Second)
+	d := daysUntil(now, t)
+	u := t.Unix()
+	return &s, &d, &u
+}
+
 func (s *Server) serveExpirationsText(w http.ResponseWriter, r *http.Request, expirations []Expiration) {
 	w.Header().Add("Content-Type", "text/plain")
 	for _, exp := range expirations {
-		fmt.Fprintln(w, exp.Text())
+		writeExpirationText(w, exp)
 	}
 }
 
+// writeExpirationText writes the line for exp, followed by its trace if
+// it has one.
+func writeExpirationText(w io.Writer, exp Expiration) {
+	fmt.Fprintln(w, exp.Text())
+	if exp.Details != nil {
+		for _, line := range exp.Details.Trace {
+			fmt.Fprintln(w, "  "+line)
+		}
+	}
+}
+
+// streamText returns true if the text response to r, for hostnames, is
+// written as each host is checked, which the stream parameter asks for.
+// Otherwise scripts that want the status code, or the hosts in the order
+// they were given, would lose them. A single host has nothing to stream,
+// and quiet responses are for scripts, as are HEAD requests. Sorted
+// responses can't be written until every host has been checked.
+func streamText(contentType string, r *http.Request, hostnames []string) bool {
+	query := r.URL.Query()
+	return contentType == "text/plain" && len(hostnames) > 1 && r.Method != "HEAD" &&
+		query["stream"] != nil && query["quiet"] == nil && query["summary"] == nil && query.Get("sort") == ""
+}
+
+// serveExpirationsTextStream checks hostnames and writes the line for
+// each as soon as it has been checked, in the order they finish, so that
+// progress through a long list can be seen. The status is always 200 OK.
+func (s *Server) serveExpirationsTextStream(w http.ResponseWriter, r *http.Request, hostnames []string, opts checkOptions) {
+	w.Header().Add("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+
+	details := r.URL.Query()["details"] != nil || opts.Debug
+	streamExpirations(r.Context(), hostnames, opts, func(exp Expiration) {
+		if !details {
+			exp.Details = nil
+		}
+		writeExpirationText(w, exp)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	})
+}
+
 type Expirations []Expiration
 
+// calendarEvent is an event in the calendar rendering of expirations: a
+// certificate or domain expiration, or a failure to check one, which is
+// shown on the day it happened.
+type calendarEvent struct {
+	UID         string
+	Date        time.Time
+	Summary     string
+	Description string
+	URL         string // of the detail page for the host, if known
+	Failure     bool   // the event is a failure to check, not an expiration
+	Change      bool   // the event is a change that was seen, not an expiration
+}
+
+// calendarEvents returns the events for expirations, as of now, and for
+// their Changes on the day each was seen. If baseURL is not empty, each
+// event links to the host's detail page. If quiet is not nil, expirations
+// that aren't soon by its thresholds are left out, so only failures and
+// imminent expirations are shown.
+func calendarEvents(expirations []Expiration, now time.Time, baseURL string, quiet *thresholds) []calendarEvent {
+	var rv []calendarEvent
+	for _, exp := range expirations {
+		var detailURL string
+		if baseURL != "" {
+			detailURL = baseURL + detailPrefix + url.PathEscape(exp.Name)
+		}
+
+		// nothing to show for targets that don't expire, e.g. a PGP key
+		// without an expiration
+		if exp.CertificateError != nil || !exp.CertificateExpires.IsZero() {
+			event := calendarEvent{UID: exp.Name + "@certificates.expire.sh", URL: detailURL}
+			include := true
+			if exp.CertificateError == nil {
+				// with the chain, the leaf and each intermediate are
+				// separate events, so it's clear which expires
+				leaf := exp
+				if len(exp.CertificateChain) > 0 {
+					leaf.CertificateExpires = exp.CertificateChain[0].NotAfter
+				}
+				expires := leaf.CertificateExpires
+				event.Date = expires
+				event.Description = fmt.Sprintf("%s certificate expires", exp.Name)
+				event.Summary = fmt.Sprintf("%s certificate expires on %s", exp.Name, expires)
+				include = quiet == nil || quiet.CertificateSoon(leaf)
+			} else {
+				event.Date = now
+				event.Failure = true
+				event.Description = fmt.Sprintf("%s: error checking certificate", exp.Name)
+				event.Summary = fmt.Sprintf("checking certificate for %s: %s", exp.Name,
+					exp.CertificateError)
+			}
+			if include {
+				rv = append(rv, event)
+			}
+		}
+		if exp.CertificateError == nil && len(exp.CertificateChain) > 1 {
+			for _, cert := range exp.CertificateChain[1:] {
+				if quiet != nil && !cert.NotAfter.Before(quiet.Soon) {
+					continue
+				}
+				rv = append(rv, calendarEvent{
+					UID:         cert.Fingerprint + "." + exp.Name + "@chain.expire.sh",
+					URL:         detailURL,
+					Date:        cert.NotAfter,
+					Description: fmt.Sprintf("%s intermediate certificate expires", exp.Name),
+					Summary: fmt.Sprintf("The intermediate certificate %s in the chain for %s expires on %s",
+						cert.Subject, exp.Name, cert.NotAfter),
+				})
+			}
+		}
+
+		for _, change := range exp.Changes {
+			rv = append(rv, calendarEvent{
+				UID:         fmt.Sprintf("%d.%s.%s@changes.expire.sh", change.Time.Unix(), change.Check, exp.Name),
+				URL:         detailURL,
+				Date:        change.Time,
+				Change:      true,
+				Description: fmt.Sprintf("%s %s %s", exp.Name, change.Check, change.Kind),
+				Summary:     fmt.Sprintf("%s: %s", exp.Name, change),
+			})
+		}
+
+		// targets that aren't TLS hosts don't have a domain
+		if exp.Domain == "" && exp.DomainError == nil {
+			continue
+		}
+		if quiet != nil && exp.DomainError == nil && !quiet.DomainSoon(exp) {
+			continue
+		}
+
+		event := calendarEvent{UID: exp.Name + "@domain.expire.sh", URL: detailURL}
+		if exp.DomainError == nil {
+			event.Date = exp.DomainExpires
+			event.Description = fmt.Sprintf("%s domain expires", exp.Name)
+			event.Summary = fmt.Sprintf("The domain registration for %s (%s) expires on %s",
+				exp.Name, exp.Domain, exp.DomainExpires)
+		} else {
+			event.Date = now
+			event.Failure = true
+			event.Description = fmt.Sprintf("%s: error checking domain expiration", exp.Name)
+			event.Summary = fmt.Sprintf("checking domain expiration for %s: %s", exp.Name,
+				exp.DomainError)
+		}
+		rv = append(rv, event)
+	}
+	return rv
+}
+
 func (expirations Expirations) EmitICal() goics.Componenter {
+	return Calendar{Expirations: expirations}.EmitICal()
+}
+
+// Calendar is the iCal rendering of expirations in which each event links
+// to the detail page for its host under BaseURL. Each expiration has a
+// reminder for each of Alarms, that long before it. If Quiet is not nil,
+// only failures and expirations that are soon by its thresholds are shown.
+type Calendar struct {
+	Expirations []Expiration
+	BaseURL     string
+	Alarms      []time.Duration
+	Quiet       *thresholds
+}
+
+func (cal Calendar) EmitICal() goics.Componenter {
 	c := goics.NewComponent()
 	c.SetType("VCALENDAR")
 	c.AddProperty("CALSCAL", "GREGORIAN")
 	c.AddProperty("PRODID;X-RICAL-TZSOURCE=TZINFO", "-//tmpo.io")
 
-	now := time.Now()
-	for _, exp := range expirations {
+	for _, event := range calendarEvents(cal.Expirations, time.Now(), cal.BaseURL, cal.Quiet) {
 		s := goics.NewComponent()
 		s.SetType("VEVENT")
-		s.AddProperty("UID", exp.Name+"@certificates.expire.sh")
-		if exp.CertificateError == nil {
-			s.AddProperty(goics.FormatDateField("DTEND", exp.CertificateExpires))
-			s.AddProperty(goics.FormatDateField("DTSTART", exp.CertificateExpires))
-			s.AddProperty("DESCRIPTION", fmt.Sprintf("%s certificate expires", exp.Name))
-			s.AddProperty("SUMMARY", fmt.Sprintf("%s certificate expires on %s", exp.Name,
-				exp.CertificateExpires))
-		} else {
-			s.AddProperty(goics.FormatDateField("DTEND", now))
-			s.AddProperty(goics.FormatDateField("DTSTART", now))
-			s.AddProperty("DESCRIPTION", fmt.Sprintf("%s: error checking certificate", exp.Name))
-			s.AddProperty("SUMMARY", fmt.Sprintf("checking certificate for %s: %s", exp.Name,
-				exp.CertificateError))
+		s.AddProperty("UID", event.UID)
+		s.AddProperty(goics.FormatDateField("DTEND", event.Date))
+		s.AddProperty(goics.FormatDateField("DTSTART", event.Date))
+		s.AddProperty("DESCRIPTION", event.Description)
+		s.AddProperty("SUMMARY", event.Summary)
+		if event.URL != "" {
+			s.AddProperty("URL", event.URL)
 		}
-		c.AddComponent(s)
-
-		s = goics.NewComponent()
-		s.SetType("VEVENT")
-		s.AddProperty("UID", exp.Name+"@domain.expire.sh")
-		if exp.DomainError == nil {
-			s.AddProperty(goics.FormatDateField("DTEND", exp.DomainExpires))
-			s.AddProperty(goics.FormatDateField("DTSTART", exp.DomainExpires))
-			s.AddProperty("DESCRIPTION", fmt.Sprintf("%s domain expires", exp.Name))
-			s.AddProperty("SUMMARY", fmt.Sprintf("The domain registration for %s (%s) expires on %s",
-				exp.Name, exp.Domain, exp.DomainExpires))
-		} else {
-			s.AddProperty(goics.FormatDateField("DTEND", now))
-			s.AddProperty(goics.FormatDateField("DTSTART", now))
-			s.AddProperty("DESCRIPTION", fmt.Sprintf("%s: error checking domain expiration", exp.Name))
-			s.AddProperty("SUMMARY", fmt.Sprintf("checking domain expiration for %s: %s", exp.Name,
-				exp.DomainError))
+		if !event.Failure && !event.Change {
+			for _, before := range cal.Alarms {
+				a := goics.NewComponent()
+				a.SetType("VALARM")
+				a.AddProperty("ACTION", "DISPLAY")
+				a.AddProperty("TRIGGER", formatAlarmTrigger(before))
+				a.AddProperty("DESCRIPTION", event.Description)
+				s.AddComponent(a)
+			}
 		}
 		c.AddComponent(s)
 	}
@@ -258,65 +1457,211 @@ func (expirations Expirations) EmitICal() goics.Componenter {
 }
 
 func (s *Server) serveExpirationsIcal(w http.ResponseWriter, r *http.Request, expirations []Expiration) {
+	var alarms []time.Duration
+	var quiet *thresholds
+	if r != nil {
+		var err error
+		if alarms, err = parseAlarms(r.FormValue("alarm")); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintln(w, "Cannot parse alarm parameter:", err.Error())
+			return
+		}
+		if r.URL.Query()["quiet"] != nil {
+			t, err := parseThresholds(r)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintln(w, err.Error())
+				return
+			}
+			quiet = &t
+		}
+	}
 	w.Header().Set("Content-type", "text/calendar")
 	w.Header().Set("charset", "utf-8")
 	w.Header().Set("Content-Disposition", "inline")
 	w.Header().Set("filename", "calendar.ics")
-	goics.NewICalEncode(w).Encode(Expirations(expirations))
+	goics.NewICalEncode(w).Encode(Calendar{Expirations: expirations, BaseURL: s.baseURL(r), Alarms: alarms, Quiet: quiet})
+}
+
+// defaultMaxHosts is the most hosts a request may check, unless the
+// configuration says otherwise.
+const defaultMaxHosts = 100
+
+// hostCountError returns an error if a request may not check n hosts,
+// because that would let anyone use the server to scan with.
+func (s *Server) hostCountError(n int) error {
+	max := s.Config.MaxHosts
+	if max == 0 {
+		max = defaultMaxHosts
+	}
+	if max < 0 || n <= max {
+		return nil
+	}
+	return fmt.Errorf("Too many hosts: a request may check at most %d, not %d, "+
+		"counting those that www and follow may add. Check them in batches, after "+
+		"POSTing up to %d at a time to /prefetch so that each batch is answered "+
+		"from the cache, or add them to a watchlist.", max, n, maxPrefetchHosts)
+}
+
+// checkHostCount responds with 413 Request Entity Too Large and returns
+// false if a request may not check n hosts.
+func (s *Server) checkHostCount(w http.ResponseWriter, n int) bool {
+	if err := s.hostCountError(n); err != nil {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		fmt.Fprintln(w, err.Error())
+		return false
+	}
+	return true
 }
 
 func (s *Server) serveExpirations(w http.ResponseWriter, r *http.Request) {
 	hostnames := strings.Split(strings.Trim(r.URL.Path, "/"), ",")
-	expirations := getExpirations(r.Context(), hostnames)
+	if !s.checkHostCount(w, len(hostnames)) {
+		return
+	}
+	s.serveHostnames(w, r, hostnames)
+}
+
+// serveHostnames checks hostnames and writes the results in the format
+// requested by r.
+func (s *Server) serveHostnames(w http.ResponseWriter, r *http.Request, hostnames []string) {
+	opts, err := s.parseCheckOptions(r)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		fmt.Fprintln(w, err.Error())
+		return
+	}
 
+	// event streams are only sent to clients that ask for them, so they
+	// come last, after anything a wildcard would match first
 	contentType := httputil.NegotiateContentType(r, []string{
 		"application/json",
 		"text/plain",
+		"text/csv",
 		"text/calendar",
+		"text/prometheus",
+		"text/html",
+		"text/event-stream",
 	}, "text/plain")
 
-	ttl := time.Hour * 24 * 30
-	if ttlStr := r.FormValue("ttl"); ttlStr != "" {
-		var err error
-		ttl, err = time.ParseDuration(ttlStr)
+	t, err := parseThresholds(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, err.Error())
+		return
+	}
+
+	var fields []field
+	if fieldsStr := r.FormValue("fields"); fieldsStr != "" {
+		fields, err = parseFields(fieldsStr)
 		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
-			fmt.Fprintln(w, "Cannot parse ttl parameter:", err.Error())
+			fmt.Fprintln(w, "Cannot parse fields parameter:", err.Error())
 			return
 		}
 	}
 
-	soon := time.Now().Add(-1 * ttl)
+	maxAge, err := s.cacheMaxAge(r, contentType)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, err.Error())
+		return
+	}
+
+	order, err := parseSort(r.FormValue("sort"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, err.Error())
+		return
+	}
+
+	statusCodes, err := s.statusCodes(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, err.Error())
+		return
+	}
+
+	n := checkedHostCount(hostnames, opts)
+	if !s.checkHostCount(w, n) || !s.chargeQuota(w, r, n) {
+		return
+	}
+	stats.CountRequest(contentType)
+	if contentType == "text/event-stream" && r.Method != "HEAD" {
+		s.serveExpirationEvents(w, r, hostnames, opts, t, fields)
+		return
+	}
+	if streamText(contentType, r, hostnames) {
+		// some of the hosts may fail
+		if maxAge > maxErrorCacheMaxAge {
+			maxAge = maxErrorCacheMaxAge
+		}
+		setCacheHeaders(w, t.Now, maxAge)
+		s.serveExpirationsTextStream(w, r, hostnames, opts)
+		return
+	}
+	expirations := getExpirations(r.Context(), hostnames, opts)
+	s.applyAcknowledgements(expirations, time.Now())
+	if r.URL.Query()["changes"] != nil {
+		applyChanges(expirations)
+	}
+
 	hasError := false
 	hasExpirationSoon := false
 	for _, expiration := range expirations {
-		if expiration.CertificateError != nil {
+		switch expiration.Status(t) {
+		case StatusError:
 			hasError = true
-		} else if expiration.CertificateExpires.Before(soon) {
+		case StatusExpiring:
 			hasExpirationSoon = true
 		}
-		if expiration.DomainError != nil {
-			hasError = true
-		} else if expiration.DomainExpires.Before(soon) {
-			hasExpirationSoon = true
+	}
+	if hasError && maxAge > maxErrorCacheMaxAge {
+		maxAge = maxErrorCacheMaxAge
+	}
+	setCacheHeaders(w, t.Now, maxAge)
+	modified := lastChecked(expirations)
+
+	// details are only included in the output when requested
+	details := r.URL.Query()["details"] != nil || opts.Debug
+	raw := r.URL.Query()["raw"] != nil
+	for i := range expirations {
+		if !details {
+			expirations[i].Details = nil
+		} else if !raw {
+			expirations[i].Details.Whois = ""
 		}
 	}
 
+	// of every host, before any are left out
+	summary := summarize(expirations, t)
+	etag := resultsETag(r, contentType, expirations, t)
+	w.Header().Set("X-Expires-Soon", strconv.Itoa(summary.ExpiringSoon))
+	w.Header().Set("X-Check-Errors", strconv.Itoa(summary.Failing))
+
 	quiet := r.URL.Query()["quiet"] != nil
 	if quiet {
 		filteredExpirations := expirations[:0]
 		for _, expiration := range expirations {
-			if expiration.OK(soon) {
+			if expiration.OK(t) {
 				continue
 			}
 			filteredExpirations = append(filteredExpirations, expiration)
 		}
 		expirations = filteredExpirations
 	}
+	sortExpirations(expirations, order, t)
+
+	// the response is held until it is complete, so that clients that
+	// poll, like calendar programs, can be told when nothing has changed
+	bw := &bufferedResponse{ResponseWriter: w}
+	defer bw.send(r, etag, modified)
+	w = bw
 
 	// don't do content type detection for iCal because it would
-	// break calendar programs
-	if contentType != "text/calendar" {
+	// break calendar programs, or for Prometheus, which treats any
+	// other status as a failed scrape
+	if contentType != "text/calendar" && contentType != "text/prometheus" && statusCodes == StatusCodesStrict {
 		if hasError {
 			w.WriteHeader(http.StatusBadGateway)
 		} else if hasExpirationSoon {
@@ -324,22 +1669,205 @@ func (s *Server) serveExpirations(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if r.URL.Query()["summary"] != nil {
+		s.serveSummary(w, r, contentType, summary)
+		return
+	}
+
 	switch contentType {
 	case "application/json":
-		s.serveExpirationsJSON(w, r, expirations)
+		s.serveExpirationsJSON(w, r, expirations, fields, summary)
 		return
 	case "text/plain":
 		s.serveExpirationsText(w, r, expirations)
 		return
+	case "text/csv":
+		s.serveExpirationsCSV(w, r, expirations, fields)
+		return
 	case "text/calendar":
 		s.serveExpirationsIcal(w, r, expirations)
 		return
+	case "text/prometheus":
+		s.serveExpirationsMetrics(w, r, expirations)
+		return
+	case "text/html":
+		s.serveExpirationsHTML(w, r, expirations, t)
+		return
 	}
 
 }
 
 func main() {
-	s := NewServer()
+	config := &Config{}
+	if path := os.Getenv("EXPIRE_CONFIG"); path != "" {
+		var err error
+		config, err = LoadConfig(path)
+		if err != nil {
+			log.Fatalf("cannot load config: %s", err)
+		}
+	}
+
+	if err := loadConcurrencyEnv(config); err != nil {
+		log.Fatalf("cannot load config: %s", err)
+	}
+	if err := loadCheckTimeoutEnv(config); err != nil {
+		log.Fatalf("cannot load config: %s", err)
+	}
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+		case "check":
+			os.Exit(checkCommand(config, os.Args[2:]))
+		case "sweep":
+			os.Exit(sweepCommand(config, os.Args[2:]))
+		case "authenticode":
+			os.Exit(authenticodeCommand(os.Args[2:]))
+		case "kubeconfig":
+			os.Exit(kubeconfigCommand(os.Args[2:]))
+		default:
+			fmt.Fprintf(os.Stderr, "usage: %s [serve|check|sweep|authenticode|kubeconfig] ...\n", os.Args[0])
+			os.Exit(2)
+		}
+	}
+
+	serve(config)
+}
+
+// configureChecks applies the parts of config that change how hosts are
+// checked, for the server and the check subcommand alike.
+func configureChecks(config *Config) error {
+	switch config.ExpiryPolicy {
+	case "":
+	case expire.PolicyFirst, expire.PolicyEarliest, expire.PolicyLatest, expire.PolicyRegistry:
+		expire.ExpiryPolicy = config.ExpiryPolicy
+	default:
+		return fmt.Errorf("unknown expiry policy %q, expected first, earliest, latest or registry", config.ExpiryPolicy)
+	}
+	for _, format := range config.WhoisFormats {
+		if _, err := time.LoadLocation(format.Timezone); err != nil {
+			return fmt.Errorf("whois format for %s: %s", strings.Join(format.Suffixes, ", "), err)
+		}
+	}
+	if config.ResultCacheTTL < 0 {
+		return fmt.Errorf("result cache ttl must not be negative")
+	}
+	if config.ResultCacheTTL != 0 {
+		results = newResultCache(config.ResultCacheTTL)
+	}
+	if config.DomainCacheTTL < 0 {
+		return fmt.Errorf("domain cache ttl must not be negative")
+	}
+	if config.DomainCacheTTL != 0 {
+		domainResults = newDomainCache(config.DomainCacheTTL)
+	}
+	expire.AddWhoisFormats(config.WhoisFormats)
+	expire.AddExpirationKeywords(config.ExpirationKeywords)
+	if err := loadTrustStores(config.TrustStores); err != nil {
+		return fmt.Errorf("cannot load trust stores: %s", err)
+	}
+	return nil
+}
+
+func serve(config *Config) {
+	switch config.Logging.Privacy {
+	case "", privacyHash, privacyTruncate:
+		logPrivacy = config.Logging.Privacy
+	default:
+		log.Fatalf("unknown logging privacy mode %q, expected hash or truncate", config.Logging.Privacy)
+	}
+	if err := configureChecks(config); err != nil {
+		log.Fatal(err)
+	}
+	for _, wl := range config.Watchlists {
+		if err := wl.ValidateThresholds(); err != nil {
+			log.Fatal(err)
+		}
+		for _, m := range wl.MaintenanceWindows {
+			if err := m.Validate(); err != nil {
+				log.Fatalf("watchlist %s: %s", wl.Name, err)
+			}
+		}
+	}
+	if err := config.Monitor.Validate(config); err != nil {
+		log.Fatal(err)
+	}
+	for _, report := range config.Reports {
+		if err := report.Validate(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if !config.PublicSuffixList.Disabled {
+		url := config.PublicSuffixList.URL
+		if url == "" {
+			url = expire.DefaultPublicSuffixListURL
+		}
+		refresh := config.PublicSuffixList.Refresh
+		if refresh == 0 {
+			refresh = 24 * time.Hour
+		}
+		go expire.RefreshSuffixList(url, refresh)
+	}
+
+	if !config.RDAPBootstrap.Disabled {
+		url := config.RDAPBootstrap.URL
+		if url == "" {
+			url = expire.DefaultRDAPBootstrapURL
+		}
+		refresh := config.RDAPBootstrap.Refresh
+		if refresh == 0 {
+			refresh = 24 * time.Hour
+		}
+		go expire.RefreshRDAPBootstrap(url, refresh)
+	}
+
+	envKeys, err := envAPIKeys(os.Getenv("EXPIRE_API_KEYS"))
+	if err != nil {
+		log.Fatalf("cannot parse EXPIRE_API_KEYS: %s", err)
+	}
+	config.APIKeys = append(config.APIKeys, envKeys...)
+	if config.RequireAPIKey && len(config.APIKeys) == 0 {
+		log.Fatal("requireAPIKey is set, but there are no API keys")
+	}
+	if _, err := parseStatusCodes(config.StatusCodes); err != nil {
+		log.Fatalf("statusCodes: %s", err)
+	}
+
+	s := NewServer(config)
+	store, err := openStore(config.Store)
+	if err != nil {
+		log.Fatalf("cannot open store: %s", err)
+	}
+	s.store = store
+	checkHistory, err = openHistory(config.History)
+	if err != nil {
+		log.Fatalf("cannot open history: %s", err)
+	}
+	shared, err := openSharedCache(config.Cache)
+	if err != nil {
+		log.Fatalf("cannot open cache: %s", err)
+	}
+	results.shared, domainResults.shared = shared, shared
+	s.audit, err = openAuditLog(config.Audit)
+	if err != nil {
+		log.Fatalf("cannot open audit log: %s", err)
+	}
+	s.notifiers, err = openNotifiers(config.Notifiers)
+	if err != nil {
+		log.Fatalf("cannot configure notifiers: %s", err)
+	}
+	for _, wl := range config.Watchlists {
+		if err := checkEscalation(wl, s.notifiers); err != nil {
+			log.Fatal(err)
+		}
+	}
+	s.StartDiscovery()
+	s.StartPublishing()
+	s.StartGraphSync()
+	s.StartAlerting()
+	s.StartReports()
+	s.StartMonitoring()
 	http.Handle("/", s)
 
 	port := os.Getenv("PORT")
@@ -349,5 +1877,7 @@ func main() {
 	}
 
 	log.Printf("Listening on port %s", port)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", port), nil))
+	// h2c lets gRPC clients, which need HTTP/2, connect without TLS, e.g.
+	// behind a load balancer that terminates it
+	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", port), h2c.NewHandler(http.DefaultServeMux, &http2.Server{})))
 }
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+func TestCalendarEventsChain(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	exp := Expiration{
+		Name:               "example.com",
+		CertificateExpires: now.AddDate(0, 0, 10),
+		CertificateChain: []expire.ChainCertificate{
+			{Subject: "CN=example.com", NotAfter: now.AddDate(0, 0, 60), Fingerprint: "aaaa"},
+			{Subject: "CN=Example CA", NotAfter: now.AddDate(0, 0, 10), Fingerprint: "bbbb"},
+		},
+	}
+
+	var got []string
+	for _, event := range calendarEvents([]Expiration{exp}, now, "", nil) {
+		got = append(got, fmt.Sprintf("%s %s", event.UID, event.Date.Format("2006-01-02")))
+	}
+	want := "[example.com@certificates.expire.sh 2020-03-02 bbbb.example.com@chain.expire.sh 2020-01-12]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	// without the chain, the certificate expires when the first in the
+	// chain does
+	exp.CertificateChain = nil
+	events := calendarEvents([]Expiration{exp}, now, "", nil)
+	if len(events) != 1 || !events[0].Date.Equal(exp.CertificateExpires) {
+		t.Errorf("unexpected events %+v", events)
+	}
+}
+
+func TestCalendarEventsChanges(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	exp := Expiration{
+		Name:               "example.com",
+		CertificateExpires: now.AddDate(0, 3, 0),
+		Domain:             "example.com",
+		DomainExpires:      now.AddDate(1, 0, 0),
+		Changes: []HistoryChange{{
+			Time:            now.AddDate(0, 0, -1),
+			Check:           CheckCertificate,
+			Kind:            ChangeRenewed,
+			PreviousExpires: now.AddDate(0, 0, 10),
+			Expires:         now.AddDate(0, 3, 0),
+		}},
+	}
+	events := calendarEvents([]Expiration{exp}, now, "", nil)
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %+v", events)
+	}
+	change := events[1]
+	if !change.Change || !change.Date.Equal(now.AddDate(0, 0, -1)) || change.Description != "example.com certificate renewed" {
+		t.Errorf("unexpected event %+v", change)
+	}
+	if want := "example.com: certificate renewed, expired 2020-01-12, now expires 2020-04-02"; change.Summary != want {
+		t.Errorf("expected %q, got %q", want, change.Summary)
+	}
+}
+
+func TestCalendarEventsQuiet(t *testing.T) {
+	now := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	quiet := newThresholds(now, 30*24*time.Hour)
+	expirations := []Expiration{
+		{
+			Name:               "example.com",
+			CertificateExpires: now.AddDate(0, 0, 10),
+			CertificateChain: []expire.ChainCertificate{
+				{Subject: "CN=example.com", NotAfter: now.AddDate(0, 0, 60), Fingerprint: "aaaa"},
+				{Subject: "CN=Example CA", NotAfter: now.AddDate(0, 0, 10), Fingerprint: "bbbb"},
+			},
+			Domain:        "example.com",
+			DomainExpires: now.AddDate(1, 0, 0),
+		},
+		{
+			Name:        "example.org",
+			DomainError: fmt.Errorf("whois: timeout"),
+			Domain:      "example.org",
+		},
+	}
+
+	var got []string
+	for _, event := range calendarEvents(expirations, now, "", &quiet) {
+		got = append(got, event.UID)
+	}
+	want := "[bbbb.example.com@chain.expire.sh example.org@domain.expire.sh]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestJSONExpiration(t *testing.T) {
+	now := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+	exp := newJSONExpiration(Expiration{
+		Name:               "example.com",
+		CertificateExpires: now.Add(10*24*time.Hour + time.Hour),
+		DomainError:        fmt.Errorf("whois failed"),
+		DomainExpires:      now.Add(100 * 24 * time.Hour),
+	}, now)
+	buf, err := json.Marshal(exp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"Name":"example.com"`,
+		`"CertificateExpiresIn":867600`,
+		`"CertificateDaysRemaining":10`,
+		`"CertificateExpiresUnix":1547211600`,
+	} {
+		if !strings.Contains(string(buf), want) {
+			t.Errorf("expected %s in %s", want, buf)
+		}
+	}
+	if strings.Contains(string(buf), "DomainExpiresIn") {
+		t.Errorf("expected no domain expiration in %s", buf)
+	}
+}
+
+func TestMaxHosts(t *testing.T) {
+	s := NewServer(&Config{MaxHosts: 2})
+	for _, r := range []*http.Request{
+		httptest.NewRequest("GET", "/a.example.com,b.example.com,c.example.com", nil),
+		httptest.NewRequest("POST", "/json/", strings.NewReader(`{"hosts":["a.example.com","b.example.com","c.example.com"]}`)),
+		httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query": "{ a: expirations(hosts: [\"a.example.com\", \"b.example.com\"]) { name } b: expirations(hosts: [\"c.example.com\"]) { name } }"}`)),
+		httptest.NewRequest("GET", "/example.com,b.example.com?www", nil),
+		httptest.NewRequest("GET", "/a.example.com,b.example.com?follow", nil),
+	} {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), "at most 2") {
+			t.Errorf("%s %s: expected 413, got %d %s", r.Method, r.URL, w.Code, w.Body.String())
+		}
+	}
+
+	if err := NewServer(&Config{}).hostCountError(defaultMaxHosts + 1); err == nil {
+		t.Errorf("expected the default limit to apply")
+	}
+	if err := NewServer(&Config{MaxHosts: -1}).hostCountError(defaultMaxHosts + 1); err != nil {
+		t.Errorf("expected no limit, got %s", err)
+	}
+}
+
+func TestStreamText(t *testing.T) {
+	now := time.Now()
+	s := NewServer(&Config{})
+	opts := s.defaultCheckOptions()
+	results.Set(opts.cacheKey("stream1.example.com"), Expiration{Name: "stream1.example.com", CertificateExpires: now.AddDate(0, 0, 10), Details: &Details{}}, now)
+	results.Set(opts.cacheKey("stream2.example.com"), Expiration{Name: "stream2.example.com", CertificateExpires: now.AddDate(1, 0, 0), Details: &Details{}}, now)
+
+	for _, tt := range []struct {
+		url   string
+		code  int
+		lines int
+	}{
+		{"/text/stream1.example.com,stream2.example.com?stream", http.StatusOK, 2},
+		{"/text/stream1.example.com,stream2.example.com", http.StatusExpectationFailed, 2},
+		{"/text/stream1.example.com,stream2.example.com?stream&quiet", http.StatusExpectationFailed, 1},
+		{"/text/stream1.example.com?stream", http.StatusExpectationFailed, 1},
+	} {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", tt.url, nil))
+		lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		if w.Code != tt.code || len(lines) != tt.lines {
+			t.Errorf("%s: expected %d with %d lines, got %d %q", tt.url, tt.code, tt.lines, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+// Exit codes of the check subcommand, which mirror the status codes of the
+// text and JSON responses.
+const (
+	checkExitOK       = 0
+	checkExitExpiring = 1 // something expires soon, as for 417
+	checkExitError    = 2 // something couldn't be checked, as for 502, or the usage was wrong
+)
+
+// checkCommand implements the check subcommand, which checks hosts as the
+// server would, without running it, e.g. from cron or CI. It returns the
+// exit code, which is a monitoring plugin's for the nagios format.
+func checkCommand(config *Config, args []string) int {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	format := flags.String("format", "text", "output format: text, json, csv, ical, or nagios for a Nagios or Icinga plugin")
+	ttl := durationValue(defaultTTL)
+	flags.Var(&ttl, "ttl", "report expirations within this long, e.g. 60d or 1y, as expiring soon")
+	critical := durationValue(7 * 24 * time.Hour)
+	flags.Var(&critical, "critical", "with -format nagios, report expirations within this long as critical rather than warning")
+	lifetime := flags.String("lifetime", "", "report certificates this percentage of the way through their validity period as expiring soon")
+	quiet := flags.Bool("quiet", false, "only show hosts that are expiring soon or couldn't be checked")
+	proto := flags.String("proto", "", "how to start TLS: tls, or smtp for mail servers that use STARTTLS")
+	trustStores := flags.String("truststores", "", "comma separated list of trust stores to verify certificate chains against")
+	www := flags.Bool("www", false, "also check www.example.com for each bare domain like example.com")
+	follow := flags.Bool("follow", false, "also check the hosts that each host redirects to")
+	allIPs := flags.Bool("allips", false, "check the certificate served at every address each host resolves to")
+	noSNI := flags.Bool("nosni", false, "also check the default certificate each host presents to clients without SNI")
+	tlsPolicy := flags.Bool("tlspolicy", false, "warn about hosts that accept TLS 1.0, TLS 1.1 or weak cipher suites")
+	caa := flags.Bool("caa", false, "report CAA records, and warn if they don't authorize the CA that issued a certificate")
+	concurrency := flags.Int("concurrency", 0, "number of hosts to check at once")
+	timeout := flags.Duration("timeout", 5*time.Minute, "give up on checks that haven't finished after this long")
+	checkTimeout := flags.Duration("check-timeout", 0, "give up on each certificate or domain check after this long (default 15s)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s check [flags] HOST[,HOST...] ...\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	args = parseInterspersed(flags, args)
+
+	var hostnames []string
+	for _, arg := range args {
+		for _, hostname := range strings.Split(arg, ",") {
+			if hostname = strings.TrimSpace(hostname); hostname != "" {
+				hostnames = append(hostnames, hostname)
+			}
+		}
+	}
+	if len(hostnames) == 0 {
+		flags.Usage()
+		return checkExitError
+	}
+
+	if err := configureChecks(config); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return checkExitError
+	}
+	s := NewServer(config)
+	opts := s.defaultCheckOptions()
+	if *trustStores != "" {
+		var err error
+		if opts.TrustStores, err = expire.ParseTrustStores(*trustStores); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return checkExitError
+		}
+	}
+	opts.Protocol = *proto
+	opts.WWW = *www
+	opts.Follow = *follow
+	opts.CAA = *caa
+	opts.AllIPs = *allIPs
+	opts.NoSNI = *noSNI
+	opts.TLSPolicy = *tlsPolicy
+	if *concurrency > 0 {
+		opts.Concurrency = *concurrency
+	}
+	if *checkTimeout > 0 {
+		opts.Timeout = *checkTimeout
+	}
+	t := newThresholds(time.Now(), time.Duration(ttl))
+	if *lifetime != "" {
+		var err error
+		if t.Lifetime, err = parseLifetime(*lifetime); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid lifetime %q: %s\n", *lifetime, err)
+			return checkExitError
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	expirations := getExpirations(ctx, hostnames, opts)
+	for i := range expirations {
+		expirations[i].Details = nil
+	}
+	if *format == "nagios" {
+		return writeNagios(os.Stdout, expirations, t, t.Now.Add(time.Duration(critical)))
+	}
+	rv := checkExitCode(expirations, t)
+
+	if *quiet {
+		filtered := expirations[:0]
+		for _, exp := range expirations {
+			if !exp.OK(t) {
+				filtered = append(filtered, exp)
+			}
+		}
+		expirations = filtered
+	}
+	if err := writeExpirations(os.Stdout, config, *format, expirations); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return checkExitError
+	}
+	return rv
+}
+
+// checkExitCode returns the exit code for expirations: checkExitError if
+// any couldn't be checked, otherwise checkExitExpiring if any expire soon.
+func checkExitCode(expirations []Expiration, t thresholds) int {
+	rv := checkExitOK
+	for _, exp := range expirations {
+		switch exp.Status(t) {
+		case StatusError:
+			return checkExitError
+		case StatusExpiring:
+			rv = checkExitExpiring
+		}
+	}
+	return rv
+}
+
+// parseInterspersed parses args with flags, allowing flags to follow the
+// other arguments as in "check example.com --ttl 1440h", and returns the
+// other arguments.
+func parseInterspersed(flags *flag.FlagSet, args []string) []string {
+	var rv []string
+	for {
+		flags.Parse(args)
+		rest := flags.Args()
+		// everything after -- is an argument, even if it looks like a flag
+		if n := len(args) - len(rest); n > 0 && args[n-1] == "--" {
+			return append(rv, rest...)
+		}
+		if len(rest) == 0 {
+			return rv
+		}
+		rv = append(rv, rest[0])
+		args = rest[1:]
+	}
+}
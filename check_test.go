@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseInterspersed(t *testing.T) {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	format := flags.String("format", "text", "")
+	ttl := flags.Duration("ttl", defaultTTL, "")
+	quiet := flags.Bool("quiet", false, "")
+
+	args := parseInterspersed(flags, []string{"example.com,example.org", "--ttl", "1440h", "--quiet", "example.net", "-format=json", "--", "-odd"})
+	if want := []string{"example.com,example.org", "example.net", "-odd"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("expected %q, got %q", want, args)
+	}
+	if *format != "json" || *ttl != 1440*time.Hour || !*quiet {
+		t.Errorf("unexpected flags format=%s ttl=%s quiet=%v", *format, *ttl, *quiet)
+	}
+}
+
+func TestCheckExitCode(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	th := newThresholds(now, defaultTTL)
+	ok := Expiration{Name: "example.com", CertificateExpires: now.AddDate(0, 6, 0), DomainExpires: now.AddDate(1, 0, 0)}
+	soon := Expiration{Name: "example.net", CertificateExpires: now.AddDate(0, 0, 7), DomainExpires: now.AddDate(1, 0, 0)}
+	failed := Expiration{Name: "example.org", CertificateError: fmt.Errorf("connection refused")}
+
+	for _, tc := range []struct {
+		expirations []Expiration
+		want        int
+	}{
+		{[]Expiration{ok}, checkExitOK},
+		{[]Expiration{ok, soon}, checkExitExpiring},
+		{[]Expiration{soon, failed, ok}, checkExitError},
+		{nil, checkExitOK},
+	} {
+		if got := checkExitCode(tc.expirations, th); got != tc.want {
+			t.Errorf("expected %d for %v, got %d", tc.want, tc.expirations, got)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// cliResponseWriter lets subcommands write expirations with the same code
+// that serves them over HTTP. Headers are discarded.
+type cliResponseWriter struct {
+	io.Writer
+	header http.Header
+}
+
+func (w *cliResponseWriter) Header() http.Header {
+	if w.header == nil {
+		w.header = http.Header{}
+	}
+	return w.header
+}
+
+func (w *cliResponseWriter) WriteHeader(statusCode int) {}
+
+// writeExpirations writes expirations to w in format, which is one of
+// text, json, csv or ical, as a server with config would.
+func writeExpirations(w io.Writer, config *Config, format string, expirations []Expiration) error {
+	s := &Server{Config: config}
+	rw := &cliResponseWriter{Writer: w}
+	switch format {
+	case "text", "":
+		s.serveExpirationsText(rw, nil, expirations)
+	case "json":
+		s.serveExpirationsJSON(rw, nil, expirations, nil, nil)
+	case "csv":
+		s.serveExpirationsCSV(rw, nil, expirations, nil)
+	case "ical":
+		s.serveExpirationsIcal(rw, nil, expirations)
+	default:
+		return fmt.Errorf("unknown format %q, expected text, json, csv or ical", format)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+const (
+	// defaultConcurrency is how many checks a request runs at once,
+	// unless it or the server's configuration says otherwise.
+	defaultConcurrency = expire.DefaultConcurrency
+
+	// defaultMaxConcurrency is the most checks a single request may run
+	// at once, unless the server's configuration allows more.
+	defaultMaxConcurrency = 16
+
+	// defaultMaxCheckTimeout is the longest a request may ask each check
+	// to take, unless the server's configuration allows longer.
+	defaultMaxCheckTimeout = time.Minute
+)
+
+// loadConcurrencyEnv sets the concurrency in config from
+// $EXPIRE_CONCURRENCY and $EXPIRE_MAX_CONCURRENCY, if they are set, so
+// that it can be tuned for a deployment without a config file.
+func loadConcurrencyEnv(config *Config) error {
+	for name, value := range map[string]*int{
+		"EXPIRE_CONCURRENCY":     &config.Concurrency,
+		"EXPIRE_MAX_CONCURRENCY": &config.MaxConcurrency,
+	} {
+		s := os.Getenv(name)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return fmt.Errorf("$%s: expected a positive number, got %q", name, s)
+		}
+		*value = n
+	}
+	return nil
+}
+
+// loadCheckTimeoutEnv sets the check timeouts in config from
+// $EXPIRE_CHECK_TIMEOUT and $EXPIRE_MAX_CHECK_TIMEOUT, if they are set.
+func loadCheckTimeoutEnv(config *Config) error {
+	for name, value := range map[string]*time.Duration{
+		"EXPIRE_CHECK_TIMEOUT":     &config.CheckTimeout,
+		"EXPIRE_MAX_CHECK_TIMEOUT": &config.MaxCheckTimeout,
+	} {
+		s := os.Getenv(name)
+		if s == "" {
+			continue
+		}
+		d, err := parseDuration(s)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("$%s: expected a duration like 30s, got %q", name, s)
+		}
+		*value = d
+	}
+	return nil
+}
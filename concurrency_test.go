@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+func TestParseConcurrency(t *testing.T) {
+	s := NewServer(&Config{Concurrency: 4, MaxConcurrency: 8})
+	for _, tc := range []struct {
+		url  string
+		want int
+		err  bool
+	}{
+		{"/example.com", 4, false},
+		{"/example.com?concurrency=1", 1, false},
+		{"/example.com?concurrency=8", 8, false},
+		{"/example.com?concurrency=9", 0, true},
+		{"/example.com?concurrency=0", 0, true},
+		{"/example.com?concurrency=lots", 0, true},
+	} {
+		opts, err := s.parseCheckOptions(httptest.NewRequest("GET", tc.url, nil))
+		if tc.err {
+			if err == nil {
+				t.Errorf("%s: expected error", tc.url)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", tc.url, err)
+		} else if opts.Concurrency != tc.want {
+			t.Errorf("%s: expected %d, got %d", tc.url, tc.want, opts.Concurrency)
+		}
+	}
+}
+
+func TestLoadConcurrencyEnv(t *testing.T) {
+	defer os.Unsetenv("EXPIRE_CONCURRENCY")
+	defer os.Unsetenv("EXPIRE_MAX_CONCURRENCY")
+
+	config := &Config{Concurrency: 2, MaxConcurrency: 4}
+	os.Setenv("EXPIRE_MAX_CONCURRENCY", "32")
+	if err := loadConcurrencyEnv(config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Concurrency != 2 || config.MaxConcurrency != 32 {
+		t.Errorf("unexpected concurrency %d, max %d", config.Concurrency, config.MaxConcurrency)
+	}
+
+	os.Setenv("EXPIRE_CONCURRENCY", "zero")
+	if err := loadConcurrencyEnv(config); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestGetExpirationsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	opts := NewServer(&Config{}).defaultCheckOptions()
+	for _, exp := range getExpirations(ctx, []string{"a.cancelled.example.com", "b.cancelled.example.com"}, opts) {
+		if exp.CertificateError != context.Canceled || exp.DomainError != context.Canceled {
+			t.Errorf("%s: expected the checks to be skipped, got %v, %v", exp.Name, exp.CertificateError, exp.DomainError)
+		}
+	}
+}
+
+func TestParseCheckTimeout(t *testing.T) {
+	s := NewServer(&Config{CheckTimeout: 10 * time.Second, MaxCheckTimeout: 30 * time.Second})
+	for _, tc := range []struct {
+		url  string
+		want time.Duration
+		err  bool
+	}{
+		{"/example.com", 10 * time.Second, false},
+		{"/example.com?timeout=2s", 2 * time.Second, false},
+		{"/example.com?timeout=30s", 30 * time.Second, false},
+		{"/example.com?timeout=31s", 0, true},
+		{"/example.com?timeout=0", 0, true},
+		{"/example.com?timeout=soon", 0, true},
+	} {
+		opts, err := s.parseCheckOptions(httptest.NewRequest("GET", tc.url, nil))
+		if tc.err {
+			if err == nil {
+				t.Errorf("%s: expected error", tc.url)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", tc.url, err)
+		} else if opts.Timeout != tc.want {
+			t.Errorf("%s: expected %s, got %s", tc.url, tc.want, opts.Timeout)
+		}
+	}
+
+	if opts := NewServer(&Config{}).defaultCheckOptions(); opts.Timeout != expire.DefaultTimeout {
+		t.Errorf("expected the default timeout, got %s", opts.Timeout)
+	}
+}
+
+func TestLoadCheckTimeoutEnv(t *testing.T) {
+	defer os.Unsetenv("EXPIRE_CHECK_TIMEOUT")
+
+	config := &Config{}
+	os.Setenv("EXPIRE_CHECK_TIMEOUT", "1m")
+	if err := loadCheckTimeoutEnv(config); err != nil {
+		t.Fatal(err)
+	}
+	if config.CheckTimeout != time.Minute || config.MaxCheckTimeout != 0 {
+		t.Errorf("unexpected timeout %s, max %s", config.CheckTimeout, config.MaxCheckTimeout)
+	}
+
+	os.Setenv("EXPIRE_CHECK_TIMEOUT", "-1s")
+	if err := loadCheckTimeoutEnv(config); err == nil {
+		t.Error("expected an error")
+	}
+}
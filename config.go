@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/crewjam/expire-sh/expire"
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the server configuration. It is read from the YAML file named
+// by the EXPIRE_CONFIG environment variable.
+type Config struct {
+	// BaseURL is the URL the server is reached at, e.g.
+	// https://expire.sh, which is used in links from calendar events that
+	// aren't served in response to a request, such as those published to
+	// Outlook. Otherwise the URL of the request is used.
+	BaseURL string `yaml:"baseURL"`
+
+	// Watchlists are named lists of hosts that can be served as a status
+	// page.
+	Watchlists []Watchlist `yaml:"watchlists"`
+
+	// WhoisFormats describe how to find the expiration date in whois
+	// records for registries that aren't handled, or are handled
+	// incorrectly, by the built in formats.
+	WhoisFormats []expire.WhoisFormat `yaml:"whoisFormats"`
+
+	// ExpirationKeywords are phrases that identify the line of a whois
+	// record containing the expiration date, in addition to the built in
+	// ones. Keywords for a particular registry can be given in
+	// WhoisFormats instead.
+	ExpirationKeywords []string `yaml:"expirationKeywords"`
+
+	// StatusCodes is the default for the status parameter: strict (the
+	// default) for 502 when a check fails and 417 when something expires
+	// soon, or always200 for clients that can't handle those.
+	StatusCodes string `yaml:"statusCodes"`
+
+	// MaxHosts is the most hosts that a request may check (default: 100),
+	// or -1 for no limit. Watchlist status pages and alerts aren't limited.
+	MaxHosts int `yaml:"maxHosts"`
+
+	// ResultCacheTTL is how long the result of checking a host is reused
+	// before it is checked again (default: 15m), e.g. 1h so that
+	// calendars that refresh every 15 minutes don't check every time.
+	ResultCacheTTL time.Duration `yaml:"resultCacheTTL"`
+
+	// DomainCacheTTL is how long domain expirations are reused before the
+	// registry is asked again (default: 12h)
+	DomainCacheTTL time.Duration `yaml:"domainCacheTTL"`
+
+	// ExpiryPolicy chooses the expiration date when a whois record has
+	// more than one: first (the default), earliest, latest, or registry to
+	// prefer the registry's date over the registrar's.
+	ExpiryPolicy string `yaml:"expiryPolicy"`
+
+	// PublicSuffixList controls how the public suffix list, which is used
+	// to find the registered domain for a host, is kept up to date.
+	PublicSuffixList PublicSuffixListConfig `yaml:"publicSuffixList"`
+
+	// RDAPBootstrap controls how the RDAP bootstrap file, which is used
+	// to find the RDAP server for a domain, is kept up to date.
+	RDAPBootstrap RDAPBootstrapConfig `yaml:"rdapBootstrap"`
+
+	// TrustStores are additional trust stores that certificate chains can
+	// be verified against, e.g. the cacerts bundled with an older JRE.
+	TrustStores []TrustStoreConfig `yaml:"trustStores"`
+
+	// MaxCertificateLifetime is the longest validity period a leaf
+	// certificate may have without a warning (default: 398 days).
+	MaxCertificateLifetime time.Duration `yaml:"maxCertificateLifetime"`
+
+	// PGPKeyserver is the keyserver used to find PGP keys that aren't
+	// published with WKD (default: https://keys.openpgp.org)
+	PGPKeyserver string `yaml:"pgpKeyserver"`
+
+	// CacheControl is how long responses may be cached by CDNs and
+	// calendar programs, by format (ical, json, text, csv or prometheus).
+	// The defaults are 6h for ical, 1m for prometheus and 5m for the
+	// others.
+	CacheControl map[string]time.Duration `yaml:"cacheControl"`
+
+	// Concurrency is the number of checks a request runs at once, unless
+	// it asks for a different number with the concurrency parameter (default:
+	// 8). MaxConcurrency is the most a request may ask for (default: 16).
+	// $EXPIRE_CONCURRENCY and $EXPIRE_MAX_CONCURRENCY override these.
+	Concurrency    int `yaml:"concurrency"`
+	MaxConcurrency int `yaml:"maxConcurrency"`
+
+	// CheckTimeout is how long each certificate and domain check may take,
+	// unless a request asks for a different time with the timeout
+	// parameter (default: 15s). MaxCheckTimeout is the longest a request
+	// may ask for (default: 1m). $EXPIRE_CHECK_TIMEOUT and
+	// $EXPIRE_MAX_CHECK_TIMEOUT override these.
+	CheckTimeout    time.Duration `yaml:"checkTimeout"`
+	MaxCheckTimeout time.Duration `yaml:"maxCheckTimeout"`
+
+	// SelfTestHost is the host checked by /selftest (default:
+	// example.com)
+	SelfTestHost string `yaml:"selfTestHost"`
+
+	// DebugToken, if set, is required to use the debug parameter.
+	// Otherwise debug requests are rate limited.
+	DebugToken string `yaml:"debugToken"`
+
+	// Publish are watchlists that are rendered and uploaded to object
+	// storage on a schedule.
+	Publish []PublishConfig `yaml:"publish"`
+
+	// Reports are watchlist reports that are generated and uploaded to
+	// object storage on a schedule.
+	Reports []ReportConfig `yaml:"reports"`
+
+	// GraphCalendars are watchlists that are kept in sync with Outlook
+	// calendars using the Microsoft Graph API.
+	GraphCalendars []GraphCalendarConfig `yaml:"graphCalendars"`
+
+	// Notifiers are where alerts are sent, e.g. a Slack channel, which
+	// watchlist escalation policies refer to by name.
+	Notifiers []NotifierConfig `yaml:"notifiers"`
+
+	// TokenSecret is the secret from which the key used to encrypt /t/
+	// links is derived (default: $EXPIRE_TOKEN_SECRET). If neither is
+	// set, these links are disabled.
+	TokenSecret string `yaml:"tokenSecret"`
+
+	// Logging controls what is logged, e.g. to keep hostnames out of logs.
+	Logging LoggingConfig `yaml:"logging"`
+
+	// APIKeys identify the clients of the server. When any are
+	// configured, requests are recorded in the audit log, which admin keys
+	// may read at /audit.
+	APIKeys []APIKey    `yaml:"apiKeys"`
+	Audit   AuditConfig `yaml:"audit"`
+
+	// RequireAPIKey refuses to check hosts for requests made without an
+	// API key, so that a public server can't be used by anyone to make
+	// connections and whois queries. Keys may also be given in
+	// $EXPIRE_API_KEYS, as name:key pairs separated by commas.
+	RequireAPIKey bool `yaml:"requireAPIKey"`
+
+	// Store is where state such as short links is kept.
+	Store StoreConfig `yaml:"store"`
+
+	// History is where the results of checks are recorded.
+	History HistoryConfig `yaml:"history"`
+
+	// Cache is where recent results are kept, e.g. in Redis so that
+	// replicas behind a load balancer share them.
+	Cache CacheConfig `yaml:"cache"`
+
+	// Sweep configures the network ranges scanned by the sweep command.
+	Sweep SweepConfig `yaml:"sweep"`
+
+	// Monitor are hosts that are checked continuously in the background.
+	Monitor MonitorConfig `yaml:"monitor"`
+}
+
+// PublicSuffixListConfig controls how often the public suffix list is
+// fetched. Until a list has been fetched, the list compiled into the
+// binary is used.
+type PublicSuffixListConfig struct {
+	Disabled bool          `yaml:"disabled"`
+	URL      string        `yaml:"url"`     // default: https://publicsuffix.org/list/public_suffix_list.dat
+	Refresh  time.Duration `yaml:"refresh"` // default: 24h
+}
+
+// RDAPBootstrapConfig controls how often the RDAP bootstrap file, which
+// says which RDAP server is responsible for each TLD, is fetched. Until a
+// file has been fetched, the file compiled into the binary is used.
+type RDAPBootstrapConfig struct {
+	Disabled bool          `yaml:"disabled"`
+	URL      string        `yaml:"url"`     // default: https://data.iana.org/rdap/dns.json
+	Refresh  time.Duration `yaml:"refresh"` // default: 24h
+}
+
+// TrustStoreConfig names a file of PEM encoded root certificates that
+// chains can be verified against.
+type TrustStoreConfig struct {
+	Name string `yaml:"name"`
+	File string `yaml:"file"`
+}
+
+// Watchlist is a named list of hosts.
+type Watchlist struct {
+	Name  string          `yaml:"name"`
+	Hosts []WatchlistHost `yaml:"hosts"`
+
+	// ZoneTransfers are zones whose hosts are added to the watchlist. The
+	// zones are transferred every DiscoveryInterval (default: 1h).
+	ZoneTransfers     []ZoneTransferConfig `yaml:"zoneTransfers"`
+	DiscoveryInterval time.Duration        `yaml:"discoveryInterval"`
+
+	// MaintenanceWindows are when notifications for the watchlist are
+	// suppressed, e.g. during planned renewal work.
+	MaintenanceWindows []MaintenanceWindow `yaml:"maintenanceWindows"`
+
+	// Escalation is the watchlist's escalation policy: who to alert as
+	// each expiration approaches. The watchlist is checked every
+	// AlertInterval (default: 1h).
+	Escalation    []EscalationStage `yaml:"escalation"`
+	AlertInterval time.Duration     `yaml:"alertInterval"`
+
+	// NotifyChanges also tells the notifiers of the first escalation
+	// stage when a certificate or domain is renewed, moves earlier, or is
+	// replaced by another certificate.
+	NotifyChanges bool `yaml:"notifyChanges"`
+
+	// TTL and Lifetime are the defaults for the ttl and lifetime
+	// parameters when the watchlist is served at /group/<name>, e.g. 60d
+	// and 80%.
+	TTL      string `yaml:"ttl"`
+	Lifetime string `yaml:"lifetime"`
+}
+
+// WatchlistHost is a host in a watchlist, optionally labeled with the team
+// that is responsible for it.
+type WatchlistHost struct {
+	Name string `yaml:"name"`
+	Team string `yaml:"team"`
+}
+
+// UnmarshalYAML allows a host to be given as either a bare string or as a
+// mapping with name and team keys.
+func (h *WatchlistHost) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		h.Name = name
+		return nil
+	}
+
+	type plain WatchlistHost
+	return unmarshal((*plain)(h))
+}
+
+// Hostnames returns the names of the hosts in the watchlist.
+func (wl Watchlist) Hostnames() []string {
+	rv := make([]string, len(wl.Hosts))
+	for i, host := range wl.Hosts {
+		rv[i] = host.Name
+	}
+	return rv
+}
+
+// Watchlist returns the watchlist with the specified name, or nil if there
+// is no such watchlist.
+func (c *Config) Watchlist(name string) *Watchlist {
+	for i := range c.Watchlists {
+		if c.Watchlists[i].Name == name {
+			return &c.Watchlists[i]
+		}
+	}
+	return nil
+}
+
+// LoadConfig reads the configuration from path.
+func LoadConfig(path string) (*Config, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := Config{}
+	if err := yaml.Unmarshal(buf, &config); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return &config, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"time"
+)
+
+// dashboardRow is a host in the HTML rendering of expirations.
+type dashboardRow struct {
+	Name               string
+	Status             string
+	CertificateExpires time.Time
+	CertificateError   string
+	DomainExpires      time.Time
+	DomainError        string
+	DaysRemaining      *int
+}
+
+// dashboard is the data rendered in the HTML rendering of expirations.
+type dashboard struct {
+	Generated time.Time
+	Rows      []dashboardRow
+}
+
+func newDashboard(expirations []Expiration, t thresholds) dashboard {
+	rv := dashboard{Generated: t.Now}
+	for _, exp := range expirations {
+		row := dashboardRow{
+			Name:               exp.Name,
+			Status:             exp.Status(t),
+			CertificateExpires: exp.CertificateExpires,
+			DomainExpires:      exp.DomainExpires,
+		}
+		if exp.CertificateError != nil {
+			row.CertificateError = exp.CertificateError.Error()
+		}
+		if exp.DomainError != nil {
+			row.DomainError = exp.DomainError.Error()
+		}
+		if soonest, ok := exp.Soonest(); ok {
+			days := daysUntil(t.Now, soonest)
+			row.DaysRemaining = &days
+		}
+		rv.Rows = append(rv.Rows, row)
+	}
+	return rv
+}
+
+// dashboardTemplate renders a table that sorts by a column when its
+// heading is clicked. Each cell's data-sort attribute is the value it
+// sorts by, so dates and numbers sort properly.
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format("2006-01-02")
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Expirations - expire.sh</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { padding: 0.3em 1em; text-align: left; border-bottom: 1px solid #ddd; }
+th { cursor: pointer; }
+tr.ok td.status { background: #c8e6c9; }
+tr.expiring td.status { background: #fff59d; }
+tr.error td.status { background: #ef9a9a; }
+tr.withheld td.status { background: #e0e0e0; }
+tr.acknowledged td.status { background: #bbdefb; }
+.errors { color: #b71c1c; font-size: smaller; }
+</style>
+</head>
+<body>
+<p>Generated {{.Generated.Format "2006-01-02 15:04:05 MST"}}</p>
+<table id="expirations">
+<thead>
+<tr><th>Host</th><th>Status</th><th>Certificate</th><th>Domain</th><th>Days</th></tr>
+</thead>
+<tbody>
+{{range .Rows}}<tr class="{{.Status}}">
+<td data-sort="{{.Name}}">{{.Name}}</td>
+<td class="status" data-sort="{{.Status}}">{{.Status}}</td>
+<td data-sort="{{date .CertificateExpires}}">{{if .CertificateError}}<span class="errors">{{.CertificateError}}</span>{{else}}{{date .CertificateExpires}}{{end}}</td>
+<td data-sort="{{date .DomainExpires}}">{{if .DomainError}}<span class="errors">{{.DomainError}}</span>{{else}}{{date .DomainExpires}}{{end}}</td>
+<td data-sort="{{if .DaysRemaining}}{{.DaysRemaining}}{{end}}">{{if .DaysRemaining}}{{.DaysRemaining}}{{else}}-{{end}}</td>
+</tr>
+{{end}}</tbody>
+</table>
+<script>
+(function() {
+  var table = document.getElementById("expirations");
+  var headings = table.tHead.rows[0].cells;
+  var sorted = -1;
+  for (var i = 0; i < headings.length; i++) {
+    headings[i].onclick = (function(column) {
+      return function() {
+        var rows = Array.prototype.slice.call(table.tBodies[0].rows);
+        var descending = sorted === column;
+        rows.sort(function(a, b) {
+          var x = a.cells[column].getAttribute("data-sort");
+          var y = b.cells[column].getAttribute("data-sort");
+          if (x === "" || y === "") {
+            return (x === "") - (y === ""); // blanks last
+          }
+          var cmp;
+          if (!isNaN(x) && !isNaN(y)) {
+            cmp = x - y;
+          } else {
+            cmp = x < y ? -1 : x > y ? 1 : 0;
+          }
+          return descending ? -cmp : cmp;
+        });
+        rows.forEach(function(row) { table.tBodies[0].appendChild(row); });
+        sorted = descending ? -1 : column;
+      };
+    })(i);
+  }
+})();
+</script>
+</body>
+</html>
+`))
+
+func (s *Server) serveExpirationsHTML(w http.ResponseWriter, r *http.Request, expirations []Expiration, t thresholds) {
+	w.Header().Add("Content-Type", "text/html; charset=utf-8")
+	dashboardTemplate.Execute(w, newDashboard(expirations, t))
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDashboard(t *testing.T) {
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	expirations := []Expiration{
+		{Name: "a.example.com", CertificateExpires: now.Add(90 * 24 * time.Hour), DomainExpires: now.Add(365 * 24 * time.Hour)},
+		{Name: "b.example.com", CertificateExpires: now.Add(10 * 24 * time.Hour), DomainExpires: now.Add(365 * 24 * time.Hour)},
+		{Name: "c.example.com", CertificateError: fmt.Errorf("dial failed <timeout>"), DomainExpires: now.Add(365 * 24 * time.Hour)},
+	}
+	d := newDashboard(expirations, thresholds{Now: now, Soon: now.Add(30 * 24 * time.Hour)})
+
+	var got []string
+	for _, row := range d.Rows {
+		got = append(got, fmt.Sprintf("%s:%s:%d", row.Name, row.Status, *row.DaysRemaining))
+	}
+	want := "[a.example.com:ok:90 b.example.com:expiring:10 c.example.com:error:365]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := dashboardTemplate.Execute(buf, d); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`<tr class="expiring">`, `data-sort="2019-01-11"`, "dial failed &lt;timeout&gt;"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %s in\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// debugRequestsPerMinute limits how often each client may use the debug
+// parameter when no debug token is configured, since traced checks are
+// more expensive and reveal more about the service's network.
+const debugRequestsPerMinute = 10
+
+// authorizeDebug returns an error, and the status code to respond with, if
+// r may not use the debug parameter. When the server has a debug token,
+// it must be given either as the value of the debug parameter or as a
+// bearer token. Otherwise debug requests are rate limited.
+func (s *Server) authorizeDebug(r *http.Request) (int, error) {
+	if token := s.Config.DebugToken; token != "" {
+		given := r.FormValue("debug")
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			given = strings.TrimPrefix(auth, "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			return http.StatusUnauthorized, fmt.Errorf("The debug parameter requires a valid debug token")
+		}
+		return 0, nil
+	}
+	if !s.debugLimiter.Allow(clientAddress(r), time.Now()) {
+		return http.StatusTooManyRequests, fmt.Errorf("Too many debug requests, try again in a minute")
+	}
+	return 0, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthorizeDebug(t *testing.T) {
+	s := NewServer(&Config{DebugToken: "sekrit"})
+	r := httptest.NewRequest("GET", "/example.com?debug", nil)
+	if status, err := s.authorizeDebug(r); err == nil || status != http.StatusUnauthorized {
+		t.Errorf("expected unauthorized, got %d %v", status, err)
+	}
+	r = httptest.NewRequest("GET", "/example.com?debug=sekrit", nil)
+	if _, err := s.authorizeDebug(r); err != nil {
+		t.Errorf("expected token to be accepted, got %s", err)
+	}
+	r = httptest.NewRequest("GET", "/example.com?debug", nil)
+	r.Header.Set("Authorization", "Bearer sekrit")
+	if _, err := s.authorizeDebug(r); err != nil {
+		t.Errorf("expected bearer token to be accepted, got %s", err)
+	}
+
+	s = NewServer(&Config{})
+	for i := 0; i < debugRequestsPerMinute; i++ {
+		r = httptest.NewRequest("GET", "/example.com?debug", nil)
+		if _, err := s.authorizeDebug(r); err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+	}
+	if status, err := s.authorizeDebug(r); err == nil || status != http.StatusTooManyRequests {
+		t.Errorf("expected too many requests, got %d %v", status, err)
+	}
+}
+
+func TestDebugRequiresAuthorization(t *testing.T) {
+	s := NewServer(&Config{DebugToken: "sekrit"})
+	for _, url := range []string{
+		"/example.com?debug",
+		"/days/example.com?debug",
+		"/shield/example.com?debug",
+		detailPrefix + "example.com?debug",
+	} {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected %d, got %d", url, http.StatusUnauthorized, w.Code)
+		}
+	}
+}
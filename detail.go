@@ -0,0 +1,180 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const detailPrefix = "/detail/"
+
+// baseURL returns the URL the server was reached at by r, for links to
+// detail pages.
+func (s *Server) baseURL(r *http.Request) string {
+	if s.Config != nil && s.Config.BaseURL != "" {
+		return strings.TrimSuffix(s.Config.BaseURL, "/")
+	}
+	if r == nil || r.Host == "" {
+		return ""
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// detailPage is the data rendered on the detail page for a host.
+type detailPage struct {
+	Expiration
+	Status  string
+	History []HistoryEntry
+	Now     time.Time
+
+	// AckKey and AckToken are the admin key the page was requested with
+	// and the token for its acknowledgement form, which is only shown to
+	// admins.
+	AckKey   string
+	AckToken string
+}
+
+// serveDetail checks a single host and shows everything we know about it:
+// the certificate chain, the whois record, and the results of earlier
+// checks. Calendar events link here.
+func (s *Server) serveDetail(w http.ResponseWriter, r *http.Request, name string) {
+	if name == "" || strings.Contains(name, ",") {
+		http.NotFound(w, r)
+		return
+	}
+	opts, err := s.parseCheckOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	opts.Follow = false
+	opts.WWW = false
+	t, err := parseThresholds(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !s.chargeQuota(w, r, 1) {
+		return
+	}
+	expirations := getExpirations(r.Context(), []string{name}, opts)
+	s.applyAcknowledgements(expirations, t.Now)
+	exp := expirations[0]
+	page := detailPage{
+		Expiration: exp,
+		Status:     exp.Status(t),
+		History:    checkHistory.Entries(name),
+		Now:        t.Now,
+	}
+	if key := s.apiKey(r); key != nil && key.Admin {
+		page.AckKey = key.Key
+		page.AckToken = ackFormToken(key.Key, exp.Name)
+	}
+
+	w.Header().Add("Content-Type", "text/html; charset=utf-8")
+	detailPageTemplate.Execute(w, page)
+}
+
+var detailPageTemplate = template.Must(template.New("detail").Funcs(template.FuncMap{
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Format("2006-01-02 15:04:05 MST")
+	},
+	"days": daysUntil,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}} - expire.sh</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { padding: 0.3em 1em; text-align: left; border-bottom: 1px solid #ddd; vertical-align: top; }
+.ok { background: #c8e6c9; }
+.expiring { background: #fff59d; }
+.error { background: #ef9a9a; }
+.withheld { background: #e0e0e0; }
+.acknowledged { background: #bbdefb; }
+.errors { color: #b71c1c; }
+pre { background: #f5f5f5; padding: 1em; overflow-x: auto; }
+</style>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+<p>Status: <span class="{{.Status}}">{{.Status}}</span>, checked {{date .Now}}</p>
+{{with .Acknowledgement}}<p>Acknowledged until {{date .Until}}{{if .By}} by {{.By}}{{end}}{{if .Reason}}: {{.Reason}}{{end}}</p>
+{{if $.AckToken}}<form method="post" action="/ack/{{.Host}}?key={{$.AckKey}}"><input type="hidden" name="redirect" value="1"><input type="hidden" name="token" value="{{$.AckToken}}"><input type="hidden" name="clear" value="1"><button>Clear acknowledgement</button></form>{{end}}
+{{else}}{{if $.AckToken}}<form method="post" action="/ack/{{.Name}}?key={{$.AckKey}}"><input type="hidden" name="redirect" value="1"><input type="hidden" name="token" value="{{$.AckToken}}">
+Acknowledge until <input type="date" name="until" required> <input type="text" name="reason" placeholder="reason"> <button>Acknowledge</button></form>{{end}}
+{{end}}
+<h2>Certificate</h2>
+{{if .CertificateError}}<p class="errors">{{.CertificateError}}</p>
+{{else}}<table>
+<tr><th>Expires</th><td>{{date .CertificateExpires}}{{if not .CertificateExpires.IsZero}} ({{days $.Now .CertificateExpires}} days){{end}}</td></tr>
+<tr><th>Valid from</th><td>{{date .CertificateNotBefore}}</td></tr>
+{{if .TLSVersion}}<tr><th>Negotiated</th><td>{{.TLSVersion}}, {{.CipherSuite}}</td></tr>{{end}}
+{{with .Details}}{{if .CertificateValidationLevel}}<tr><th>Validation</th><td>{{.CertificateValidationLevel}}</td></tr>{{end}}
+{{range $store, $result := .TrustStores}}<tr><th>Trust store {{$store}}</th><td>{{$result}}</td></tr>
+{{end}}{{end}}{{range .Warnings}}<tr><th>Warning</th><td>{{.}}</td></tr>
+{{end}}</table>
+{{end}}
+{{if .CertificateChain}}
+<h2>Certificate Chain</h2>
+<table>
+<tr><th>Subject</th><th>Issuer</th><th>Valid from</th><th>Expires</th><th>SHA-256</th></tr>
+{{range .CertificateChain}}<tr><td>{{.Subject}}</td><td>{{.Issuer}}</td><td>{{date .NotBefore}}</td><td>{{date .NotAfter}}</td><td><code>{{.Fingerprint}}</code></td></tr>
+{{end}}</table>
+{{end}}
+{{with .DefaultCertificate}}
+<h2>Default Certificate</h2>
+{{if .Error}}<p class="errors">{{.Error}}</p>
+{{else}}<table>
+<tr><th>Subject</th><td>{{.Subject}}</td></tr>
+<tr><th>Expires</th><td>{{date .Expires}}</td></tr>
+<tr><th>SHA-256</th><td><code>{{.Fingerprint}}</code></td></tr>
+</table>
+{{end}}{{end}}
+{{if .Addresses}}
+<h2>Addresses</h2>
+<table>
+<tr><th>Address</th><th>Expires</th><th>SHA-256</th></tr>
+{{range .Addresses}}<tr><td>{{.Address}}</td>{{if .Error}}<td colspan="2" class="errors">{{.Error}}</td>{{else}}<td>{{date .CertificateExpires}}</td><td><code>{{.Fingerprint}}</code></td>{{end}}</tr>
+{{end}}</table>
+{{end}}
+{{if or .Domain .DomainError}}
+<h2>Domain</h2>
+{{if .DomainError}}<p class="errors">{{.DomainError}}</p>
+{{else}}<table>
+<tr><th>Domain</th><td>{{.Domain}}</td></tr>
+<tr><th>Expires</th><td>{{date .DomainExpires}}{{if not .DomainExpires.IsZero}} ({{days $.Now .DomainExpires}} days){{end}}</td></tr>
+{{with .Details}}{{if .DomainConfidence}}<tr><th>Confidence</th><td>{{.DomainConfidence}}</td></tr>{{end}}{{end}}
+</table>
+{{end}}
+{{with .Details}}{{if .DomainCandidates}}<table>
+<tr><th>Candidate</th><th>Server</th><th>Line</th></tr>
+{{range .DomainCandidates}}<tr><td>{{date .Expires}}</td><td>{{.Server}}</td><td><code>{{.Line}}</code></td></tr>
+{{end}}</table>
+{{end}}{{if .Whois}}
+<h2>Whois</h2>
+<pre>{{.Whois}}</pre>
+{{end}}{{end}}{{end}}
+{{if .History}}
+<h2>History</h2>
+<table>
+<tr><th>First checked</th><th>Last checked</th><th>Certificate</th><th>Domain</th></tr>
+{{range .History}}<tr><td>{{date .FirstChecked}}</td><td>{{date .LastChecked}}</td>
+<td>{{if .CertificateError}}<span class="errors">{{.CertificateError}}</span>{{else}}{{date .CertificateExpires}}{{end}}</td>
+<td>{{if .DomainError}}<span class="errors">{{.DomainError}}</span>{{else}}{{date .DomainExpires}}{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
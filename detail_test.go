@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+func TestDetailPageTemplate(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	page := detailPage{
+		Expiration: Expiration{
+			Name:               "example.com",
+			CertificateExpires: now.AddDate(0, 0, 30),
+			Domain:             "example.com",
+			DomainError:        errors.New("whois failed"),
+			Warnings:           []string{"certificate is valid for too long"},
+			CertificateChain: []expire.ChainCertificate{
+				{Subject: "CN=example.com", Issuer: "CN=Example CA", NotAfter: now.AddDate(0, 0, 30)},
+				{Subject: "CN=Example CA", Issuer: "CN=Example Root", NotAfter: now.AddDate(5, 0, 0)},
+			},
+			Details: &Details{
+				Whois: "Domain Name: EXAMPLE.COM\n",
+			},
+		},
+		Status: StatusError,
+		History: []HistoryEntry{
+			{FirstChecked: now.AddDate(0, 0, -1), LastChecked: now, CertificateExpires: now.AddDate(0, 0, 30)},
+		},
+		Now: now,
+	}
+	buf := &bytes.Buffer{}
+	if err := detailPageTemplate.Execute(buf, page); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"CN=Example Root", "whois failed", "(30 days)", "Domain Name: EXAMPLE.COM", "History"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected page to contain %q", want)
+		}
+	}
+	if strings.Contains(buf.String(), "<form") {
+		t.Errorf("expected no acknowledgement form without an admin key")
+	}
+
+	page.AckKey, page.AckToken = "secret", ackFormToken("secret", "example.com")
+	buf.Reset()
+	if err := detailPageTemplate.Execute(buf, page); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `action="/ack/example.com?key=secret"`) || !strings.Contains(buf.String(), page.AckToken) {
+		t.Errorf("expected an acknowledgement form for the admin, got %s", buf.String())
+	}
+}
+
+func TestHistory(t *testing.T) {
+	h := newMemoryHistory()
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	exp := Expiration{Name: "example.com", CertificateExpires: now.AddDate(0, 1, 0)}
+	h.Record(exp, now)
+	h.Record(exp, now.Add(time.Hour))
+	exp.CertificateExpires = now.AddDate(0, 3, 0)
+	h.Record(exp, now.Add(2*time.Hour))
+
+	entries := h.Entries("example.com")
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+	if !entries[0].LastChecked.Equal(now.Add(time.Hour)) {
+		t.Errorf("expected repeated results to extend the first entry, got %+v", entries[0])
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+	s := NewServer(&Config{})
+	r := httptest.NewRequest("GET", "/ical/example.com", nil)
+	r.Host = "expire.example"
+	r.Header.Set("X-Forwarded-Proto", "https")
+	if got, want := s.baseURL(r), "https://expire.example"; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	s = NewServer(&Config{BaseURL: "https://expire.sh/"})
+	if got, want := s.baseURL(nil), "https://expire.sh"; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
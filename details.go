@@ -0,0 +1,87 @@
+package main
+
+import (
+	"time"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+// maxRawSize is the largest raw upstream response that is included in the
+// output. Larger responses are truncated.
+const maxRawSize = 16 * 1024
+
+// Details is additional information about a check, which is included in
+// JSON responses when the details parameter is given.
+type Details struct {
+	// Cached is true if the result was checked by an earlier request, e.g.
+	// a prefetch, rather than by this one. Checked is when it was checked.
+	Cached  bool `json:",omitempty"`
+	Checked time.Time
+
+	// RedirectedFrom is the host that redirected to this one, when the
+	// follow parameter is given.
+	RedirectedFrom string `json:",omitempty"`
+
+	// Whois is the raw whois record for the domain, included only if the
+	// raw parameter is also given.
+	Whois string `json:",omitempty"`
+
+	// CAAError is why the CAA records couldn't be looked up.
+	CAAError string `json:",omitempty"`
+
+	// TrustStores is the result of verifying the certificate chain against
+	// each trust store given in the truststores parameter.
+	TrustStores map[string]string `json:",omitempty"`
+
+	// CertificateLifetimeDays is the length of the certificate's
+	// validity period, and CertificateLifetimeElapsed is the fraction of
+	// it that has passed.
+	CertificateLifetimeDays    int     `json:",omitempty"`
+	CertificateLifetimeElapsed float64 `json:",omitempty"`
+
+	// CertificateCheckMillis and DomainCheckMillis are how long the
+	// certificate and domain checks took. DomainSource is where the domain
+	// expiration came from, and DomainRetries is how many times the lookup
+	// was retried.
+	CertificateCheckMillis int64  `json:",omitempty"`
+	DomainCheckMillis      int64  `json:",omitempty"`
+	DomainSource           string `json:",omitempty"`
+	DomainRetries          int    `json:",omitempty"`
+
+	// DomainCached is true if the domain expiration was looked up by an
+	// earlier request, which happens for up to 12 hours by default.
+	DomainCached bool `json:",omitempty"`
+
+	// DomainCandidates are all the expiration dates found for the domain,
+	// with where each came from, and DomainConfidence is how sure we are
+	// of the one that was chosen: high, medium or low.
+	DomainCandidates []expire.DomainCandidate `json:",omitempty"`
+	DomainConfidence string                   `json:",omitempty"`
+
+	// CertificateValidationLevel is DV, OV, IV or EV
+	CertificateValidationLevel string `json:",omitempty"`
+
+	// PGPKeys are the primary key and subkeys found for a pgp: target, and
+	// PGPKeySource is where they were found.
+	PGPKeys      []PGPKey `json:",omitempty"`
+	PGPKeySource string   `json:",omitempty"`
+
+	// Trace is each step of the checks, when the debug parameter is given.
+	Trace []string `json:",omitempty"`
+
+	// TUFRoles are the top-level metadata found for a tuf: target.
+	TUFRoles []TUFRole `json:",omitempty"`
+}
+
+func truncateRaw(s string) string {
+	if len(s) <= maxRawSize {
+		return s
+	}
+	return s[:maxRawSize] + "\n[truncated]\n"
+}
+
+// millisSince returns the number of milliseconds since start, for the
+// check durations in Details.
+func millisSince(start time.Time) int64 {
+	return int64(time.Since(start) / time.Millisecond)
+}
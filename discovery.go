@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// ZoneTransferConfig describes a zone whose hosts are added to a watchlist
+// by periodically transferring (AXFR) the zone from a DNS server.
+type ZoneTransferConfig struct {
+	Zone   string `yaml:"zone"`
+	Server string `yaml:"server"` // host:port, port defaults to 53
+	Team   string `yaml:"team"`   // assigned to every host in the zone
+
+	// TSIG key used to authenticate the transfer, if any
+	TSIGKey       string `yaml:"tsigKey"`
+	TSIGSecret    string `yaml:"tsigSecret"`    // base64
+	TSIGAlgorithm string `yaml:"tsigAlgorithm"` // default: hmac-sha256
+}
+
+// defaultDiscoveryInterval is how often zones are transferred if the
+// watchlist doesn't specify.
+const defaultDiscoveryInterval = time.Hour
+
+// transferZone returns the hostnames of the A, AAAA and CNAME records in
+// the zone described by zt.
+func transferZone(zt ZoneTransferConfig) ([]string, error) {
+	server := zt.Server
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+
+	m := new(dns.Msg)
+	m.SetAxfr(dns.Fqdn(zt.Zone))
+
+	t := &dns.Transfer{
+		DialTimeout: 10 * time.Second,
+		ReadTimeout: time.Minute,
+	}
+	if zt.TSIGKey != "" {
+		algorithm := zt.TSIGAlgorithm
+		if algorithm == "" {
+			algorithm = dns.HmacSHA256
+		}
+		key := dns.Fqdn(zt.TSIGKey)
+		t.TsigSecret = map[string]string{key: zt.TSIGSecret}
+		m.SetTsig(key, dns.Fqdn(algorithm), 300, time.Now().Unix())
+	}
+
+	env, err := t.In(m, server)
+	if err != nil {
+		return nil, err
+	}
+
+	var rv []string
+	seen := map[string]bool{}
+	for e := range env {
+		if e.Error != nil {
+			return nil, e.Error
+		}
+		for _, rr := range e.RR {
+			name, ok := hostnameFromRR(rr)
+			if !ok || seen[name] {
+				continue
+			}
+			seen[name] = true
+			rv = append(rv, name)
+		}
+	}
+	if len(rv) == 0 {
+		return nil, fmt.Errorf("zone transfer of %s from %s returned no hosts", zt.Zone, server)
+	}
+	sort.Strings(rv)
+	return rv, nil
+}
+
+// discoverHosts transfers each of the zones configured for wl and returns
+// the hosts found. If a transfer fails, the hosts previously discovered in
+// that zone are kept so that a flaky DNS server doesn't empty the
+// watchlist.
+func (s *Server) discoverHosts(wl Watchlist, previous map[string][]WatchlistHost) map[string][]WatchlistHost {
+	rv := map[string][]WatchlistHost{}
+	for _, zt := range wl.ZoneTransfers {
+		hostnames, err := transferZone(zt)
+		if err != nil {
+			log.Printf("watchlist %s: cannot transfer zone %s: %s", wl.Name, logName(zt.Zone), err)
+			rv[zt.Zone] = previous[zt.Zone]
+			continue
+		}
+		hosts := make([]WatchlistHost, len(hostnames))
+		for i, hostname := range hostnames {
+			hosts[i] = WatchlistHost{Name: hostname, Team: zt.Team}
+		}
+		rv[zt.Zone] = hosts
+	}
+	return rv
+}
+
+// runDiscovery keeps the discovered hosts for wl up to date, forever.
+func (s *Server) runDiscovery(wl Watchlist) {
+	interval := wl.DiscoveryInterval
+	if interval == 0 {
+		interval = defaultDiscoveryInterval
+	}
+
+	var discovered map[string][]WatchlistHost
+	for {
+		discovered = s.discoverHosts(wl, discovered)
+
+		var hosts []WatchlistHost
+		for _, zt := range wl.ZoneTransfers {
+			hosts = append(hosts, discovered[zt.Zone]...)
+		}
+
+		s.mu.Lock()
+		if s.discovered == nil {
+			s.discovered = map[string][]WatchlistHost{}
+		}
+		s.discovered[wl.Name] = hosts
+		s.mu.Unlock()
+
+		time.Sleep(interval)
+	}
+}
+
+// StartDiscovery starts keeping watchlists with zone transfers configured
+// in sync with the contents of their zones.
+func (s *Server) StartDiscovery() {
+	for _, wl := range s.Config.Watchlists {
+		if len(wl.ZoneTransfers) > 0 {
+			go s.runDiscovery(wl)
+		}
+	}
+}
+
+// watchlist returns the watchlist with the specified name including any
+// discovered hosts, or nil if there is no such watchlist.
+func (s *Server) watchlist(name string) *Watchlist {
+	wl := s.Config.Watchlist(name)
+	if wl == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	discovered := s.discovered[name]
+	s.mu.Unlock()
+
+	rv := *wl
+	seen := map[string]bool{}
+	rv.Hosts = nil
+	for _, host := range append(append([]WatchlistHost{}, wl.Hosts...), discovered...) {
+		if seen[host.Name] {
+			continue
+		}
+		seen[host.Name] = true
+		rv.Hosts = append(rv.Hosts, host)
+	}
+	return &rv
+}
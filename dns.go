@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// resolvConf is where the DNS servers used by lookupDNS are configured.
+const resolvConf = "/etc/resolv.conf"
+
+// lookupDNS queries the system's DNS resolver for records of type qtype
+// at name. It is used for record types that net.Resolver doesn't support.
+func lookupDNS(ctx context.Context, name string, qtype uint16) ([]dns.RR, error) {
+	config, err := dns.ClientConfigFromFile(resolvConf)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.Servers) == 0 {
+		return nil, fmt.Errorf("no DNS servers configured in %s", resolvConf)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	m.SetEdns0(4096, true)
+
+	c := &dns.Client{Timeout: 5 * time.Second}
+	var lastErr error
+	for _, server := range config.Servers {
+		resp, _, err := c.ExchangeContext(ctx, m, net.JoinHostPort(server, config.Port))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp.Rcode == dns.RcodeNameError {
+			return nil, nil
+		}
+		if resp.Rcode != dns.RcodeSuccess {
+			lastErr = fmt.Errorf("%s: %s", name, dns.RcodeToString[resp.Rcode])
+			continue
+		}
+
+		var rv []dns.RR
+		for _, rr := range resp.Answer {
+			if rr.Header().Rrtype == qtype {
+				rv = append(rv, rr)
+			}
+		}
+		return rv, nil
+	}
+	return nil, lastErr
+}
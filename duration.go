@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// durationUnits are the units parseDuration accepts. A month is 30 days
+// and a year is 365, which is close enough for deciding what is soon.
+var durationUnits = map[string]time.Duration{
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+	"mo": 30 * 24 * time.Hour,
+	"y":  365 * 24 * time.Hour,
+}
+
+const durationUnitsHelp = "s, m, h, d (days), w (weeks), mo (months) or y (years)"
+
+// parseDuration parses a duration such as 60d, 1y or 2w3d: a sequence of
+// numbers, each with a unit from durationUnits. Durations that
+// time.ParseDuration accepts, like 1440h or 1h30m, mean the same thing.
+func parseDuration(s string) (time.Duration, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+	if s == "" {
+		return 0, fmt.Errorf("expected a duration like 30d, with units %s", durationUnitsHelp)
+	}
+
+	var rv time.Duration
+	for rest := s; rest != ""; {
+		i := strings.IndexFunc(rest, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+		if i <= 0 {
+			return 0, fmt.Errorf("invalid duration %q: expected a number followed by a unit, one of %s", s, durationUnitsHelp)
+		}
+		n, err := strconv.ParseFloat(rest[:i], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q: expected a number followed by a unit, one of %s", s, durationUnitsHelp)
+		}
+		rest = rest[i:]
+
+		j := strings.IndexFunc(rest, func(r rune) bool { return r >= '0' && r <= '9' || r == '.' })
+		if j < 0 {
+			j = len(rest)
+		}
+		unit, ok := durationUnits[rest[:j]]
+		if !ok {
+			return 0, fmt.Errorf("invalid duration %q: unknown unit %q, expected %s", s, rest[:j], durationUnitsHelp)
+		}
+		rest = rest[j:]
+		rv += time.Duration(n * float64(unit))
+	}
+	return rv, nil
+}
+
+// durationValue is a flag.Value for durations in any form parseDuration
+// accepts.
+type durationValue time.Duration
+
+func (d *durationValue) Set(s string) error {
+	v, err := parseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = durationValue(v)
+	return nil
+}
+
+func (d *durationValue) String() string {
+	return time.Duration(*d).String()
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	day := 24 * time.Hour
+	for _, tt := range []struct {
+		s    string
+		want time.Duration
+	}{
+		{"60d", 60 * day},
+		{"1y", 365 * day},
+		{"2w", 14 * day},
+		{"3mo", 90 * day},
+		{"1y6mo", 545 * day},
+		{"1.5d", 36 * time.Hour},
+		{"1440h", 60 * day},
+		{"1h30m", 90 * time.Minute},
+		{"0", 0},
+	} {
+		got, err := parseDuration(tt.s)
+		if err != nil {
+			t.Errorf("%q: %s", tt.s, err)
+		} else if got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.s, got, tt.want)
+		}
+	}
+
+	for _, s := range []string{"", "d", "60", "60x", "1y6", "-1d", "1..5d"} {
+		if _, err := parseDuration(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+	if _, err := parseDuration("60x"); err == nil || !strings.Contains(err.Error(), "mo (months)") {
+		t.Errorf("expected the error to list the units, got %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// SMTPConfig is how to send email.
+type SMTPConfig struct {
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"` // default: 587
+
+	// Username and Password authenticate with the server, if given. The
+	// connection is upgraded with STARTTLS when the server supports it,
+	// and must be for a server other than localhost.
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+
+	From string `yaml:"from"`
+}
+
+// EmailRecipient is someone who is emailed alerts: only those for Hosts,
+// if any are given, otherwise all of them.
+type EmailRecipient struct {
+	Address string   `yaml:"address"`
+	Hosts   []string `yaml:"hosts"`
+}
+
+// Wants returns true if the recipient should be told about alert.
+func (r EmailRecipient) Wants(alert Alert) bool {
+	if len(r.Hosts) == 0 {
+		return true
+	}
+	for _, host := range r.Hosts {
+		if host == alert.Host {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	defaultSMTPPort = 587
+
+	defaultEmailSubject = `{{len .Alerts}} expiration {{if eq (len .Alerts) 1}}alert{{else}}alerts{{end}}`
+
+	defaultEmailTemplate = `{{range .Alerts}}{{.}}
+{{end}}
+--
+Sent by expire.sh
+`
+)
+
+// emailData is what email templates are executed with.
+type emailData struct {
+	Alerts    []Alert
+	Recipient EmailRecipient
+}
+
+// emailNotifier emails alerts, with one message to each recipient that
+// wants any of them.
+type emailNotifier struct {
+	SMTP       SMTPConfig
+	Recipients []EmailRecipient
+	Subject    *template.Template
+	Body       *template.Template
+
+	// sendMail is smtp.SendMail, except in tests
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func newEmailNotifier(config NotifierConfig) (*emailNotifier, error) {
+	if config.SMTP.Host == "" || config.SMTP.From == "" {
+		return nil, fmt.Errorf("an email notifier requires an smtp host and from address")
+	}
+	if len(config.Recipients) == 0 {
+		return nil, fmt.Errorf("an email notifier requires recipients")
+	}
+	for _, r := range config.Recipients {
+		if !strings.Contains(r.Address, "@") {
+			return nil, fmt.Errorf("invalid recipient address %q", r.Address)
+		}
+	}
+	n := &emailNotifier{SMTP: config.SMTP, Recipients: config.Recipients, sendMail: smtp.SendMail}
+	if n.SMTP.Port == 0 {
+		n.SMTP.Port = defaultSMTPPort
+	}
+
+	subject, body := config.Subject, config.Template
+	if subject == "" {
+		subject = defaultEmailSubject
+	}
+	if body == "" {
+		body = defaultEmailTemplate
+	}
+	var err error
+	if n.Subject, err = template.New("subject").Parse(subject); err != nil {
+		return nil, fmt.Errorf("cannot parse subject: %s", err)
+	}
+	if n.Body, err = template.New("body").Parse(body); err != nil {
+		return nil, fmt.Errorf("cannot parse template: %s", err)
+	}
+	return n, nil
+}
+
+// message returns the email to recipient about alerts, at now.
+func (n *emailNotifier) message(recipient EmailRecipient, alerts []Alert, now time.Time) ([]byte, error) {
+	data := emailData{Alerts: alerts, Recipient: recipient}
+	subject, body := &bytes.Buffer{}, &bytes.Buffer{}
+	if err := n.Subject.Execute(subject, data); err != nil {
+		return nil, err
+	}
+	if err := n.Body.Execute(body, data); err != nil {
+		return nil, err
+	}
+
+	msg := &bytes.Buffer{}
+	fmt.Fprintf(msg, "From: %s\r\n", n.SMTP.From)
+	fmt.Fprintf(msg, "To: %s\r\n", recipient.Address)
+	fmt.Fprintf(msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject.String())))
+	fmt.Fprintf(msg, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(msg, "Content-Type: text/plain; charset=utf-8\r\n")
+	fmt.Fprintf(msg, "\r\n")
+	msg.WriteString(strings.Replace(body.String(), "\n", "\r\n", -1))
+	return msg.Bytes(), nil
+}
+
+func (n *emailNotifier) Notify(ctx context.Context, alerts []Alert) error {
+	addr := net.JoinHostPort(n.SMTP.Host, strconv.Itoa(n.SMTP.Port))
+	var auth smtp.Auth
+	if n.SMTP.Username != "" {
+		auth = smtp.PlainAuth("", n.SMTP.Username, n.SMTP.Password, n.SMTP.Host)
+	}
+
+	var failed []string
+	for _, recipient := range n.Recipients {
+		var wanted []Alert
+		for _, alert := range alerts {
+			if recipient.Wants(alert) {
+				wanted = append(wanted, alert)
+			}
+		}
+		if len(wanted) == 0 {
+			continue
+		}
+		msg, err := n.message(recipient, wanted, time.Now())
+		if err == nil {
+			err = n.sendMail(addr, auth, n.SMTP.From, []string{recipient.Address}, msg)
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", recipient.Address, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("cannot email %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewEmailNotifier(t *testing.T) {
+	smtpConfig := SMTPConfig{Host: "mail.example.com", From: "expire@example.com"}
+	n, err := newEmailNotifier(NotifierConfig{Type: "email", SMTP: smtpConfig, Recipients: []EmailRecipient{{Address: "ops@example.com"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.SMTP.Port != defaultSMTPPort {
+		t.Errorf("expected the default port, got %d", n.SMTP.Port)
+	}
+
+	for _, config := range []NotifierConfig{
+		{Recipients: []EmailRecipient{{Address: "ops@example.com"}}},
+		{SMTP: smtpConfig},
+		{SMTP: smtpConfig, Recipients: []EmailRecipient{{Address: "ops"}}},
+		{SMTP: smtpConfig, Recipients: []EmailRecipient{{Address: "ops@example.com"}}, Template: "{{.Nope"},
+	} {
+		if _, err := newEmailNotifier(config); err == nil {
+			t.Errorf("%+v: expected error", config)
+		}
+	}
+}
+
+func TestEmailNotifier(t *testing.T) {
+	n, err := newEmailNotifier(NotifierConfig{
+		SMTP: SMTPConfig{Host: "mail.example.com", Port: 25, From: "expire@example.com"},
+		Recipients: []EmailRecipient{
+			{Address: "ops@example.com"},
+			{Address: "web@example.com", Hosts: []string{"a.example.com"}},
+			{Address: "mail@example.com", Hosts: []string{"mx.example.com"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := map[string]string{}
+	n.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		if addr != "mail.example.com:25" || from != "expire@example.com" || a != nil {
+			return fmt.Errorf("unexpected %s %v %s", addr, a, from)
+		}
+		sent[strings.Join(to, ",")] = string(msg)
+		return nil
+	}
+
+	alerts := []Alert{
+		{Watchlist: "prod", Host: "a.example.com", Check: CheckCertificate, DaysRemaining: 3,
+			Expires: time.Date(2019, 1, 4, 0, 0, 0, 0, time.UTC)},
+		{Watchlist: "prod", Host: "b.example.com", Check: CheckDomain, Error: "timeout"},
+	}
+	if err := n.Notify(context.Background(), alerts); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 2 {
+		t.Fatalf("expected emails to ops and web, got %v", sent)
+	}
+	if msg := sent["ops@example.com"]; !strings.Contains(msg, "Subject: 2 expiration alerts\r\n") || !strings.Contains(msg, "b.example.com: cannot check domain: timeout\r\n") {
+		t.Errorf("unexpected message %q", msg)
+	}
+	if msg := sent["web@example.com"]; !strings.Contains(msg, "Subject: 1 expiration alert\r\n") || strings.Contains(msg, "b.example.com") ||
+		!strings.Contains(msg, "a.example.com: certificate expires in 3 days, on 2019-01-04") {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+// Codes for why a check failed, so that programs can tell failures apart
+// without parsing messages
+const (
+	ErrorDialTimeout        = "DIAL_TIMEOUT"
+	ErrorTimeout            = "TIMEOUT"
+	ErrorDNSNotFound        = "DNS_NOT_FOUND"
+	ErrorDNSFailed          = "DNS_FAILED"
+	ErrorConnectionRefused  = "CONNECTION_REFUSED"
+	ErrorConnectionFailed   = "CONNECTION_FAILED"
+	ErrorTLSHandshake       = "TLS_HANDSHAKE_FAILED"
+	ErrorCertificateInvalid = "CERTIFICATE_INVALID"
+	ErrorExpiryWithheld     = "EXPIRY_WITHHELD"
+	ErrorWhoisParse         = "WHOIS_PARSE_FAILED"
+	ErrorCheckFailed        = "CHECK_FAILED" // anything else
+)
+
+// CheckError is why a check failed, as it is served in JSON: a code, the
+// message, and whether checking again later might succeed.
+type CheckError struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Retryable bool   `json:"retryable"`
+}
+
+// newCheckError returns err as a CheckError, or nil if err is nil.
+func newCheckError(err error) *CheckError {
+	if err == nil {
+		return nil
+	}
+	code, retryable := errorCode(err)
+	return &CheckError{Code: code, Message: err.Error(), Retryable: retryable}
+}
+
+// errorCode classifies err, and returns whether checking again might
+// succeed.
+func errorCode(err error) (string, bool) {
+	var validationErr expire.ValidationError
+	var withheldErr expire.ExpiryWithheldError
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var recordErr tls.RecordHeaderError
+	switch {
+	case errors.As(err, &validationErr):
+		return ErrorCertificateInvalid, false
+	case errors.As(err, &withheldErr):
+		return ErrorExpiryWithheld, false
+	case strings.HasPrefix(err.Error(), expire.ErrNoExpiration.Error()):
+		return ErrorWhoisParse, false
+	case errors.As(err, &dnsErr):
+		if dnsErr.IsNotFound {
+			return ErrorDNSNotFound, false
+		}
+		return ErrorDNSFailed, true
+	case errors.As(err, &opErr):
+		switch {
+		case opErr.Timeout() && opErr.Op == "dial":
+			return ErrorDialTimeout, true
+		case opErr.Timeout():
+			return ErrorTimeout, true
+		case errors.Is(err, syscall.ECONNREFUSED):
+			return ErrorConnectionRefused, true
+		}
+		return ErrorConnectionFailed, true
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorTimeout, true
+	case errors.As(err, &recordErr), strings.HasPrefix(err.Error(), "tls: "),
+		strings.HasPrefix(err.Error(), "remote error: tls: "):
+		return ErrorTLSHandshake, false
+	}
+	return ErrorCheckFailed, false
+}
+
+// errorCodeOrNil returns the code for err, or nil if there is no error.
+func errorCodeOrNil(err error) interface{} {
+	if err == nil {
+		return nil
+	}
+	code, _ := errorCode(err)
+	return code
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestErrorCode(t *testing.T) {
+	for _, tt := range []struct {
+		err       error
+		code      string
+		retryable bool
+	}{
+		{&net.OpError{Op: "dial", Net: "tcp", Err: timeoutError{}}, ErrorDialTimeout, true},
+		{&net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}, ErrorTimeout, true},
+		{context.DeadlineExceeded, ErrorTimeout, true},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true}}, ErrorDNSNotFound, false},
+		{&net.DNSError{Err: "server misbehaving", Name: "example.com"}, ErrorDNSFailed, true},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, ErrorConnectionRefused, true},
+		{&net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, ErrorConnectionFailed, true},
+		{tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}, ErrorTLSHandshake, false},
+		{fmt.Errorf("remote error: tls: handshake failure"), ErrorTLSHandshake, false},
+		{expire.ValidationError{Problems: []expire.ValidationProblem{{Code: expire.ProblemExpired, Message: "expired"}}}, ErrorCertificateInvalid, false},
+		{expire.ExpiryWithheldError{Domain: "example.com"}, ErrorExpiryWithheld, false},
+		{expire.ErrNoExpiration, ErrorWhoisParse, false},
+		{fmt.Errorf("%s: 2100-01-01 on line \"Expiry: 2100-01-01\" is implausible", expire.ErrNoExpiration), ErrorWhoisParse, false},
+		{fmt.Errorf("no certificates found"), ErrorCheckFailed, false},
+	} {
+		if code, retryable := errorCode(tt.err); code != tt.code || retryable != tt.retryable {
+			t.Errorf("%v: expected %s %v, got %s %v", tt.err, tt.code, tt.retryable, code, retryable)
+		}
+	}
+}
+
+func TestJSONCheckError(t *testing.T) {
+	now := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+	buf, err := json.Marshal(newJSONExpiration(Expiration{
+		Name:             "example.com",
+		CertificateError: &net.OpError{Op: "dial", Net: "tcp", Err: timeoutError{}},
+	}, now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"CertificateError":{"code":"DIAL_TIMEOUT","message":"dial tcp: i/o timeout","retryable":true}`,
+		`"DomainError":null`,
+	} {
+		if !strings.Contains(string(buf), want) {
+			t.Errorf("expected %s in %s", want, buf)
+		}
+	}
+}
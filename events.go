@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// serveExpirationEvents checks hostnames and writes the result for each as
+// a server-sent event as soon as it is known, so that clients can show
+// progress through a long list. Each result is an "expiration" event with
+// the same JSON as a host in a JSON response, and a "done" event with the
+// number of hosts checked follows the last.
+func (s *Server) serveExpirationEvents(w http.ResponseWriter, r *http.Request, hostnames []string, opts checkOptions, t thresholds, fields []field) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush() // so the client knows the checks have started
+	}
+
+	details := r.URL.Query()["details"] != nil || opts.Debug
+	raw := r.URL.Query()["raw"] != nil
+	quiet := r.URL.Query()["quiet"] != nil
+
+	n := 0
+	streamExpirations(r.Context(), hostnames, opts, func(exp Expiration) {
+		n++
+		now := time.Now()
+		expirations := []Expiration{exp}
+		s.applyAcknowledgements(expirations, now)
+		exp = expirations[0]
+		if quiet && exp.OK(t) {
+			return
+		}
+		if !details {
+			exp.Details = nil
+		} else if !raw {
+			exp.Details.Whois = ""
+		}
+		writeEvent(w, "expiration", jsonRow(exp, fields, now))
+		if flusher != nil {
+			flusher.Flush()
+		}
+	})
+	writeEvent(w, "done", struct {
+		Hosts int `json:"hosts"`
+	}{n})
+}
+
+// writeEvent writes a server-sent event of type event, whose data is v
+// encoded as JSON.
+func writeEvent(w http.ResponseWriter, event string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeExpirationEvents(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	server := testTLSServer(t, "example.com", now.AddDate(0, 1, 0))
+	defer server.Close()
+	host := server.Listener.Addr().String()
+
+	r := httptest.NewRequest("GET", "/"+host+"?fields=name", nil)
+	r.Header.Set("Accept", "text/event-stream")
+	w := httptest.NewRecorder()
+	NewServer(&Config{}).ServeHTTP(w, r)
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected an event stream, got %s", ct)
+	}
+	want := fmt.Sprintf("event: expiration\ndata: {\"name\":%q}\n\nevent: done\ndata: {\"hosts\":1}\n\n", host)
+	if got := w.Body.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	// event streams are never chosen for wildcards
+	r = httptest.NewRequest("GET", "/"+host, nil)
+	r.Header.Set("Accept", "*/*")
+	w = httptest.NewRecorder()
+	NewServer(&Config{}).ServeHTTP(w, r)
+	if strings.Contains(w.Header().Get("Content-Type"), "event-stream") {
+		t.Errorf("expected another format for */*")
+	}
+}
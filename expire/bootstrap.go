@@ -0,0 +1,147 @@
+package expire
+
+//go:generate go run rdap_bootstrap_gen.go
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultRDAPBootstrapURL = "https://data.iana.org/rdap/dns.json"
+
+// rdapBootstrap is a parsed RDAP bootstrap file for domain names, see
+// RFC 9224.
+type rdapBootstrap struct {
+	Publication string
+	servers     map[string][]string // base URLs by TLD
+}
+
+func parseRDAPBootstrap(buf []byte) (*rdapBootstrap, error) {
+	var file struct {
+		Publication string       `json:"publication"`
+		Services    [][][]string `json:"services"`
+	}
+	if err := json.Unmarshal(buf, &file); err != nil {
+		return nil, err
+	}
+	b := &rdapBootstrap{
+		Publication: file.Publication,
+		servers:     map[string][]string{},
+	}
+	for _, service := range file.Services {
+		if len(service) != 2 {
+			continue
+		}
+		for _, tld := range service[0] {
+			b.servers[strings.ToLower(tld)] = service[1]
+		}
+	}
+	return b, nil
+}
+
+func (b *rdapBootstrap) Len() int {
+	return len(b.servers)
+}
+
+// Servers returns the base URLs of the RDAP servers for domain, from the
+// entry that matches the most labels of it. HTTPS URLs come first.
+func (b *rdapBootstrap) Servers(domain string) []string {
+	labels := strings.Split(strings.ToLower(strings.TrimSuffix(domain, ".")), ".")
+	for i := range labels {
+		urls, ok := b.servers[strings.Join(labels[i:], ".")]
+		if !ok {
+			continue
+		}
+		var rv []string
+		for _, u := range urls {
+			if strings.HasPrefix(u, "https://") {
+				rv = append(rv, u)
+			}
+		}
+		for _, u := range urls {
+			if !strings.HasPrefix(u, "https://") {
+				rv = append(rv, u)
+			}
+		}
+		return rv
+	}
+	return nil
+}
+
+var (
+	rdapBootstrapMu sync.RWMutex
+	bootstrap       = mustParseRDAPBootstrap(bundledRDAPBootstrap)
+)
+
+func mustParseRDAPBootstrap(s string) *rdapBootstrap {
+	b, err := parseRDAPBootstrap([]byte(s))
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// rdapServers returns the base URLs of the RDAP servers for domain using
+// the most recently fetched bootstrap file, or the bundled one if none has
+// been fetched.
+func rdapServers(domain string) []string {
+	rdapBootstrapMu.RLock()
+	b := bootstrap
+	rdapBootstrapMu.RUnlock()
+	return b.Servers(domain)
+}
+
+// minRDAPBootstrapLen is the fewest TLDs a fetched bootstrap file must
+// have before we will use it. It protects against replacing the bundled
+// file with an error page or a truncated download.
+const minRDAPBootstrapLen = 100
+
+// rdapBootstrapClient fetches the RDAP bootstrap file. The timeout keeps a
+// stalled download from stopping the refreshes for good.
+var rdapBootstrapClient = &http.Client{Timeout: time.Minute}
+
+func fetchRDAPBootstrap(url string) (*rdapBootstrap, error) {
+	resp, err := rdapBootstrapClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	b, err := parseRDAPBootstrap(buf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", url, err)
+	}
+	if b.Len() < minRDAPBootstrapLen {
+		return nil, fmt.Errorf("%s: only %d TLDs, expected at least %d", url, b.Len(), minRDAPBootstrapLen)
+	}
+	return b, nil
+}
+
+// RefreshRDAPBootstrap fetches the RDAP bootstrap file from url every
+// interval, forever. If a fetch fails the previous file remains in use.
+func RefreshRDAPBootstrap(url string, interval time.Duration) {
+	for {
+		b, err := fetchRDAPBootstrap(url)
+		if err != nil {
+			log.Printf("cannot refresh RDAP bootstrap file: %s", err)
+		} else {
+			rdapBootstrapMu.Lock()
+			bootstrap = b
+			rdapBootstrapMu.Unlock()
+			log.Printf("loaded RDAP servers for %d TLDs from %s, published %s", b.Len(), url, b.Publication)
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,41 @@
+package expire
+
+import (
+	"fmt"
+	"testing"
+)
+
+const testRDAPBootstrap = `{
+  "publication": "2020-01-02T03:04:05Z",
+  "services": [
+    [["com", "net"], ["http://rdap.example/com/", "https://rdap.example/com/"]],
+    [["co.example"], ["https://rdap.co.example/"]]
+  ],
+  "version": "1.0"
+}`
+
+func TestRDAPBootstrap(t *testing.T) {
+	b, err := parseRDAPBootstrap([]byte(testRDAPBootstrap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != 3 || b.Publication != "2020-01-02T03:04:05Z" {
+		t.Errorf("unexpected bootstrap %+v", b)
+	}
+	for domain, want := range map[string]string{
+		"www.Example.COM": "[https://rdap.example/com/ http://rdap.example/com/]",
+		"example.net.":    "[https://rdap.example/com/ http://rdap.example/com/]",
+		"a.co.example":    "[https://rdap.co.example/]",
+		"example.unknown": "[]",
+	} {
+		if got := fmt.Sprint(b.Servers(domain)); got != want {
+			t.Errorf("%s: expected %s, got %s", domain, want, got)
+		}
+	}
+}
+
+func TestBundledRDAPBootstrap(t *testing.T) {
+	if len(rdapServers("example.com")) == 0 {
+		t.Errorf("expected the bundled bootstrap file to have a server for .com")
+	}
+}
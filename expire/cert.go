@@ -0,0 +1,230 @@
+package expire
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CertificateResult is the outcome of a certificate expiration check.
+type CertificateResult struct {
+	Expires   time.Time
+	NotBefore time.Time // of the certificate that expires first
+
+	// TrustStores is the result of verifying the chain against each of the
+	// trust stores in Options.TrustStores
+	TrustStores map[string]string
+
+	ValidationLevel string // of the leaf certificate: DV, OV, IV or EV
+
+	// Chain is the certificates presented by the server, leaf first
+	Chain []ChainCertificate
+
+	Warnings []string
+
+	// Address is the address of the server that was connected to
+	Address string
+
+	// TLSVersion and CipherSuite are what was negotiated in the
+	// handshake, e.g. TLS 1.3 and TLS_AES_128_GCM_SHA256.
+	TLSVersion  string
+	CipherSuite string
+}
+
+// ChainCertificate describes a certificate presented by a server.
+type ChainCertificate struct {
+	Subject     string    `json:"subject"`
+	Issuer      string    `json:"issuer"`
+	NotBefore   time.Time `json:"notBefore"`
+	NotAfter    time.Time `json:"notAfter"`
+	Fingerprint string    `json:"fingerprint"` // SHA-256
+}
+
+func newChainCertificate(cert *x509.Certificate) ChainCertificate {
+	fingerprint := sha256.Sum256(cert.Raw)
+	return ChainCertificate{
+		Subject:     cert.Subject.String(),
+		Issuer:      cert.Issuer.String(),
+		NotBefore:   cert.NotBefore,
+		NotAfter:    cert.NotAfter,
+		Fingerprint: hex.EncodeToString(fingerprint[:]),
+	}
+}
+
+// DefaultPort is the port certificates are checked on, for hosts that
+// don't specify one.
+const DefaultPort = "443"
+
+// Protocols that certificates can be checked with, for Options.Protocol
+const (
+	ProtocolTLS  = "tls"  // TLS from the start, as for HTTPS (the default)
+	ProtocolSMTP = "smtp" // SMTP, upgraded to TLS with STARTTLS
+)
+
+// defaultPorts are the ports for hosts that don't specify one, by protocol.
+var defaultPorts = map[string]string{
+	"":           DefaultPort,
+	ProtocolTLS:  DefaultPort,
+	ProtocolSMTP: "25",
+}
+
+// SplitHostPort splits name, a hostname with an optional port such as
+// mail.example.com:8443, into the host and the port, which is DefaultPort
+// if name doesn't have one.
+func SplitHostPort(name string) (host, port string) {
+	return splitHostPort(name, DefaultPort)
+}
+
+func splitHostPort(name, defaultPort string) (host, port string) {
+	if host, port, err := net.SplitHostPort(name); err == nil {
+		return host, port
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(name, "["), "]"), defaultPort
+}
+
+// checkTarget returns the host and port to check for hostname, which may
+// have a port, with opts.
+func checkTarget(hostname string, opts Options) (host, port string, err error) {
+	defaultPort, ok := defaultPorts[opts.Protocol]
+	if !ok {
+		return "", "", fmt.Errorf("unknown protocol %q, expected tls or smtp", opts.Protocol)
+	}
+	host, port = splitHostPort(hostname, defaultPort)
+	if host, err = ToASCII(host); err != nil {
+		return "", "", err
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "", "", fmt.Errorf("invalid port %q", port)
+	}
+	return host, port, nil
+}
+
+// handshake connects to host on port and makes a TLS handshake with
+// config, after STARTTLS if opts.Protocol requires it. It returns the
+// connection state and the address of the server connected to.
+func handshake(ctx context.Context, host, port string, opts Options, config *tls.Config) (tls.ConnectionState, string, error) {
+	address := net.JoinHostPort(host, port)
+	traceResolve(ctx, host)
+	Tracef(ctx, "dialing %s", address)
+	plaintextConn, err := dial(ctx, opts.Dialer, "tcp", address)
+	if err != nil {
+		Tracef(ctx, "dial failed: %s", err)
+		return tls.ConnectionState{}, "", err
+	}
+	defer plaintextConn.Close()
+	remoteAddress := plaintextConn.RemoteAddr().String()
+	Tracef(ctx, "connected to %s", remoteAddress)
+	if deadline, ok := ctx.Deadline(); ok {
+		plaintextConn.SetDeadline(deadline)
+	}
+
+	var state tls.ConnectionState
+	if opts.Protocol == ProtocolSMTP {
+		state, err = startTLSSMTP(ctx, plaintextConn, config)
+	} else {
+		conn := tls.Client(plaintextConn, config)
+		err = conn.Handshake()
+		state = conn.ConnectionState()
+	}
+	if err != nil {
+		Tracef(ctx, "TLS handshake failed: %s", err)
+		return state, remoteAddress, err
+	}
+	traceConnectionState(ctx, state)
+	return state, remoteAddress, nil
+}
+
+// CheckCertificate connects to hostname, on the default port for
+// opts.Protocol unless it specifies another like mail.example.com:8443, and
+// returns when the first of the certificates it presents expires. The chain
+// is verified against the system roots, or against each of
+// opts.TrustStores. Internationalized hostnames may be given in Unicode.
+// The check gives up after opts.Timeout.
+func CheckCertificate(ctx context.Context, hostname string, opts Options) (CertificateResult, error) {
+	ctx, cancel := opts.withTimeout(ctx)
+	defer cancel()
+	rv := CertificateResult{}
+	hostname, port, err := checkTarget(hostname, opts)
+	if err != nil {
+		return rv, err
+	}
+
+	// The chain is verified after the handshake, so that the expiration
+	// of a certificate that isn't valid can still be reported. When
+	// specific trust stores are requested, it is verified against each of
+	// them instead of against the system roots.
+	config := &tls.Config{
+		ServerName:         hostname,
+		InsecureSkipVerify: true,
+	}
+	if opts.OmitSNI {
+		config.ServerName = ""
+	}
+	state, address, err := handshake(ctx, hostname, port, opts, config)
+	rv.Address = address
+	if err != nil {
+		return rv, err
+	}
+	rv.TLSVersion = TLSVersionName(state.Version)
+	rv.CipherSuite = tls.CipherSuiteName(state.CipherSuite)
+
+	if len(state.PeerCertificates) == 0 {
+		err := fmt.Errorf("weird connection state: %#v", state)
+		return rv, err
+	}
+
+	var minExpires time.Time
+
+	for _, cert := range state.PeerCertificates {
+		rv.Chain = append(rv.Chain, newChainCertificate(cert))
+		if minExpires.IsZero() || cert.NotAfter.Before(minExpires) {
+			minExpires = cert.NotAfter
+			rv.NotBefore = cert.NotBefore
+		}
+	}
+	rv.Expires = minExpires
+
+	leaf := state.PeerCertificates[0]
+	rv.ValidationLevel = validationLevel(leaf)
+	if lifetime := leaf.NotAfter.Sub(leaf.NotBefore); opts.MaxCertificateLifetime > 0 && lifetime > opts.MaxCertificateLifetime {
+		rv.Warnings = append(rv.Warnings, fmt.Sprintf("certificate is valid for %d days, longer than the maximum of %d days",
+			int(lifetime.Hours()/24), int(opts.MaxCertificateLifetime.Hours()/24)))
+	}
+
+	if opts.OmitSNI {
+		return rv, nil
+	}
+	rootPools := []*x509.CertPool{nil} // the system roots
+	if len(opts.TrustStores) > 0 {
+		rv.TrustStores = verifyChain(hostname, state.PeerCertificates, opts.TrustStores)
+		rootPools = rootPools[:0]
+		for _, name := range opts.TrustStores {
+			rootPools = append(rootPools, trustStores[name])
+		}
+	}
+
+	// the chain is valid if any of the trust stores accepts it, and
+	// otherwise the problems are those found with the store that accepts
+	// it most nearly, e.g. one that has the root, for the wrong host
+	var problems []ValidationProblem
+	for i, roots := range rootPools {
+		p := validateChain(hostname, state.PeerCertificates, roots, time.Now())
+		if i == 0 || len(p) < len(problems) {
+			problems = p
+		}
+	}
+	if len(problems) > 0 {
+		for _, p := range problems {
+			Tracef(ctx, "certificate is not valid: %s", p.Message)
+		}
+		return rv, ValidationError{Problems: problems}
+	}
+	return rv, nil
+}
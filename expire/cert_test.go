@@ -0,0 +1,17 @@
+package expire
+
+import "testing"
+
+func TestSplitHostPort(t *testing.T) {
+	for name, want := range map[string][2]string{
+		"example.com":           {"example.com", "443"},
+		"mail.example.com:8443": {"mail.example.com", "8443"},
+		"192.0.2.1:993":         {"192.0.2.1", "993"},
+		"[2001:db8::1]:8443":    {"2001:db8::1", "8443"},
+		"[2001:db8::1]":         {"2001:db8::1", "443"},
+	} {
+		if host, port := SplitHostPort(name); host != want[0] || port != want[1] {
+			t.Errorf("%s: expected %s %s, got %s %s", name, want[0], want[1], host, port)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package expire
+
+import (
+	"context"
+	"net"
+)
+
+// Dialer makes the outbound connections for certificate checks. It is
+// satisfied by *net.Dialer, and can be replaced, e.g. to route checks
+// through a proxy, or in tests to connect to a fake server.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// DefaultDialer is used by checks that don't specify a dialer.
+var DefaultDialer Dialer = &net.Dialer{}
+
+// dial connects to address with dialer, or the default dialer if it is
+// nil, giving up when ctx is done.
+func dial(ctx context.Context, dialer Dialer, network, address string) (net.Conn, error) {
+	if dialer == nil {
+		dialer = DefaultDialer
+	}
+	return dialer.DialContext(ctx, network, address)
+}
@@ -0,0 +1,145 @@
+package expire
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDialer connects to a test server whatever address is dialed, and
+// remembers the addresses. Check dials concurrently, so they are only
+// read once it returns.
+type fakeDialer struct {
+	target string
+
+	mu        sync.Mutex
+	addresses []string
+}
+
+func (d *fakeDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	d.mu.Lock()
+	d.addresses = append(d.addresses, address)
+	d.mu.Unlock()
+	return (&net.Dialer{}).DialContext(ctx, network, d.target)
+}
+
+// trustTestServer adds the certificate of server, which is its own root, as
+// a trust store, and returns the store's name.
+func trustTestServer(server *httptest.Server) string {
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	AddTrustStore("test", pool)
+	return "test"
+}
+
+func TestCheckCertificateDialer(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	dialer := &fakeDialer{target: server.Listener.Addr().String()}
+	opts := Options{Dialer: dialer}
+
+	// the test server's certificate isn't trusted, but it is the one we
+	// got
+	result, err := CheckCertificate(context.Background(), "example.com", opts)
+	if err == nil || !strings.Contains(err.Error(), "unknown authority") {
+		t.Errorf("expected an unknown authority error, got %v", err)
+	}
+	if !IsValidationError(err) || result.Expires.IsZero() {
+		t.Errorf("expected the expiration of the invalid certificate, got %+v", result)
+	}
+	if len(dialer.addresses) != 1 || dialer.addresses[0] != "example.com:443" {
+		t.Errorf("unexpected addresses dialed %v", dialer.addresses)
+	}
+
+	// with a port, the certificate is still checked against the hostname
+	_, err = CheckCertificate(context.Background(), "example.com:8443", opts)
+	if err == nil || !strings.Contains(err.Error(), "unknown authority") {
+		t.Errorf("expected an unknown authority error, got %v", err)
+	}
+	if len(dialer.addresses) != 2 || dialer.addresses[1] != "example.com:8443" {
+		t.Errorf("unexpected addresses dialed %v", dialer.addresses)
+	}
+	if _, err := CheckCertificate(context.Background(), "example.com:https", opts); err == nil || len(dialer.addresses) != 2 {
+		t.Errorf("expected an invalid port error, got %v", err)
+	}
+
+	opts.TrustStores = []string{"mozilla"}
+	if _, err := CheckCertificate(context.Background(), "example.com", opts); !IsValidationError(err) {
+		t.Errorf("expected a validation error with a trust store that doesn't have the root, got %v", err)
+	}
+
+	opts.TrustStores = []string{"mozilla", trustTestServer(server)}
+	result, err = CheckCertificate(context.Background(), "example.com", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := server.Certificate()
+	if !result.Expires.Equal(leaf.NotAfter) || result.Address != server.Listener.Addr().String() {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	// trusted, but for another host
+	_, err = CheckCertificate(context.Background(), "example.org", opts)
+	if verr, ok := err.(ValidationError); !ok || problemCodes(verr.Problems) != ProblemHostnameMismatch {
+		t.Errorf("expected a hostname mismatch, got %v", err)
+	}
+}
+
+func TestCheckCertificateTimeout(t *testing.T) {
+	// a server that accepts connections but never starts the handshake
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	opts := Options{Dialer: &fakeDialer{target: l.Addr().String()}, Timeout: 50 * time.Millisecond}
+	start := time.Now()
+	_, err = CheckCertificate(context.Background(), "example.com", opts)
+	if err == nil {
+		t.Fatalf("expected a timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the check to give up after the timeout, took %s", elapsed)
+	}
+}
+
+func TestCheckCertificateOmitSNI(t *testing.T) {
+	var serverNames []string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		serverNames = append(serverNames, hello.ServerName)
+		return nil, nil
+	}}
+	server.StartTLS()
+	defer server.Close()
+
+	opts := Options{Dialer: &fakeDialer{target: server.Listener.Addr().String()}, OmitSNI: true}
+	result, err := CheckCertificate(context.Background(), "example.com", opts)
+	if err != nil {
+		t.Fatalf("expected the unverified certificate to be reported, got %s", err)
+	}
+	if result.Expires.IsZero() || len(result.Chain) == 0 || result.TLSVersion != "TLS 1.3" || result.CipherSuite == "" {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if len(serverNames) != 1 || serverNames[0] != "" {
+		t.Errorf("expected no server name, got %q", serverNames)
+	}
+}
@@ -0,0 +1,183 @@
+// Package expire checks when the TLS certificates and domain registrations
+// of hosts expire.
+//
+// CheckCertificate and CheckDomain make a single check. Check checks a list
+// of hosts, running the certificate checks concurrently and looking up each
+// registered domain only once.
+package expire
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxCertificateLifetime is the limit imposed by the CA/Browser
+	// Forum baseline requirements on certificates issued after September
+	// 2020.
+	DefaultMaxCertificateLifetime = 398 * 24 * time.Hour
+
+	// DefaultConcurrency is how many checks Check runs at once, unless
+	// Options says otherwise.
+	DefaultConcurrency = 8
+
+	// DefaultTimeout is how long a certificate or domain check may take,
+	// unless Options says otherwise.
+	DefaultTimeout = 15 * time.Second
+)
+
+// Options control how hosts are checked. The zero value checks with the
+// defaults.
+type Options struct {
+	// TrustStores are the names of the trust stores to verify certificate
+	// chains against, after the handshake. The chain is valid if any of
+	// them accepts it. When empty, it is verified against the system roots.
+	TrustStores []string
+
+	// MaxCertificateLifetime is the longest validity period a leaf
+	// certificate may have before a warning is reported. Zero means no
+	// limit.
+	MaxCertificateLifetime time.Duration
+
+	// Concurrency is the number of checks that run at once (default:
+	// DefaultConcurrency)
+	Concurrency int
+
+	// Protocol is how to start TLS: ProtocolTLS (the default) for
+	// services like HTTPS that start with it, or ProtocolSMTP for mail
+	// servers that upgrade to it with STARTTLS.
+	Protocol string
+
+	// Dialer connects to hosts to check their certificates (default:
+	// DefaultDialer)
+	Dialer Dialer
+
+	// OmitSNI leaves the hostname out of the handshake, to find the
+	// certificate a server presents to clients that don't send it: its
+	// default certificate. The chain isn't verified, since the default
+	// certificate needn't be for the hostname.
+	OmitSNI bool
+
+	// Timeout is how long each certificate and domain check may take,
+	// including connecting, the handshake and any whois retries and
+	// referrals (default: DefaultTimeout)
+	Timeout time.Duration
+}
+
+// withTimeout returns ctx limited to the check timeout in opts.
+func (opts Options) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Expiration is when the certificate and domain of a host expire. If a
+// check fails, the error is reported instead.
+type Expiration struct {
+	Name string
+
+	CertificateExpires   time.Time
+	CertificateNotBefore time.Time // of the certificate that expires first
+	CertificateError     error
+
+	// CertificateChain is the certificates presented by the server, leaf
+	// first, so that an intermediate that expires first can be told apart
+	// from the leaf.
+	CertificateChain []ChainCertificate
+
+	Domain        string // the registered domain, e.g. example.com for www.example.com
+	DomainExpires time.Time
+	DomainError   error
+
+	// Warnings are problems that don't cause a check to fail, e.g. a
+	// certificate valid for longer than Options.MaxCertificateLifetime
+	Warnings []string
+}
+
+// Check returns the expirations of hostnames, in the same order. Checks
+// that haven't started when ctx is done fail with its error.
+func Check(ctx context.Context, hostnames []string, opts Options) []Expiration {
+	concurrency := opts.Concurrency
+	if concurrency == 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	rv := make([]Expiration, len(hostnames))
+	ForEach(len(hostnames), concurrency, func(i int) {
+		rv[i].Name = hostnames[i]
+		if err := ctx.Err(); err != nil {
+			rv[i].CertificateError = err
+			return
+		}
+		result, err := CheckCertificate(ctx, hostnames[i], opts)
+		rv[i].CertificateExpires = result.Expires
+		rv[i].CertificateNotBefore = result.NotBefore
+		rv[i].CertificateChain = result.Chain
+		rv[i].CertificateError = err
+		rv[i].Warnings = result.Warnings
+	})
+
+	var domains []string
+	seen := map[string]bool{}
+	for i, hostname := range hostnames {
+		host, _ := SplitHostPort(hostname)
+		domain, err := EffectiveTLDPlusOne(host)
+		if err != nil {
+			rv[i].DomainError = err
+			continue
+		}
+		if !seen[domain] {
+			seen[domain] = true
+			domains = append(domains, domain)
+		}
+		rv[i].Domain = domain
+	}
+
+	// each host has only one domain, so the checks for different domains
+	// never update the same result
+	ForEach(len(domains), concurrency, func(d int) {
+		result, err := DomainResult{}, ctx.Err()
+		if err == nil {
+			domainCtx, cancel := opts.withTimeout(ctx)
+			result, err = CheckDomain(domainCtx, domains[d])
+			cancel()
+		}
+		for i := range rv {
+			if rv[i].Domain == domains[d] {
+				rv[i].DomainExpires = result.Expires
+				rv[i].DomainError = err
+			}
+		}
+	})
+	return rv
+}
+
+// ForEach calls f for each i in [0, n), running at most concurrency calls
+// at once.
+func ForEach(n, concurrency int, f func(i int)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	indexes := make(chan int)
+	go func() {
+		for i := 0; i < n; i++ {
+			indexes <- i
+		}
+		close(indexes)
+	}()
+
+	wg := sync.WaitGroup{}
+	for w := 0; w < concurrency && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				f(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
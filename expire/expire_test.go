@@ -0,0 +1,117 @@
+package expire
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestForEach(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	seen := make([]bool, 20)
+	ForEach(len(seen), 3, func(i int) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+		seen[i] = true
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+	})
+	if maxRunning > 3 {
+		t.Errorf("expected at most 3 concurrent calls, got %d", maxRunning)
+	}
+	for i, ok := range seen {
+		if !ok {
+			t.Errorf("expected f to be called for %d", i)
+		}
+	}
+}
+
+// testTLSServer returns a server with a self signed certificate for names.
+func testTLSServer(t *testing.T, names ...string) *httptest.Server {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	server.StartTLS()
+	return server
+}
+
+func TestCheck(t *testing.T) {
+	server := testTLSServer(t, "www.example.test", "example.test")
+	defer server.Close()
+
+	expires := time.Now().Add(200 * 24 * time.Hour).UTC().Truncate(time.Second)
+	var mu sync.Mutex
+	queries := 0
+	rdapServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		queries++
+		mu.Unlock()
+		fmt.Fprintf(w, `{"events": [{"eventAction": "expiration", "eventDate": %q}]}`, expires.Format(time.RFC3339))
+	}))
+	defer rdapServer.Close()
+	defer withRDAPServer(t, rdapServer.URL)()
+
+	opts := Options{
+		TrustStores: []string{trustTestServer(server)},
+		Dialer:      &fakeDialer{target: server.Listener.Addr().String()},
+	}
+	got := Check(context.Background(), []string{"www.example.test", "example.test"}, opts)
+	if len(got) != 2 || got[0].Name != "www.example.test" || got[1].Name != "example.test" {
+		t.Fatalf("unexpected expirations %+v", got)
+	}
+	for _, exp := range got {
+		if exp.CertificateError != nil || !exp.CertificateExpires.Equal(server.Certificate().NotAfter) {
+			t.Errorf("%s: unexpected certificate expiration %s, %v", exp.Name, exp.CertificateExpires, exp.CertificateError)
+		}
+		if exp.Domain != "example.test" || exp.DomainError != nil || !exp.DomainExpires.Equal(expires) {
+			t.Errorf("%s: unexpected domain expiration %s %s, %v", exp.Name, exp.Domain, exp.DomainExpires, exp.DomainError)
+		}
+	}
+	if queries != 1 {
+		t.Errorf("expected the domain to be looked up once, got %d", queries)
+	}
+}
+
+func TestCheckCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, exp := range Check(ctx, []string{"a.cancelled.example.com", "b.cancelled.example.com"}, Options{}) {
+		if exp.CertificateError != context.Canceled || exp.DomainError != context.Canceled {
+			t.Errorf("%s: expected the checks to be skipped, got %v, %v", exp.Name, exp.CertificateError, exp.DomainError)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package expire
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// ToASCII returns name, a hostname with an optional port, with its host
+// converted to the ASCII form used in DNS, TLS and whois, e.g.
+// xn--bcher-kva.example for bücher.example. Names that are already ASCII
+// are returned unchanged.
+func ToASCII(name string) (string, error) {
+	if isASCII(name) {
+		return name, nil
+	}
+	host, port, err := net.SplitHostPort(name)
+	if err != nil {
+		host, port = name, ""
+	}
+	host, err = idna.Lookup.ToASCII(host)
+	if err != nil {
+		return "", fmt.Errorf("invalid internationalized hostname %q: %s", name, err)
+	}
+	if port != "" {
+		return net.JoinHostPort(host, port), nil
+	}
+	return host, nil
+}
+
+// ToUnicode returns name, a hostname with an optional port, with its host
+// converted to Unicode for display, e.g. bücher.example for
+// xn--bcher-kva.example. Names that can't be converted are returned
+// unchanged.
+func ToUnicode(name string) string {
+	if !strings.Contains(strings.ToLower(name), "xn--") {
+		return name
+	}
+	host, port, err := net.SplitHostPort(name)
+	if err != nil {
+		host, port = name, ""
+	}
+	host, err = idna.Lookup.ToUnicode(host)
+	if err != nil {
+		return name
+	}
+	if port != "" {
+		return net.JoinHostPort(host, port)
+	}
+	return host
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,58 @@
+package expire
+
+import "testing"
+
+func TestToASCII(t *testing.T) {
+	for name, want := range map[string]string{
+		"example.com":              "example.com",
+		"bücher.example":           "xn--bcher-kva.example",
+		"Bücher.example:8443":      "xn--bcher-kva.example:8443",
+		"xn--bcher-kva.example":    "xn--bcher-kva.example",
+		"[2001:db8::1]:443":        "[2001:db8::1]:443",
+		"münchen.example.com:smtp": "xn--mnchen-3ya.example.com:smtp",
+	} {
+		got, err := ToASCII(name)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+		} else if got != want {
+			t.Errorf("%s: got %s, want %s", name, got, want)
+		}
+	}
+	if _, err := ToASCII("bü cher.example"); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestToUnicode(t *testing.T) {
+	for name, want := range map[string]string{
+		"example.com":                "example.com",
+		"xn--bcher-kva.example":      "bücher.example",
+		"XN--BCHER-KVA.example:8443": "bücher.example:8443",
+		"bücher.example":             "bücher.example",
+		"xn--a.example":              "xn--a.example",
+	} {
+		if got := ToUnicode(name); got != want {
+			t.Errorf("%s: got %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestEffectiveTLDPlusOneUnicode(t *testing.T) {
+	for domain, want := range map[string]string{
+		"www.bücher.de":         "bücher.de",
+		"www.xn--bcher-kva.de":  "xn--bcher-kva.de",
+		"shop.example.xn--p1ai": "example.xn--p1ai",
+		"shop.пример.рф":        "пример.рф",
+		"www.example.com":       "example.com",
+	} {
+		got, err := EffectiveTLDPlusOne(domain)
+		if err != nil {
+			t.Errorf("%s: %s", domain, err)
+		} else if got != want {
+			t.Errorf("%s: got %s, want %s", domain, got, want)
+		}
+	}
+	if got := PublicSuffix("пример.рф"); got != "рф" {
+		t.Errorf("expected рф, got %s", got)
+	}
+}
@@ -0,0 +1,81 @@
+//go:build ignore
+// +build ignore
+
+// This program generates mozilla_roots.go from a PEM bundle of the Mozilla
+// CA certificate store. Run it with:
+//
+//	go run mozilla_roots_gen.go [-src URL or file]
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+)
+
+func main() {
+	src := flag.String("src", "https://curl.se/ca/cacert.pem", "URL or path of the PEM bundle")
+	out := flag.String("o", "mozilla_roots.go", "output file")
+	flag.Parse()
+
+	var input []byte
+	var err error
+	if strings.HasPrefix(*src, "https://") {
+		var resp *http.Response
+		resp, err = http.Get(*src)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer resp.Body.Close()
+		input, err = ioutil.ReadAll(resp.Body)
+	} else {
+		input, err = ioutil.ReadFile(*src)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	buf := bytes.Buffer{}
+	fmt.Fprintln(&buf, "// Code generated by mozilla_roots_gen.go; DO NOT EDIT.")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "package expire")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "// mozillaRootsPEM are the root certificates in the Mozilla CA certificate store.")
+	fmt.Fprintln(&buf, "const mozillaRootsPEM = `")
+
+	count := 0
+	for {
+		var block *pem.Block
+		block, input = pem.Decode(input)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(&buf, "# %s\n", strings.Replace(cert.Subject.String(), "`", "'", -1))
+		pem.Encode(&buf, block)
+		count++
+	}
+	fmt.Fprintln(&buf, "`")
+
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(*out, formatted, 0644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("wrote %d certificates to %s", count, *out)
+}
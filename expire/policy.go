@@ -0,0 +1,55 @@
+package expire
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+)
+
+// legacyTLSVersions are the protocol versions deprecated by RFC 8996.
+var legacyTLSVersions = []uint16{tls.VersionTLS10, tls.VersionTLS11}
+
+// CheckTLSPolicy makes further handshakes with hostname to see whether it
+// still accepts the legacy protocol versions TLS 1.0 and 1.1, or cipher
+// suites with known weaknesses, and returns a description of each that it
+// accepts. The certificate isn't verified, as CheckCertificate does that.
+func CheckTLSPolicy(ctx context.Context, hostname string, opts Options) ([]string, error) {
+	ctx, cancel := opts.withTimeout(ctx)
+	defer cancel()
+	host, port, err := checkTarget(hostname, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var rv []string
+	for _, version := range legacyTLSVersions {
+		config := &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: true,
+			MinVersion:         version,
+			MaxVersion:         version,
+		}
+		if _, _, err := handshake(ctx, host, port, opts, config); err == nil {
+			rv = append(rv, fmt.Sprintf("server accepts %s, which is deprecated", TLSVersionName(version)))
+		}
+		if err := ctx.Err(); err != nil {
+			return rv, err
+		}
+	}
+
+	var weak []uint16
+	for _, suite := range tls.InsecureCipherSuites() {
+		weak = append(weak, suite.ID)
+	}
+	config := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS10,
+		MaxVersion:         tls.VersionTLS12,
+		CipherSuites:       weak,
+	}
+	if state, _, err := handshake(ctx, host, port, opts, config); err == nil {
+		rv = append(rv, fmt.Sprintf("server accepts the weak cipher suite %s", tls.CipherSuiteName(state.CipherSuite)))
+	}
+	return rv, ctx.Err()
+}
@@ -0,0 +1,44 @@
+package expire
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCheckTLSPolicy(t *testing.T) {
+	modern := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer modern.Close()
+
+	legacy := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	legacy.TLS = &tls.Config{
+		MinVersion:   tls.VersionTLS10,
+		CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA, tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA},
+	}
+	legacy.StartTLS()
+	defer legacy.Close()
+
+	for _, tt := range []struct {
+		server *httptest.Server
+		want   []string
+	}{
+		{modern, nil},
+		{legacy, []string{
+			"server accepts TLS 1.0, which is deprecated",
+			"server accepts TLS 1.1, which is deprecated",
+			"server accepts the weak cipher suite TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA",
+		}},
+	} {
+		opts := Options{Dialer: &fakeDialer{target: tt.server.Listener.Addr().String()}}
+		got, err := CheckTLSPolicy(context.Background(), "example.com", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expected %q, got %q", tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package expire
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+)
+
+const DefaultPublicSuffixListURL = "https://publicsuffix.org/list/public_suffix_list.dat"
+
+// suffixList is a parsed public suffix list, see
+// https://publicsuffix.org/list/
+type suffixList struct {
+	rules      map[string]bool // example.com
+	wildcards  map[string]bool // *.example.com (stored without the "*.")
+	exceptions map[string]bool // !www.example.com (stored without the "!")
+}
+
+func parseSuffixList(r io.Reader) (*suffixList, error) {
+	l := &suffixList{
+		rules:      map[string]bool{},
+		wildcards:  map[string]bool{},
+		exceptions: map[string]bool{},
+	}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		rule := strings.Fields(line)[0]
+
+		m := l.rules
+		if strings.HasPrefix(rule, "!") {
+			m, rule = l.exceptions, rule[1:]
+		} else if strings.HasPrefix(rule, "*.") {
+			m, rule = l.wildcards, rule[2:]
+		}
+		rule, err := idna.ToASCII(rule)
+		if err != nil {
+			continue
+		}
+		m[strings.ToLower(rule)] = true
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *suffixList) Len() int {
+	return len(l.rules) + len(l.wildcards) + len(l.exceptions)
+}
+
+// PublicSuffix returns the public suffix of domain. As with
+// publicsuffix.PublicSuffix, when no rule matches the last label is
+// assumed to be the suffix.
+func (l *suffixList) PublicSuffix(domain string) string {
+	labels := strings.Split(strings.ToLower(domain), ".")
+	for i := range labels {
+		candidate := strings.Join(labels[i:], ".")
+		if l.exceptions[candidate] {
+			return strings.Join(labels[i+1:], ".")
+		}
+		if l.rules[candidate] {
+			return candidate
+		}
+		if i+1 < len(labels) && l.wildcards[strings.Join(labels[i+1:], ".")] {
+			return candidate
+		}
+	}
+	return labels[len(labels)-1]
+}
+
+// EffectiveTLDPlusOne returns the public suffix of domain plus one more
+// label, i.e. the registrable domain.
+func (l *suffixList) EffectiveTLDPlusOne(domain string) (string, error) {
+	suffix := l.PublicSuffix(domain)
+	if len(domain) <= len(suffix) {
+		return "", fmt.Errorf("cannot derive eTLD+1 for domain %q", domain)
+	}
+	rest := domain[:len(domain)-len(suffix)-1]
+	if i := strings.LastIndex(rest, "."); i >= 0 {
+		rest = rest[i+1:]
+	}
+	if rest == "" {
+		return "", fmt.Errorf("cannot derive eTLD+1 for domain %q", domain)
+	}
+	return rest + "." + suffix, nil
+}
+
+var (
+	suffixesMu sync.RWMutex
+	suffixes   *suffixList // nil until a list has been fetched
+)
+
+// PublicSuffix returns the public suffix of domain using the most recently
+// fetched list, or the list compiled into golang.org/x/net/publicsuffix if
+// none has been fetched. Internationalized domains may be given in either
+// form, and the suffix is returned in the same form.
+func PublicSuffix(domain string) string {
+	ascii, err := ToASCII(domain)
+	if err != nil {
+		ascii = domain
+	}
+	suffixesMu.RLock()
+	l := suffixes
+	suffixesMu.RUnlock()
+	var suffix string
+	if l == nil {
+		suffix, _ = publicsuffix.PublicSuffix(ascii)
+	} else {
+		suffix = l.PublicSuffix(ascii)
+	}
+	if ascii != domain {
+		suffix = ToUnicode(suffix)
+	}
+	return suffix
+}
+
+// EffectiveTLDPlusOne returns the registrable domain for domain, using the
+// same list as PublicSuffix, in the same form as domain.
+func EffectiveTLDPlusOne(domain string) (string, error) {
+	ascii, err := ToASCII(domain)
+	if err != nil {
+		return "", err
+	}
+	suffixesMu.RLock()
+	l := suffixes
+	suffixesMu.RUnlock()
+	var rv string
+	if l == nil {
+		rv, err = publicsuffix.EffectiveTLDPlusOne(ascii)
+	} else {
+		rv, err = l.EffectiveTLDPlusOne(ascii)
+	}
+	if err != nil || ascii == domain {
+		return rv, err
+	}
+	return ToUnicode(rv), nil
+}
+
+// minSuffixListLen is the fewest rules a fetched list must have before we
+// will use it. It protects against replacing the built in list with an
+// error page or a truncated download.
+const minSuffixListLen = 1000
+
+// suffixListClient fetches the public suffix list. The timeout keeps a
+// stalled download from stopping the refreshes for good.
+var suffixListClient = &http.Client{Timeout: time.Minute}
+
+func fetchSuffixList(url string) (*suffixList, error) {
+	resp, err := suffixListClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	l, err := parseSuffixList(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if l.Len() < minSuffixListLen {
+		return nil, fmt.Errorf("%s: only %d rules, expected at least %d", url, l.Len(), minSuffixListLen)
+	}
+	return l, nil
+}
+
+// RefreshSuffixList fetches the public suffix list from url every interval,
+// forever. If a fetch fails the previous list remains in use.
+func RefreshSuffixList(url string, interval time.Duration) {
+	for {
+		l, err := fetchSuffixList(url)
+		if err != nil {
+			log.Printf("cannot refresh public suffix list: %s", err)
+		} else {
+			suffixesMu.Lock()
+			suffixes = l
+			suffixesMu.Unlock()
+			log.Printf("loaded %d public suffix rules from %s", l.Len(), url)
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,57 @@
+package expire
+
+import (
+	"strings"
+	"testing"
+)
+
+const testSuffixList = `
+// comment
+com
+uk
+co.uk
+
+// wildcards and exceptions
+ck
+*.ck
+!www.ck
+*.kawasaki.jp
+!city.kawasaki.jp
+jp
+`
+
+func TestSuffixList(t *testing.T) {
+	l, err := parseSuffixList(strings.NewReader(testSuffixList))
+	if err != nil {
+		t.Fatalf("parseSuffixList: %s", err)
+	}
+
+	tests := []struct {
+		domain, suffix, registrable string
+	}{
+		{"www.example.com", "com", "example.com"},
+		{"example.co.uk", "co.uk", "example.co.uk"},
+		{"a.b.example.co.uk", "co.uk", "example.co.uk"},
+		{"foo.bar.ck", "bar.ck", "foo.bar.ck"},
+		{"www.ck", "ck", "www.ck"},
+		{"a.www.ck", "ck", "www.ck"},
+		{"x.city.kawasaki.jp", "kawasaki.jp", "city.kawasaki.jp"},
+		{"x.y.kawasaki.jp", "y.kawasaki.jp", "x.y.kawasaki.jp"},
+		{"example.unknowntld", "unknowntld", "example.unknowntld"},
+	}
+	for _, tt := range tests {
+		if got := l.PublicSuffix(tt.domain); got != tt.suffix {
+			t.Errorf("%s: expected suffix %s, got %s", tt.domain, tt.suffix, got)
+		}
+		got, err := l.EffectiveTLDPlusOne(tt.domain)
+		if err != nil {
+			t.Errorf("%s: %s", tt.domain, err)
+		} else if got != tt.registrable {
+			t.Errorf("%s: expected %s, got %s", tt.domain, tt.registrable, got)
+		}
+	}
+
+	if _, err := l.EffectiveTLDPlusOne("co.uk"); err == nil {
+		t.Errorf("expected error for a bare public suffix")
+	}
+}
@@ -0,0 +1,117 @@
+package expire
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// methodRDAP is the method of candidates found in RDAP responses
+const methodRDAP = "rdap"
+
+// maxRDAPResponseSize limits how much of an RDAP response is read.
+const maxRDAPResponseSize = 1 << 20
+
+var rdapClient = &http.Client{Timeout: 30 * time.Second}
+
+// rdapDomain is the part of an RDAP domain object (RFC 9083) that we
+// need.
+type rdapDomain struct {
+	LDHName string `json:"ldhName"`
+	Events  []struct {
+		Action string `json:"eventAction"`
+		Date   string `json:"eventDate"`
+	} `json:"events"`
+}
+
+// errNoRDAPServer is returned for domains whose registry doesn't appear in
+// the RDAP bootstrap file.
+var errNoRDAPServer = fmt.Errorf("no RDAP server for domain")
+
+// getRDAPExpiration returns the expiration date for domain from the RDAP
+// servers listed for its TLD in the bootstrap file, trying each in turn.
+func getRDAPExpiration(ctx context.Context, domain string) (DomainResult, error) {
+	rv := DomainResult{Source: methodRDAP}
+	servers := rdapServers(domain)
+	if len(servers) == 0 {
+		return rv, errNoRDAPServer
+	}
+
+	var err error
+	for _, server := range servers {
+		var result DomainResult
+		result, err = queryRDAP(ctx, server, domain)
+		if err == nil {
+			return result, nil
+		}
+		Tracef(ctx, "RDAP query to %s failed: %s", server, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return rv, err
+}
+
+// queryRDAP asks the RDAP server at base for the expiration of domain.
+func queryRDAP(ctx context.Context, base, domain string) (DomainResult, error) {
+	rv := DomainResult{Source: methodRDAP}
+	u := strings.TrimSuffix(base, "/") + "/domain/" + url.PathEscape(domain)
+	Tracef(ctx, "querying RDAP server %s for %s", base, domain)
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return rv, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/rdap+json")
+	resp, err := rdapClient.Do(req)
+	if err != nil {
+		return rv, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return rv, fmt.Errorf("%s", resp.Status)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxRDAPResponseSize))
+	if err != nil {
+		return rv, err
+	}
+	rv.Whois = string(body)
+	Tracef(ctx, "received %d bytes from RDAP server %s", len(body), base)
+
+	var object rdapDomain
+	if err := json.Unmarshal(body, &object); err != nil {
+		return rv, fmt.Errorf("cannot parse RDAP response: %s", err)
+	}
+	for _, event := range object.Events {
+		if event.Action != "expiration" {
+			continue
+		}
+		expires, err := time.Parse(time.RFC3339, event.Date)
+		if err != nil {
+			return rv, fmt.Errorf("cannot parse RDAP expiration %q: %s", event.Date, err)
+		}
+		candidate := DomainCandidate{
+			Source:  methodRDAP,
+			Server:  req.URL.Host,
+			Method:  methodRDAP,
+			Line:    fmt.Sprintf("%s: %s", event.Action, event.Date),
+			Expires: expires,
+		}
+		if !isPlausibleExpiry(expires, time.Now()) {
+			return rv, fmt.Errorf("RDAP expiration %s is implausible", expires.Format("2006-01-02"))
+		}
+		Tracef(ctx, "RDAP expiration is %s", expires)
+		rv.Expires = expires
+		rv.Candidates = []DomainCandidate{candidate}
+		rv.Confidence = ConfidenceHigh
+		return rv, nil
+	}
+	return rv, fmt.Errorf("RDAP response has no expiration event")
+}
@@ -0,0 +1,27 @@
+package expire
+
+// bundledRDAPBootstrap is the IANA RDAP bootstrap file for domain names.
+// This copy is a hand-written subset for the most common TLDs, which is
+// used until the full file is fetched. Run go generate, which writes this
+// file with rdap_bootstrap_gen.go, to replace it with the full file.
+const bundledRDAPBootstrap = `{
+  "description": "RDAP bootstrap file for Domain Name System registrations",
+  "publication": "",
+  "services": [
+    [["app","dev","page","new","how","soy"],["https://pubapi.registry.google/rdap/"]],
+    [["br"],["https://rdap.registro.br/"]],
+    [["ca"],["https://rdap.ca.fury.ca/rdap/"]],
+    [["cc"],["https://tld-rdap.verisign.com/cc/v1/"]],
+    [["com"],["https://rdap.verisign.com/com/v1/"]],
+    [["cz"],["https://rdap.nic.cz/"]],
+    [["fr"],["https://rdap.nic.fr/"]],
+    [["info","io","sh","ac"],["https://rdap.identitydigital.services/rdap/"]],
+    [["net"],["https://rdap.verisign.com/net/v1/"]],
+    [["nl"],["https://rdap.sidn.nl/"]],
+    [["no"],["https://rdap.norid.no/"]],
+    [["org"],["https://rdap.publicinterestregistry.org/rdap/"]],
+    [["uk"],["https://rdap.nominet.uk/uk/"]],
+    [["xyz"],["https://rdap.centralnic.com/xyz/"]]
+  ],
+  "version": "1.0"
+}`
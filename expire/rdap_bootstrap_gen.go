@@ -0,0 +1,94 @@
+//go:build ignore
+// +build ignore
+
+// This program generates rdap_bootstrap.go from the IANA RDAP bootstrap
+// file for domain names. Run it with:
+//
+//	go run rdap_bootstrap_gen.go [-src URL or file]
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func main() {
+	src := flag.String("src", "https://data.iana.org/rdap/dns.json", "URL or path of the bootstrap file")
+	out := flag.String("o", "rdap_bootstrap.go", "output file")
+	flag.Parse()
+
+	var input []byte
+	var err error
+	if strings.HasPrefix(*src, "https://") {
+		var resp *http.Response
+		client := &http.Client{Timeout: time.Minute}
+		resp, err = client.Get(*src)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer resp.Body.Close()
+		input, err = ioutil.ReadAll(resp.Body)
+	} else {
+		input, err = ioutil.ReadFile(*src)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// re-encode the file, one service per line, to check that it is valid
+	// and keep diffs readable
+	var file struct {
+		Description string       `json:"description"`
+		Publication string       `json:"publication"`
+		Services    [][][]string `json:"services"`
+		Version     string       `json:"version"`
+	}
+	if err := json.Unmarshal(input, &file); err != nil {
+		log.Fatal(err)
+	}
+	if strings.Contains(string(input), "`") {
+		log.Fatal("bootstrap file contains a backquote")
+	}
+
+	buf := bytes.Buffer{}
+	fmt.Fprintln(&buf, "// Code generated by rdap_bootstrap_gen.go; DO NOT EDIT.")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "package expire")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "// bundledRDAPBootstrap is the IANA RDAP bootstrap file for domain names.")
+	fmt.Fprintln(&buf, "const bundledRDAPBootstrap = `{")
+	fmt.Fprintf(&buf, "  \"description\": %q,\n", file.Description)
+	fmt.Fprintf(&buf, "  \"publication\": %q,\n", file.Publication)
+	fmt.Fprintln(&buf, "  \"services\": [")
+	for i, service := range file.Services {
+		line, err := json.Marshal(service)
+		if err != nil {
+			log.Fatal(err)
+		}
+		sep := ","
+		if i == len(file.Services)-1 {
+			sep = ""
+		}
+		fmt.Fprintf(&buf, "    %s%s\n", line, sep)
+	}
+	fmt.Fprintln(&buf, "  ],")
+	fmt.Fprintf(&buf, "  \"version\": %q\n", file.Version)
+	fmt.Fprintln(&buf, "}`")
+
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(*out, formatted, 0644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("wrote %d services to %s", len(file.Services), *out)
+}
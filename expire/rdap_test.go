@@ -0,0 +1,69 @@
+package expire
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// withRDAPServer uses the RDAP server at u for the test TLD until the
+// returned function is called.
+func withRDAPServer(t *testing.T, u string) func() {
+	b, err := parseRDAPBootstrap([]byte(fmt.Sprintf(`{"services": [[["test"], [%q]]]}`, u+"/")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rdapBootstrapMu.Lock()
+	prev := bootstrap
+	bootstrap = b
+	rdapBootstrapMu.Unlock()
+	return func() {
+		rdapBootstrapMu.Lock()
+		bootstrap = prev
+		rdapBootstrapMu.Unlock()
+	}
+}
+
+func TestRDAPExpiration(t *testing.T) {
+	expires := time.Now().Add(200 * 24 * time.Hour).UTC().Truncate(time.Second)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/domain/example.test":
+			fmt.Fprintf(w, `{"objectClassName": "domain", "ldhName": "EXAMPLE.TEST", "events": [
+				{"eventAction": "registration", "eventDate": "1995-08-14T04:00:00Z"},
+				{"eventAction": "expiration", "eventDate": %q}
+			]}`, expires.Format(time.RFC3339))
+		case "/domain/noexpiry.test":
+			fmt.Fprint(w, `{"objectClassName": "domain", "ldhName": "NOEXPIRY.TEST", "events": []}`)
+		case "/domain/ancient.test":
+			fmt.Fprint(w, `{"events": [{"eventAction": "expiration", "eventDate": "0001-01-01T00:00:00Z"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	defer withRDAPServer(t, server.URL)()
+
+	result, err := getRDAPExpiration(context.Background(), "example.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Expires.Equal(expires) || result.Source != "rdap" || result.Confidence != ConfidenceHigh {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if len(result.Candidates) != 1 || result.Candidates[0].Method != methodRDAP {
+		t.Errorf("unexpected candidates %+v", result.Candidates)
+	}
+
+	for _, domain := range []string{"noexpiry.test", "ancient.test", "missing.test"} {
+		if _, err := getRDAPExpiration(context.Background(), domain); err == nil {
+			t.Errorf("%s: expected error", domain)
+		}
+	}
+	if _, err := getRDAPExpiration(context.Background(), "example.invalid"); err != errNoRDAPServer {
+		t.Errorf("expected errNoRDAPServer, got %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package expire
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+)
+
+// smtpHelloName is the name we introduce ourselves to mail servers with.
+const smtpHelloName = "localhost"
+
+// startTLSSMTP upgrades conn, to a mail server, to TLS with the SMTP
+// STARTTLS command and returns the state of the TLS connection.
+func startTLSSMTP(ctx context.Context, conn net.Conn, config *tls.Config) (tls.ConnectionState, error) {
+	c, err := smtp.NewClient(conn, config.ServerName)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	if err := c.Hello(smtpHelloName); err != nil {
+		return tls.ConnectionState{}, err
+	}
+	if ok, _ := c.Extension("STARTTLS"); !ok {
+		return tls.ConnectionState{}, fmt.Errorf("%s does not support STARTTLS", config.ServerName)
+	}
+	Tracef(ctx, "sending STARTTLS")
+	if err := c.StartTLS(config); err != nil {
+		return tls.ConnectionState{}, err
+	}
+	state, _ := c.TLSConnectionState()
+	c.Quit()
+	return state, nil
+}
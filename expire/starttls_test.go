@@ -0,0 +1,83 @@
+package expire
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serveSMTP accepts a connection on l and speaks just enough SMTP to
+// start TLS with config, advertising STARTTLS only if startTLS is true.
+func serveSMTP(l net.Listener, config *tls.Config, startTLS bool) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "220 mail.example.com ESMTP\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+		case strings.HasPrefix(cmd, "EHLO"):
+			if startTLS {
+				fmt.Fprint(conn, "250-mail.example.com\r\n250 STARTTLS\r\n")
+			} else {
+				fmt.Fprint(conn, "250 mail.example.com\r\n")
+			}
+		case cmd == "STARTTLS":
+			// the client says EHLO again once TLS is started
+			fmt.Fprint(conn, "220 go ahead\r\n")
+			conn = tls.Server(conn, config)
+			r = bufio.NewReader(conn)
+			startTLS = false
+		default:
+			fmt.Fprint(conn, "221 bye\r\n")
+			return
+		}
+	}
+}
+
+func TestCheckCertificateSMTP(t *testing.T) {
+	// borrow a certificate from a test server
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	for _, startTLS := range []bool{true, false} {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go serveSMTP(l, server.TLS, startTLS)
+
+		dialer := &fakeDialer{target: l.Addr().String()}
+		opts := Options{Protocol: ProtocolSMTP, TrustStores: []string{trustTestServer(server)}, Dialer: dialer}
+		result, err := CheckCertificate(context.Background(), "example.com", opts)
+		l.Close()
+
+		if len(dialer.addresses) != 1 || dialer.addresses[0] != "example.com:25" {
+			t.Errorf("unexpected addresses dialed %v", dialer.addresses)
+		}
+		if !startTLS {
+			if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+				t.Errorf("expected STARTTLS not to be supported, got %v", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Expires.Equal(server.Certificate().NotAfter) {
+			t.Errorf("unexpected result %+v", result)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package expire
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Trace records the steps of a check, so that a check that fails only
+// from one vantage point can be diagnosed.
+type Trace struct {
+	mu    sync.Mutex
+	start time.Time
+	lines []string
+}
+
+type traceKey struct{}
+
+// WithTrace returns a context that records a trace of the checks made
+// with it.
+func WithTrace(ctx context.Context) (context.Context, *Trace) {
+	t := &Trace{start: time.Now()}
+	return context.WithValue(ctx, traceKey{}, t), t
+}
+
+// Tracef adds a step to the trace in ctx, if there is one.
+func Tracef(ctx context.Context, format string, args ...interface{}) {
+	t, ok := ctx.Value(traceKey{}).(*Trace)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lines = append(t.lines, fmt.Sprintf("%6dms ", int64(time.Since(t.start)/time.Millisecond))+
+		fmt.Sprintf(format, args...))
+}
+
+// Lines returns the steps recorded so far.
+func (t *Trace) Lines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string{}, t.lines...)
+}
+
+// Add appends lines recorded by another trace, e.g. the domain check that
+// is shared by several hosts.
+func (t *Trace) Add(lines []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lines = append(t.lines, lines...)
+}
+
+// traceResolve records the addresses hostname resolves to. The lookup is
+// only made when tracing, since the dialer resolves the name itself.
+func traceResolve(ctx context.Context, hostname string) {
+	if _, ok := ctx.Value(traceKey{}).(*Trace); !ok {
+		return
+	}
+	addrs, err := net.DefaultResolver.LookupHost(ctx, hostname)
+	if err != nil {
+		Tracef(ctx, "resolving %s: %s", hostname, err)
+		return
+	}
+	Tracef(ctx, "resolved %s to %s", hostname, strings.Join(addrs, ", "))
+}
+
+var tlsVersions = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	0x0304:           "TLS 1.3",
+}
+
+// TLSVersionName returns the name of a TLS version, e.g. TLS 1.2.
+func TLSVersionName(version uint16) string {
+	if name, ok := tlsVersions[version]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04x", version)
+}
+
+// traceConnectionState records the negotiated TLS parameters and the
+// certificates presented.
+func traceConnectionState(ctx context.Context, state tls.ConnectionState) {
+	Tracef(ctx, "negotiated %s, cipher suite %s, server name %q, protocol %q",
+		TLSVersionName(state.Version), tls.CipherSuiteName(state.CipherSuite), state.ServerName, state.NegotiatedProtocol)
+	for i, cert := range state.PeerCertificates {
+		Tracef(ctx, "certificate %d: subject %q, issuer %q, not before %s, not after %s",
+			i, cert.Subject.String(), cert.Issuer.String(), cert.NotBefore, cert.NotAfter)
+	}
+}
@@ -0,0 +1,27 @@
+package expire
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	// without a trace, Tracef does nothing
+	Tracef(context.Background(), "ignored")
+
+	ctx, tr := WithTrace(context.Background())
+	Tracef(ctx, "dialing %s", "example.com:443")
+	tr.Add([]string{"from the domain check"})
+
+	lines := tr.Lines()
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", lines)
+	}
+	if !strings.HasSuffix(lines[0], "ms dialing example.com:443") {
+		t.Errorf("unexpected line %q", lines[0])
+	}
+	if lines[1] != "from the domain check" {
+		t.Errorf("unexpected line %q", lines[1])
+	}
+}
@@ -0,0 +1,78 @@
+package expire
+
+//go:generate go run mozilla_roots_gen.go
+
+import (
+	"crypto/x509"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// trustStores are the trust stores that can be named in
+// Options.TrustStores. A nil pool means to use the system roots.
+var trustStores = map[string]*x509.CertPool{
+	"system":  nil,
+	"mozilla": mustCertPool(mozillaRootsPEM),
+}
+
+func mustCertPool(pemCerts string) *x509.CertPool {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(pemCerts)) {
+		panic("cannot parse certificates")
+	}
+	return pool
+}
+
+// AddTrustStore makes pool available to Options.TrustStores as name,
+// replacing any trust store with the same name.
+func AddTrustStore(name string, pool *x509.CertPool) {
+	trustStores[name] = pool
+}
+
+// ParseTrustStores parses a comma separated list of trust store names.
+func ParseTrustStores(s string) ([]string, error) {
+	var rv []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := trustStores[name]; !ok {
+			var names []string
+			for name := range trustStores {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown trust store %q (expected one of %s)",
+				name, strings.Join(names, ", "))
+		}
+		rv = append(rv, name)
+	}
+	return rv, nil
+}
+
+// verifyChain verifies the certificates presented by hostname against each
+// of the named trust stores and returns "ok" or the verification error for
+// each.
+func verifyChain(hostname string, certs []*x509.Certificate, stores []string) map[string]string {
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	rv := map[string]string{}
+	for _, name := range stores {
+		_, err := certs[0].Verify(x509.VerifyOptions{
+			DNSName:       hostname,
+			Roots:         trustStores[name],
+			Intermediates: intermediates,
+		})
+		if err != nil {
+			rv[name] = err.Error()
+		} else {
+			rv[name] = "ok"
+		}
+	}
+	return rv
+}
@@ -0,0 +1,63 @@
+package expire
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+)
+
+// Certificate validation levels
+const (
+	ValidationDV = "DV" // domain validated
+	ValidationOV = "OV" // organization validated
+	ValidationIV = "IV" // individual validated
+	ValidationEV = "EV" // extended validation
+)
+
+// The CA/Browser Forum reserved certificate policy identifiers, see
+// https://cabforum.org/object-registry/
+var (
+	oidPolicyEV = asn1.ObjectIdentifier{2, 23, 140, 1, 1}
+	oidPolicyDV = asn1.ObjectIdentifier{2, 23, 140, 1, 2, 1}
+	oidPolicyOV = asn1.ObjectIdentifier{2, 23, 140, 1, 2, 2}
+	oidPolicyIV = asn1.ObjectIdentifier{2, 23, 140, 1, 2, 3}
+
+	// subject attributes that are required in EV certificates
+	oidBusinessCategory    = asn1.ObjectIdentifier{2, 5, 4, 15}
+	oidJurisdictionCountry = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 60, 2, 1, 3}
+)
+
+// validationLevel returns the validation level of cert. The reserved
+// policy identifiers are used when present; otherwise, which is the case
+// for older certificates that only carry CA specific policies, the level
+// is inferred from the subject.
+func validationLevel(cert *x509.Certificate) string {
+	for _, policy := range cert.PolicyIdentifiers {
+		switch {
+		case policy.Equal(oidPolicyEV):
+			return ValidationEV
+		case policy.Equal(oidPolicyOV):
+			return ValidationOV
+		case policy.Equal(oidPolicyIV):
+			return ValidationIV
+		case policy.Equal(oidPolicyDV):
+			return ValidationDV
+		}
+	}
+
+	hasBusinessCategory, hasJurisdiction := false, false
+	for _, name := range cert.Subject.Names {
+		if name.Type.Equal(oidBusinessCategory) {
+			hasBusinessCategory = true
+		}
+		if name.Type.Equal(oidJurisdictionCountry) {
+			hasJurisdiction = true
+		}
+	}
+	if hasBusinessCategory && hasJurisdiction && cert.Subject.SerialNumber != "" {
+		return ValidationEV
+	}
+	if len(cert.Subject.Organization) > 0 {
+		return ValidationOV
+	}
+	return ValidationDV
+}
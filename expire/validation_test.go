@@ -0,0 +1,33 @@
+package expire
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"testing"
+)
+
+func TestValidationLevel(t *testing.T) {
+	tests := []struct {
+		cert  x509.Certificate
+		level string
+	}{
+		{x509.Certificate{PolicyIdentifiers: []asn1.ObjectIdentifier{oidPolicyDV}}, ValidationDV},
+		{x509.Certificate{PolicyIdentifiers: []asn1.ObjectIdentifier{{1, 2, 3}, oidPolicyEV}}, ValidationEV},
+		{x509.Certificate{Subject: pkix.Name{Organization: []string{"Example Corp"}}}, ValidationOV},
+		{x509.Certificate{Subject: pkix.Name{CommonName: "example.com"}}, ValidationDV},
+		{x509.Certificate{Subject: pkix.Name{
+			Organization: []string{"Example Corp"},
+			SerialNumber: "1234",
+			Names: []pkix.AttributeTypeAndValue{
+				{Type: oidBusinessCategory, Value: "Private Organization"},
+				{Type: oidJurisdictionCountry, Value: "US"},
+			},
+		}}, ValidationEV},
+	}
+	for i, tt := range tests {
+		if got := validationLevel(&tt.cert); got != tt.level {
+			t.Errorf("%d: expected %s, got %s", i, tt.level, got)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package expire
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Codes of the problems that CheckCertificate finds when verifying a
+// certificate chain.
+const (
+	ProblemHostnameMismatch    = "hostname-mismatch"
+	ProblemSelfSigned          = "self-signed"
+	ProblemUnknownAuthority    = "unknown-authority"
+	ProblemExpired             = "expired"
+	ProblemNotYetValid         = "not-yet-valid"
+	ProblemExpiredIntermediate = "expired-intermediate"
+	ProblemInvalid             = "invalid" // anything else the verifier objects to
+)
+
+// ValidationProblem is a reason why a certificate chain isn't valid for a
+// host.
+type ValidationProblem struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned by CheckCertificate when the certificate a
+// server presents isn't valid for it. Unlike other errors, the result
+// still has when the certificate expires.
+type ValidationError struct {
+	Problems []ValidationProblem
+}
+
+func (e ValidationError) Error() string {
+	messages := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		messages[i] = p.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// IsValidationError returns true if err is a ValidationError.
+func IsValidationError(err error) bool {
+	_, ok := err.(ValidationError)
+	return ok
+}
+
+// notYetValid returns a problem for each of certs that isn't valid until
+// after now. A certificate deployed early, or a server whose clock is
+// wrong, breaks clients just as an expired certificate does.
+func notYetValid(certs []*x509.Certificate, now time.Time) []ValidationProblem {
+	var rv []ValidationProblem
+	for i, cert := range certs {
+		if !now.Before(cert.NotBefore) {
+			continue
+		}
+		what := "certificate"
+		if i > 0 {
+			what = "intermediate certificate " + cert.Subject.String()
+		}
+		rv = append(rv, ValidationProblem{Code: ProblemNotYetValid,
+			Message: fmt.Sprintf("%s is not valid until %s", what, cert.NotBefore.UTC().Format("2006-01-02 15:04 MST"))})
+	}
+	return rv
+}
+
+// validateChain returns the problems with certs, as presented by hostname,
+// at now: whether the leaf is for hostname, whether any certificate has
+// expired, and whether the chain leads to one of roots, or to one of the
+// system roots if roots is nil.
+func validateChain(hostname string, certs []*x509.Certificate, roots *x509.CertPool, now time.Time) []ValidationProblem {
+	var rv []ValidationProblem
+	leaf := certs[0]
+	if err := leaf.VerifyHostname(hostname); err != nil {
+		rv = append(rv, ValidationProblem{Code: ProblemHostnameMismatch, Message: err.Error()})
+	}
+	if now.After(leaf.NotAfter) {
+		rv = append(rv, ValidationProblem{Code: ProblemExpired,
+			Message: fmt.Sprintf("certificate expired on %s", leaf.NotAfter.Format("2006-01-02"))})
+	}
+	rv = append(rv, notYetValid(certs, now)...)
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	// the leaf's expiration is reported above, so the chain is verified
+	// while the leaf is valid to find any other problems with it
+	at := now
+	if now.After(leaf.NotAfter) || now.Before(leaf.NotBefore) {
+		at = leaf.NotAfter.Add(-time.Second)
+	}
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   at,
+	})
+
+	// servers often still send an expired cross-sign alongside a path that
+	// is valid, which clients use instead, so an expired intermediate is
+	// only a problem if there is no other path
+	for _, cert := range certs[1:] {
+		if now.After(cert.NotAfter) && onEveryChain(cert, chains) {
+			rv = append(rv, ValidationProblem{Code: ProblemExpiredIntermediate,
+				Message: fmt.Sprintf("intermediate certificate %s expired on %s", cert.Subject, cert.NotAfter.Format("2006-01-02"))})
+		}
+	}
+
+	switch err := err.(type) {
+	case nil:
+	case x509.UnknownAuthorityError:
+		if bytes.Equal(leaf.RawIssuer, leaf.RawSubject) && leaf.CheckSignature(leaf.SignatureAlgorithm, leaf.RawTBSCertificate, leaf.Signature) == nil {
+			rv = append(rv, ValidationProblem{Code: ProblemSelfSigned, Message: err.Error() + " (the certificate is self-signed)"})
+		} else {
+			rv = append(rv, ValidationProblem{Code: ProblemUnknownAuthority, Message: err.Error()})
+		}
+	case x509.CertificateInvalidError:
+		if err.Reason != x509.Expired {
+			rv = append(rv, ValidationProblem{Code: ProblemInvalid, Message: err.Error()})
+		}
+	default:
+		rv = append(rv, ValidationProblem{Code: ProblemInvalid, Message: err.Error()})
+	}
+	return rv
+}
+
+// onEveryChain returns true if cert is on each of chains, which it is if
+// there are none.
+func onEveryChain(cert *x509.Certificate, chains [][]*x509.Certificate) bool {
+	for _, chain := range chains {
+		found := false
+		for _, c := range chain {
+			if c.Equal(cert) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
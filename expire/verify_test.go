@@ -0,0 +1,99 @@
+package expire
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCert returns a certificate for name valid from notBefore until
+// notAfter, signed by parent, or self signed if parent is nil.
+func testCert(t *testing.T, name string, ca bool, notBefore, notAfter time.Time, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		IsCA:                  ca,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if !ca {
+		template.DNSNames = []string{name}
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func problemCodes(problems []ValidationProblem) string {
+	var codes []string
+	for _, p := range problems {
+		codes = append(codes, p.Code)
+	}
+	return strings.Join(codes, ",")
+}
+
+func TestValidateChain(t *testing.T) {
+	now := time.Now()
+	year := 365 * 24 * time.Hour
+	root, rootKey := testCert(t, "Test Root", true, now.Add(-year), now.Add(year), nil, nil)
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	intermediate, intermediateKey := testCert(t, "Test Intermediate", true, now.Add(-year), now.Add(year/2), root, rootKey)
+	expiredIntermediate, expiredIntermediateKey := testCert(t, "Old Intermediate", true, now.Add(-year), now.Add(-time.Hour), root, rootKey)
+	leaf, _ := testCert(t, "www.example.com", false, now.Add(-time.Hour), now.Add(year/4), intermediate, intermediateKey)
+	expiredLeaf, _ := testCert(t, "www.example.com", false, now.Add(-year/2), now.Add(-time.Hour), intermediate, intermediateKey)
+	oldLeaf, _ := testCert(t, "www.example.com", false, now.Add(-year/2), now.Add(year/4), expiredIntermediate, expiredIntermediateKey)
+	futureLeaf, _ := testCert(t, "www.example.com", false, now.Add(time.Hour), now.Add(year/4), intermediate, intermediateKey)
+	futureIntermediate, futureIntermediateKey := testCert(t, "New Intermediate", true, now.Add(time.Hour), now.Add(year), root, rootKey)
+	newLeaf, _ := testCert(t, "www.example.com", false, now.Add(-time.Hour), now.Add(year/4), futureIntermediate, futureIntermediateKey)
+	selfSigned, _ := testCert(t, "www.example.com", false, now.Add(-time.Hour), now.Add(year), nil, nil)
+
+	for _, tt := range []struct {
+		name     string
+		hostname string
+		certs    []*x509.Certificate
+		want     string
+	}{
+		{"valid", "www.example.com", []*x509.Certificate{leaf, intermediate}, ""},
+		{"mismatch", "example.com", []*x509.Certificate{leaf, intermediate}, ProblemHostnameMismatch},
+		{"expired", "www.example.com", []*x509.Certificate{expiredLeaf, intermediate}, ProblemExpired},
+		{"not yet valid", "www.example.com", []*x509.Certificate{futureLeaf, intermediate}, ProblemNotYetValid},
+		{"intermediate not yet valid", "www.example.com", []*x509.Certificate{newLeaf, futureIntermediate}, ProblemNotYetValid},
+		{"expired intermediate", "www.example.com", []*x509.Certificate{oldLeaf, expiredIntermediate}, ProblemExpiredIntermediate},
+		{"unused expired intermediate", "www.example.com", []*x509.Certificate{leaf, intermediate, expiredIntermediate}, ""},
+		{"missing intermediate", "www.example.com", []*x509.Certificate{leaf}, ProblemUnknownAuthority},
+		{"self signed", "www.example.com", []*x509.Certificate{selfSigned}, ProblemSelfSigned},
+		{"self signed mismatch", "example.net", []*x509.Certificate{selfSigned}, ProblemHostnameMismatch + "," + ProblemSelfSigned},
+	} {
+		if got := problemCodes(validateChain(tt.hostname, tt.certs, roots, now)); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+
+	err := ValidationError{Problems: validateChain("example.com", []*x509.Certificate{expiredLeaf, intermediate}, roots, now)}
+	if !IsValidationError(err) || !strings.Contains(err.Error(), "; certificate expired on ") {
+		t.Errorf("unexpected error %q", err)
+	}
+}
@@ -0,0 +1,474 @@
+package expire
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/araddon/dateparse"
+	"github.com/domainr/whois"
+)
+
+// DomainResult is the outcome of a domain expiration lookup.
+type DomainResult struct {
+	Expires time.Time
+	Whois   string // the whois record or RDAP response the expiration was parsed from
+
+	// Source is where the expiration came from, rdap or whois
+	Source string
+
+	// Retries is the number of times the lookup was retried after a
+	// transient failure.
+	Retries int
+
+	// Candidates are all the expiration dates found, and Confidence is how
+	// sure we are that the one chosen for Expires is right.
+	Candidates []DomainCandidate
+	Confidence string
+}
+
+// DomainCandidate is a possible expiration date for a domain.
+type DomainCandidate struct {
+	Source  string    `json:"source"` // rdap or whois
+	Server  string    `json:"server"` // the server that answered
+	Method  string    `json:"method"` // format, keyword or rdap
+	Line    string    `json:"line"`   // the line the date was found in
+	Expires time.Time `json:"expires"`
+}
+
+// Whois candidates are found either by a known whois format for the
+// domain, or by scanning for expirationKeywords.
+const (
+	methodFormat  = "format"
+	methodKeyword = "keyword"
+)
+
+// Confidence levels for the chosen expiration date
+const (
+	ConfidenceHigh   = "high"   // found by a known format, and all candidates agree
+	ConfidenceMedium = "medium" // either found by a known format, or all candidates agree
+	ConfidenceLow    = "low"    // found by keyword, and the candidates disagree
+)
+
+// whoisCandidates returns the expiration dates found in a whois record
+// from server. Dates on lines that match a known format come first,
+// followed by any others on lines containing one of the
+// expirationKeywords.
+func whoisCandidates(domain, server, record string) []DomainCandidate {
+	var rv []DomainCandidate
+	matched := map[string]bool{}
+	for _, m := range matchWhoisExpirations(domain, record) {
+		matched[m.Line] = true
+		rv = append(rv, DomainCandidate{
+			Source:  "whois",
+			Server:  server,
+			Method:  methodFormat,
+			Line:    m.Line,
+			Expires: m.Expires,
+		})
+	}
+
+	loc := whoisLocation(domain)
+	keywords := whoisKeywords(domain)
+	s := bufio.NewScanner(strings.NewReader(record))
+	for s.Scan() {
+		text := strings.TrimSpace(s.Text())
+		if matched[text] {
+			continue
+		}
+		line := strings.ToLower(text)
+		for _, keyword := range keywords {
+			k := strings.Index(line, keyword)
+			if k < 0 {
+				continue
+			}
+			// prefer the first date following the keyword, then the first
+			// date anywhere on the line
+			after := ""
+			if len(line) == len(text) {
+				after = text[k+len(keyword):]
+			}
+			possibleDate, ok := firstDate(after, loc)
+			if !ok {
+				possibleDate, ok = firstDate(text, loc)
+			}
+			if ok {
+				rv = append(rv, DomainCandidate{
+					Source:  "whois",
+					Server:  server,
+					Method:  methodKeyword,
+					Line:    text,
+					Expires: possibleDate,
+				})
+			}
+			break
+		}
+	}
+	return rv
+}
+
+// firstDate returns the first date found in s. Dates without an offset are
+// in loc.
+func firstDate(s string, loc *time.Location) (time.Time, bool) {
+	for i := 0; i < len(s); i++ {
+		possibleDate, err := dateparse.ParseIn(s[i:], loc)
+		if err == nil {
+			return possibleDate, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// A date outside the range of plausible expirations, such as year 1 or
+// 9999 or one decades in the past, is a sign that the record was parsed
+// wrong, and is not used.
+const (
+	maxPlausibleExpiredFor = 5 * 365 * 24 * time.Hour
+	maxPlausibleExpiresIn  = 100 * 365 * 24 * time.Hour
+)
+
+// isPlausibleExpiry returns true if t is a plausible expiration date at
+// now.
+func isPlausibleExpiry(t, now time.Time) bool {
+	return t.After(now.Add(-maxPlausibleExpiredFor)) && t.Before(now.Add(maxPlausibleExpiresIn))
+}
+
+// plausibleCandidates returns the candidates that are plausible
+// expiration dates at now, and those that are not.
+func plausibleCandidates(candidates []DomainCandidate, now time.Time) (plausible, implausible []DomainCandidate) {
+	for _, c := range candidates {
+		if isPlausibleExpiry(c.Expires, now) {
+			plausible = append(plausible, c)
+		} else {
+			implausible = append(implausible, c)
+		}
+	}
+	return plausible, implausible
+}
+
+// Policies for choosing among several expiration dates in a whois record,
+// e.g. when the registry and the registrar disagree.
+const (
+	PolicyFirst    = "first"    // the first found by a known format, otherwise by keyword
+	PolicyEarliest = "earliest" // the earliest, to be warned as soon as possible
+	PolicyLatest   = "latest"   // the latest, e.g. when registrars renew ahead of the registry
+	PolicyRegistry = "registry" // the registry's, rather than the registrar's
+)
+
+// ExpiryPolicy is the policy used by CheckDomain, e.g. set from the
+// configuration at startup.
+var ExpiryPolicy = PolicyFirst
+
+// isRegistryCandidate returns true if c was found on a line that
+// attributes the date to the registry rather than the registrar, e.g.
+// "Registry Expiry Date".
+func isRegistryCandidate(c DomainCandidate) bool {
+	line := strings.ToLower(c.Line)
+	return strings.Contains(line, "registry") && !strings.Contains(line, "registrar")
+}
+
+// chooseCandidate returns the candidate selected by policy, and the
+// confidence in it. Candidates within a day of each other are considered
+// to agree, since some registries report only the date.
+func chooseCandidate(candidates []DomainCandidate, policy string) (DomainCandidate, string, bool) {
+	if len(candidates) == 0 {
+		return DomainCandidate{}, "", false
+	}
+	chosen := candidates[0] // format candidates come first
+	switch policy {
+	case PolicyEarliest:
+		for _, c := range candidates {
+			if c.Expires.Before(chosen.Expires) {
+				chosen = c
+			}
+		}
+	case PolicyLatest:
+		for _, c := range candidates {
+			if c.Expires.After(chosen.Expires) {
+				chosen = c
+			}
+		}
+	case PolicyRegistry:
+		for _, c := range candidates {
+			if isRegistryCandidate(c) {
+				chosen = c
+				break
+			}
+		}
+	}
+
+	agree := true
+	for _, c := range candidates {
+		d := c.Expires.Sub(chosen.Expires)
+		if d < -24*time.Hour || d > 24*time.Hour {
+			agree = false
+		}
+	}
+
+	switch {
+	case chosen.Method == methodFormat && agree:
+		return chosen, ConfidenceHigh, true
+	case chosen.Method == methodFormat || agree:
+		return chosen, ConfidenceMedium, true
+	default:
+		return chosen, ConfidenceLow, true
+	}
+}
+
+// whoisRetries is how many times a whois query that fails, e.g. because
+// the server is rate limiting us, is retried, with whoisRetryDelay between
+// attempts.
+const whoisRetries = 2
+
+var whoisRetryDelay = time.Second
+
+// CheckDomain returns the expiration date for a registered domain, such as
+// example.com. RDAP, which returns structured data, is tried first. Whois
+// is the fallback for registries without an RDAP server, or whose server
+// fails. Internationalized domains may be given in Unicode.
+func CheckDomain(ctx context.Context, domain string) (DomainResult, error) {
+	domain, err := ToASCII(domain)
+	if err != nil {
+		return DomainResult{}, err
+	}
+	rv, err := getRDAPExpiration(ctx, domain)
+	if err == nil {
+		return rv, nil
+	}
+	if err != errNoRDAPServer {
+		Tracef(ctx, "falling back to whois: %s", err)
+	}
+	if ctx.Err() != nil {
+		return rv, err
+	}
+	return getWhoisExpiration(ctx, domain)
+}
+
+// maxWhoisReferrals is how many referrals to other whois servers are
+// followed, e.g. from the registry to the registrar, before giving up.
+const maxWhoisReferrals = 2
+
+// whoisReferralFields are the fields in which whois servers refer to
+// another server with a more complete record, such as a thin registry
+// referring to the registrar.
+var whoisReferralFields = []string{
+	"registrar whois server",
+	"whois server",
+	"referralserver",
+}
+
+// whoisFetch queries a whois server. It is a variable so that tests can
+// replace it.
+var whoisFetch = whois.DefaultClient.FetchContext
+
+// getWhoisExpiration returns the expiration date for a domain from whois.
+// When the record refers to another whois server, the referral is
+// followed, and the expiration is taken from the most specific record that
+// has one, falling back to those that referred to it.
+func getWhoisExpiration(ctx context.Context, domain string) (DomainResult, error) {
+	rv := DomainResult{Source: "whois"}
+	request, err := whois.NewRequest(domain)
+	if err != nil {
+		return rv, err
+	}
+	if err := request.Prepare(); err == nil {
+		Tracef(ctx, "querying whois server %s for %s", request.Host, domain)
+	}
+	response, err := fetchWhois(ctx, request, &rv)
+	if err != nil {
+		return rv, err
+	}
+	text, err := response.Text()
+	if err != nil {
+		return rv, err
+	}
+	Tracef(ctx, "received %d bytes from whois server %s", len(text), response.Host)
+
+	servers := []string{response.Host}
+	records := []string{string(text)}
+	for len(servers) <= maxWhoisReferrals {
+		referral := whoisReferral(records[len(records)-1])
+		if referral == "" || hasServer(servers, referral) {
+			break
+		}
+		Tracef(ctx, "following referral to whois server %s", referral)
+		request := &whois.Request{Query: domain, Host: referral}
+		if err := request.Prepare(); err != nil {
+			Tracef(ctx, "cannot query whois server %s: %s", referral, err)
+			break
+		}
+		response, err := fetchWhois(ctx, request, &rv)
+		if err != nil {
+			Tracef(ctx, "whois referral failed, using the record from %s", servers[len(servers)-1])
+			break
+		}
+		text, err := response.Text()
+		if err != nil {
+			break
+		}
+		Tracef(ctx, "received %d bytes from whois server %s", len(text), referral)
+		servers = append(servers, referral)
+		records = append(records, string(text))
+	}
+
+	for i := len(records) - 1; i >= 0; i-- {
+		err = parseWhoisRecord(ctx, domain, servers[i], records[i], &rv)
+		if err == nil {
+			return rv, nil
+		}
+		if i > 0 {
+			Tracef(ctx, "%s; falling back to the record from %s", err, servers[i-1])
+		}
+	}
+	return rv, err
+}
+
+// fetchWhois sends request, retrying transient failures and counting the
+// retries in rv.
+func fetchWhois(ctx context.Context, request *whois.Request, rv *DomainResult) (*whois.Response, error) {
+	response, err := whoisFetch(ctx, request)
+	for err != nil && rv.Retries < whoisRetries && ctx.Err() == nil {
+		Tracef(ctx, "whois query failed: %s", err)
+		rv.Retries++
+		select {
+		case <-time.After(whoisRetryDelay):
+		case <-ctx.Done():
+		}
+		response, err = whoisFetch(ctx, request)
+	}
+	if err != nil {
+		Tracef(ctx, "whois query failed: %s", err)
+	}
+	return response, err
+}
+
+// parseWhoisRecord sets the expiration in rv from record, the whois record
+// for domain from server.
+func parseWhoisRecord(ctx context.Context, domain, server, record string, rv *DomainResult) error {
+	rv.Whois = record
+	candidates := whoisCandidates(domain, server, record)
+	var implausible []DomainCandidate
+	rv.Candidates, implausible = plausibleCandidates(candidates, time.Now())
+	for _, c := range implausible {
+		Tracef(ctx, "ignoring implausible expiration %s from line %q", c.Expires, c.Line)
+	}
+	if chosen, confidence, ok := chooseCandidate(rv.Candidates, ExpiryPolicy); ok {
+		Tracef(ctx, "chose expiration %s from line %q with %s confidence", chosen.Expires, chosen.Line, confidence)
+		rv.Expires = chosen.Expires
+		rv.Confidence = confidence
+		return nil
+	}
+	if len(implausible) > 0 {
+		return fmt.Errorf("cannot determine expiration date from whois record: %s on line %q is implausible",
+			implausible[0].Expires.Format("2006-01-02"), implausible[0].Line)
+	}
+
+	if isRedacted(record) {
+		Tracef(ctx, "no expiration found, and the record is redacted")
+		return ExpiryWithheldError{Domain: domain}
+	}
+	Tracef(ctx, "no expiration found")
+	return ErrNoExpiration
+}
+
+// whoisReferral returns the whois server that record refers to, or "" if
+// it doesn't refer to one.
+func whoisReferral(record string) string {
+	s := bufio.NewScanner(strings.NewReader(record))
+	for s.Scan() {
+		key, value, ok := splitWhoisLine(s.Text())
+		if !ok || !hasField(whoisReferralFields, key) {
+			continue
+		}
+		value = strings.TrimPrefix(strings.ToLower(value), "whois://")
+		value = strings.TrimSuffix(value, "/")
+		if value != "" && !strings.ContainsAny(value, " /") {
+			return value
+		}
+	}
+	return ""
+}
+
+func hasServer(servers []string, server string) bool {
+	for _, s := range servers {
+		if strings.EqualFold(s, server) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrNoExpiration is returned when no expiration date can be found in a
+// domain's whois record. The record is in the result, for diagnosis.
+var ErrNoExpiration = fmt.Errorf("cannot determine expiration date from whois record")
+
+// ExpiryWithheldError is returned when the whois record for a domain has
+// been redacted, either by the registry (e.g. for GDPR) or by a privacy
+// service, such that the expiration date is not available.
+type ExpiryWithheldError struct {
+	Domain string
+}
+
+func (e ExpiryWithheldError) Error() string {
+	return "expiration date withheld from whois record for " + e.Domain +
+		"; check the expiration date with your registrar"
+}
+
+func IsExpiryWithheld(err error) bool {
+	_, ok := err.(ExpiryWithheldError)
+	return ok
+}
+
+// redactionMarkers are phrases that appear in whois records which have
+// been redacted for privacy.
+var redactionMarkers = []string{
+	"redacted for privacy",
+	"redacted for gdpr",
+	"data redacted",
+	"not disclosed",
+	"non-public data",
+	"gdpr masked",
+	"privacy protect",
+	"privacy service",
+	"whoisguard",
+	"domains by proxy",
+	"contact privacy",
+	"withheld for privacy",
+	"statutory masking",
+}
+
+func isRedacted(record string) bool {
+	record = strings.ToLower(record)
+	for _, marker := range redactionMarkers {
+		if strings.Contains(record, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddExpirationKeywords adds keywords, e.g. from the config file, to
+// expirationKeywords.
+func AddExpirationKeywords(keywords []string) {
+	for _, keyword := range keywords {
+		expirationKeywords = append(expirationKeywords, strings.ToLower(keyword))
+	}
+}
+
+// expirationKeywords are phrases that identify the line of a whois record
+// containing the expiration date, for any domain.
+var expirationKeywords = []string{
+	"expiry",
+	"expiration",
+	"expires",
+	"registered through",
+	"expired",
+	"expire",
+	"expired",
+	"domain_datebilleduntil",
+	"paid-till",
+	"renewal date",
+	"fecha de vencimiento",
+}
@@ -0,0 +1,518 @@
+package expire
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/domainr/whois"
+)
+
+func TestWhois(t *testing.T) {
+	t.Skip()
+
+	domains := strings.Fields(googleDomains)
+
+	for _, domain := range domains {
+		t.Logf("domain: %s", domain)
+		result, err := CheckDomain(context.Background(), domain)
+		if err != nil {
+			t.Errorf("domain: %s: error: %s", domain, err)
+		} else if result.Expires.Before(time.Now()) {
+			t.Errorf("domain: %s: expected domain to be non-expired, actually expires %s", domain, result.Expires)
+		}
+	}
+}
+
+func TestParseWhoisExpiration(t *testing.T) {
+	tests := []struct {
+		domain  string
+		record  string
+		expires time.Time
+	}{
+		{
+			"example.com",
+			"   Domain Name: EXAMPLE.COM\n   Creation Date: 1995-08-14T04:00:00Z\n   Registry Expiry Date: 2020-08-13T04:00:00Z\n",
+			time.Date(2020, 8, 13, 4, 0, 0, 0, time.UTC),
+		},
+		{
+			"example.co.uk",
+			"    Relevant dates:\n        Registered on: 26-Jun-1996\n        Expiry date:  26-Jun-2020\n",
+			time.Date(2020, 6, 26, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			"example.jp",
+			"[Domain Name]                   EXAMPLE.JP\n[Created on]                    2001/02/07\n[Expires on]                    2021/02/28\n",
+			time.Date(2021, 2, 28, 0, 0, 0, 0, time.FixedZone("JST", 9*60*60)),
+		},
+		{
+			"example.fi",
+			"created.............: 1.1.2010 00:00:00\nexpires.............: 31.8.2021 12:00:00\n",
+			time.Date(2021, 8, 31, 12, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		expires, ok := parseWhoisExpiration(tt.domain, tt.record)
+		if !ok {
+			t.Errorf("%s: cannot parse expiration", tt.domain)
+		} else if !expires.Equal(tt.expires) {
+			t.Errorf("%s: expected %s, got %s", tt.domain, tt.expires, expires)
+		}
+	}
+
+	if _, ok := parseWhoisExpiration("example.net", "Creation Date: 1995-08-14T04:00:00Z\n"); ok {
+		t.Errorf("expected creation date not to be mistaken for the expiration")
+	}
+}
+
+func TestWhoisCandidatesTimezone(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	candidates := whoisCandidates("example.ru", "whois.example", "paid-till: 2021-06-07T21:00:00Z\nfree-date: 2021-07-08\nexpires: 2021-06-08\n")
+	if len(candidates) != 2 {
+		t.Fatalf("expected 2 candidates, got %+v", candidates)
+	}
+	if want := time.Date(2021, 6, 8, 0, 0, 0, 0, msk); !candidates[0].Expires.Equal(want) {
+		t.Errorf("expected an explicit offset to be kept, got %s", candidates[0].Expires)
+	}
+	if want := time.Date(2021, 6, 8, 0, 0, 0, 0, msk); !candidates[1].Expires.Equal(want) {
+		t.Errorf("expected a date without an offset to be in MSK, got %s", candidates[1].Expires)
+	}
+}
+
+func TestWhoisCandidates(t *testing.T) {
+	record := "   Domain Name: EXAMPLE.COM\n" +
+		"   Registry Expiry Date: 2020-08-13T04:00:00Z\n" +
+		"   Registrar Registration Expiration Date: 2020-08-13\n"
+	candidates := whoisCandidates("example.com", "whois.example", record)
+	if len(candidates) != 2 {
+		t.Fatalf("expected 2 candidates, got %+v", candidates)
+	}
+	if candidates[0].Method != methodFormat || candidates[0].Line != "Registry Expiry Date: 2020-08-13T04:00:00Z" {
+		t.Errorf("unexpected first candidate %+v", candidates[0])
+	}
+	if candidates[1].Server != "whois.example" {
+		t.Errorf("unexpected second candidate %+v", candidates[1])
+	}
+	chosen, confidence, ok := chooseCandidate(candidates, PolicyFirst)
+	if !ok || !chosen.Expires.Equal(time.Date(2020, 8, 13, 4, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected choice %+v", chosen)
+	}
+	if confidence != ConfidenceHigh {
+		t.Errorf("expected %s confidence, got %s", ConfidenceHigh, confidence)
+	}
+
+	// keyword matches that disagree
+	candidates = whoisCandidates("example.zz", "whois.example", "expires: 2020-08-13\nrenewal date: 2021-08-13\n")
+	if _, confidence, _ := chooseCandidate(candidates, PolicyFirst); confidence != ConfidenceLow {
+		t.Errorf("expected %s confidence, got %s for %+v", ConfidenceLow, confidence, candidates)
+	}
+
+	if _, _, ok := chooseCandidate(nil, PolicyFirst); ok {
+		t.Errorf("expected no choice without candidates")
+	}
+}
+
+func TestChooseCandidatePolicy(t *testing.T) {
+	record := "   Registrar Registration Expiration Date: 2021-08-13\n" +
+		"   Registry Expiry Date: 2020-08-13T04:00:00Z\n" +
+		"   Expires: 2022-08-13\n"
+	candidates := whoisCandidates("example.zz", "whois.example", record)
+	for policy, want := range map[string]time.Time{
+		PolicyFirst:    time.Date(2021, 8, 13, 0, 0, 0, 0, time.UTC),
+		PolicyEarliest: time.Date(2020, 8, 13, 4, 0, 0, 0, time.UTC),
+		PolicyLatest:   time.Date(2022, 8, 13, 0, 0, 0, 0, time.UTC),
+		PolicyRegistry: time.Date(2020, 8, 13, 4, 0, 0, 0, time.UTC),
+	} {
+		chosen, _, ok := chooseCandidate(candidates, policy)
+		if !ok || !chosen.Expires.Equal(want) {
+			t.Errorf("%s: expected %s, got %+v", policy, want, chosen)
+		}
+	}
+}
+
+func TestPlausibleCandidates(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	record := "Registry Expiry Date: 0001-01-01T00:00:00Z\n" +
+		"Registrar Registration Expiration Date: 9999-12-31\n" +
+		"Expiration Date: 1990-02-03\n" +
+		"Expiry Date: 2021-06-07\n"
+	plausible, implausible := plausibleCandidates(whoisCandidates("example.com", "whois.example", record), now)
+	if len(plausible) != 1 || !plausible[0].Expires.Equal(time.Date(2021, 6, 7, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected plausible candidates %+v", plausible)
+	}
+	if len(implausible) != 3 {
+		t.Errorf("expected 3 implausible candidates, got %+v", implausible)
+	}
+}
+
+func TestKeywordCandidateFollowsKeyword(t *testing.T) {
+	candidates := whoisCandidates("example.zz", "whois.example", "created 2001-02-03, expires 2021-06-07\n")
+	if len(candidates) != 1 || !candidates[0].Expires.Equal(time.Date(2021, 6, 7, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the date following the keyword, got %+v", candidates)
+	}
+}
+
+func TestWhoisKeywords(t *testing.T) {
+	defer func(formats []WhoisFormat, keywords []string) {
+		whoisFormats, expirationKeywords = formats, keywords
+	}(whoisFormats, expirationKeywords)
+
+	record := "Valido ate: 2021-06-07\nGueltig bis: 2022-06-07\n"
+	if candidates := whoisCandidates("example.zz", "whois.example", record); len(candidates) != 0 {
+		t.Fatalf("expected no candidates, got %+v", candidates)
+	}
+
+	AddWhoisFormats([]WhoisFormat{{Suffixes: []string{"zz"}, Keywords: []string{"Valido Ate"}}})
+	AddExpirationKeywords([]string{"Gueltig bis"})
+	candidates := whoisCandidates("example.zz", "whois.example", record)
+	if len(candidates) != 2 {
+		t.Fatalf("expected 2 candidates, got %+v", candidates)
+	}
+	if candidates := whoisCandidates("example.yy", "whois.example", record); len(candidates) != 1 {
+		t.Errorf("expected the registry's keywords to apply only to its domains, got %+v", candidates)
+	}
+}
+
+func TestIsRedacted(t *testing.T) {
+	if !isRedacted("Registrant Name: REDACTED FOR PRIVACY\n") {
+		t.Errorf("expected record to be redacted")
+	}
+	if isRedacted("Registrant Name: Example Corp\n") {
+		t.Errorf("expected record not to be redacted")
+	}
+}
+
+const googleDomains = `google.com
+google.ac
+google.ad
+google.ae
+google.com.af
+google.com.ag
+google.com.ai
+google.al
+google.am
+google.co.ao
+google.com.ar
+google.as
+google.at
+google.com.au
+google.az
+google.ba
+google.com.bd
+google.be
+google.bf
+google.bg
+google.com.bh
+google.bi
+google.bj
+google.com.bn
+google.com.bo
+google.com.br
+google.bs
+google.bt
+google.co.bw
+google.by
+google.com.bz
+google.ca
+google.com.kh
+google.cc
+google.cd
+google.cf
+google.cat
+google.cg
+google.ch
+google.ci
+google.co.ck
+google.cl
+google.cm
+google.cn
+google.com.co
+google.co.cr
+google.com.cu
+google.cv
+google.com.cy
+google.cz
+google.de
+google.dj
+google.dk
+google.dm
+google.com.do
+google.dz
+google.com.ec
+google.ee
+google.com.eg
+google.es
+google.com.et
+google.fi
+google.com.fj
+google.fm
+google.fr
+google.ga
+google.ge
+google.gf
+google.gg
+google.com.gh
+google.com.gi
+google.gl
+google.gm
+google.gp
+google.gr
+google.com.gt
+google.gy
+google.com.hk
+google.hn
+google.hr
+google.ht
+google.hu
+google.co.id
+google.iq
+google.ie
+google.co.il
+google.im
+google.co.in
+google.io
+google.is
+google.it
+google.je
+google.com.jm
+google.jo
+google.co.jp
+google.co.ke
+google.ki
+google.kg
+google.co.kr
+google.com.kw
+google.kz
+google.la
+google.com.lb
+google.com.lc
+google.li
+google.lk
+google.co.ls
+google.lt
+google.lu
+google.lv
+google.com.ly
+google.co.ma
+google.md
+google.me
+google.mg
+google.mk
+google.ml
+google.com.mm
+google.mn
+google.ms
+google.com.mt
+google.mu
+google.mv
+google.mw
+google.com.mx
+google.com.my
+google.co.mz
+google.com.na
+google.ne
+google.com.nf
+google.com.ng
+google.com.ni
+google.nl
+google.no
+google.com.np
+google.nr
+google.nu
+google.co.nz
+google.com.om
+google.com.pk
+google.com.pa
+google.com.pe
+google.com.ph
+google.pl
+google.com.pg
+google.pn
+google.com.pr
+google.ps
+google.pt
+google.com.py
+google.com.qa
+google.ro
+google.rs
+google.ru
+google.rw
+google.com.sa
+google.com.sb
+google.sc
+google.se
+google.com.sg
+google.sh
+google.si
+google.sk
+google.com.sl
+google.sn
+google.sm
+google.so
+google.st
+google.sr
+google.com.sv
+google.td
+google.tg
+google.co.th
+google.com.tj
+google.tk
+google.tl
+google.tm
+google.to
+google.tn
+google.com.tr
+google.tt
+google.com.tw
+google.co.tz
+google.com.ua
+google.co.ug
+google.co.uk
+google.com.uy
+google.co.uz
+google.com.vc
+google.co.ve
+google.vg
+google.co.vi
+google.com.vn
+google.vu
+google.ws
+google.co.za
+google.co.zm
+google.co.zw
+admob.com
+adsense.com
+adwords.com
+abc.xyz
+android.com
+blogger.com
+blogspot.com
+chromium.org
+chrome.com
+chromebook.com
+cobrasearch.com
+com.google
+feedburner.com
+doubleclick.com
+igoogle.com
+foofle.com
+froogle.com
+googleanalytics.com
+google-analytics.com
+googletagmanager.com
+googlecode.com
+googlesource.com
+googledrive.com
+googlearth.com
+googleearth.com
+googlemaps.com
+googlepagecreator.com
+googlescholar.com
+gmail.com
+googlemail.com
+keyhole.com
+madewithcode.com
+panoramio.com
+picasa.com
+urchin.com
+waze.com
+youtube.com
+yt.be
+ytimg.com
+youtubeeducation.com
+like.com
+google.org
+google.net
+466453.com
+gooogle.com
+gogle.com
+ggoogle.com
+gogole.com
+goolge.com
+googel.com
+duck.com
+googlee.com
+googil.com
+googlr.com
+googl.com
+gmodules.com
+googleadservices.com
+googleapps.com
+googleapis.com
+googlebot.com
+googlecommerce.com
+googlesyndication.com
+g.co
+whatbrowser.org
+withgoogle.com
+1e100.net
+ggpht.com
+googleusercontent.com
+youtubegaming.com
+domains.google
+www.googlecapital.com
+www.gv.com
+blog.google`
+
+func TestWhoisReferral(t *testing.T) {
+	for record, want := range map[string]string{
+		"Domain Name: EXAMPLE.COM\n   Registrar WHOIS Server: whois.registrar.example\n": "whois.registrar.example",
+		"Whois Server: WHOIS.REGISTRAR.EXAMPLE\n":                                        "whois.registrar.example",
+		"ReferralServer: whois://whois.registrar.example:4343/\n":                        "whois.registrar.example:4343",
+		"Registrar WHOIS Server: \n":                                                     "",
+		"Registrar URL: https://registrar.example/\n":                                    "",
+	} {
+		if got := whoisReferral(record); got != want {
+			t.Errorf("%q: got %q, want %q", record, got, want)
+		}
+	}
+}
+
+func TestWhoisFollowsReferrals(t *testing.T) {
+	defer func(f func(context.Context, *whois.Request) (*whois.Response, error)) { whoisFetch = f }(whoisFetch)
+	defer func(d time.Duration) { whoisRetryDelay = d }(whoisRetryDelay)
+	whoisRetryDelay = 0
+
+	registry := time.Now().AddDate(1, 0, 0).UTC().Truncate(time.Second)
+	registrar := registry.AddDate(1, 0, 0)
+	thin := "Domain Name: EXAMPLE.COM\n" +
+		"Registrar WHOIS Server: whois.registrar.example\n" +
+		"Registry Expiry Date: " + registry.Format(time.RFC3339) + "\n"
+
+	for _, tt := range []struct {
+		name      string
+		registrar string // the registrar's record, or "" if it fails
+		expires   time.Time
+		queries   int
+	}{
+		{"thick", "Registrar Registration Expiration Date: " + registrar.Format(time.RFC3339) + "\n", registrar, 2},
+		{"failed", "", registry, 2 + whoisRetries},
+		{"redacted", "Registrant Name: REDACTED FOR PRIVACY\n", registry, 2},
+		{"loop", "Registrar WHOIS Server: whois.registrar.example\n", registry, 2},
+	} {
+		queries := 0
+		whoisFetch = func(ctx context.Context, req *whois.Request) (*whois.Response, error) {
+			queries++
+			if req.Host != "whois.registrar.example" {
+				return &whois.Response{Host: "whois.registry.example", Body: []byte(thin)}, nil
+			}
+			if tt.registrar == "" {
+				return nil, fmt.Errorf("connection refused")
+			}
+			return &whois.Response{Host: req.Host, Body: []byte(tt.registrar)}, nil
+		}
+		rv, err := getWhoisExpiration(context.Background(), "example.com")
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if !rv.Expires.Equal(tt.expires) {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expires, rv.Expires)
+		}
+		if queries != tt.queries {
+			t.Errorf("%s: expected %d queries, got %d", tt.name, tt.queries, queries)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package expire
+
+import (
+	"strings"
+	"time"
+)
+
+// WhoisFormat describes where a registry puts the expiration date in its
+// whois records, and how the date is formatted.
+type WhoisFormat struct {
+	// Suffixes are the public suffixes (e.g. "com" or "co.uk") whose
+	// registry uses this format.
+	Suffixes []string `yaml:"suffixes"`
+
+	// Fields are the names of the fields that contain the expiration
+	// date, compared without regard to case.
+	Fields []string `yaml:"fields"`
+
+	// Layouts are the formats of the date, as understood by time.Parse
+	Layouts []string `yaml:"layouts"`
+
+	// Keywords are phrases, compared without regard to case, that
+	// identify a line containing the expiration date when none of the
+	// Fields match, in addition to the expirationKeywords used for every
+	// domain.
+	Keywords []string `yaml:"keywords"`
+
+	// Timezone is the IANA name of the registry's timezone, e.g.
+	// Asia/Tokyo, which is used for dates that don't include an offset
+	// (default: UTC)
+	Timezone string `yaml:"timezone"`
+}
+
+// location returns the timezone of dates in the format.
+func (f WhoisFormat) location() *time.Location {
+	if f.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(f.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// defaultWhoisFormat is the format required of gTLD registries by ICANN,
+// which is also used by a good number of ccTLDs. It is tried for
+// every domain.
+var defaultWhoisFormat = WhoisFormat{
+	Fields: []string{
+		"Registry Expiry Date",
+		"Registrar Registration Expiration Date",
+		"Expiration Date",
+		"Expiry Date",
+	},
+	Layouts: []string{
+		time.RFC3339,
+		"2006-01-02T15:04:05Z0700",
+		"2006-01-02 15:04:05Z0700",
+		"2006-01-02",
+	},
+}
+
+// whoisFormats are the registry specific formats. Formats added with
+// AddWhoisFormats are placed in front of these, so they take precedence.
+var whoisFormats = []WhoisFormat{
+	{
+		Suffixes: []string{"uk", "co.uk", "org.uk", "me.uk", "ltd.uk", "plc.uk", "net.uk"},
+		Fields:   []string{"Expiry date"},
+		Layouts:  []string{"02-Jan-2006"},
+	},
+	{
+		Suffixes: []string{"jp", "co.jp", "ne.jp", "or.jp", "ac.jp", "go.jp"},
+		Fields:   []string{"Expires on", "有効期限", "State"},
+		Layouts:  []string{"2006/01/02", "Active (2006/01/02)", "Connected (2006/01/02)"},
+		Timezone: "Asia/Tokyo",
+	},
+	{
+		Suffixes: []string{"ru", "su", "xn--p1ai"},
+		Fields:   []string{"paid-till"},
+		Layouts:  []string{time.RFC3339},
+		Timezone: "Europe/Moscow",
+	},
+	{
+		Suffixes: []string{"br", "com.br", "net.br", "org.br"},
+		Fields:   []string{"expires"},
+		Layouts:  []string{"20060102"},
+	},
+	{
+		Suffixes: []string{"pl", "com.pl", "net.pl", "org.pl"},
+		Fields:   []string{"expiration date"},
+		Layouts:  []string{"2006.01.02 15:04:05"},
+	},
+	{
+		Suffixes: []string{"it"},
+		Fields:   []string{"Expire Date"},
+		Layouts:  []string{"2006-01-02"},
+	},
+	{
+		Suffixes: []string{"cz"},
+		Fields:   []string{"expire"},
+		Layouts:  []string{"02.01.2006"},
+	},
+	{
+		Suffixes: []string{"se", "nu"},
+		Fields:   []string{"expires"},
+		Layouts:  []string{"2006-01-02"},
+	},
+	{
+		Suffixes: []string{"fi"},
+		Fields:   []string{"expires"},
+		Layouts:  []string{"2.1.2006 15:04:05", "2.1.2006"},
+	},
+	{
+		Suffixes: []string{"kr", "co.kr", "or.kr"},
+		Fields:   []string{"Expiration Date", "사용 종료일"},
+		Layouts:  []string{"2006. 01. 02."},
+		Timezone: "Asia/Seoul",
+	},
+	{
+		Suffixes: []string{"cn", "com.cn", "net.cn", "org.cn"},
+		Fields:   []string{"Expiration Time"},
+		Layouts:  []string{"2006-01-02 15:04:05"},
+		Timezone: "Asia/Shanghai",
+	},
+	{
+		Suffixes: []string{"hk", "com.hk"},
+		Fields:   []string{"Expiry Date"},
+		Layouts:  []string{"02-01-2006"},
+		Timezone: "Asia/Hong_Kong",
+	},
+}
+
+// AddWhoisFormats adds formats to the registry, in front of the built in
+// formats.
+func AddWhoisFormats(formats []WhoisFormat) {
+	whoisFormats = append(append([]WhoisFormat{}, formats...), whoisFormats...)
+}
+
+// whoisFormatsFor returns the formats to try for domain, most specific
+// first.
+func whoisFormatsFor(domain string) []WhoisFormat {
+	suffix := PublicSuffix(domain)
+
+	var rv []WhoisFormat
+	for _, format := range whoisFormats {
+		for _, s := range format.Suffixes {
+			if strings.EqualFold(s, suffix) {
+				rv = append(rv, format)
+				break
+			}
+		}
+	}
+	return append(rv, defaultWhoisFormat)
+}
+
+// whoisKeywords returns the keywords that identify a line containing the
+// expiration date in whois records for domain, most specific first.
+func whoisKeywords(domain string) []string {
+	var rv []string
+	for _, format := range whoisFormatsFor(domain) {
+		for _, keyword := range format.Keywords {
+			rv = append(rv, strings.ToLower(keyword))
+		}
+	}
+	return append(rv, expirationKeywords...)
+}
+
+// whoisLocation returns the timezone of dates that don't include an
+// offset in whois records for domain.
+func whoisLocation(domain string) *time.Location {
+	for _, format := range whoisFormatsFor(domain) {
+		if format.Timezone != "" {
+			return format.location()
+		}
+	}
+	return time.UTC
+}
+
+// splitWhoisLine splits a line of a whois record into a field name and a
+// value. It understands "Field: value", "Field....: value" and
+// "[Field] value". If the line is not a field, ok is false.
+func splitWhoisLine(line string) (key, value string, ok bool) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "[") {
+		if i := strings.Index(line, "]"); i > 0 {
+			return strings.TrimSpace(line[1:i]), strings.TrimSpace(line[i+1:]), true
+		}
+		return "", "", false
+	}
+
+	i := strings.Index(line, ":")
+	if i <= 0 {
+		return "", "", false
+	}
+	key = strings.TrimRight(line[:i], " .\t")
+	return key, strings.TrimSpace(line[i+1:]), true
+}
+
+// parseWhoisExpiration looks for the expiration date in a whois record
+// using the registered formats for domain.
+func parseWhoisExpiration(domain string, record string) (time.Time, bool) {
+	t, _, ok := matchWhoisExpiration(domain, record)
+	return t, ok
+}
+
+// whoisMatch is an expiration date found by a known whois format, and the
+// line it was found in.
+type whoisMatch struct {
+	Expires time.Time
+	Line    string
+}
+
+// matchWhoisExpiration is like parseWhoisExpiration, but also returns the
+// line the expiration was found in.
+func matchWhoisExpiration(domain string, record string) (time.Time, string, bool) {
+	matches := matchWhoisExpirations(domain, record)
+	if len(matches) == 0 {
+		return time.Time{}, "", false
+	}
+	return matches[0].Expires, matches[0].Line, true
+}
+
+// matchWhoisExpirations returns every expiration date in record that
+// matches one of the registered formats for domain, in order of format and
+// then of line.
+func matchWhoisExpirations(domain string, record string) []whoisMatch {
+	var rv []whoisMatch
+	seen := map[string]bool{}
+	lines := strings.Split(record, "\n")
+	for _, format := range whoisFormatsFor(domain) {
+		loc := format.location()
+		for _, line := range lines {
+			key, value, ok := splitWhoisLine(line)
+			if !ok || !hasField(format.Fields, key) {
+				continue
+			}
+			line = strings.TrimSpace(line)
+			if seen[line] {
+				continue
+			}
+			for _, layout := range format.Layouts {
+				if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+					seen[line] = true
+					rv = append(rv, whoisMatch{Expires: t, Line: line})
+					break
+				}
+			}
+		}
+	}
+	return rv
+}
+
+func hasField(fields []string, key string) bool {
+	for _, f := range fields {
+		if strings.EqualFold(f, key) {
+			return true
+		}
+	}
+	return false
+}
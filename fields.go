@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// field is a named value derived from an Expiration that can be selected
+// with the fields query parameter in JSON and CSV responses.
+type field struct {
+	Name  string
+	Value func(e Expiration, now time.Time) interface{}
+}
+
+var allFields = []field{
+	{"name", func(e Expiration, now time.Time) interface{} { return e.Name }},
+	{"certExpires", func(e Expiration, now time.Time) interface{} {
+		return timeOrNil(e.CertificateExpires, e.CertificateError)
+	}},
+	{"certNotBefore", func(e Expiration, now time.Time) interface{} {
+		return timeOrNil(e.CertificateNotBefore, e.CertificateError)
+	}},
+	{"certLifetimeElapsed", func(e Expiration, now time.Time) interface{} {
+		elapsed, ok := e.LifetimeElapsed(now)
+		if !ok {
+			return nil
+		}
+		return elapsed
+	}},
+	{"certError", func(e Expiration, now time.Time) interface{} { return errorOrNil(e.CertificateError) }},
+	{"certErrorCode", func(e Expiration, now time.Time) interface{} { return errorCodeOrNil(e.CertificateError) }},
+	{"tlsVersion", func(e Expiration, now time.Time) interface{} { return e.TLSVersion }},
+	{"cipherSuite", func(e Expiration, now time.Time) interface{} { return e.CipherSuite }},
+	{"domain", func(e Expiration, now time.Time) interface{} { return e.Domain }},
+	{"domainExpires", func(e Expiration, now time.Time) interface{} {
+		return timeOrNil(e.DomainExpires, e.DomainError)
+	}},
+	{"domainError", func(e Expiration, now time.Time) interface{} { return errorOrNil(e.DomainError) }},
+	{"domainErrorCode", func(e Expiration, now time.Time) interface{} { return errorCodeOrNil(e.DomainError) }},
+	{"daysRemaining", func(e Expiration, now time.Time) interface{} {
+		soonest, ok := e.Soonest()
+		if !ok {
+			return nil
+		}
+		return daysUntil(now, soonest)
+	}},
+}
+
+// parseFields parses a comma separated list of field names.
+func parseFields(s string) ([]field, error) {
+	var rv []field
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		f, ok := lookupField(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		rv = append(rv, f)
+	}
+	if len(rv) == 0 {
+		return nil, fmt.Errorf("no fields specified")
+	}
+	return rv, nil
+}
+
+func lookupField(name string) (field, bool) {
+	for _, f := range allFields {
+		if strings.EqualFold(f.Name, name) {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// daysUntil returns the number of whole days from now until t, which is
+// negative if t is in the past.
+func daysUntil(now, t time.Time) int {
+	return int(math.Floor(t.Sub(now).Hours() / 24))
+}
+
+func timeOrNil(t time.Time, err error) interface{} {
+	if err != nil || t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+func errorOrNil(err error) interface{} {
+	if err == nil {
+		return nil
+	}
+	return err.Error()
+}
+
+func (s *Server) serveExpirationsCSV(w http.ResponseWriter, r *http.Request, expirations []Expiration, fields []field) {
+	if fields == nil {
+		fields = allFields
+	}
+
+	w.Header().Add("Content-Type", "text/csv")
+	cw := csv.NewWriter(w)
+	header := make([]string, len(fields))
+	for i, f := range fields {
+		header[i] = f.Name
+	}
+	cw.Write(header)
+
+	now := time.Now()
+	for _, exp := range expirations {
+		row := make([]string, len(fields))
+		for i, f := range fields {
+			switch v := f.Value(exp, now).(type) {
+			case nil:
+			case time.Time:
+				row[i] = v.Format(time.RFC3339)
+			default:
+				row[i] = fmt.Sprint(v)
+			}
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+}
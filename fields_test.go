@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseFields(t *testing.T) {
+	fields, err := parseFields("name, daysRemaining,certExpires")
+	if err != nil {
+		t.Fatalf("parseFields: %s", err)
+	}
+	var names []string
+	for _, f := range fields {
+		names = append(names, f.Name)
+	}
+	if got, want := fmt.Sprint(names), "[name daysRemaining certExpires]"; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	if _, err := parseFields("name,bogus"); err == nil {
+		t.Errorf("expected error for unknown field")
+	}
+	if _, err := parseFields(","); err == nil {
+		t.Errorf("expected error for empty field list")
+	}
+}
+
+func TestDaysRemaining(t *testing.T) {
+	now := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+	f, _ := lookupField("daysRemaining")
+
+	e := Expiration{
+		CertificateExpires: now.Add(10*24*time.Hour + time.Hour),
+		DomainExpires:      now.Add(100 * 24 * time.Hour),
+	}
+	if got := f.Value(e, now); got != 10 {
+		t.Errorf("expected 10, got %v", got)
+	}
+
+	e.CertificateError = fmt.Errorf("dial failed")
+	if got := f.Value(e, now); got != 100 {
+		t.Errorf("expected 100, got %v", got)
+	}
+
+	e.DomainError = fmt.Errorf("whois failed")
+	if got := f.Value(e, now); got != nil {
+		t.Errorf("expected nil, got %v", got)
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// grafanaPrefix is where each watchlist is served as a Grafana data source
+// implementing the simple JSON data source contract, e.g. /grafana/prod/
+// for the prod watchlist.
+const grafanaPrefix = "/grafana/"
+
+// grafanaTableTarget is the target that queries the current results of
+// the watchlist as a table.
+const grafanaTableTarget = "expirations"
+
+// grafanaRange is the time range of a query or annotation request.
+type grafanaRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// contains returns true if t is within the range. A range with a zero
+// bound is unbounded on that side.
+func (r grafanaRange) contains(t time.Time) bool {
+	return (r.From.IsZero() || !t.Before(r.From)) && (r.To.IsZero() || !t.After(r.To))
+}
+
+type grafanaSearchRequest struct {
+	Target string `json:"target"`
+}
+
+type grafanaQueryRequest struct {
+	Range   grafanaRange `json:"range"`
+	Targets []struct {
+		Target string `json:"target"`
+		RefID  string `json:"refId"`
+		Type   string `json:"type"` // timeserie or table
+	} `json:"targets"`
+}
+
+type grafanaAnnotationRequest struct {
+	Range      grafanaRange    `json:"range"`
+	Annotation json.RawMessage `json:"annotation"`
+}
+
+// grafanaSeries is a time series: the days remaining until a certificate
+// or domain expires, as of each time it was checked. Each datapoint is the
+// value and the time in milliseconds since the epoch.
+type grafanaSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+type grafanaColumn struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+type grafanaTable struct {
+	Type    string          `json:"type"`
+	Columns []grafanaColumn `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+type grafanaAnnotation struct {
+	Annotation json.RawMessage `json:"annotation"`
+	Time       int64           `json:"time"`
+	Title      string          `json:"title"`
+	Text       string          `json:"text"`
+	Tags       []string        `json:"tags"`
+}
+
+// grafanaMillis returns t in milliseconds since the epoch, as Grafana
+// expects.
+func grafanaMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// grafanaTargets returns the time series targets for hostnames: the
+// certificate and domain of each.
+func grafanaTargets(hostnames []string) []string {
+	var rv []string
+	for _, name := range hostnames {
+		rv = append(rv, name+" "+CheckCertificate, name+" "+CheckDomain)
+	}
+	return rv
+}
+
+// newGrafanaSeries returns the series for target, which is a hostname and
+// a check, from the history of the host within rng. Failed checks are left
+// out.
+func newGrafanaSeries(target string, history func(string) []HistoryEntry, rng grafanaRange) grafanaSeries {
+	rv := grafanaSeries{Target: target, Datapoints: [][2]float64{}}
+	i := strings.LastIndex(target, " ")
+	if i < 0 {
+		return rv
+	}
+	name, check := target[:i], target[i+1:]
+	for _, entry := range history(name) {
+		expires, errStr := entry.CertificateExpires, entry.CertificateError
+		if check == CheckDomain {
+			expires, errStr = entry.DomainExpires, entry.DomainError
+		} else if check != CheckCertificate {
+			return rv
+		}
+		if errStr != "" || expires.IsZero() {
+			continue
+		}
+		// an entry stands for every check between the first and last
+		// time it was seen
+		for _, at := range []time.Time{entry.FirstChecked, entry.LastChecked} {
+			if !rng.contains(at) {
+				continue
+			}
+			days := expires.Sub(at).Hours() / 24
+			rv.Datapoints = append(rv.Datapoints, [2]float64{days, float64(grafanaMillis(at))})
+			if entry.LastChecked.Equal(entry.FirstChecked) {
+				break
+			}
+		}
+	}
+	return rv
+}
+
+// newGrafanaTable returns the table of the current results of checking the
+// hosts of a watchlist.
+func newGrafanaTable(expirations []Expiration, t thresholds) grafanaTable {
+	rv := grafanaTable{
+		Type: "table",
+		Columns: []grafanaColumn{
+			{Text: "Host", Type: "string"},
+			{Text: "Status", Type: "string"},
+			{Text: "Certificate expires", Type: "time"},
+			{Text: "Domain expires", Type: "time"},
+			{Text: "Days remaining", Type: "number"},
+			{Text: "Error", Type: "string"},
+		},
+		Rows: [][]interface{}{},
+	}
+	for _, exp := range expirations {
+		var certExpires, domainExpires, days interface{}
+		if exp.CertificateError == nil && !exp.CertificateExpires.IsZero() {
+			certExpires = grafanaMillis(exp.CertificateExpires)
+		}
+		if exp.DomainError == nil && !exp.DomainExpires.IsZero() {
+			domainExpires = grafanaMillis(exp.DomainExpires)
+		}
+		if soonest, ok := exp.Soonest(); ok {
+			days = daysUntil(t.Now, soonest)
+		}
+		var errs []string
+		if exp.CertificateError != nil {
+			errs = append(errs, "certificate: "+exp.CertificateError.Error())
+		}
+		if exp.DomainError != nil {
+			errs = append(errs, "domain: "+exp.DomainError.Error())
+		}
+		rv.Rows = append(rv.Rows, []interface{}{
+			exp.Name, exp.Status(t), certExpires, domainExpires, days, strings.Join(errs, "; "),
+		})
+	}
+	return rv
+}
+
+// grafanaAnnotations returns the renewals, regressions and replacements of
+// hostnames within rng, and the expirations that fall within it, as
+// annotations. If query is not empty, only the host it names is annotated.
+func grafanaAnnotations(hostnames []string, query string, history func(string) []HistoryEntry, rng grafanaRange, annotation json.RawMessage) []grafanaAnnotation {
+	rv := []grafanaAnnotation{}
+	for _, name := range hostnames {
+		if query != "" && name != query {
+			continue
+		}
+		entries := history(name)
+		for _, change := range historyChanges(entries) {
+			if !rng.contains(change.Time) {
+				continue
+			}
+			rv = append(rv, grafanaAnnotation{
+				Annotation: annotation,
+				Time:       grafanaMillis(change.Time),
+				Title:      fmt.Sprintf("%s %s %s", name, change.Check, change.Kind),
+				Text:       change.String(),
+				Tags:       []string{name, change.Check, change.Kind},
+			})
+		}
+
+		if len(entries) == 0 {
+			continue
+		}
+		latest := entries[len(entries)-1]
+		for _, check := range []struct {
+			name    string
+			expires time.Time
+			errStr  string
+		}{
+			{CheckCertificate, latest.CertificateExpires, latest.CertificateError},
+			{CheckDomain, latest.DomainExpires, latest.DomainError},
+		} {
+			if check.errStr != "" || check.expires.IsZero() || !rng.contains(check.expires) {
+				continue
+			}
+			rv = append(rv, grafanaAnnotation{
+				Annotation: annotation,
+				Time:       grafanaMillis(check.expires),
+				Title:      fmt.Sprintf("%s %s expires", name, check.name),
+				Text:       fmt.Sprintf("%s %s expires on %s", name, check.name, check.expires.Format("2006-01-02")),
+				Tags:       []string{name, check.name, "expires"},
+			})
+		}
+	}
+	sort.SliceStable(rv, func(i, j int) bool {
+		return rv[i].Time < rv[j].Time
+	})
+	return rv
+}
+
+// serveGrafana serves path, which is a watchlist name followed by one of
+// the endpoints of the simple JSON data source: / to test the connection,
+// /search for the available targets, /query for the time series of days
+// remaining for each target, or a table of the current results, and
+// /annotations for renewals and expirations. The series and annotations
+// come from the recorded history, so only the table target checks hosts.
+func (s *Server) serveGrafana(w http.ResponseWriter, r *http.Request, path string) {
+	name, endpoint := path, ""
+	if i := strings.Index(path, "/"); i >= 0 {
+		name, endpoint = path[:i], path[i+1:]
+	}
+	wl := s.watchlist(name)
+	if wl == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if endpoint == "" {
+		w.Header().Add("Content-Type", "text/plain")
+		fmt.Fprintln(w, "OK")
+		return
+	}
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var rv interface{}
+	switch endpoint {
+	case "search":
+		var req grafanaSearchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Cannot parse request: %s", err), http.StatusBadRequest)
+			return
+		}
+		targets := []string{}
+		for _, target := range append(grafanaTargets(wl.Hostnames()), grafanaTableTarget) {
+			if strings.Contains(target, req.Target) {
+				targets = append(targets, target)
+			}
+		}
+		rv = targets
+
+	case "query":
+		var req grafanaQueryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Cannot parse request: %s", err), http.StatusBadRequest)
+			return
+		}
+		results := []interface{}{}
+		for _, target := range req.Targets {
+			if target.Type == "table" || target.Target == grafanaTableTarget {
+				applyWatchlistThresholds(r, *wl)
+				t, err := parseThresholds(r)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				if !s.chargeQuota(w, r, len(wl.Hosts)) {
+					return
+				}
+				expirations := getExpirations(r.Context(), wl.Hostnames(), s.defaultCheckOptions())
+				s.applyAcknowledgements(expirations, t.Now)
+				results = append(results, newGrafanaTable(expirations, t))
+				continue
+			}
+			results = append(results, newGrafanaSeries(target.Target, checkHistory.Entries, req.Range))
+		}
+		rv = results
+
+	case "annotations":
+		var req grafanaAnnotationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Cannot parse request: %s", err), http.StatusBadRequest)
+			return
+		}
+		var annotation struct {
+			Query string `json:"query"`
+		}
+		json.Unmarshal(req.Annotation, &annotation)
+		rv = grafanaAnnotations(wl.Hostnames(), strings.TrimSpace(annotation.Query), checkHistory.Entries, req.Range, req.Annotation)
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rv)
+}
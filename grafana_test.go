@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGrafanaSeries(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	history := func(name string) []HistoryEntry {
+		if name != "example.com" {
+			return nil
+		}
+		return []HistoryEntry{
+			{FirstChecked: now, LastChecked: now.AddDate(0, 0, 1), CertificateExpires: now.AddDate(0, 0, 10)},
+			{FirstChecked: now.AddDate(0, 0, 2), LastChecked: now.AddDate(0, 0, 2), CertificateError: "dial failed"},
+			{FirstChecked: now.AddDate(0, 0, 3), LastChecked: now.AddDate(0, 0, 3), CertificateExpires: now.AddDate(0, 0, 90)},
+		}
+	}
+
+	series := newGrafanaSeries("example.com certificate", history, grafanaRange{From: now.Add(time.Hour)})
+	var got []string
+	for _, point := range series.Datapoints {
+		got = append(got, fmt.Sprintf("%g@%s", point[0], time.Unix(0, int64(point[1])*int64(time.Millisecond)).UTC().Format("01-02")))
+	}
+	want := "[9@01-02 87@01-04]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	if series := newGrafanaSeries("example.com bogus", history, grafanaRange{}); len(series.Datapoints) != 0 {
+		t.Errorf("expected no datapoints for an unknown check, got %v", series.Datapoints)
+	}
+}
+
+func TestGrafanaAnnotations(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	history := func(name string) []HistoryEntry {
+		return []HistoryEntry{
+			{FirstChecked: now, LastChecked: now, CertificateExpires: now.AddDate(0, 0, 10), DomainExpires: now.AddDate(2, 0, 0)},
+			{FirstChecked: now.AddDate(0, 0, 5), LastChecked: now.AddDate(0, 0, 5), CertificateExpires: now.AddDate(0, 0, 95), DomainExpires: now.AddDate(2, 0, 0)},
+		}
+	}
+	rng := grafanaRange{From: now, To: now.AddDate(1, 0, 0)}
+
+	var got []string
+	for _, a := range grafanaAnnotations([]string{"a.example.com", "b.example.com"}, "b.example.com", history, rng, nil) {
+		got = append(got, a.Title)
+	}
+	want := "[b.example.com certificate renewed b.example.com certificate expires]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestServeGrafanaSearch(t *testing.T) {
+	s := &Server{Config: &Config{Watchlists: []Watchlist{
+		{Name: "prod", Hosts: []WatchlistHost{{Name: "a.example.com"}, {Name: "b.example.com"}}},
+	}}}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/grafana/prod/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected the connection test to succeed, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/grafana/prod/search", strings.NewReader(`{"target":"b.example"}`)))
+	var targets []string
+	if err := json.NewDecoder(w.Body).Decode(&targets); err != nil {
+		t.Fatal(err)
+	}
+	want := "[b.example.com certificate b.example.com domain]"
+	if fmt.Sprint(targets) != want {
+		t.Errorf("expected %s, got %s", want, targets)
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/grafana/staging/search", strings.NewReader(`{}`)))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown watchlist, got %d", w.Code)
+	}
+}
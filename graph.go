@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GraphCalendarConfig describes a watchlist whose expirations are kept in
+// sync with an Outlook calendar, e.g. that of a team's shared mailbox,
+// using the Microsoft Graph API. The app registration identified by
+// ClientID needs the Calendars.ReadWrite application permission.
+type GraphCalendarConfig struct {
+	Watchlist string `yaml:"watchlist"`
+
+	TenantID     string `yaml:"tenantID"`
+	ClientID     string `yaml:"clientID"`
+	ClientSecret string `yaml:"clientSecret"` // default: $GRAPH_CLIENT_SECRET
+
+	// Mailbox is the user principal name of the mailbox, and Calendar is
+	// the ID of the calendar in it (default: the mailbox's calendar)
+	Mailbox  string `yaml:"mailbox"`
+	Calendar string `yaml:"calendar"`
+
+	// Interval is how often the calendar is updated (default: 1h)
+	Interval time.Duration `yaml:"interval"`
+}
+
+const (
+	graphBaseURL  = "https://graph.microsoft.com/v1.0"
+	graphTokenURL = "https://login.microsoftonline.com/%s/oauth2/v2.0/token"
+
+	// graphUIDProperty is the extended property that holds the UID of the
+	// expiration an event is for, so that we update the events we created
+	// and leave any others in the calendar alone.
+	graphUIDProperty = "String {5f6b8c1e-8a0d-4f4e-9b57-2e3c1d7a9f60} Name expireShUID"
+
+	defaultGraphSyncInterval = time.Hour
+)
+
+var graphClient = &http.Client{Timeout: 30 * time.Second}
+
+// graphEvent is the part of a Graph event resource that we read and write.
+type graphEvent struct {
+	ID         string          `json:"id,omitempty"`
+	Subject    string          `json:"subject"`
+	Body       graphBody       `json:"body"`
+	Start      graphDateTime   `json:"start"`
+	End        graphDateTime   `json:"end"`
+	IsAllDay   bool            `json:"isAllDay"`
+	ShowAs     string          `json:"showAs"`
+	Properties []graphProperty `json:"singleValueExtendedProperties,omitempty"`
+}
+
+type graphBody struct {
+	ContentType string `json:"contentType"`
+	Content     string `json:"content"`
+}
+
+type graphDateTime struct {
+	DateTime string `json:"dateTime"`
+	TimeZone string `json:"timeZone"`
+}
+
+type graphProperty struct {
+	ID    string `json:"id"`
+	Value string `json:"value"`
+}
+
+// newGraphEvent returns the all day Graph event for event.
+func newGraphEvent(event calendarEvent) graphEvent {
+	day := time.Date(event.Date.Year(), event.Date.Month(), event.Date.Day(), 0, 0, 0, 0, time.UTC)
+	return graphEvent{
+		Subject:    event.Summary,
+		Body:       graphBody{ContentType: "text", Content: event.Description},
+		Start:      graphDateTime{DateTime: day.Format("2006-01-02T15:04:05"), TimeZone: "UTC"},
+		End:        graphDateTime{DateTime: day.AddDate(0, 0, 1).Format("2006-01-02T15:04:05"), TimeZone: "UTC"},
+		IsAllDay:   true,
+		ShowAs:     "free",
+		Properties: []graphProperty{{ID: graphUIDProperty, Value: event.UID}},
+	}
+}
+
+// graphChanges are the requests needed to bring a calendar up to date.
+type graphChanges struct {
+	Create []graphEvent
+	Update []graphEvent // with ID set
+	Delete []string     // event IDs
+}
+
+// planGraphSync compares the events we created earlier, by UID, with the
+// events that should be in the calendar.
+func planGraphSync(existing map[string]graphEvent, events []calendarEvent) graphChanges {
+	rv := graphChanges{}
+	seen := map[string]bool{}
+	for _, event := range events {
+		seen[event.UID] = true
+		want := newGraphEvent(event)
+		have, ok := existing[event.UID]
+		if !ok {
+			rv.Create = append(rv.Create, want)
+			continue
+		}
+		if have.Subject == want.Subject && strings.HasPrefix(have.Start.DateTime, want.Start.DateTime[:10]) {
+			continue
+		}
+		want.ID = have.ID
+		rv.Update = append(rv.Update, want)
+	}
+	for uid, have := range existing {
+		if !seen[uid] {
+			rv.Delete = append(rv.Delete, have.ID)
+		}
+	}
+	sort.Strings(rv.Delete)
+	return rv
+}
+
+// graphToken returns an access token for the app registration in config,
+// using the client credentials flow.
+func graphToken(ctx context.Context, config GraphCalendarConfig) (string, error) {
+	secret := config.ClientSecret
+	if secret == "" {
+		secret = os.Getenv("GRAPH_CLIENT_SECRET")
+	}
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {config.ClientID},
+		"client_secret": {secret},
+		"scope":         {"https://graph.microsoft.com/.default"},
+	}
+	req, err := http.NewRequest("POST", fmt.Sprintf(graphTokenURL, url.PathEscape(config.TenantID)), strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := graphClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("cannot get access token: %s", resp.Status)
+	}
+	token := struct {
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("cannot get access token: %s", err)
+	}
+	return token.AccessToken, nil
+}
+
+// graphRequest makes a Graph API request and decodes the response into
+// out, if it isn't nil.
+func graphRequest(ctx context.Context, token, method, u string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		buf, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(buf)
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := graphClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(&io.LimitedReader{R: resp.Body, N: 1024})
+		return fmt.Errorf("%s %s: %s: %s", method, u, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// graphCalendarURL returns the URL of the calendar in config.
+func graphCalendarURL(config GraphCalendarConfig) string {
+	u := graphBaseURL + "/users/" + url.PathEscape(config.Mailbox)
+	if config.Calendar != "" {
+		return u + "/calendars/" + url.PathEscape(config.Calendar)
+	}
+	return u + "/calendar"
+}
+
+// listGraphEvents returns the events in the calendar that we created, by
+// UID.
+func listGraphEvents(ctx context.Context, token string, config GraphCalendarConfig) (map[string]graphEvent, error) {
+	query := url.Values{
+		"$filter": {"singleValueExtendedProperties/Any(ep: ep/id eq '" + graphUIDProperty + "' and ep/value ne null)"},
+		"$expand": {"singleValueExtendedProperties($filter=id eq '" + graphUIDProperty + "')"},
+		"$select": {"subject,start"},
+		"$top":    {"100"},
+	}
+	next := graphCalendarURL(config) + "/events?" + query.Encode()
+
+	rv := map[string]graphEvent{}
+	for next != "" {
+		page := struct {
+			Value    []graphEvent `json:"value"`
+			NextLink string       `json:"@odata.nextLink"`
+		}{}
+		if err := graphRequest(ctx, token, "GET", next, nil, &page); err != nil {
+			return nil, err
+		}
+		for _, event := range page.Value {
+			for _, p := range event.Properties {
+				if strings.EqualFold(p.ID, graphUIDProperty) {
+					rv[p.Value] = event
+				}
+			}
+		}
+		next = page.NextLink
+	}
+	return rv, nil
+}
+
+// syncGraphCalendar checks the watchlist in config and brings the calendar
+// up to date: creating events for new expirations, updating those that
+// have changed, and deleting those for hosts no longer in the watchlist.
+func (s *Server) syncGraphCalendar(ctx context.Context, config GraphCalendarConfig) error {
+	wl := s.watchlist(config.Watchlist)
+	if wl == nil {
+		return fmt.Errorf("no such watchlist %q", config.Watchlist)
+	}
+	expirations := getExpirations(ctx, wl.Hostnames(), s.defaultCheckOptions())
+	events := calendarEvents(expirations, time.Now(), s.Config.BaseURL, nil)
+
+	token, err := graphToken(ctx, config)
+	if err != nil {
+		return err
+	}
+	existing, err := listGraphEvents(ctx, token, config)
+	if err != nil {
+		return err
+	}
+
+	changes := planGraphSync(existing, events)
+	calendar := graphCalendarURL(config)
+	for _, event := range changes.Create {
+		if err := graphRequest(ctx, token, "POST", calendar+"/events", event, nil); err != nil {
+			return err
+		}
+	}
+	for _, event := range changes.Update {
+		id := event.ID
+		event.ID = ""
+		if err := graphRequest(ctx, token, "PATCH", calendar+"/events/"+url.PathEscape(id), event, nil); err != nil {
+			return err
+		}
+	}
+	for _, id := range changes.Delete {
+		if err := graphRequest(ctx, token, "DELETE", calendar+"/events/"+url.PathEscape(id), nil, nil); err != nil {
+			return err
+		}
+	}
+	log.Printf("watchlist %s: synced %s calendar: %d created, %d updated, %d deleted", config.Watchlist,
+		config.Mailbox, len(changes.Create), len(changes.Update), len(changes.Delete))
+	return nil
+}
+
+// runGraphSync keeps the calendar in config up to date, forever.
+func (s *Server) runGraphSync(config GraphCalendarConfig) {
+	interval := config.Interval
+	if interval == 0 {
+		interval = defaultGraphSyncInterval
+	}
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		if err := s.syncGraphCalendar(ctx, config); err != nil {
+			log.Printf("watchlist %s: cannot sync %s calendar: %s", config.Watchlist, config.Mailbox, err)
+		}
+		cancel()
+		time.Sleep(interval)
+	}
+}
+
+// StartGraphSync starts keeping each of the configured Outlook calendars
+// up to date.
+func (s *Server) StartGraphSync() {
+	for _, config := range s.Config.GraphCalendars {
+		go s.runGraphSync(config)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlanGraphSync(t *testing.T) {
+	expires := time.Date(2030, 1, 2, 12, 0, 0, 0, time.UTC)
+	events := []calendarEvent{
+		{UID: "unchanged@certificates.expire.sh", Date: expires, Summary: "unchanged"},
+		{UID: "renewed@certificates.expire.sh", Date: expires.AddDate(0, 3, 0), Summary: "renewed"},
+		{UID: "new@certificates.expire.sh", Date: expires, Summary: "new"},
+	}
+	existing := map[string]graphEvent{
+		"unchanged@certificates.expire.sh": {ID: "1", Subject: "unchanged", Start: graphDateTime{DateTime: "2030-01-02T00:00:00.0000000"}},
+		"renewed@certificates.expire.sh":   {ID: "2", Subject: "renewed", Start: graphDateTime{DateTime: "2030-01-02T00:00:00.0000000"}},
+		"removed@certificates.expire.sh":   {ID: "3", Subject: "removed"},
+	}
+
+	changes := planGraphSync(existing, events)
+	if len(changes.Create) != 1 || changes.Create[0].Subject != "new" {
+		t.Errorf("unexpected creates %+v", changes.Create)
+	}
+	if len(changes.Update) != 1 || changes.Update[0].ID != "2" || changes.Update[0].Start.DateTime != "2030-04-02T00:00:00" {
+		t.Errorf("unexpected updates %+v", changes.Update)
+	}
+	if len(changes.Delete) != 1 || changes.Delete[0] != "3" {
+		t.Errorf("unexpected deletes %+v", changes.Delete)
+	}
+	if uid := changes.Create[0].Properties; len(uid) != 1 || uid[0].Value != "new@certificates.expire.sh" {
+		t.Errorf("expected the UID property to be set, got %+v", uid)
+	}
+}
@@ -0,0 +1,574 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The GraphQL endpoint supports the subset of the language that dashboards
+// need to select fields and filter results: a single query operation with
+// variables, arguments and aliases, but no fragments or directives. The
+// schema is:
+//
+//	type Query {
+//	  expirations(hosts: [String!], watchlist: String, status: [String!],
+//	    ttl: String, lifetime: Float, quiet: Boolean): [Expiration!]!
+//	}
+//
+// where the fields of Expiration are those that can be selected with the
+// fields parameter, and status.
+
+const graphqlPath = "/graphql"
+
+// graphqlField is a field selected in a query.
+type graphqlField struct {
+	Alias      string
+	Name       string
+	Args       map[string]interface{}
+	Selections []graphqlField
+}
+
+// Key returns the name of the field in the response.
+func (f graphqlField) Key() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// graphqlParser parses a query document. Variables are substituted as the
+// arguments that use them are parsed.
+type graphqlParser struct {
+	src       string
+	pos       int
+	variables map[string]interface{}
+}
+
+// parseGraphQL parses query and returns the fields of its selection set.
+func parseGraphQL(query string, variables map[string]interface{}) ([]graphqlField, error) {
+	if variables == nil {
+		variables = map[string]interface{}{}
+	}
+	p := &graphqlParser{src: query, variables: variables}
+	p.skipIgnored()
+	if p.peek() != '{' {
+		switch keyword := p.name(); keyword {
+		case "query":
+		case "":
+			return nil, p.errorf("expected a query")
+		default:
+			return nil, p.errorf("%s operations are not supported", keyword)
+		}
+		p.skipIgnored()
+		p.name() // the operation name, if any
+		p.skipIgnored()
+		if p.peek() == '(' {
+			if err := p.variableDefinitions(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	fields, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	p.skipIgnored()
+	if p.pos < len(p.src) {
+		return nil, p.errorf("only a single operation is supported")
+	}
+	return fields, nil
+}
+
+func (p *graphqlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("syntax error at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *graphqlParser) peek() byte {
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+// skipIgnored skips whitespace, commas and comments, which are
+// insignificant in GraphQL.
+func (p *graphqlParser) skipIgnored() {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *graphqlParser) expect(c byte) error {
+	p.skipIgnored()
+	if p.peek() != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+// name returns the name at the current position, or "" if there isn't
+// one.
+func (p *graphqlParser) name() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || p.pos > start && '0' <= c && c <= '9' {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.src[start:p.pos]
+}
+
+// variableDefinitions parses the variable definitions of an operation,
+// using the default values of variables that weren't given.
+func (p *graphqlParser) variableDefinitions() error {
+	if err := p.expect('('); err != nil {
+		return err
+	}
+	for {
+		p.skipIgnored()
+		if p.peek() == ')' {
+			p.pos++
+			return nil
+		}
+		if err := p.expect('$'); err != nil {
+			return err
+		}
+		name := p.name()
+		if name == "" {
+			return p.errorf("expected a variable name")
+		}
+		if err := p.expect(':'); err != nil {
+			return err
+		}
+		// types aren't checked, since arguments are checked when the
+		// query is run
+		p.skipIgnored()
+		for {
+			if c := p.peek(); c == '[' || c == ']' || c == '!' {
+				p.pos++
+			} else if p.name() == "" {
+				break
+			}
+			p.skipIgnored()
+		}
+		if p.peek() == '=' {
+			p.pos++
+			value, err := p.value()
+			if err != nil {
+				return err
+			}
+			if _, ok := p.variables[name]; !ok {
+				p.variables[name] = value
+			}
+		}
+	}
+}
+
+func (p *graphqlParser) selectionSet() ([]graphqlField, error) {
+	if err := p.expect('{'); err != nil {
+		return nil, err
+	}
+	var rv []graphqlField
+	for {
+		p.skipIgnored()
+		switch p.peek() {
+		case '}':
+			p.pos++
+			if len(rv) == 0 {
+				return nil, p.errorf("empty selection")
+			}
+			return rv, nil
+		case '.':
+			return nil, p.errorf("fragments are not supported")
+		case '@':
+			return nil, p.errorf("directives are not supported")
+		}
+		field, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		rv = append(rv, field)
+	}
+}
+
+func (p *graphqlParser) field() (graphqlField, error) {
+	var rv graphqlField
+	rv.Name = p.name()
+	if rv.Name == "" {
+		return rv, p.errorf("expected a field")
+	}
+	p.skipIgnored()
+	if p.peek() == ':' {
+		p.pos++
+		p.skipIgnored()
+		rv.Alias, rv.Name = rv.Name, p.name()
+		if rv.Name == "" {
+			return rv, p.errorf("expected a field")
+		}
+		p.skipIgnored()
+	}
+	if p.peek() == '(' {
+		p.pos++
+		rv.Args = map[string]interface{}{}
+		for {
+			p.skipIgnored()
+			if p.peek() == ')' {
+				p.pos++
+				break
+			}
+			name := p.name()
+			if name == "" {
+				return rv, p.errorf("expected an argument")
+			}
+			if err := p.expect(':'); err != nil {
+				return rv, err
+			}
+			value, err := p.value()
+			if err != nil {
+				return rv, err
+			}
+			rv.Args[name] = value
+		}
+		p.skipIgnored()
+	}
+	if p.peek() == '{' {
+		var err error
+		if rv.Selections, err = p.selectionSet(); err != nil {
+			return rv, err
+		}
+	}
+	return rv, nil
+}
+
+// value parses a value, as the types that encoding/json would decode it
+// to, so that literals and variables look the same.
+func (p *graphqlParser) value() (interface{}, error) {
+	p.skipIgnored()
+	switch c := p.peek(); {
+	case c == '$':
+		p.pos++
+		name := p.name()
+		if name == "" {
+			return nil, p.errorf("expected a variable name")
+		}
+		return p.variables[name], nil
+	case c == '"':
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.src) && p.src[p.pos] != '"' {
+			if p.src[p.pos] == '\\' {
+				p.pos++
+			}
+			p.pos++
+		}
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("unterminated string")
+		}
+		p.pos++
+		var s string
+		if err := json.Unmarshal([]byte(p.src[start:p.pos]), &s); err != nil {
+			return nil, p.errorf("invalid string")
+		}
+		return s, nil
+	case c == '[':
+		p.pos++
+		rv := []interface{}{}
+		for {
+			p.skipIgnored()
+			if p.peek() == ']' {
+				p.pos++
+				return rv, nil
+			}
+			if p.peek() == 0 {
+				return nil, p.errorf("unterminated list")
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			rv = append(rv, v)
+		}
+	case c == '-' || '0' <= c && c <= '9':
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.src) && strings.IndexByte("0123456789.eE+-", p.src[p.pos]) >= 0 {
+			p.pos++
+		}
+		f, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			return nil, p.errorf("invalid number")
+		}
+		return f, nil
+	}
+	switch name := p.name(); name {
+	case "":
+		return nil, p.errorf("expected a value")
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	default:
+		return name, nil // an enum value
+	}
+}
+
+// graphqlStrings returns v, an argument that is a string or a list of
+// strings, as a list.
+func graphqlStrings(v interface{}) ([]string, bool) {
+	switch v := v.(type) {
+	case string:
+		return []string{v}, true
+	case []interface{}:
+		rv := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			rv[i] = s
+		}
+		return rv, true
+	}
+	return nil, false
+}
+
+// graphqlExpirationField returns the value of the field named name of e,
+// which is one of the fields that can be selected with the fields
+// parameter, or status.
+func graphqlExpirationField(name string, e Expiration, t thresholds) (interface{}, bool) {
+	switch name {
+	case "status":
+		return e.Status(t), true
+	case "__typename":
+		return "Expiration", true
+	}
+	for _, f := range allFields {
+		if f.Name == name {
+			return f.Value(e, t.Now), true
+		}
+	}
+	return nil, false
+}
+
+// graphqlExpirationsQuery is the arguments of the expirations field.
+type graphqlExpirationsQuery struct {
+	Hostnames  []string
+	Status     map[string]bool // if not empty, only these statuses
+	Quiet      bool
+	Thresholds thresholds
+}
+
+// parseGraphQLExpirations checks the arguments and selections of the
+// expirations field f, before any hosts are checked.
+func (s *Server) parseGraphQLExpirations(f graphqlField, now time.Time) (graphqlExpirationsQuery, error) {
+	var rv graphqlExpirationsQuery
+	ttl, lifetime := "", ""
+
+	if v, ok := f.Args["watchlist"]; ok && v != nil {
+		name, _ := v.(string)
+		wl := s.watchlist(name)
+		if wl == nil {
+			return rv, fmt.Errorf("no such watchlist %q", name)
+		}
+		rv.Hostnames = wl.Hostnames()
+		ttl, lifetime = wl.TTL, wl.Lifetime
+	}
+	for name, v := range f.Args {
+		if v == nil {
+			continue
+		}
+		var ok bool
+		switch name {
+		case "watchlist":
+		case "hosts":
+			hosts, ok := graphqlStrings(v)
+			if !ok {
+				return rv, fmt.Errorf("hosts: expected a list of strings")
+			}
+			rv.Hostnames = append(rv.Hostnames, hosts...)
+		case "status":
+			statuses, ok := graphqlStrings(v)
+			if !ok {
+				return rv, fmt.Errorf("status: expected a list of strings")
+			}
+			rv.Status = map[string]bool{}
+			for _, status := range statuses {
+				rv.Status[strings.ToLower(status)] = true
+			}
+		case "ttl":
+			if ttl, ok = v.(string); !ok {
+				return rv, fmt.Errorf("ttl: expected a duration like 30d")
+			}
+		case "lifetime":
+			switch v := v.(type) {
+			case float64:
+				lifetime = strconv.FormatFloat(v, 'f', -1, 64)
+			case string:
+				lifetime = v
+			default:
+				return rv, fmt.Errorf("lifetime: expected a percentage")
+			}
+		case "quiet":
+			if rv.Quiet, ok = v.(bool); !ok {
+				return rv, fmt.Errorf("quiet: expected a boolean")
+			}
+		default:
+			return rv, fmt.Errorf("unknown argument %q to expirations", name)
+		}
+	}
+	if len(rv.Hostnames) == 0 {
+		return rv, fmt.Errorf("expirations requires hosts or a watchlist")
+	}
+
+	d := defaultTTL
+	if ttl != "" {
+		var err error
+		if d, err = parseDuration(ttl); err != nil {
+			return rv, fmt.Errorf("ttl: %s", err)
+		}
+	}
+	rv.Thresholds = newThresholds(now, d)
+	if lifetime != "" {
+		var err error
+		if rv.Thresholds.Lifetime, err = parseLifetime(lifetime); err != nil {
+			return rv, fmt.Errorf("lifetime: %s", err)
+		}
+	}
+
+	if len(f.Selections) == 0 {
+		return rv, fmt.Errorf("expirations requires a selection of fields")
+	}
+	for _, sel := range f.Selections {
+		if _, ok := graphqlExpirationField(sel.Name, Expiration{}, rv.Thresholds); !ok {
+			return rv, fmt.Errorf("unknown field %q of Expiration", sel.Name)
+		}
+		if sel.Selections != nil || sel.Args != nil {
+			return rv, fmt.Errorf("field %q of Expiration takes no arguments or selections", sel.Name)
+		}
+	}
+	return rv, nil
+}
+
+// graphqlExpirations returns the selected fields of those of expirations
+// that match q.
+func graphqlExpirations(expirations []Expiration, q graphqlExpirationsQuery, selections []graphqlField) []map[string]interface{} {
+	rv := []map[string]interface{}{}
+	for _, exp := range expirations {
+		if q.Quiet && exp.OK(q.Thresholds) {
+			continue
+		}
+		if len(q.Status) > 0 && !q.Status[exp.Status(q.Thresholds)] {
+			continue
+		}
+		row := map[string]interface{}{}
+		for _, sel := range selections {
+			row[sel.Key()], _ = graphqlExpirationField(sel.Name, exp, q.Thresholds)
+		}
+		rv = append(rv, row)
+	}
+	return rv
+}
+
+// graphqlError writes a response with a single error and no data.
+func graphqlError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]string{{"message": err.Error()}},
+	})
+}
+
+// serveGraphQL answers a GraphQL query, given as the query and variables
+// parameters of a GET, or as a JSON body of a POST.
+func (s *Server) serveGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}
+	switch r.Method {
+	case "GET":
+		req.Query = r.FormValue("query")
+		if v := r.FormValue("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				graphqlError(w, http.StatusBadRequest, fmt.Errorf("cannot parse variables: %s", err))
+				return
+			}
+		}
+	case "POST":
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			graphqlError(w, http.StatusBadRequest, fmt.Errorf("cannot parse request: %s", err))
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		graphqlError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+	stats.CountRequest("application/graphql")
+
+	fields, err := parseGraphQL(req.Query, req.Variables)
+	if err != nil {
+		graphqlError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// check every field before checking any hosts
+	now := time.Now()
+	queries := make([]graphqlExpirationsQuery, len(fields))
+	n := 0
+	for i, f := range fields {
+		switch f.Name {
+		case "__typename":
+		case "expirations":
+			if queries[i], err = s.parseGraphQLExpirations(f, now); err != nil {
+				graphqlError(w, http.StatusBadRequest, err)
+				return
+			}
+			n += len(queries[i].Hostnames)
+		default:
+			graphqlError(w, http.StatusBadRequest, fmt.Errorf("unknown field %q of Query", f.Name))
+			return
+		}
+	}
+	// the limit is on the whole query, however its hosts are split
+	// between fields
+	if err := s.hostCountError(n); err != nil {
+		graphqlError(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	if !s.chargeQuota(w, r, n) {
+		return
+	}
+
+	data := map[string]interface{}{}
+	for i, f := range fields {
+		if f.Name == "__typename" {
+			data[f.Key()] = "Query"
+			continue
+		}
+		expirations := getExpirations(r.Context(), queries[i].Hostnames, s.defaultCheckOptions())
+		s.applyAcknowledgements(expirations, now)
+		data[f.Key()] = graphqlExpirations(expirations, queries[i], f.Selections)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseGraphQL(t *testing.T) {
+	query := `
+	query Dashboard($hosts: [String!]!, $ttl: String = "60d") {
+		# only what the dashboard shows
+		soon: expirations(hosts: $hosts, ttl: $ttl, lifetime: 80, status: [expiring, error], quiet: true) {
+			name
+			expires: domainExpires
+		}
+	}`
+	fields, err := parseGraphQL(query, map[string]interface{}{"hosts": []interface{}{"example.com", "example.net"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 1 {
+		t.Fatalf("expected a single field, got %+v", fields)
+	}
+	f := fields[0]
+	if f.Key() != "soon" || f.Name != "expirations" {
+		t.Errorf("unexpected field %s: %s", f.Alias, f.Name)
+	}
+	got := fmt.Sprintf("%v %v %v %v %v", f.Args["hosts"], f.Args["ttl"], f.Args["lifetime"], f.Args["status"], f.Args["quiet"])
+	if want := "[example.com example.net] 60d 80 [expiring error] true"; got != want {
+		t.Errorf("expected arguments %s, got %s", want, got)
+	}
+	if len(f.Selections) != 2 || f.Selections[1].Key() != "expires" || f.Selections[1].Name != "domainExpires" {
+		t.Errorf("unexpected selections %+v", f.Selections)
+	}
+
+	for _, query := range []string{
+		`{ expirations(hosts: "example.com") { name }`,
+		`mutation { expirations { name } }`,
+		`{ expirations(hosts: "example.com") { ...fields } }`,
+		`{ expirations(hosts: "example.com) { name } }`,
+		`{ }`,
+	} {
+		if _, err := parseGraphQL(query, nil); err == nil {
+			t.Errorf("expected an error parsing %s", query)
+		}
+	}
+}
+
+func TestGraphQLExpirations(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := &Server{Config: &Config{}}
+	fields, err := parseGraphQL(`{ expirations(hosts: ["a.example.com", "b.example.com"], status: ERROR) { name status } }`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := s.parseGraphQLExpirations(fields[0], now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expirations := []Expiration{
+		{Name: "a.example.com", CertificateExpires: now.AddDate(1, 0, 0), DomainExpires: now.AddDate(1, 0, 0)},
+		{Name: "b.example.com", CertificateError: fmt.Errorf("dial failed")},
+	}
+	rows := graphqlExpirations(expirations, q, fields[0].Selections)
+	got, _ := json.Marshal(rows)
+	if want := `[{"name":"b.example.com","status":"error"}]`; string(got) != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	for _, query := range []string{
+		`{ expirations { name } }`,
+		`{ expirations(hosts: "a.example.com") { name bogus } }`,
+		`{ expirations(hosts: "a.example.com", ttl: "soon") { name } }`,
+		`{ expirations(hosts: "a.example.com", color: "red") { name } }`,
+		`{ expirations(watchlist: "prod") { name } }`,
+	} {
+		fields, err := parseGraphQL(query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.parseGraphQLExpirations(fields[0], now); err == nil {
+			t.Errorf("expected an error for %s", query)
+		}
+	}
+}
+
+func TestServeGraphQLErrors(t *testing.T) {
+	s := NewServer(&Config{})
+	for _, body := range []string{
+		`{"query": "{ certificates { name } }"}`,
+		`{"query": "{ expirations(hosts: [\"example.com\"]) }"}`,
+		`not json`,
+	} {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("POST", "/graphql", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, w.Code)
+		}
+		var resp struct {
+			Errors []struct{ Message string }
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || len(resp.Errors) != 1 {
+			t.Errorf("%s: expected an error, got %v %+v", body, err, resp)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+const groupPrefix = "/group/"
+
+// ValidateThresholds returns an error if the watchlist's ttl or lifetime
+// can't be parsed.
+func (wl Watchlist) ValidateThresholds() error {
+	if wl.TTL != "" {
+		if _, err := parseDuration(wl.TTL); err != nil {
+			return fmt.Errorf("watchlist %s: ttl: %s", wl.Name, err)
+		}
+	}
+	if wl.Lifetime != "" {
+		if _, err := parseLifetime(wl.Lifetime); err != nil {
+			return fmt.Errorf("watchlist %s: lifetime: %s", wl.Name, err)
+		}
+	}
+	return nil
+}
+
+// serveGroup checks the hosts of the watchlist named name and writes the
+// results as for a list of hosts, in any format. The watchlist's ttl and
+// lifetime apply unless the request gives its own.
+func (s *Server) serveGroup(w http.ResponseWriter, r *http.Request, name string) {
+	wl := s.watchlist(name)
+	if wl == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	applyWatchlistThresholds(r, *wl)
+	s.serveHostnames(w, r, wl.Hostnames())
+}
+
+// applyWatchlistThresholds sets the ttl and lifetime parameters of r to
+// the watchlist's, unless r gives its own.
+func applyWatchlistThresholds(r *http.Request, wl Watchlist) {
+	query := r.URL.Query()
+	for param, value := range map[string]string{"ttl": wl.TTL, "lifetime": wl.Lifetime} {
+		if value != "" && query.Get(param) == "" {
+			query.Set(param, value)
+		}
+	}
+	r.URL.RawQuery = query.Encode()
+	r.Form = nil // parsed again with the defaults
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchlistValidateThresholds(t *testing.T) {
+	for _, wl := range []Watchlist{{}, {TTL: "60d", Lifetime: "80%"}} {
+		if err := wl.ValidateThresholds(); err != nil {
+			t.Errorf("%+v: %s", wl, err)
+		}
+	}
+	for _, wl := range []Watchlist{{TTL: "soon"}, {Lifetime: "200"}} {
+		if err := wl.ValidateThresholds(); err == nil {
+			t.Errorf("%+v: expected error", wl)
+		}
+	}
+}
+
+func TestServeGroup(t *testing.T) {
+	now := time.Now()
+	s := NewServer(&Config{Watchlists: []Watchlist{{
+		Name:  "prod",
+		Hosts: []WatchlistHost{{Name: "group.example.com"}},
+		TTL:   "60d",
+	}}})
+	opts := s.defaultCheckOptions()
+	results.Set(opts.cacheKey("group.example.com"), Expiration{
+		Name:               "group.example.com",
+		CertificateExpires: now.AddDate(0, 0, 45),
+		Details:            &Details{},
+	}, now)
+
+	// expiring soon under the group's ttl, but not the default of 30 days
+	for _, tt := range []struct {
+		url  string
+		code int
+	}{
+		{"/json/group/prod", 417},
+		{"/json/group/prod?ttl=30d", 200},
+		{"/json/group/nope", 404},
+	} {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", tt.url, nil))
+		if w.Code != tt.code {
+			t.Errorf("%s: expected %d, got %d: %s", tt.url, tt.code, w.Code, w.Body.String())
+		}
+		if tt.code != 404 && !strings.Contains(w.Body.String(), "group.example.com") {
+			t.Errorf("%s: unexpected body %s", tt.url, w.Body.String())
+		}
+	}
+}
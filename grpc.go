@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The gRPC API is described by expire.proto. It is served by hand over
+// HTTP/2 rather than with a generated server, since it is small: a single
+// streaming method whose messages are simple to encode.
+
+// grpcCheckMethod is the path of the Check method of the Expire service.
+const grpcCheckMethod = "/expiresh.Expire/Check"
+
+// maxGRPCRequest is the largest request message accepted.
+const maxGRPCRequest = 1 << 20
+
+// gRPC status codes
+const (
+	grpcOK                = 0
+	grpcInvalidArgument   = 3
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+	grpcUnauthenticated   = 16
+)
+
+// isGRPC returns true if r is a gRPC call.
+func isGRPC(r *http.Request) bool {
+	return r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
+}
+
+// grpcCheckRequest is the CheckRequest message.
+type grpcCheckRequest struct {
+	Hosts  []string
+	Follow bool
+	WWW    bool
+	CAA    bool
+}
+
+// errTruncatedMessage is returned when a protobuf message ends in the
+// middle of a field.
+var errTruncatedMessage = errors.New("truncated message")
+
+// decodeGRPCCheckRequest decodes a CheckRequest. Unknown fields are
+// skipped, as protobuf requires.
+func decodeGRPCCheckRequest(b []byte) (grpcCheckRequest, error) {
+	var rv grpcCheckRequest
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return rv, errTruncatedMessage
+		}
+		b = b[n:]
+		field, wireType := key>>3, key&7
+		switch wireType {
+		case 0: // varint
+			v, n := binary.Uvarint(b)
+			if n <= 0 {
+				return rv, errTruncatedMessage
+			}
+			b = b[n:]
+			switch field {
+			case 2:
+				rv.Follow = v != 0
+			case 3:
+				rv.WWW = v != 0
+			case 4:
+				rv.CAA = v != 0
+			}
+		case 1: // 64 bit
+			if len(b) < 8 {
+				return rv, errTruncatedMessage
+			}
+			b = b[8:]
+		case 2: // length delimited
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return rv, errTruncatedMessage
+			}
+			value := b[n : n+int(l)]
+			b = b[n+int(l):]
+			if field == 1 {
+				rv.Hosts = append(rv.Hosts, string(value))
+			}
+		case 5: // 32 bit
+			if len(b) < 4 {
+				return rv, errTruncatedMessage
+			}
+			b = b[4:]
+		default:
+			return rv, fmt.Errorf("unsupported wire type %d", wireType)
+		}
+	}
+	return rv, nil
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return append(b, buf[:binary.PutUvarint(buf, v)]...)
+}
+
+func appendProtoVarint(b []byte, field int, v uint64) []byte {
+	b = appendUvarint(b, uint64(field)<<3)
+	return appendUvarint(b, v)
+}
+
+func appendProtoBytes(b []byte, field int, v []byte) []byte {
+	b = appendUvarint(b, uint64(field)<<3|2)
+	b = appendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// appendProtoString appends a string field, unless it is empty, which is
+// the default.
+func appendProtoString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return appendProtoBytes(b, field, []byte(s))
+}
+
+// appendProtoTimestamp appends a google.protobuf.Timestamp field, unless t
+// is zero.
+func appendProtoTimestamp(b []byte, field int, t time.Time) []byte {
+	if t.IsZero() {
+		return b
+	}
+	var ts []byte
+	if s := t.Unix(); s != 0 {
+		ts = appendProtoVarint(ts, 1, uint64(s))
+	}
+	if ns := t.Nanosecond(); ns != 0 {
+		ts = appendProtoVarint(ts, 2, uint64(ns))
+	}
+	return appendProtoBytes(b, field, ts)
+}
+
+// encodeGRPCExpiration encodes exp as an Expiration message.
+func encodeGRPCExpiration(exp Expiration) []byte {
+	var b []byte
+	b = appendProtoString(b, 1, exp.Name)
+	if exp.CertificateError == nil {
+		b = appendProtoTimestamp(b, 2, exp.CertificateExpires)
+		b = appendProtoTimestamp(b, 3, exp.CertificateNotBefore)
+	} else {
+		b = appendProtoString(b, 4, exp.CertificateError.Error())
+	}
+	b = appendProtoString(b, 5, exp.Domain)
+	if exp.DomainError == nil {
+		b = appendProtoTimestamp(b, 6, exp.DomainExpires)
+	} else {
+		b = appendProtoString(b, 7, exp.DomainError.Error())
+	}
+	for _, warning := range exp.Warnings {
+		b = appendProtoBytes(b, 8, []byte(warning))
+	}
+	b = appendProtoString(b, 9, exp.TLSVersion)
+	b = appendProtoString(b, 10, exp.CipherSuite)
+	return b
+}
+
+// writeGRPCMessage writes msg to w as an uncompressed, length prefixed
+// gRPC message.
+func writeGRPCMessage(w io.Writer, msg []byte) error {
+	prefix := make([]byte, 5)
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(msg)))
+	_, err := w.Write(append(prefix, msg...))
+	return err
+}
+
+// readGRPCMessage reads the single request message of a unary or server
+// streaming call.
+func readGRPCMessage(r io.Reader) ([]byte, error) {
+	prefix := make([]byte, 5)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, fmt.Errorf("reading message: %s", err)
+	}
+	if prefix[0] != 0 {
+		return nil, fmt.Errorf("compressed messages are not supported")
+	}
+	n := binary.BigEndian.Uint32(prefix[1:])
+	if n > maxGRPCRequest {
+		return nil, fmt.Errorf("message is too large")
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, fmt.Errorf("reading message: %s", err)
+	}
+	return msg, nil
+}
+
+// serveGRPC answers a gRPC call. The status is always sent in the
+// trailers, as gRPC requires, even when there are no messages.
+func (s *Server) serveGRPC(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	code, message := s.serveGRPCCheck(w, r)
+	w.Header().Set("Grpc-Status", fmt.Sprint(code))
+	if message != "" {
+		w.Header().Set("Grpc-Message", message)
+	}
+}
+
+// serveGRPCCheck implements the Check method, streaming the result for
+// each host as soon as it is known, and returns the status of the call.
+func (s *Server) serveGRPCCheck(w http.ResponseWriter, r *http.Request) (int, string) {
+	if r.URL.Path != grpcCheckMethod {
+		return grpcUnimplemented, fmt.Sprintf("unknown method %s", r.URL.Path)
+	}
+	msg, err := readGRPCMessage(r.Body)
+	if err != nil {
+		return grpcInvalidArgument, err.Error()
+	}
+	req, err := decodeGRPCCheckRequest(msg)
+	if err != nil {
+		return grpcInvalidArgument, err.Error()
+	}
+	if len(req.Hosts) == 0 {
+		return grpcInvalidArgument, "no hosts"
+	}
+	opts := s.defaultCheckOptions()
+	opts.Follow = req.Follow
+	opts.WWW = req.WWW
+	opts.CAA = req.CAA
+	n := checkedHostCount(req.Hosts, opts)
+	if err := s.hostCountError(n); err != nil {
+		return grpcInvalidArgument, err.Error()
+	}
+
+	key := s.apiKey(r)
+	if key == nil && s.Config.RequireAPIKey {
+		return grpcUnauthenticated, "checking hosts requires an API key"
+	}
+	if key != nil {
+		if err := s.quotas.Charge(key, n, time.Now()); err != nil {
+			return grpcResourceExhausted, err.Error()
+		}
+	}
+
+	flusher, _ := w.(http.Flusher)
+	streamExpirations(r.Context(), req.Hosts, opts, func(exp Expiration) {
+		if err := writeGRPCMessage(w, encodeGRPCExpiration(exp)); err == nil && flusher != nil {
+			flusher.Flush()
+		}
+	})
+	return grpcOK, ""
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDecodeGRPCCheckRequest(t *testing.T) {
+	var b []byte
+	b = appendProtoBytes(b, 1, []byte("example.com"))
+	b = appendProtoVarint(b, 2, 1)
+	b = appendProtoVarint(b, 99, 7) // unknown fields are skipped
+	b = appendProtoBytes(b, 1, []byte("example.net"))
+
+	req, err := decodeGRPCCheckRequest(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(req.Hosts) != "[example.com example.net]" || !req.Follow || req.WWW {
+		t.Errorf("unexpected request %+v", req)
+	}
+
+	if _, err := decodeGRPCCheckRequest(b[:len(b)-3]); err == nil {
+		t.Errorf("expected an error for a truncated message")
+	}
+}
+
+func TestEncodeGRPCExpiration(t *testing.T) {
+	exp := Expiration{
+		Name:               "example.com",
+		CertificateExpires: time.Unix(1600000000, 0),
+		DomainError:        fmt.Errorf("whois: timeout"),
+	}
+	var want []byte
+	want = appendProtoBytes(want, 1, []byte("example.com"))
+	want = appendProtoBytes(want, 2, appendProtoVarint(nil, 1, 1600000000))
+	want = appendProtoBytes(want, 7, []byte("whois: timeout"))
+	if got := encodeGRPCExpiration(exp); !bytes.Equal(got, want) {
+		t.Errorf("expected %x, got %x", want, got)
+	}
+}
+
+// grpcRequest returns a gRPC call of method with msg.
+func grpcRequest(method string, msg []byte) *httptest.ResponseRecorder {
+	body := &bytes.Buffer{}
+	writeGRPCMessage(body, msg)
+	r := httptest.NewRequest("POST", method, body)
+	r.ProtoMajor = 2
+	r.Header.Set("Content-Type", "application/grpc")
+	w := httptest.NewRecorder()
+	NewServer(&Config{}).ServeHTTP(w, r)
+	return w
+}
+
+func TestServeGRPC(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	server := testTLSServer(t, "example.com", now.AddDate(0, 1, 0))
+	defer server.Close()
+	host := server.Listener.Addr().String()
+
+	w := grpcRequest(grpcCheckMethod, appendProtoBytes(nil, 1, []byte(host)))
+	if status := w.Result().Trailer.Get("Grpc-Status"); status != "0" {
+		t.Fatalf("expected status 0, got %q (%s)", status, w.Result().Trailer.Get("Grpc-Message"))
+	}
+	b := w.Body.Bytes()
+	if len(b) < 5 || int(binary.BigEndian.Uint32(b[1:5])) != len(b)-5 {
+		t.Fatalf("expected a single message, got %x", b)
+	}
+	want := appendProtoBytes(nil, 1, []byte(host))
+	if !bytes.HasPrefix(b[5:], want) {
+		t.Errorf("expected the result for %s, got %x", host, b[5:])
+	}
+
+	w = grpcRequest(grpcCheckMethod, nil)
+	if status := w.Header().Get("Grpc-Status"); status != "3" {
+		t.Errorf("expected status 3 without hosts, got %q", status)
+	}
+	w = grpcRequest("/expiresh.Expire/Renew", nil)
+	if status := w.Header().Get("Grpc-Status"); status != "12" {
+		t.Errorf("expected status 12 for an unknown method, got %q", status)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net"
+	"time"
+)
+
+// UpstreamStats describe how the servers we depend on have been doing:
+// the whois servers of a registry, or the TLS servers on a network. They
+// tell a registry that is throttling us apart from a bug in the checks.
+type UpstreamStats struct {
+	Checks      int     `json:"checks"`
+	Failures    int     `json:"failures"`
+	FailureRate float64 `json:"failureRate"`
+	MeanMillis  int64   `json:"meanMillis"`
+	MaxMillis   int64   `json:"maxMillis"`
+
+	totalMillis int64
+}
+
+// add records a check that took elapsed.
+func (u *UpstreamStats) add(elapsed time.Duration, failed bool) {
+	millis := int64(elapsed / time.Millisecond)
+	u.Checks++
+	if failed {
+		u.Failures++
+	}
+	u.totalMillis += millis
+	if millis > u.MaxMillis {
+		u.MaxMillis = millis
+	}
+	u.FailureRate = float64(u.Failures) / float64(u.Checks)
+	u.MeanMillis = u.totalMillis / int64(u.Checks)
+}
+
+// unknownNetwork is the network of TLS checks that failed before an
+// address was known, e.g. because the host doesn't resolve.
+const unknownNetwork = "unknown"
+
+// tlsNetwork returns the network that address, or the address that err
+// failed to dial, belongs to: its /24 for IPv4 or /48 for IPv6.
+func tlsNetwork(address string, err error) string {
+	if address == "" {
+		if opErr, ok := err.(*net.OpError); ok && opErr.Addr != nil {
+			address = opErr.Addr.String()
+		}
+	}
+	host, _, splitErr := net.SplitHostPort(address)
+	if splitErr != nil {
+		host = address
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return unknownNetwork
+	}
+	if v4 := ip.To4(); v4 != nil {
+		mask := net.CIDRMask(24, 32)
+		return (&net.IPNet{IP: v4.Mask(mask), Mask: mask}).String()
+	}
+	mask := net.CIDRMask(48, 128)
+	return (&net.IPNet{IP: ip.Mask(mask), Mask: mask}).String()
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTLSNetwork(t *testing.T) {
+	for _, tc := range []struct {
+		address string
+		err     error
+		want    string
+	}{
+		{"192.0.2.17:443", nil, "192.0.2.0/24"},
+		{"[2001:db8:1:2::1]:443", nil, "2001:db8:1::/48"},
+		{"", &net.OpError{Op: "dial", Net: "tcp", Addr: &net.TCPAddr{IP: net.ParseIP("198.51.100.9"), Port: 443}}, "198.51.100.0/24"},
+		{"", &net.OpError{Op: "dial", Net: "tcp", Err: fmt.Errorf("no such host")}, unknownNetwork},
+		{"", fmt.Errorf("timeout"), unknownNetwork},
+	} {
+		if got := tlsNetwork(tc.address, tc.err); got != tc.want {
+			t.Errorf("%q %v: expected %s, got %s", tc.address, tc.err, tc.want, got)
+		}
+	}
+}
+
+func TestUpstreamStats(t *testing.T) {
+	u := &UpstreamStats{}
+	u.add(100*time.Millisecond, false)
+	u.add(300*time.Millisecond, true)
+	if u.Checks != 2 || u.Failures != 1 || u.FailureRate != 0.5 || u.MeanMillis != 200 || u.MaxMillis != 300 {
+		t.Errorf("unexpected stats %#v", u)
+	}
+}
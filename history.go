@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxHistoryEntries is how many distinct results are remembered for each
+// host.
+const maxHistoryEntries = 50
+
+// HistoryEntry is a result that was seen for a host between FirstChecked
+// and LastChecked.
+type HistoryEntry struct {
+	FirstChecked       time.Time `json:"firstChecked"`
+	LastChecked        time.Time `json:"lastChecked"`
+	CertificateExpires time.Time `json:"certExpires"`
+	CertificateError   string    `json:"certError,omitempty"`
+	Fingerprint        string    `json:"fingerprint,omitempty"` // of the leaf, SHA-256
+	DomainExpires      time.Time `json:"domainExpires"`
+	DomainError        string    `json:"domainError,omitempty"`
+}
+
+// sameResult returns true if e and other differ only in when they were
+// checked.
+func (e HistoryEntry) sameResult(other HistoryEntry) bool {
+	return e.CertificateExpires.Equal(other.CertificateExpires) &&
+		e.CertificateError == other.CertificateError &&
+		e.Fingerprint == other.Fingerprint &&
+		e.DomainExpires.Equal(other.DomainExpires) &&
+		e.DomainError == other.DomainError
+}
+
+// History remembers the results of checks of each host.
+type History interface {
+	// Record adds the result of a check made at now.
+	Record(exp Expiration, now time.Time)
+
+	// Entries returns the history of name, oldest first.
+	Entries(name string) []HistoryEntry
+}
+
+// HistoryConfig selects where the history of checks is kept.
+type HistoryConfig struct {
+	// Type is memory, which keeps the last 50 distinct results of each
+	// host until restart, or sqlite, which records every check in the
+	// SQLite database at Path. The default is sqlite if Path is set, and
+	// memory otherwise.
+	Type string `yaml:"type"`
+	Path string `yaml:"path"`
+}
+
+// openHistory returns the history described by config.
+func openHistory(config HistoryConfig) (History, error) {
+	typ := config.Type
+	if typ == "" && config.Path != "" {
+		typ = "sqlite"
+	}
+	switch typ {
+	case "", "memory":
+		return newMemoryHistory(), nil
+	case "sqlite":
+		if config.Path == "" {
+			return nil, fmt.Errorf("a sqlite history requires a path")
+		}
+		return openSQLiteHistory(config.Path)
+	default:
+		return nil, fmt.Errorf("unknown history type %q, expected memory or sqlite", config.Type)
+	}
+}
+
+// checkHistory is the history recorded by getExpirations
+var checkHistory History = newMemoryHistory()
+
+// newHistoryEntry returns the entry for the result of a check made at now.
+func newHistoryEntry(exp Expiration, now time.Time) HistoryEntry {
+	entry := HistoryEntry{
+		FirstChecked:       now,
+		LastChecked:        now,
+		CertificateExpires: exp.CertificateExpires,
+		CertificateError:   errorString(exp.CertificateError),
+		DomainExpires:      exp.DomainExpires,
+		DomainError:        errorString(exp.DomainError),
+	}
+	if exp.CertificateError == nil && len(exp.CertificateChain) > 0 {
+		entry.Fingerprint = exp.CertificateChain[0].Fingerprint
+	}
+	return entry
+}
+
+// memoryHistory remembers the results of recent checks of each host, in
+// memory.
+type memoryHistory struct {
+	mu      sync.Mutex
+	entries map[string][]HistoryEntry
+}
+
+func newMemoryHistory() *memoryHistory {
+	return &memoryHistory{entries: map[string][]HistoryEntry{}}
+}
+
+func (h *memoryHistory) Record(exp Expiration, now time.Time) {
+	entry := newHistoryEntry(exp, now)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entries := h.entries[exp.Name]
+	if n := len(entries); n > 0 && entries[n-1].sameResult(entry) {
+		entries[n-1].LastChecked = now
+		return
+	}
+	entries = append(entries, entry)
+	if len(entries) > maxHistoryEntries {
+		entries = entries[len(entries)-maxHistoryEntries:]
+	}
+	h.entries[exp.Name] = entries
+}
+
+func (h *memoryHistory) Entries(name string) []HistoryEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]HistoryEntry{}, h.entries[name]...)
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// Kinds of change in an expiration
+const (
+	ChangeRenewed   = "renewed"
+	ChangeRegressed = "regressed"
+	ChangeReplaced  = "replaced"
+)
+
+// HistoryChange is a change in when a certificate or domain expires: a
+// renewal if it moved later, or a regression if it moved earlier, e.g.
+// because an old certificate was deployed again. A certificate that was
+// replaced by another that expires at the same time is also a change.
+type HistoryChange struct {
+	Time                time.Time `json:"time"` // when the change was first seen
+	Check               string    `json:"check"`
+	Kind                string    `json:"kind"`
+	PreviousExpires     time.Time `json:"previousExpires"`
+	Expires             time.Time `json:"expires"`
+	PreviousFingerprint string    `json:"previousFingerprint,omitempty"` // of certificates, if known
+	Fingerprint         string    `json:"fingerprint,omitempty"`
+}
+
+func (c HistoryChange) String() string {
+	if c.Kind == ChangeReplaced {
+		return fmt.Sprintf("%s %s replaced by %s, still expires %s", c.Check,
+			shortFingerprint(c.PreviousFingerprint), shortFingerprint(c.Fingerprint),
+			c.Expires.Format("2006-01-02"))
+	}
+	return fmt.Sprintf("%s %s, expired %s, now expires %s", c.Check, c.Kind,
+		c.PreviousExpires.Format("2006-01-02"), c.Expires.Format("2006-01-02"))
+}
+
+// shortFingerprint returns enough of fingerprint to tell certificates
+// apart.
+func shortFingerprint(fingerprint string) string {
+	if len(fingerprint) > 16 {
+		return fingerprint[:16]
+	}
+	return fingerprint
+}
+
+// historyChanges returns the changes in expiration in entries, oldest
+// first. Failed checks are skipped, so a failure between two results
+// doesn't hide a change. Fingerprints are only compared when both are
+// known, since older entries may not have them.
+func historyChanges(entries []HistoryEntry) []HistoryChange {
+	var rv []HistoryChange
+	for _, check := range []struct {
+		name  string
+		entry func(HistoryEntry) (time.Time, string, string)
+	}{
+		{CheckCertificate, func(e HistoryEntry) (time.Time, string, string) {
+			return e.CertificateExpires, e.Fingerprint, e.CertificateError
+		}},
+		{CheckDomain, func(e HistoryEntry) (time.Time, string, string) {
+			return e.DomainExpires, "", e.DomainError
+		}},
+	} {
+		var previous time.Time
+		var previousFingerprint string
+		for _, entry := range entries {
+			expires, fingerprint, errStr := check.entry(entry)
+			if errStr != "" || expires.IsZero() {
+				continue
+			}
+			replaced := previousFingerprint != "" && fingerprint != "" && fingerprint != previousFingerprint
+			if !previous.IsZero() && (!expires.Equal(previous) || replaced) {
+				kind := ChangeReplaced
+				if expires.After(previous) {
+					kind = ChangeRenewed
+				} else if expires.Before(previous) {
+					kind = ChangeRegressed
+				}
+				rv = append(rv, HistoryChange{
+					Time:                entry.FirstChecked,
+					Check:               check.name,
+					Kind:                kind,
+					PreviousExpires:     previous,
+					Expires:             expires,
+					PreviousFingerprint: previousFingerprint,
+					Fingerprint:         fingerprint,
+				})
+			}
+			previous, previousFingerprint = expires, fingerprint
+		}
+	}
+	sort.SliceStable(rv, func(i, j int) bool {
+		return rv[i].Time.Before(rv[j].Time)
+	})
+	return rv
+}
+
+// applyChanges attaches the changes recorded in the history of each of
+// expirations.
+func applyChanges(expirations []Expiration) {
+	for i := range expirations {
+		expirations[i].Changes = historyChanges(checkHistory.Entries(expirations[i].Name))
+	}
+}
+
+const historyPrefix = "/history/"
+
+// serveHistory writes the recorded results of checking the host name, and
+// the changes among them, as JSON. It doesn't check the
+// host.
+func (s *Server) serveHistory(w http.ResponseWriter, r *http.Request, name string) {
+	if name == "" || strings.Contains(name, ",") {
+		http.NotFound(w, r)
+		return
+	}
+	entries := checkHistory.Entries(name)
+	w.Header().Add("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Host    string          `json:"host"`
+		Entries []HistoryEntry  `json:"entries"`
+		Changes []HistoryChange `json:"changes"`
+	}{
+		Host:    name,
+		Entries: entries,
+		Changes: historyChanges(entries),
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const sqliteHistorySchema = `
+CREATE TABLE IF NOT EXISTS checks (
+	host TEXT NOT NULL,
+	checked INTEGER NOT NULL,
+	cert_expires INTEGER NOT NULL,
+	cert_error TEXT NOT NULL,
+	domain_expires INTEGER NOT NULL,
+	domain_error TEXT NOT NULL,
+	fingerprint TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS checks_host ON checks (host, checked);
+`
+
+// sqliteHistory records every check in a SQLite database. Times are
+// stored as seconds since the epoch, or 0 if there isn't one.
+type sqliteHistory struct {
+	db *sql.DB
+}
+
+func openSQLiteHistory(path string) (*sqliteHistory, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteHistorySchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// databases created before fingerprints were recorded don't have the
+	// column
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('checks') WHERE name = 'fingerprint'`).Scan(&n); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if n == 0 {
+		if _, err := db.Exec(`ALTER TABLE checks ADD COLUMN fingerprint TEXT NOT NULL DEFAULT ''`); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return &sqliteHistory{db: db}, nil
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func timeOrZero(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0).UTC()
+}
+
+func (h *sqliteHistory) Record(exp Expiration, now time.Time) {
+	entry := newHistoryEntry(exp, now)
+	_, err := h.db.Exec(`INSERT INTO checks
+		(host, checked, cert_expires, cert_error, domain_expires, domain_error, fingerprint)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		exp.Name, unixOrZero(now),
+		unixOrZero(entry.CertificateExpires), entry.CertificateError,
+		unixOrZero(entry.DomainExpires), entry.DomainError, entry.Fingerprint)
+	if err != nil {
+		log.Printf("cannot record history: %s", err)
+	}
+}
+
+// Entries returns the checks of name, with consecutive checks that had the
+// same result combined into one entry.
+func (h *sqliteHistory) Entries(name string) []HistoryEntry {
+	rows, err := h.db.Query(`SELECT checked, cert_expires, cert_error, domain_expires, domain_error, fingerprint
+		FROM checks WHERE host = ? ORDER BY checked, rowid`, name)
+	if err != nil {
+		log.Printf("cannot read history: %s", err)
+		return nil
+	}
+	defer rows.Close()
+
+	var rv []HistoryEntry
+	for rows.Next() {
+		var checked, certExpires, domainExpires int64
+		var entry HistoryEntry
+		if err := rows.Scan(&checked, &certExpires, &entry.CertificateError, &domainExpires, &entry.DomainError, &entry.Fingerprint); err != nil {
+			log.Printf("cannot read history: %s", err)
+			return rv
+		}
+		entry.FirstChecked = timeOrZero(checked)
+		entry.LastChecked = entry.FirstChecked
+		entry.CertificateExpires = timeOrZero(certExpires)
+		entry.DomainExpires = timeOrZero(domainExpires)
+		if n := len(rv); n > 0 && rv[n-1].sameResult(entry) {
+			rv[n-1].LastChecked = entry.LastChecked
+			continue
+		}
+		rv = append(rv, entry)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("cannot read history: %s", err)
+	}
+	return rv
+}
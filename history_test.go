@@ -0,0 +1,167 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+func TestOpenHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if h, err := openHistory(HistoryConfig{}); err != nil {
+		t.Error(err)
+	} else if _, ok := h.(*memoryHistory); !ok {
+		t.Errorf("expected a memory history by default, got %T", h)
+	}
+	if h, err := openHistory(HistoryConfig{Path: filepath.Join(dir, "history.db")}); err != nil {
+		t.Error(err)
+	} else if _, ok := h.(*sqliteHistory); !ok {
+		t.Errorf("expected a sqlite history when there is a path, got %T", h)
+	}
+	for _, config := range []HistoryConfig{{Type: "sqlite"}, {Type: "postgres"}} {
+		if _, err := openHistory(config); err == nil {
+			t.Errorf("%+v: expected error", config)
+		}
+	}
+}
+
+func TestSQLiteHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "history.db")
+
+	h, err := openSQLiteHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	exp := Expiration{Name: "example.com", CertificateExpires: now.AddDate(0, 1, 0), DomainError: fmt.Errorf("timeout")}
+	h.Record(exp, now)
+	h.Record(exp, now.Add(time.Hour))
+	exp.CertificateExpires = now.AddDate(0, 3, 0)
+	h.Record(exp, now.Add(2*time.Hour))
+	h.Record(Expiration{Name: "example.net"}, now)
+
+	// the results survive reopening the database
+	h, err = openSQLiteHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := h.Entries("example.com")
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+	if !entries[0].FirstChecked.Equal(now) || !entries[0].LastChecked.Equal(now.Add(time.Hour)) {
+		t.Errorf("expected repeated results to extend the first entry, got %+v", entries[0])
+	}
+	if !entries[1].CertificateExpires.Equal(now.AddDate(0, 3, 0)) || entries[1].DomainError != "timeout" || !entries[1].DomainExpires.IsZero() {
+		t.Errorf("unexpected entry %+v", entries[1])
+	}
+}
+
+func TestHistoryChanges(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	days := func(n int) time.Time { return now.Add(time.Duration(n) * 24 * time.Hour) }
+	changes := historyChanges([]HistoryEntry{
+		{FirstChecked: days(0), CertificateExpires: days(10), DomainExpires: days(300)},
+		{FirstChecked: days(1), CertificateError: "timeout", DomainExpires: days(300)},
+		{FirstChecked: days(2), CertificateExpires: days(90), DomainExpires: days(300)},
+		{FirstChecked: days(3), CertificateExpires: days(5), DomainExpires: days(665)},
+	})
+
+	var got []string
+	for _, c := range changes {
+		got = append(got, fmt.Sprintf("%s %s %s", c.Time.Format("2006-01-02"), c.Check, c.Kind))
+	}
+	want := "[2020-01-03 certificate renewed 2020-01-04 certificate regressed 2020-01-04 domain renewed]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestHistoryChangesReplaced(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	changes := historyChanges([]HistoryEntry{
+		{FirstChecked: now, CertificateExpires: now.AddDate(0, 3, 0)},
+		{FirstChecked: now.Add(time.Hour), CertificateExpires: now.AddDate(0, 3, 0), Fingerprint: "aaaa"},
+		{FirstChecked: now.Add(2 * time.Hour), CertificateExpires: now.AddDate(0, 3, 0), Fingerprint: "bbbb"},
+	})
+
+	// the first fingerprint isn't known, so only the second is a change
+	if len(changes) != 1 || changes[0].Kind != ChangeReplaced || changes[0].PreviousFingerprint != "aaaa" || changes[0].Fingerprint != "bbbb" {
+		t.Fatalf("expected a replacement, got %+v", changes)
+	}
+	if got, want := changes[0].String(), "certificate aaaa replaced by bbbb, still expires 2020-04-01"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestSQLiteHistoryFingerprint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "history.db")
+
+	// a database from before fingerprints were recorded
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE checks (host TEXT NOT NULL, checked INTEGER NOT NULL,
+		cert_expires INTEGER NOT NULL, cert_error TEXT NOT NULL,
+		domain_expires INTEGER NOT NULL, domain_error TEXT NOT NULL)`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	h, err := openSQLiteHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	exp := Expiration{
+		Name:               "example.com",
+		CertificateExpires: now.AddDate(0, 1, 0),
+		CertificateChain:   []expire.ChainCertificate{{NotAfter: now.AddDate(0, 1, 0), Fingerprint: "aaaa"}},
+	}
+	h.Record(exp, now)
+	exp.CertificateChain[0].Fingerprint = "bbbb"
+	h.Record(exp, now.Add(time.Hour))
+
+	entries := h.Entries("example.com")
+	if len(entries) != 2 || entries[0].Fingerprint != "aaaa" || entries[1].Fingerprint != "bbbb" {
+		t.Errorf("expected the fingerprints to be recorded, got %+v", entries)
+	}
+}
+
+func TestServeHistory(t *testing.T) {
+	defer func(h History) { checkHistory = h }(checkHistory)
+	checkHistory = newMemoryHistory()
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	checkHistory.Record(Expiration{Name: "example.com", CertificateExpires: now.AddDate(0, 1, 0)}, now)
+	checkHistory.Record(Expiration{Name: "example.com", CertificateExpires: now.AddDate(0, 3, 0)}, now.Add(time.Hour))
+
+	w := httptest.NewRecorder()
+	NewServer(&Config{}).ServeHTTP(w, httptest.NewRequest("GET", "/history/example.com", nil))
+	if !strings.Contains(w.Body.String(), `"kind":"renewed"`) || !strings.Contains(w.Body.String(), `"host":"example.com"`) {
+		t.Errorf("unexpected response %s", w.Body.String())
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net"
+	"strings"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+// addWWWHosts returns hostnames plus www.<hostname> for each hostname that
+// is a bare registered domain, e.g. example.com but not mail.example.com.
+// Hosts that are already present are not added again.
+func addWWWHosts(hostnames []string) []string {
+	seen := map[string]bool{}
+	for _, hostname := range hostnames {
+		seen[strings.ToLower(hostname)] = true
+	}
+
+	rv := append([]string{}, hostnames...)
+	for _, hostname := range hostnames {
+		if !isHost(hostname) {
+			continue
+		}
+		host, port := expire.SplitHostPort(hostname)
+		domain, err := expire.EffectiveTLDPlusOne(host)
+		if err != nil || !strings.EqualFold(domain, host) {
+			continue
+		}
+		www := "www." + strings.ToLower(host)
+		if port != expire.DefaultPort {
+			www = net.JoinHostPort(www, port)
+		}
+		if seen[www] {
+			continue
+		}
+		seen[www] = true
+		rv = append(rv, www)
+	}
+	return rv
+}
+
+// checkedHostCount returns how many hosts checking hostnames with opts may
+// check: www adds www.<domain> for each bare domain, and follow may add the
+// host each one redirects to, which isn't known until it is checked.
+func checkedHostCount(hostnames []string, opts checkOptions) int {
+	n := len(hostnames)
+	if opts.WWW {
+		n = len(addWWWHosts(unicodeHostnames(hostnames)))
+	}
+	if opts.Follow {
+		n *= 2
+	}
+	return n
+}
+
+// unicodeHostnames returns hostnames with internationalized hosts in their
+// Unicode form, so that a host given in punycode, e.g.
+// xn--bcher-kva.example, is checked, cached and displayed the same as one
+// given as bücher.example.
+func unicodeHostnames(hostnames []string) []string {
+	rv := make([]string, len(hostnames))
+	for i, hostname := range hostnames {
+		rv[i] = hostname
+		if isHost(hostname) {
+			rv[i] = expire.ToUnicode(hostname)
+		}
+	}
+	return rv
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestAddWWWHosts(t *testing.T) {
+	got := addWWWHosts([]string{"example.com", "mail.example.org", "example.net", "www.example.net", "example.org:8443"})
+	want := "[example.com mail.example.org example.net www.example.net example.org:8443 www.example.com www.example.org:8443]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestUnicodeHostnames(t *testing.T) {
+	got := unicodeHostnames([]string{"example.com", "xn--bcher-kva.example:8443", "bücher.example"})
+	want := []string{"example.com", "bücher.example:8443", "bücher.example"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
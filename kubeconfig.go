@@ -0,0 +1,322 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// kubeconfig is the part of a kubectl configuration file that we need.
+type kubeconfig struct {
+	Clusters []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			ClientCertificate     string `yaml:"client-certificate"`
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKey             string `yaml:"client-key"`
+			ClientKeyData         string `yaml:"client-key-data"`
+			Token                 string `yaml:"token"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+// kubeconfigData returns the contents of the base64 encoded data if it is
+// present, otherwise of path, which is relative to dir.
+func kubeconfigData(data, path, dir string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	if path == "" {
+		return nil, nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	return ioutil.ReadFile(path)
+}
+
+// pemCertificateExpiration returns an Expiration for the first of the PEM
+// encoded certificates in buf to expire.
+func pemCertificateExpiration(name string, buf []byte) Expiration {
+	exp := Expiration{Name: name}
+	for {
+		var block *pem.Block
+		block, buf = pem.Decode(buf)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			exp.CertificateError = err
+			return exp
+		}
+		if exp.CertificateExpires.IsZero() || cert.NotAfter.Before(exp.CertificateExpires) {
+			exp.CertificateExpires = cert.NotAfter
+			exp.CertificateNotBefore = cert.NotBefore
+		}
+	}
+	if exp.CertificateExpires.IsZero() {
+		exp.CertificateError = fmt.Errorf("no certificates found")
+	}
+	return exp
+}
+
+// servingCertificateExpiration connects to address and returns an
+// Expiration for the first of the certificates it presents to expire.
+// Verification is skipped because cluster endpoints are commonly signed by
+// a private CA, and we want to know about broken certificates too.
+func servingCertificateExpiration(name, address, serverName string, timeout time.Duration) Expiration {
+	exp := Expiration{Name: name}
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		exp.CertificateError = err
+		return exp
+	}
+	defer conn.Close()
+	for _, cert := range conn.ConnectionState().PeerCertificates {
+		if exp.CertificateExpires.IsZero() || cert.NotAfter.Before(exp.CertificateExpires) {
+			exp.CertificateExpires = cert.NotAfter
+			exp.CertificateNotBefore = cert.NotBefore
+		}
+	}
+	return exp
+}
+
+// kubeNodes is the part of the Kubernetes NodeList we need to find each
+// kubelet's serving endpoint.
+type kubeNodes struct {
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Status struct {
+			Addresses []struct {
+				Type    string `json:"type"`
+				Address string `json:"address"`
+			} `json:"addresses"`
+			DaemonEndpoints struct {
+				KubeletEndpoint struct {
+					Port int `json:"Port"`
+				} `json:"kubeletEndpoint"`
+			} `json:"daemonEndpoints"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// kubeletExpirations lists the cluster's nodes using client and checks the
+// serving certificate of each node's kubelet.
+func kubeletExpirations(client *http.Client, server, token, cluster string, timeout time.Duration) []Expiration {
+	req, err := http.NewRequest("GET", server+"/api/v1/nodes", nil)
+	if err != nil {
+		return []Expiration{{Name: cluster + "/nodes", CertificateError: err}}
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return []Expiration{{Name: cluster + "/nodes", CertificateError: err}}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return []Expiration{{Name: cluster + "/nodes", CertificateError: fmt.Errorf("listing nodes: %s", resp.Status)}}
+	}
+	nodes := kubeNodes{}
+	if err := json.NewDecoder(resp.Body).Decode(&nodes); err != nil {
+		return []Expiration{{Name: cluster + "/nodes", CertificateError: fmt.Errorf("listing nodes: %s", err)}}
+	}
+
+	var rv []Expiration
+	for _, node := range nodes.Items {
+		name := cluster + "/node/" + node.Metadata.Name + "/kubelet"
+		var address string
+		for _, a := range node.Status.Addresses {
+			if a.Type == "InternalIP" {
+				address = a.Address
+				break
+			}
+		}
+		if address == "" {
+			rv = append(rv, Expiration{Name: name, CertificateError: fmt.Errorf("node has no internal IP address")})
+			continue
+		}
+		port := node.Status.DaemonEndpoints.KubeletEndpoint.Port
+		if port == 0 {
+			port = 10250
+		}
+		rv = append(rv, servingCertificateExpiration(name, net.JoinHostPort(address, strconv.Itoa(port)), "", timeout))
+	}
+	return rv
+}
+
+// kubeconfigExpirations checks the certificates in the kubeconfig at path:
+// the serving certificate of each cluster's API server, the cluster CAs,
+// and the users' client certificates. If kubelets is true, the serving
+// certificates of the kubelets in each cluster are also checked, using the
+// credentials of the first context that refers to the cluster.
+func kubeconfigExpirations(path string, kubelets bool, timeout time.Duration) ([]Expiration, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := kubeconfig{}
+	if err := yaml.Unmarshal(buf, &config); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	dir := filepath.Dir(path)
+
+	var rv []Expiration
+	for _, user := range config.Users {
+		cert, err := kubeconfigData(user.User.ClientCertificateData, user.User.ClientCertificate, dir)
+		if err != nil {
+			rv = append(rv, Expiration{Name: "user/" + user.Name, CertificateError: err})
+			continue
+		}
+		if cert == nil {
+			continue // authenticates some other way
+		}
+		rv = append(rv, pemCertificateExpiration("user/"+user.Name, cert))
+	}
+
+	for _, cluster := range config.Clusters {
+		name := "cluster/" + cluster.Name
+		u, err := url.Parse(cluster.Cluster.Server)
+		if err != nil {
+			rv = append(rv, Expiration{Name: name + "/apiserver", CertificateError: err})
+			continue
+		}
+		address := u.Host
+		if u.Port() == "" {
+			address = net.JoinHostPort(u.Hostname(), "443")
+		}
+		rv = append(rv, servingCertificateExpiration(name+"/apiserver", address, u.Hostname(), timeout))
+
+		ca, err := kubeconfigData(cluster.Cluster.CertificateAuthorityData, cluster.Cluster.CertificateAuthority, dir)
+		if err != nil {
+			rv = append(rv, Expiration{Name: name + "/ca", CertificateError: err})
+		} else if ca != nil {
+			rv = append(rv, pemCertificateExpiration(name+"/ca", ca))
+		}
+
+		if !kubelets {
+			continue
+		}
+		tlsConfig := &tls.Config{InsecureSkipVerify: cluster.Cluster.InsecureSkipTLSVerify}
+		if ca != nil {
+			tlsConfig.RootCAs = x509.NewCertPool()
+			tlsConfig.RootCAs.AppendCertsFromPEM(ca)
+		}
+		var token string
+		for _, context := range config.Contexts {
+			if context.Context.Cluster != cluster.Name {
+				continue
+			}
+			for _, user := range config.Users {
+				if user.Name != context.Context.User {
+					continue
+				}
+				token = user.User.Token
+				cert, err := kubeconfigData(user.User.ClientCertificateData, user.User.ClientCertificate, dir)
+				if err != nil || cert == nil {
+					break
+				}
+				key, err := kubeconfigData(user.User.ClientKeyData, user.User.ClientKey, dir)
+				if err != nil || key == nil {
+					break
+				}
+				if pair, err := tls.X509KeyPair(cert, key); err == nil {
+					tlsConfig.Certificates = []tls.Certificate{pair}
+				}
+			}
+			break
+		}
+		client := &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		}
+		rv = append(rv, kubeletExpirations(client, u.String(), token, name, timeout)...)
+	}
+	return rv, nil
+}
+
+// kubeconfigCommand implements the kubeconfig subcommand and returns the
+// exit code.
+func kubeconfigCommand(args []string) int {
+	flags := flag.NewFlagSet("kubeconfig", flag.ExitOnError)
+	format := flags.String("format", "text", "output format: text, json, csv or ical")
+	kubelets := flags.Bool("kubelets", false, "also check kubelet serving certificates, using the API to list nodes")
+	timeout := flags.Duration("timeout", 5*time.Second, "connection timeout")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s kubeconfig [flags] [KUBECONFIG ...]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		if path := os.Getenv("KUBECONFIG"); path != "" {
+			paths = filepath.SplitList(path)
+		} else if home := os.Getenv("HOME"); home != "" {
+			paths = []string{filepath.Join(home, ".kube", "config")}
+		}
+	}
+
+	rv := 0
+	var expirations []Expiration
+	for _, path := range paths {
+		e, err := kubeconfigExpirations(path, *kubelets, *timeout)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			rv = 1
+			continue
+		}
+		expirations = append(expirations, e...)
+	}
+	if err := writeExpirations(os.Stdout, &Config{}, *format, expirations); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	for _, exp := range expirations {
+		if exp.Failed() {
+			rv = 1
+		}
+	}
+	return rv
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestKubeconfigExpirations(t *testing.T) {
+	notAfter := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+	cert := testCertificate(t, "admin", 1, notAfter)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "dev.crt"), certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(path, []byte(`{
+		"apiVersion": "v1",
+		"kind": "Config",
+		"users": [
+			{"name": "admin", "user": {"client-certificate-data": "`+base64.StdEncoding.EncodeToString(certPEM)+`"}},
+			{"name": "dev", "user": {"client-certificate": "dev.crt"}},
+			{"name": "robot", "user": {"token": "secret"}}
+		]
+	}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	expirations, err := kubeconfigExpirations(path, false, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expirations) != 2 {
+		t.Fatalf("expected 2 expirations, got %d", len(expirations))
+	}
+	for i, name := range []string{"user/admin", "user/dev"} {
+		exp := expirations[i]
+		if exp.Name != name {
+			t.Errorf("expected %s, got %s", name, exp.Name)
+		}
+		if exp.CertificateError != nil {
+			t.Errorf("%s: %s", name, exp.CertificateError)
+		}
+		if !exp.CertificateExpires.Equal(notAfter) {
+			t.Errorf("%s: expected %s, got %s", name, notAfter, exp.CertificateExpires)
+		}
+	}
+}
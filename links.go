@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const linkPrefix = "/l/"
+
+// maxLinkTargetSize is the longest URL that can be shortened
+const maxLinkTargetSize = 64 * 1024
+
+// linksPerMinute limits how many short links each client may create.
+const linksPerMinute = 10
+
+var linkEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// linkCode returns the code for a short link to target. Codes are derived
+// from the target, so shortening the same URL twice gives the same link.
+func linkCode(target string) string {
+	hash := sha256.Sum256([]byte(target))
+	return linkEncoding.EncodeToString(hash[:])[:10]
+}
+
+// linkTarget returns the path and query of the URL in body, which may be
+// absolute or just a path, e.g. /ical/example.com,example.net?ttl=60d
+func linkTarget(body string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(body))
+	if err != nil {
+		return "", err
+	}
+	if u.Path == "" || !strings.HasPrefix(u.Path, "/") || u.Path == "/" {
+		return "", fmt.Errorf("expected a URL like /ical/example.com,example.net")
+	}
+	if strings.HasPrefix(u.Path, linkPrefix) {
+		return "", fmt.Errorf("cannot link to another short link")
+	}
+	target := u.EscapedPath()
+	if u.RawQuery != "" {
+		target += "?" + u.RawQuery
+	}
+	return target, nil
+}
+
+// serveCreateLink stores the URL in the request body and responds with a
+// short link to it.
+func (s *Server) serveCreateLink(w http.ResponseWriter, r *http.Request) {
+	if s.Config.RequireAPIKey && s.apiKey(r) == nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "creating short links requires an API key", http.StatusUnauthorized)
+		return
+	}
+	if !s.linkLimiter.Allow(clientAddress(r), time.Now()) {
+		http.Error(w, "Too many short links, try again in a minute", http.StatusTooManyRequests)
+		return
+	}
+
+	buf, err := ioutil.ReadAll(&io.LimitedReader{R: r.Body, N: maxLinkTargetSize + 1})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(buf) > maxLinkTargetSize {
+		http.Error(w, fmt.Sprintf("URL too long (maximum %d bytes)", maxLinkTargetSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	target, err := linkTarget(string(buf))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	code := linkCode(target)
+	if err := s.store.Put("link/"+code, []byte(target)); err == errStoreFull {
+		http.Error(w, "cannot save link: "+err.Error(), http.StatusInsufficientStorage)
+		return
+	} else if err != nil {
+		http.Error(w, "cannot save link: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintln(w, s.baseURL(r)+linkPrefix+code)
+}
+
+// serveLink serves the URL that the short link code refers to, or
+// redirects to it if the redirect parameter is given. Serving it directly
+// works with calendar programs that don't follow redirects.
+func (s *Server) serveLink(w http.ResponseWriter, r *http.Request, code string) {
+	// calendar programs sometimes insist on a file extension
+	code = strings.TrimSuffix(code, ".ics")
+
+	value, ok, err := s.store.Get("link/" + code)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	s.serveTarget(w, r, string(value), true)
+}
+
+// serveTarget serves the path and query in target as if it had been
+// requested instead of r. If redirect is true and r has the redirect
+// parameter, it redirects to target instead.
+func (s *Server) serveTarget(w http.ResponseWriter, r *http.Request, target string, redirect bool) {
+	u, err := url.Parse(target)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if redirect && r.URL.Query()["redirect"] != nil {
+		http.Redirect(w, r, u.String(), http.StatusFound)
+		return
+	}
+
+	// only for reading, so that a link can't be used to make changes,
+	// e.g. to acknowledgements
+	r2 := new(http.Request)
+	*r2 = *r
+	if r2.Method != "HEAD" {
+		r2.Method = "GET"
+	}
+	r2.Body = http.NoBody
+	r2.ContentLength = 0
+	r2.URL = u
+	// the caller's API key, which the target may need, unless the target
+	// has its own
+	if key := r.URL.Query().Get("key"); key != "" && u.Query().Get("key") == "" {
+		query := u.Query()
+		query.Set("key", key)
+		r2.URL = new(url.URL)
+		*r2.URL = *u
+		r2.URL.RawQuery = query.Encode()
+	}
+	r2.Form = nil
+	s.route(w, r2)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLinkTarget(t *testing.T) {
+	for body, want := range map[string]string{
+		"/ical/example.com,example.net?ttl=60d\n":              "/ical/example.com,example.net?ttl=60d",
+		"https://expire.sh/json/example.com":                   "/json/example.com",
+		"https://expire.sh/text/pgp:alice@example.com?details": "/text/pgp:alice@example.com?details",
+	} {
+		got, err := linkTarget(body)
+		if err != nil {
+			t.Errorf("%q: %s", body, err)
+		} else if got != want {
+			t.Errorf("%q: expected %s, got %s", body, want, got)
+		}
+	}
+	for _, body := range []string{"", "/", "example.com", "/l/abc"} {
+		if _, err := linkTarget(body); err == nil {
+			t.Errorf("%q: expected error", body)
+		}
+	}
+}
+
+func TestLinks(t *testing.T) {
+	s := NewServer(&Config{Watchlists: []Watchlist{{Name: "prod"}}})
+
+	r := httptest.NewRequest("POST", "/l", strings.NewReader("/status/prod.json"))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	link := strings.TrimSpace(w.Body.String())
+	code := link[strings.LastIndex(link, "/")+1:]
+	if code != linkCode("/status/prod.json") {
+		t.Errorf("unexpected link %s", link)
+	}
+
+	r = httptest.NewRequest("GET", "/l/"+code+".ics", nil)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"watchlist":"prod"`) {
+		t.Errorf("expected the status page, got %d: %s", w.Code, w.Body.String())
+	}
+
+	r = httptest.NewRequest("GET", "/l/"+code+"?redirect", nil)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/status/prod.json" {
+		t.Errorf("expected a redirect, got %d %s", w.Code, w.Header().Get("Location"))
+	}
+
+	r = httptest.NewRequest("GET", "/l/nosuchcode", nil)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
+
+func TestLinksAreReadOnly(t *testing.T) {
+	s := NewServer(&Config{})
+	r := httptest.NewRequest("POST", "/l", strings.NewReader(ackPrefix+"example.com"))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// served as a GET, which finds no acknowledgement
+	r = httptest.NewRequest("POST", linkPrefix+linkCode(ackPrefix+"example.com"), strings.NewReader(`{"until":"2030-01-01T00:00:00Z"}`))
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestCreateLinkLimits(t *testing.T) {
+	s := NewServer(&Config{RequireAPIKey: true, APIKeys: []APIKey{{Name: "ci", Key: "sekrit"}}})
+	r := httptest.NewRequest("POST", "/l", strings.NewReader("/ical/example.com"))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without an API key, got %d", w.Code)
+	}
+
+	for i := 0; i <= linksPerMinute; i++ {
+		r = httptest.NewRequest("POST", "/l", strings.NewReader(fmt.Sprintf("/ical/%d.example.com", i)))
+		r.Header.Set("Authorization", "Bearer sekrit")
+		w = httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		want := http.StatusCreated
+		if i == linksPerMinute {
+			want = http.StatusTooManyRequests
+		}
+		if w.Code != want {
+			t.Errorf("%d: expected %d, got %d", i, want, w.Code)
+		}
+	}
+}
+
+func TestLinkPassesAPIKey(t *testing.T) {
+	s := NewServer(&Config{
+		RequireAPIKey: true,
+		APIKeys:       []APIKey{{Name: "ci", Key: "sekrit"}},
+		Watchlists:    []Watchlist{{Name: "prod"}},
+	})
+	r := httptest.NewRequest("POST", "/l", strings.NewReader("/status/prod.json"))
+	r.Header.Set("Authorization", "Bearer sekrit")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	for url, want := range map[string]int{
+		linkPrefix + linkCode("/status/prod.json"):                 http.StatusUnauthorized,
+		linkPrefix + linkCode("/status/prod.json") + "?key=sekrit": http.StatusOK,
+	} {
+		w = httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		if w.Code != want {
+			t.Errorf("%s: expected %d, got %d: %s", url, want, w.Code, w.Body.String())
+		}
+	}
+}
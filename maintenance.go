@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxMaintenanceDuration is the longest a maintenance window may last.
+const maxMaintenanceDuration = 7 * 24 * time.Hour
+
+// MaintenanceWindow is a recurring period, such as the hours in which
+// certificates are routinely renewed, during which notifications for a
+// watchlist are suppressed. Its hosts are still checked, so the results
+// are recorded in their history as usual.
+type MaintenanceWindow struct {
+	// Schedule is when the window starts, as a cron expression with five
+	// fields: minute, hour, day of month, month and day of week, e.g.
+	// "0 2 * * 6" for 02:00 every Saturday.
+	Schedule string `yaml:"schedule"`
+
+	// Duration is how long the window lasts.
+	Duration time.Duration `yaml:"duration"`
+
+	// Timezone is the IANA name of the timezone of Schedule (default:
+	// UTC)
+	Timezone string `yaml:"timezone"`
+}
+
+// Validate returns an error if the window can't be understood.
+func (m MaintenanceWindow) Validate() error {
+	if _, err := parseCronSchedule(m.Schedule); err != nil {
+		return err
+	}
+	if m.Duration <= 0 || m.Duration > maxMaintenanceDuration {
+		return fmt.Errorf("maintenance window %q: duration must be positive and at most %s", m.Schedule, maxMaintenanceDuration)
+	}
+	if _, err := time.LoadLocation(m.Timezone); err != nil {
+		return fmt.Errorf("maintenance window %q: %s", m.Schedule, err)
+	}
+	return nil
+}
+
+// Active returns true if now is within the window. Invalid windows are
+// never active.
+func (m MaintenanceWindow) Active(now time.Time) bool {
+	schedule, err := parseCronSchedule(m.Schedule)
+	if err != nil || m.Duration > maxMaintenanceDuration {
+		return false
+	}
+	loc, err := time.LoadLocation(m.Timezone)
+	if err != nil {
+		return false
+	}
+
+	// look for a start time in the last Duration
+	start := now.In(loc).Truncate(time.Minute)
+	for t := start; now.Sub(t) < m.Duration; t = t.Add(-time.Minute) {
+		if schedule.Matches(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// InMaintenance returns true if now is within any of the watchlist's
+// maintenance windows.
+func (wl Watchlist) InMaintenance(now time.Time) bool {
+	for _, m := range wl.MaintenanceWindows {
+		if m.Active(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// cronSchedule is a parsed cron expression: the allowed values of each
+// field.
+type cronSchedule struct {
+	minute, hour, dom, month, dow map[int]bool
+
+	// as in cron, if both dom and dow are restricted a time matches if
+	// either does
+	domRestricted, dowRestricted bool
+}
+
+// Matches returns true if the schedule includes the minute t is in, in
+// t's location.
+func (c cronSchedule) Matches(t time.Time) bool {
+	if !c.minute[t.Minute()] || !c.hour[t.Hour()] || !c.month[int(t.Month())] {
+		return false
+	}
+	dom, dow := c.dom[t.Day()], c.dow[int(t.Weekday())]
+	if c.domRestricted && c.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// maxCronSearch bounds the search for the next time a schedule matches,
+// since a schedule like "0 0 31 2 *" never does.
+const maxCronSearch = 366 * 24 * time.Hour
+
+// Next returns the first minute after after that the schedule includes,
+// in after's location, or the zero time if there isn't one within a year.
+func (c cronSchedule) Next(after time.Time) time.Time {
+	start := after.Truncate(time.Minute).Add(time.Minute)
+	for t := start; t.Sub(start) < maxCronSearch; t = t.Add(time.Minute) {
+		if c.Matches(t) {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// parseCronSchedule parses a cron expression with five fields. Each field
+// is *, or a comma separated list of values, ranges like 1-5, and steps
+// like */15 or 0-30/10.
+func parseCronSchedule(s string) (cronSchedule, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 5 {
+		return cronSchedule{}, fmt.Errorf("cron schedule %q: expected 5 fields", s)
+	}
+	var rv cronSchedule
+	var err error
+	for i, f := range []struct {
+		values   *map[int]bool
+		min, max int
+	}{
+		{&rv.minute, 0, 59},
+		{&rv.hour, 0, 23},
+		{&rv.dom, 1, 31},
+		{&rv.month, 1, 12},
+		{&rv.dow, 0, 7},
+	} {
+		*f.values, err = parseCronField(fields[i], f.min, f.max)
+		if err != nil {
+			return cronSchedule{}, fmt.Errorf("cron schedule %q: %s", s, err)
+		}
+	}
+	// Sunday is either 0 or 7
+	if rv.dow[7] {
+		rv.dow[0] = true
+	}
+	rv.domRestricted = fields[2] != "*"
+	rv.dowRestricted = fields[4] != "*"
+	return rv, nil
+}
+
+func parseCronField(s string, min, max int) (map[int]bool, error) {
+	rv := map[int]bool{}
+	for _, part := range strings.Split(s, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("invalid value %q", part)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("invalid value %q", part)
+				}
+			} else if step != 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return nil, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			rv[v] = true
+		}
+	}
+	return rv, nil
+}
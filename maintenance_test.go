@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronSchedule(t *testing.T) {
+	for _, s := range []string{"* * * * *", "0 2 * * 6", "*/15 0-6 1,15 * 1-5", "30 3 * * 7"} {
+		if _, err := parseCronSchedule(s); err != nil {
+			t.Errorf("%q: %s", s, err)
+		}
+	}
+	for _, s := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		if _, err := parseCronSchedule(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+func TestCronScheduleMatches(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	for _, tt := range []struct {
+		schedule string
+		time     string
+		want     bool
+	}{
+		{"0 2 * * 6", "2019-06-01 02:00", true}, // a Saturday
+		{"0 2 * * 6", "2019-06-02 02:00", false},
+		{"0 2 * * 6", "2019-06-01 02:01", false},
+		{"*/15 * * * *", "2019-06-01 13:45", true},
+		{"*/15 * * * *", "2019-06-01 13:46", false},
+		{"0 0 * * 7", "2019-06-02 00:00", true}, // Sunday as 7
+		{"0 0 1 * 1", "2019-06-03 00:00", true}, // a Monday, not the 1st
+		{"0 0 1 * 1", "2019-06-01 00:00", true},
+		{"0 0 1 * 1", "2019-06-04 00:00", false},
+	} {
+		schedule, err := parseCronSchedule(tt.schedule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := schedule.Matches(at(tt.time)); got != tt.want {
+			t.Errorf("%q at %s: expected %v, got %v", tt.schedule, tt.time, tt.want, got)
+		}
+	}
+}
+
+func TestMaintenanceWindow(t *testing.T) {
+	wl := Watchlist{MaintenanceWindows: []MaintenanceWindow{
+		{Schedule: "0 22 * * 5", Duration: 4 * time.Hour, Timezone: "America/New_York"},
+	}}
+	for when, want := range map[string]bool{
+		"2019-06-08T02:00:00Z": true, // 22:00 Friday in New York
+		"2019-06-08T05:59:00Z": true,
+		"2019-06-08T06:00:00Z": false,
+		"2019-06-08T01:59:00Z": false,
+		"2019-06-15T03:30:00Z": true,
+	} {
+		now, _ := time.Parse(time.RFC3339, when)
+		if got := wl.InMaintenance(now); got != want {
+			t.Errorf("%s: expected %v, got %v", when, want, got)
+		}
+	}
+
+	for _, m := range []MaintenanceWindow{
+		{Schedule: "0 2 * * *"},
+		{Schedule: "0 2 * * *", Duration: 8 * 24 * time.Hour},
+		{Schedule: "0 2 * * *", Duration: time.Hour, Timezone: "Nowhere/Special"},
+		{Schedule: "0 2 * *", Duration: time.Hour},
+	} {
+		if err := m.Validate(); err == nil {
+			t.Errorf("%+v: expected error", m)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metricsPrefix serves the expirations of hosts in the Prometheus text
+// exposition format, e.g. /metrics/example.com,example.net
+const metricsPrefix = "/metrics/"
+
+// serveMetrics responds with the usage statistics in the Prometheus text
+// exposition format. When the server has API keys, an admin key is
+// required, as for /stats.
+func (s *Server) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	if s.authEnabled() && !s.authorizeAdmin(w, r, "/metrics") {
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeMetrics(w, stats.Snapshot())
+}
+
+// writeMetrics writes st in the Prometheus text exposition format.
+func writeMetrics(w io.Writer, st Stats) {
+	metric(w, "certexp_requests_total", "counter", "Requests for results, by format.")
+	for _, format := range sortedKeys(st.Requests) {
+		sample(w, "certexp_requests_total", "format", format, float64(st.Requests[format]))
+	}
+	metric(w, "certexp_responses_total", "counter", "Responses, by status code.")
+	codes := make([]int, 0, len(st.Responses))
+	for code := range st.Responses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		sample(w, "certexp_responses_total", "code", strconv.Itoa(code), float64(st.Responses[code]))
+	}
+	metric(w, "certexp_checks_in_flight", "gauge", "Checks that have started and not yet finished.")
+	sample(w, "certexp_checks_in_flight", "", "", float64(st.InFlight))
+	metric(w, "certexp_hosts_checked_total", "counter", "Hosts checked, including those answered from the cache.")
+	sample(w, "certexp_hosts_checked_total", "", "", float64(st.HostsChecked))
+	metric(w, "certexp_cache_hits_total", "counter", "Hosts answered from the cache.")
+	sample(w, "certexp_cache_hits_total", "", "", float64(st.CacheHits))
+	metric(w, "certexp_cache_hit_ratio", "gauge", "The fraction of hosts answered from the cache.")
+	sample(w, "certexp_cache_hit_ratio", "", "", st.CacheHitRate)
+
+	writeUpstreamMetrics(w, "certexp_whois", "suffix", "whois lookups", st.Registries)
+	writeUpstreamMetrics(w, "certexp_tls", "network", "TLS checks", st.Networks)
+}
+
+// writeUpstreamMetrics writes the checks, failures and durations in
+// upstreams, labeled with label.
+func writeUpstreamMetrics(w io.Writer, prefix, label, what string, upstreams map[string]UpstreamStats) {
+	keys := make([]string, 0, len(upstreams))
+	for k := range upstreams {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	metric(w, prefix+"_checks_total", "counter", "Number of "+what+", by "+label+".")
+	for _, k := range keys {
+		sample(w, prefix+"_checks_total", label, k, float64(upstreams[k].Checks))
+	}
+	metric(w, prefix+"_failures_total", "counter", "Number of failed "+what+", by "+label+".")
+	for _, k := range keys {
+		sample(w, prefix+"_failures_total", label, k, float64(upstreams[k].Failures))
+	}
+	metric(w, prefix+"_duration_seconds", "summary", "How long "+what+" took, by "+label+".")
+	for _, k := range keys {
+		sample(w, prefix+"_duration_seconds_sum", label, k, float64(upstreams[k].totalMillis)/1000)
+		sample(w, prefix+"_duration_seconds_count", label, k, float64(upstreams[k].Checks))
+	}
+}
+
+// writeExpirationMetrics writes expirations in the Prometheus text
+// exposition format: when each certificate and domain expires, and whether
+// it could be checked. Expirations that couldn't be determined are left
+// out, so that alerts on them don't fire for failed checks.
+func writeExpirationMetrics(w io.Writer, expirations []Expiration) {
+	for _, check := range []struct {
+		name    string
+		expires func(Expiration) time.Time
+		err     func(Expiration) error
+	}{
+		{"certificate", func(e Expiration) time.Time { return e.CertificateExpires }, func(e Expiration) error { return e.CertificateError }},
+		{"domain", func(e Expiration) time.Time { return e.DomainExpires }, func(e Expiration) error { return e.DomainError }},
+	} {
+		name := "certexp_" + check.name + "_expiry_timestamp_seconds"
+		metric(w, name, "gauge", "When the "+check.name+" expires, in seconds since the epoch.")
+		for _, exp := range expirations {
+			if expires := check.expires(exp); check.err(exp) == nil && !expires.IsZero() {
+				sample(w, name, "host", exp.Name, float64(expires.Unix()))
+			}
+		}
+
+		name = "certexp_" + check.name + "_check_success"
+		metric(w, name, "gauge", "Whether the "+check.name+" expiration was determined.")
+		for _, exp := range expirations {
+			// targets that aren't TLS hosts don't have a domain
+			if check.name == "domain" && exp.Domain == "" && exp.DomainError == nil {
+				continue
+			}
+			success := 1.0
+			if check.err(exp) != nil {
+				success = 0
+			}
+			sample(w, name, "host", exp.Name, success)
+		}
+	}
+}
+
+func (s *Server) serveExpirationsMetrics(w http.ResponseWriter, r *http.Request, expirations []Expiration) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeExpirationMetrics(w, expirations)
+}
+
+func metric(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample writes a sample of name with value. If label is not empty, the
+// sample is labeled with it.
+func sample(w io.Writer, name, label, labelValue string, value float64) {
+	if label == "" {
+		fmt.Fprintf(w, "%s %g\n", name, value)
+		return
+	}
+	fmt.Fprintf(w, "%s{%s=\"%s\"} %g\n", name, label, labelValueEscaper.Replace(labelValue), value)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func sortedKeys(m map[string]int) []string {
+	rv := make([]string, 0, len(m))
+	for k := range m {
+		rv = append(rv, k)
+	}
+	sort.Strings(rv)
+	return rv
+}
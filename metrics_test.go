@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	u := newUsageStats()
+	u.CountRequest("text/calendar")
+	u.CountWhois("example.io", 1500*time.Millisecond, true)
+	u.CountTLS("192.0.2.0/24", time.Second, false)
+	u.CountResponse(200)
+	u.CountResponse(429)
+	u.CountHost(true)
+	u.CheckStarted()
+
+	buf := &bytes.Buffer{}
+	writeMetrics(buf, u.Snapshot())
+	for _, want := range []string{
+		`certexp_requests_total{format="ical"} 1`,
+		`certexp_whois_failures_total{suffix="io"} 1`,
+		`certexp_whois_duration_seconds_sum{suffix="io"} 1.5`,
+		`certexp_tls_checks_total{network="192.0.2.0/24"} 1`,
+		`# TYPE certexp_tls_duration_seconds summary`,
+		`certexp_responses_total{code="200"} 1`,
+		`certexp_responses_total{code="429"} 1`,
+		`certexp_checks_in_flight 1`,
+		`certexp_cache_hit_ratio 1`,
+	} {
+		if !strings.Contains(buf.String(), want+"\n") {
+			t.Errorf("expected %s in:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestWriteExpirationMetrics(t *testing.T) {
+	expires := time.Date(2020, 12, 2, 12, 0, 0, 0, time.UTC)
+	buf := &bytes.Buffer{}
+	writeExpirationMetrics(buf, []Expiration{
+		{Name: "example.com", CertificateExpires: expires, Domain: "example.com", DomainExpires: expires},
+		{Name: "broken.example.com", CertificateError: fmt.Errorf("connection refused"), Domain: "example.com", DomainExpires: expires},
+		{Name: "pgp:alice@example.com", CertificateExpires: expires},
+	})
+	for _, want := range []string{
+		`# TYPE certexp_certificate_expiry_timestamp_seconds gauge`,
+		`certexp_certificate_expiry_timestamp_seconds{host="example.com"} 1.6069104e+09`,
+		`certexp_certificate_check_success{host="example.com"} 1`,
+		`certexp_certificate_check_success{host="broken.example.com"} 0`,
+		`certexp_domain_expiry_timestamp_seconds{host="broken.example.com"} 1.6069104e+09`,
+	} {
+		if !strings.Contains(buf.String(), want+"\n") {
+			t.Errorf("expected %s in:\n%s", want, buf.String())
+		}
+	}
+	for _, unwanted := range []string{
+		`certexp_certificate_expiry_timestamp_seconds{host="broken.example.com"}`,
+		`certexp_domain_check_success{host="pgp:alice@example.com"}`,
+	} {
+		if strings.Contains(buf.String(), unwanted) {
+			t.Errorf("unexpected %s in:\n%s", unwanted, buf.String())
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultMonitorInterval is how often monitored hosts are checked,
+	// unless the configuration says otherwise.
+	defaultMonitorInterval = 15 * time.Minute
+
+	// minMonitorInterval is the most often monitored hosts may be checked.
+	minMonitorInterval = time.Minute
+)
+
+// MonitorConfig describes hosts that are checked continuously in the
+// background, so that requests for them are answered immediately with the
+// latest results rather than by checking.
+type MonitorConfig struct {
+	// Hosts are checked, along with the hosts of Watchlists, including
+	// any that are discovered.
+	Hosts      []string `yaml:"hosts"`
+	Watchlists []string `yaml:"watchlists"`
+
+	// Interval is how often they are checked (default: 15m).
+	Interval time.Duration `yaml:"interval"`
+}
+
+// interval returns how often monitored hosts are checked.
+func (c MonitorConfig) interval() time.Duration {
+	if c.Interval == 0 {
+		return defaultMonitorInterval
+	}
+	return c.Interval
+}
+
+// Validate returns an error if the monitored hosts can't be checked.
+func (c MonitorConfig) Validate(config *Config) error {
+	if c.Interval != 0 && c.Interval < minMonitorInterval {
+		return fmt.Errorf("monitor: interval must be at least %s", minMonitorInterval)
+	}
+	for _, name := range c.Watchlists {
+		if config.Watchlist(name) == nil {
+			return fmt.Errorf("monitor: no such watchlist %q", name)
+		}
+	}
+	return nil
+}
+
+// monitorStore holds the latest result of checking each monitored host.
+// Unlike the result cache, results are kept until they are replaced,
+// however long the interval, as long as they aren't too stale.
+type monitorStore struct {
+	mu      sync.Mutex
+	entries map[string]monitorEntry
+}
+
+type monitorEntry struct {
+	exp     Expiration
+	checked time.Time
+	maxAge  time.Duration
+}
+
+func newMonitorStore() *monitorStore {
+	return &monitorStore{entries: map[string]monitorEntry{}}
+}
+
+// monitored is the store of results consulted by getExpirations
+var monitored = newMonitorStore()
+
+// Get returns the latest result for key, if it is younger than the maxAge
+// it was stored with as of now.
+func (m *monitorStore) Get(key string, now time.Time) (Expiration, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[key]
+	if !ok || now.Sub(entry.checked) >= entry.maxAge {
+		return Expiration{}, false
+	}
+	exp := entry.exp
+	if exp.Details != nil {
+		details := *exp.Details
+		exp.Details = &details
+	}
+	return exp, true
+}
+
+// Set stores exp, checked at now, as the latest result for key. It is
+// used for up to maxAge.
+func (m *monitorStore) Set(key string, exp Expiration, now time.Time, maxAge time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if exp.Details != nil {
+		details := *exp.Details
+		exp.Details = &details
+	}
+	m.entries[key] = monitorEntry{exp: exp, checked: now, maxAge: maxAge}
+}
+
+// monitorStatus is what the last round of monitoring found.
+type monitorStatus struct {
+	Interval     string    `json:"interval"`
+	LastStarted  time.Time `json:"lastStarted"`
+	LastFinished time.Time `json:"lastFinished"`
+	Hosts        int       `json:"hosts"`
+	Failed       int       `json:"failed"`
+}
+
+// monitoredHostnames returns the hosts to check in the next round.
+func (s *Server) monitoredHostnames() []string {
+	config := s.Config.Monitor
+	seen := map[string]bool{}
+	var rv []string
+	add := func(names []string) {
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				rv = append(rv, name)
+			}
+		}
+	}
+	add(config.Hosts)
+	for _, name := range config.Watchlists {
+		if wl := s.watchlist(name); wl != nil {
+			add(wl.Hostnames())
+		}
+	}
+	return rv
+}
+
+// monitorOnce checks the monitored hosts as of now and stores the
+// results, and returns how many were checked and how many failed.
+func (s *Server) monitorOnce(ctx context.Context, now time.Time) (int, int) {
+	interval := s.Config.Monitor.interval()
+	opts := s.defaultCheckOptions()
+	opts.Refresh = true
+	hostnames := s.monitoredHostnames()
+	failed := 0
+	for _, exp := range getExpirations(ctx, hostnames, opts) {
+		if exp.Failed() {
+			failed++
+		}
+		// a result is still used if the next round is late, but not if
+		// a whole round is missed
+		monitored.Set(opts.cacheKey(exp.Name), exp, now, 2*interval)
+	}
+	return len(hostnames), failed
+}
+
+// runMonitor checks the monitored hosts on their interval, forever.
+func (s *Server) runMonitor() {
+	interval := s.Config.Monitor.interval()
+	for {
+		start := time.Now()
+		s.mu.Lock()
+		s.monitor.LastStarted = start
+		s.mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		hosts, failed := s.monitorOnce(ctx, start)
+		cancel()
+		log.Printf("monitor: checked %d hosts in %s, %d failed", hosts, time.Since(start), failed)
+
+		s.mu.Lock()
+		s.monitor.LastFinished = time.Now()
+		s.monitor.Hosts = hosts
+		s.monitor.Failed = failed
+		s.mu.Unlock()
+
+		time.Sleep(time.Until(start.Add(interval)))
+	}
+}
+
+// StartMonitoring starts checking the monitored hosts in the background,
+// if there are any.
+func (s *Server) StartMonitoring() {
+	config := s.Config.Monitor
+	if len(config.Hosts) == 0 && len(config.Watchlists) == 0 {
+		return
+	}
+	go s.runMonitor()
+}
+
+// serveMonitor responds with the status of the background checks.
+func (s *Server) serveMonitor(w http.ResponseWriter, r *http.Request) {
+	config := s.Config.Monitor
+	if len(config.Hosts) == 0 && len(config.Watchlists) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	s.mu.Lock()
+	status := s.monitor
+	s.mu.Unlock()
+	status.Interval = config.interval().String()
+	w.Header().Add("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
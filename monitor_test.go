@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMonitorStore(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := newMonitorStore()
+	m.Set("example.com", Expiration{Name: "example.com", Details: &Details{}}, now, time.Hour)
+
+	exp, ok := m.Get("example.com", now.Add(59*time.Minute))
+	if !ok || exp.Name != "example.com" {
+		t.Errorf("expected the result, got %v %+v", ok, exp)
+	}
+	exp.Details.Cached = true
+	if exp, _ := m.Get("example.com", now); exp.Details.Cached {
+		t.Errorf("expected the stored details not to be changed")
+	}
+	if _, ok := m.Get("example.com", now.Add(time.Hour)); ok {
+		t.Errorf("expected a stale result not to be used")
+	}
+	if _, ok := m.Get("example.net", now); ok {
+		t.Errorf("expected no result for a host that isn't monitored")
+	}
+}
+
+func TestMonitorOnce(t *testing.T) {
+	defer func(r *resultCache, m *monitorStore) { results, monitored = r, m }(results, monitored)
+	results, monitored = newResultCache(defaultResultCacheTTL), newMonitorStore()
+
+	now := time.Now().Truncate(time.Second)
+	server := testTLSServer(t, "example.com", now.AddDate(0, 1, 0))
+	defer server.Close()
+	host := server.Listener.Addr().String()
+
+	s := NewServer(&Config{Monitor: MonitorConfig{Hosts: []string{host}, Interval: time.Hour}})
+	if hosts, _ := s.monitorOnce(context.Background(), now); hosts != 1 {
+		t.Fatalf("expected 1 host checked, got %d", hosts)
+	}
+
+	// once the cache has expired, the monitored result is still used
+	results = newResultCache(defaultResultCacheTTL)
+	expirations := getExpirations(context.Background(), []string{host}, s.defaultCheckOptions())
+	if len(expirations) != 1 || !expirations[0].Details.Cached {
+		t.Errorf("expected the monitored result, got %+v", expirations)
+	}
+	if !expirations[0].CertificateExpires.Equal(now.AddDate(0, 1, 0)) {
+		t.Errorf("unexpected expiration %s", expirations[0].CertificateExpires)
+	}
+}
+
+func TestMonitorConfigValidate(t *testing.T) {
+	config := &Config{Watchlists: []Watchlist{{Name: "prod"}}}
+	for _, c := range []struct {
+		monitor MonitorConfig
+		ok      bool
+	}{
+		{MonitorConfig{Watchlists: []string{"prod"}}, true},
+		{MonitorConfig{Watchlists: []string{"staging"}}, false},
+		{MonitorConfig{Hosts: []string{"example.com"}, Interval: time.Second}, false},
+	} {
+		if err := c.monitor.Validate(config); (err == nil) != c.ok {
+			t.Errorf("%+v: unexpected error %v", c.monitor, err)
+		}
+	}
+}
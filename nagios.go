@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+// Exit codes of monitoring plugins, as used by Nagios, Icinga and Sensu
+const (
+	nagiosOK       = 0
+	nagiosWarning  = 1
+	nagiosCritical = 2
+	nagiosUnknown  = 3
+)
+
+var nagiosStates = map[int]string{
+	nagiosOK:       "OK",
+	nagiosWarning:  "WARNING",
+	nagiosCritical: "CRITICAL",
+	nagiosUnknown:  "UNKNOWN",
+}
+
+// nagiosSeverity orders the states from best to worst, which isn't the
+// order of the exit codes: a check that failed is less bad than one that
+// found something about to expire.
+var nagiosSeverity = map[int]int{
+	nagiosOK:       0,
+	nagiosUnknown:  1,
+	nagiosWarning:  2,
+	nagiosCritical: 3,
+}
+
+type nagiosProblem struct {
+	state int
+	text  string
+}
+
+// writeNagios writes expirations to w as a monitoring plugin would, with
+// a status line for the worst of them followed by performance data of the
+// days remaining for each, and returns the plugin's exit code. Checks that
+// expire soon according to t are warnings, and those that expire before
+// critical are critical. Checks that failed are unknown, and acknowledged
+// hosts are OK.
+func writeNagios(w io.Writer, expirations []Expiration, t thresholds, critical time.Time) int {
+	var problems []nagiosProblem
+	var perfdata []string
+	for _, exp := range expirations {
+		for _, check := range []struct {
+			name    string
+			expires time.Time
+			err     error
+			soon    bool
+		}{
+			{"cert", exp.CertificateExpires, exp.CertificateError, t.CertificateSoon(exp)},
+			{"domain", exp.DomainExpires, exp.DomainError, t.DomainSoon(exp)},
+		} {
+			if check.name == "domain" && (exp.Domain == "" && exp.DomainError == nil || expire.IsExpiryWithheld(exp.DomainError)) {
+				continue // no domain, or nothing we can say about it
+			}
+			if check.err != nil {
+				if exp.Acknowledgement == nil {
+					problems = append(problems, nagiosProblem{nagiosUnknown, fmt.Sprintf("%s %s: %s", exp.Name, check.name, check.err)})
+				}
+				continue
+			}
+			if check.expires.IsZero() {
+				continue // doesn't expire, e.g. a PGP key without an expiration
+			}
+			days := daysUntil(t.Now, check.expires)
+			perfdata = append(perfdata, fmt.Sprintf("'%s %s days'=%d;%d;%d", exp.Name, check.name,
+				days, daysUntil(t.Now, t.Soon), daysUntil(t.Now, critical)))
+
+			state := nagiosOK
+			if check.expires.Before(critical) {
+				state = nagiosCritical
+			} else if check.soon {
+				state = nagiosWarning
+			}
+			if state != nagiosOK && exp.Acknowledgement == nil {
+				problems = append(problems, nagiosProblem{state, fmt.Sprintf("%s %s expires in %d days", exp.Name, check.name, days)})
+			}
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return nagiosSeverity[problems[i].state] > nagiosSeverity[problems[j].state]
+	})
+	rv := nagiosOK
+	text := fmt.Sprintf("nothing expires before %s", t.Soon.Format("2006-01-02"))
+	if len(problems) > 0 {
+		rv = problems[0].state
+		texts := make([]string, len(problems))
+		for i, p := range problems {
+			texts[i] = p.text
+		}
+		text = strings.Join(texts, ", ")
+	}
+	fmt.Fprintf(w, "%s - %s", nagiosStates[rv], text)
+	if len(perfdata) > 0 {
+		fmt.Fprintf(w, "|%s", strings.Join(perfdata, " "))
+	}
+	fmt.Fprintln(w)
+	return rv
+}
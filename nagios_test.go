@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+func TestWriteNagios(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	th := newThresholds(now, defaultTTL)
+	critical := now.AddDate(0, 0, 7)
+	ok := Expiration{Name: "example.com", CertificateExpires: now.AddDate(0, 0, 90), Domain: "example.com", DomainError: expire.ExpiryWithheldError{}}
+	soon := Expiration{Name: "example.net", CertificateExpires: now.AddDate(0, 0, 20)}
+	urgent := Expiration{Name: "example.org", CertificateExpires: now.AddDate(0, 0, 3)}
+	failed := Expiration{Name: "example.io", CertificateError: fmt.Errorf("connection refused")}
+	acked := urgent
+	acked.Acknowledgement = &Acknowledgement{}
+
+	for _, tc := range []struct {
+		expirations []Expiration
+		code        int
+		want        string
+	}{
+		{[]Expiration{ok}, nagiosOK, "OK - nothing expires before 2020-02-01|'example.com cert days'=90;30;7\n"},
+		{[]Expiration{soon}, nagiosWarning, "WARNING - example.net cert expires in 20 days|'example.net cert days'=20;30;7\n"},
+		{[]Expiration{soon, failed, urgent}, nagiosCritical,
+			"CRITICAL - example.org cert expires in 3 days, example.net cert expires in 20 days, example.io cert: connection refused" +
+				"|'example.net cert days'=20;30;7 'example.org cert days'=3;30;7\n"},
+		{[]Expiration{failed}, nagiosUnknown, "UNKNOWN - example.io cert: connection refused\n"},
+		{[]Expiration{acked}, nagiosOK, "OK - nothing expires before 2020-02-01|'example.org cert days'=3;30;7\n"},
+	} {
+		buf := &bytes.Buffer{}
+		if code := writeNagios(buf, tc.expirations, th, critical); code != tc.code || buf.String() != tc.want {
+			t.Errorf("expected %d %q, got %d %q", tc.code, tc.want, code, buf.String())
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultPagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+var notifyClient = &http.Client{Timeout: 30 * time.Second}
+
+// Notifier sends alerts somewhere a person will see them.
+type Notifier interface {
+	Notify(ctx context.Context, alerts []Alert) error
+}
+
+// NotifierConfig describes a destination for alerts, which escalation
+// policies refer to by name.
+type NotifierConfig struct {
+	Name string `yaml:"name"`
+
+	// Type is slack, to post to a Slack incoming webhook, pagerduty, to
+	// trigger PagerDuty incidents with the Events API, webhook, to post
+	// each alert as JSON to a URL of your own, or email.
+	Type string `yaml:"type"`
+
+	// URL is the Slack webhook URL, the PagerDuty Events API URL
+	// (default: https://events.pagerduty.com/v2/enqueue), or the URL to
+	// post webhooks to.
+	URL string `yaml:"url"`
+
+	// RoutingKey is the PagerDuty integration key.
+	RoutingKey string `yaml:"routingKey"`
+
+	// SMTP is the mail server that emails are sent through, and
+	// Recipients are who they are sent to.
+	SMTP       SMTPConfig       `yaml:"smtp"`
+	Recipients []EmailRecipient `yaml:"recipients"`
+
+	// Subject and Template are text/template templates for the subject
+	// and body of emails, which are executed with the alerts for the
+	// recipient as .Alerts and the recipient as .Recipient.
+	Subject  string `yaml:"subject"`
+	Template string `yaml:"template"`
+}
+
+// openNotifiers returns the notifiers described by configs, by name.
+func openNotifiers(configs []NotifierConfig) (map[string]Notifier, error) {
+	rv := map[string]Notifier{}
+	for _, config := range configs {
+		if config.Name == "" {
+			return nil, fmt.Errorf("notifier has no name")
+		}
+		if _, ok := rv[config.Name]; ok {
+			return nil, fmt.Errorf("notifier %s: duplicate name", config.Name)
+		}
+		n, err := newNotifier(config)
+		if err != nil {
+			return nil, fmt.Errorf("notifier %s: %s", config.Name, err)
+		}
+		rv[config.Name] = n
+	}
+	return rv, nil
+}
+
+func newNotifier(config NotifierConfig) (Notifier, error) {
+	switch config.Type {
+	case "slack":
+		if config.URL == "" {
+			return nil, fmt.Errorf("a slack notifier requires a url")
+		}
+		return slackNotifier{URL: config.URL}, nil
+	case "pagerduty":
+		if config.RoutingKey == "" {
+			return nil, fmt.Errorf("a pagerduty notifier requires a routingKey")
+		}
+		n := pagerDutyNotifier{URL: config.URL, RoutingKey: config.RoutingKey}
+		if n.URL == "" {
+			n.URL = defaultPagerDutyEventsURL
+		}
+		return n, nil
+	case "webhook":
+		if config.URL == "" {
+			return nil, fmt.Errorf("a webhook notifier requires a url")
+		}
+		return webhookNotifier{URL: config.URL}, nil
+	case "email":
+		return newEmailNotifier(config)
+	default:
+		return nil, fmt.Errorf("unknown notifier type %q, expected slack, pagerduty, webhook or email", config.Type)
+	}
+}
+
+// postJSON posts v to u as JSON and returns an error unless the response
+// is successful.
+func postJSON(ctx context.Context, u string, v interface{}) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", u, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := notifyClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// slackNotifier posts alerts to a Slack incoming webhook, as a single
+// message.
+type slackNotifier struct {
+	URL string
+}
+
+func (n slackNotifier) Notify(ctx context.Context, alerts []Alert) error {
+	lines := make([]string, len(alerts))
+	for i, alert := range alerts {
+		lines[i] = alert.String()
+	}
+	return postJSON(ctx, n.URL, struct {
+		Text string `json:"text"`
+	}{
+		Text: strings.Join(lines, "\n"),
+	})
+}
+
+// pagerDutyNotifier triggers a PagerDuty incident for each alert, and
+// resolves it when the alert is resolved. Alerts for the same check of the
+// same host are grouped into one incident.
+type pagerDutyNotifier struct {
+	URL        string
+	RoutingKey string
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string           `json:"routing_key"`
+	EventAction string           `json:"event_action"`
+	DedupKey    string           `json:"dedup_key"`
+	Payload     pagerDutyPayload `json:"payload"`
+}
+
+type pagerDutyPayload struct {
+	Summary  string `json:"summary"`
+	Source   string `json:"source"`
+	Severity string `json:"severity"`
+}
+
+func (n pagerDutyNotifier) Notify(ctx context.Context, alerts []Alert) error {
+	for _, alert := range alerts {
+		if alert.Change != nil {
+			continue // a change isn't an incident
+		}
+		severity, action := "critical", "trigger"
+		if alert.Error != "" {
+			severity = "error"
+		}
+		if alert.Resolved {
+			action = "resolve"
+		}
+		err := postJSON(ctx, n.URL, pagerDutyEvent{
+			RoutingKey:  n.RoutingKey,
+			EventAction: action,
+			DedupKey:    alert.Key(),
+			Payload: pagerDutyPayload{
+				Summary:  alert.String(),
+				Source:   alert.Host,
+				Severity: severity,
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// webhookNotifier posts each alert to a URL as JSON.
+type webhookNotifier struct {
+	URL string
+}
+
+// webhookPayload is the body of a webhook. Type is the check, certificate
+// or domain. Expires and DaysRemaining are omitted if the check failed.
+// Change is set if the alert is for a change in the expiration.
+type webhookPayload struct {
+	Watchlist     string         `json:"watchlist"`
+	Host          string         `json:"host"`
+	Type          string         `json:"type"`
+	Expires       *time.Time     `json:"expires,omitempty"`
+	DaysRemaining *int           `json:"daysRemaining,omitempty"`
+	Error         string         `json:"error,omitempty"`
+	Resolved      bool           `json:"resolved,omitempty"`
+	Change        *HistoryChange `json:"change,omitempty"`
+	Message       string         `json:"message"`
+}
+
+func (n webhookNotifier) Notify(ctx context.Context, alerts []Alert) error {
+	for _, alert := range alerts {
+		payload := webhookPayload{
+			Watchlist: alert.Watchlist,
+			Host:      alert.Host,
+			Type:      alert.Check,
+			Error:     alert.Error,
+			Resolved:  alert.Resolved,
+			Change:    alert.Change,
+			Message:   alert.String(),
+		}
+		if !alert.Expires.IsZero() {
+			expires, days := alert.Expires, alert.DaysRemaining
+			payload.Expires, payload.DaysRemaining = &expires, &days
+		}
+		if err := postJSON(ctx, n.URL, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
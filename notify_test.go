@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOpenNotifiers(t *testing.T) {
+	notifiers, err := openNotifiers([]NotifierConfig{
+		{Name: "ops", Type: "slack", URL: "https://hooks.slack.com/services/x"},
+		{Name: "oncall", Type: "pagerduty", RoutingKey: "key"},
+		{Name: "hook", Type: "webhook", URL: "https://example.com/hook"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pd := notifiers["oncall"].(pagerDutyNotifier); pd.URL != defaultPagerDutyEventsURL {
+		t.Errorf("expected the default events URL, got %s", pd.URL)
+	}
+
+	for _, configs := range [][]NotifierConfig{
+		{{Type: "slack", URL: "https://hooks.slack.com/services/x"}},
+		{{Name: "ops", Type: "slack"}},
+		{{Name: "oncall", Type: "pagerduty"}},
+		{{Name: "hook", Type: "webhook"}},
+		{{Name: "sms", Type: "carrier-pigeon"}},
+		{{Name: "ops", Type: "slack", URL: "https://a"}, {Name: "ops", Type: "slack", URL: "https://b"}},
+	} {
+		if _, err := openNotifiers(configs); err == nil {
+			t.Errorf("%+v: expected error", configs)
+		}
+	}
+}
+
+func TestNotifiers(t *testing.T) {
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		if body["fail"] != nil {
+			http.Error(w, "nope", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	alerts := []Alert{
+		{Watchlist: "prod", Host: "a.example.com", Check: CheckCertificate, DaysRemaining: 3,
+			Expires: time.Date(2019, 1, 4, 0, 0, 0, 0, time.UTC)},
+		{Watchlist: "prod", Host: "b.example.com", Check: CheckDomain, Error: "timeout"},
+	}
+
+	if err := (slackNotifier{URL: server.URL}).Notify(context.Background(), alerts); err != nil {
+		t.Fatal(err)
+	}
+	want := "a.example.com: certificate expires in 3 days, on 2019-01-04\nb.example.com: cannot check domain: timeout"
+	if len(bodies) != 1 || bodies[0]["text"] != want {
+		t.Errorf("unexpected slack messages %v", bodies)
+	}
+
+	bodies = nil
+	if err := (pagerDutyNotifier{URL: server.URL, RoutingKey: "key"}).Notify(context.Background(), alerts); err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 2 {
+		t.Fatalf("expected 2 events, got %v", bodies)
+	}
+	if bodies[0]["dedup_key"] != "prod/a.example.com/certificate" || bodies[0]["routing_key"] != "key" {
+		t.Errorf("unexpected event %v", bodies[0])
+	}
+	if payload := bodies[1]["payload"].(map[string]interface{}); payload["severity"] != "error" || payload["source"] != "b.example.com" {
+		t.Errorf("unexpected payload %v", payload)
+	}
+
+	bodies = nil
+	if err := (webhookNotifier{URL: server.URL}).Notify(context.Background(), alerts); err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 2 {
+		t.Fatalf("expected 2 webhooks, got %v", bodies)
+	}
+	if b := bodies[0]; b["host"] != "a.example.com" || b["type"] != "certificate" || b["daysRemaining"] != 3.0 || b["expires"] != "2019-01-04T00:00:00Z" {
+		t.Errorf("unexpected webhook %v", b)
+	}
+	if b := bodies[1]; b["type"] != "domain" || b["error"] != "timeout" || b["expires"] != nil {
+		t.Errorf("unexpected webhook %v", b)
+	}
+
+	if err := postJSON(context.Background(), server.URL, map[string]bool{"fail": true}); err == nil {
+		t.Error("expected an error for a 400 response")
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// schemaVersion is the version of the JSON responses, which is included in
+// each and in the OpenAPI description. It changes when fields are removed
+// or change meaning, not when they are added.
+const schemaVersion = "2"
+
+const openAPIPath = "/openapi.json"
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// jsonSchema returns the schema of values of t as encoding/json encodes
+// them. Named structs are added to schemas and referred to, so that each
+// is described once.
+func jsonSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == errorType:
+		return map[string]interface{}{"type": "object", "nullable": true, "description": "present if the check failed"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := jsonSchema(t.Elem(), schemas)
+		if _, ok := schema["$ref"]; ok {
+			// siblings of $ref are ignored
+			return map[string]interface{}{"allOf": []interface{}{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": jsonSchema(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchema(t.Elem(), schemas)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return structSchema(t, schemas)
+		}
+		if _, ok := schemas[t.Name()]; !ok {
+			schemas[t.Name()] = nil // in case the type refers to itself
+			schemas[t.Name()] = structSchema(t, schemas)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return map[string]interface{}{}
+}
+
+// structSchema returns the schema of a struct, with the fields of embedded
+// structs promoted as encoding/json does.
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var addFields func(t reflect.Type)
+	addFields = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name := strings.Split(tag, ",")[0]
+			if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+				addFields(f.Type)
+				continue
+			}
+			if f.PkgPath != "" {
+				continue // unexported
+			}
+			if name == "" {
+				name = f.Name
+			}
+			properties[name] = jsonSchema(f.Type, schemas)
+		}
+	}
+	addFields(t)
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+// openAPIParameter returns a query parameter.
+func openAPIParameter(name, typ, description string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        name,
+		"in":          "query",
+		"description": description,
+		"schema":      map[string]interface{}{"type": typ},
+	}
+}
+
+// openAPIFlag returns a query parameter that takes no value.
+func openAPIFlag(name, description string) map[string]interface{} {
+	p := openAPIParameter(name, "boolean", description)
+	p["allowEmptyValue"] = true
+	return p
+}
+
+// checkParameters are the query parameters of requests that check hosts.
+func checkParameters() []interface{} {
+	fieldNames := make([]interface{}, len(allFields))
+	for i, f := range allFields {
+		fieldNames[i] = f.Name
+	}
+	return []interface{}{
+		openAPIParameter("ttl", "string", "how soon an expiration is considered soon, e.g. 30d or 1y6mo (default: 30d)"),
+		openAPIParameter("lifetime", "number", "the percentage of a certificate's validity period after which it is expiring soon"),
+		openAPIFlag("quiet", "only include hosts that are expiring soon or couldn't be checked"),
+		map[string]interface{}{
+			"name":        "fields",
+			"in":          "query",
+			"description": "the fields to include in JSON and CSV responses",
+			"style":       "form",
+			"explode":     false,
+			"schema": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"type": "string", "enum": fieldNames},
+			},
+		},
+		openAPIFlag("summary", "replace the results with counts by status"),
+		openAPIFlag("stream", "write text results for several hosts as each is checked, always with 200 OK"),
+		openAPIFlag("details", "include details of how each check was made"),
+		openAPIFlag("raw", "with details, include the raw whois record"),
+		openAPIFlag("changes", "include the renewals and replacements recorded in the history of each host"),
+		openAPIFlag("follow", "also check the hosts that each host redirects to"),
+		openAPIFlag("www", "also check the www host of each domain"),
+		openAPIFlag("caa", "check that CAA records authorize the issuer"),
+		openAPIFlag("allips", "check every address of each host"),
+		openAPIFlag("nosni", "also check the certificate served without SNI"),
+		openAPIFlag("tlspolicy", "warn about legacy TLS versions and weak cipher suites"),
+		map[string]interface{}{
+			"name":        "status",
+			"in":          "query",
+			"description": "strict (the default) for 502 and 417 responses, or always200 to leave the status to the body",
+			"schema":      map[string]interface{}{"type": "string", "enum": []interface{}{StatusCodesStrict, StatusCodesAlways200}},
+		},
+		map[string]interface{}{
+			"name":        "sort",
+			"in":          "query",
+			"description": "the order of the results (default: the order the hosts were given)",
+			"schema":      map[string]interface{}{"type": "string", "enum": []interface{}{SortExpiry, SortName, SortStatus}},
+		},
+		openAPIParameter("proto", "string", "tls (the default) or smtp, for STARTTLS"),
+		openAPIParameter("truststores", "string", "comma separated trust stores to verify the chain against"),
+		openAPIParameter("concurrency", "integer", "how many checks to run at once"),
+		openAPIParameter("timeout", "string", "how long each check may take, e.g. 10s"),
+		openAPIParameter("key", "string", "an API key, for clients that can't send an Authorization header"),
+	}
+}
+
+// openAPIDocument returns the OpenAPI 3 description of the API of a server
+// at baseURL.
+func openAPIDocument(baseURL string) map[string]interface{} {
+	schemas := map[string]interface{}{}
+	schemas["Expiration"] = structSchema(reflect.TypeOf(jsonExpiration{}), schemas)
+	expiration := map[string]interface{}{"$ref": "#/components/schemas/Expiration"}
+
+	rows := make(map[string]interface{}, len(allFields))
+	for _, f := range allFields {
+		rows[f.Name] = map[string]interface{}{}
+	}
+	schemas["SelectedFields"] = map[string]interface{}{
+		"type":        "object",
+		"description": "the fields selected with the fields parameter",
+		"properties":  rows,
+	}
+	summary := jsonSchema(reflect.TypeOf(Summary{}), schemas)
+	schemas["ExpirationsResponse"] = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"schemaVersion": map[string]interface{}{"type": "string"},
+			"summary":       summary,
+			"expirations": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{"oneOf": []interface{}{
+					expiration,
+					map[string]interface{}{"$ref": "#/components/schemas/SelectedFields"},
+				}},
+			},
+		},
+	}
+	schemas["SummaryResponse"] = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"schemaVersion": map[string]interface{}{"type": "string"},
+			"summary":       summary,
+		},
+	}
+	history := jsonSchema(reflect.TypeOf(HistoryEntry{}), schemas)
+	change := jsonSchema(reflect.TypeOf(HistoryChange{}), schemas)
+	status := jsonSchema(reflect.TypeOf(statusPage{}), schemas)
+
+	results := map[string]interface{}{
+		"application/json": map[string]interface{}{
+			"schema": map[string]interface{}{"oneOf": []interface{}{
+				map[string]interface{}{"$ref": "#/components/schemas/ExpirationsResponse"},
+				map[string]interface{}{"$ref": "#/components/schemas/SummaryResponse"},
+			}},
+		},
+		"text/plain":        map[string]interface{}{},
+		"text/csv":          map[string]interface{}{},
+		"text/calendar":     map[string]interface{}{},
+		"text/html":         map[string]interface{}{},
+		"text/event-stream": map[string]interface{}{},
+	}
+	check := map[string]interface{}{
+		"get": map[string]interface{}{
+			"summary": "Check the certificate and domain expirations of hosts",
+			"parameters": append([]interface{}{map[string]interface{}{
+				"name":        "hosts",
+				"in":          "path",
+				"required":    true,
+				"description": "comma separated host names, with a port if it isn't 443",
+				"schema":      map[string]interface{}{"type": "string"},
+			}}, checkParameters()...),
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{"description": "every host is ok", "content": results},
+				"304": map[string]interface{}{"description": "every host is ok, and the results match If-None-Match or haven't changed since If-Modified-Since"},
+				"417": map[string]interface{}{"description": "a host is expiring soon", "content": results},
+				"502": map[string]interface{}{"description": "a host couldn't be checked", "content": results},
+				"400": map[string]interface{}{"description": "a parameter couldn't be parsed"},
+				"401": map[string]interface{}{"description": "the server requires an API key"},
+				"413": map[string]interface{}{"description": "too many hosts for one request"},
+				"429": map[string]interface{}{"description": "the API key's quota is exceeded"},
+			},
+		},
+	}
+	headers := map[string]interface{}{
+		"X-Expires-Soon": map[string]interface{}{"description": "how many hosts are expiring soon", "schema": map[string]interface{}{"type": "integer"}},
+		"X-Check-Errors": map[string]interface{}{"description": "how many hosts couldn't be checked", "schema": map[string]interface{}{"type": "integer"}},
+	}
+	check["head"] = map[string]interface{}{
+		"summary":    "Check hosts, and respond with only the status and counts",
+		"parameters": check["get"].(map[string]interface{})["parameters"],
+		"responses": map[string]interface{}{
+			"200": map[string]interface{}{"description": "every host is ok", "headers": headers},
+			"417": map[string]interface{}{"description": "a host is expiring soon", "headers": headers},
+			"502": map[string]interface{}{"description": "a host couldn't be checked", "headers": headers},
+		},
+	}
+	jsonResponse := func(description string, schema interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": description,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schema},
+				},
+			},
+		}
+	}
+	pathParameter := func(name, description string) []interface{} {
+		return []interface{}{map[string]interface{}{
+			"name":        name,
+			"in":          "path",
+			"required":    true,
+			"description": description,
+			"schema":      map[string]interface{}{"type": "string"},
+		}}
+	}
+
+	doc := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "expire.sh",
+			"description": "Checks domain and certificate expirations.",
+			"version":     schemaVersion,
+		},
+		"paths": map[string]interface{}{
+			"/{hosts}":      check,
+			"/json/{hosts}": check,
+			"/history/{host}": map[string]interface{}{
+				"get": map[string]interface{}{
+					"summary":    "The recorded results of checking a host",
+					"parameters": pathParameter("host", "the host name"),
+					"responses": jsonResponse("the history of the host", map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"host":    map[string]interface{}{"type": "string"},
+							"entries": map[string]interface{}{"type": "array", "items": history},
+							"changes": map[string]interface{}{"type": "array", "items": change},
+						},
+					}),
+				},
+			},
+			"/status/{watchlist}.json": map[string]interface{}{
+				"get": map[string]interface{}{
+					"summary":    "The status of the hosts of a watchlist",
+					"parameters": append(pathParameter("watchlist", "the name of the watchlist"), checkParameters()[:2]...),
+					"responses":  jsonResponse("the status page", status),
+				},
+			},
+		},
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"apiKey": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+	if baseURL != "" {
+		doc["servers"] = []interface{}{map[string]interface{}{"url": baseURL}}
+	}
+	return doc
+}
+
+func (s *Server) serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(openAPIDocument(s.baseURL(r)))
+}
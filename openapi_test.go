@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAPIDocument(t *testing.T) {
+	w := httptest.NewRecorder()
+	NewServer(&Config{BaseURL: "https://expire.example"}).ServeHTTP(w, httptest.NewRequest("GET", "/openapi.json", nil))
+
+	var doc struct {
+		OpenAPI string
+		Info    struct{ Version string }
+		Servers []struct{ URL string }
+		Paths   map[string]struct {
+			Get struct {
+				Parameters []struct{ Name string }
+			}
+		}
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]json.RawMessage
+			}
+		}
+	}
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI == "" || doc.Info.Version != schemaVersion {
+		t.Errorf("unexpected header %s %s", doc.OpenAPI, doc.Info.Version)
+	}
+	if len(doc.Servers) != 1 || doc.Servers[0].URL != "https://expire.example" {
+		t.Errorf("unexpected servers %+v", doc.Servers)
+	}
+
+	params := map[string]bool{}
+	for _, p := range doc.Paths["/json/{hosts}"].Get.Parameters {
+		params[p.Name] = true
+	}
+	for _, name := range []string{"hosts", "ttl", "quiet", "fields"} {
+		if !params[name] {
+			t.Errorf("expected the %s parameter, got %v", name, params)
+		}
+	}
+
+	// the schema follows the JSON encoding, including embedded fields
+	expiration := doc.Components.Schemas["Expiration"].Properties
+	for _, name := range []string{"Name", "CertificateExpires", "CertificateDaysRemaining", "CertificateChain"} {
+		if _, ok := expiration[name]; !ok {
+			t.Errorf("expected %s in the Expiration schema", name)
+		}
+	}
+	var expires struct{ Type, Format string }
+	json.Unmarshal(expiration["CertificateExpires"], &expires)
+	if expires.Type != "string" || expires.Format != "date-time" {
+		t.Errorf("unexpected schema for CertificateExpires: %s", expiration["CertificateExpires"])
+	}
+	if _, ok := doc.Components.Schemas["ChainCertificate"]; !ok {
+		t.Errorf("expected a schema for ChainCertificate")
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+// checkOptions are per request options that control how the checks are
+// performed.
+type checkOptions struct {
+	// Options control the certificate checks: the trust stores, the
+	// maximum certificate lifetime, the concurrency and the dialer.
+	expire.Options
+
+	// Follow adds the targets of any HTTP redirects to the hosts checked.
+	Follow bool
+
+	// WWW adds www.example.com for each bare domain like example.com
+	WWW bool
+
+	// CAA looks up the CAA records of each host, and warns if they don't
+	// authorize the CA that issued its certificate.
+	CAA bool
+
+	// AllIPs checks the certificate served at each address a host
+	// resolves to, rather than at only the one connected to.
+	AllIPs bool
+
+	// NoSNI also checks the certificate each host presents to clients that
+	// don't send SNI.
+	NoSNI bool
+
+	// TLSPolicy warns about hosts that accept legacy TLS versions or weak
+	// cipher suites.
+	TLSPolicy bool
+
+	// PGPKeyserver is the base URL of the keyserver used for pgp: targets
+	PGPKeyserver string
+
+	// Debug records a trace of each step of the checks in the details.
+	Debug bool
+
+	// Refresh checks every host, rather than answering from the cache or
+	// the latest monitoring results.
+	Refresh bool
+}
+
+// defaultCheckOptions returns the check options used when a request
+// doesn't specify otherwise, or for checks that aren't made on behalf of a
+// request.
+func (s *Server) defaultCheckOptions() checkOptions {
+	opts := checkOptions{
+		Options: expire.Options{
+			MaxCertificateLifetime: expire.DefaultMaxCertificateLifetime,
+			Concurrency:            defaultConcurrency,
+			Dialer:                 s.Dialer,
+			Timeout:                expire.DefaultTimeout,
+		},
+		PGPKeyserver: s.Config.PGPKeyserver,
+	}
+	if s.Config.MaxCertificateLifetime != 0 {
+		opts.MaxCertificateLifetime = s.Config.MaxCertificateLifetime
+	}
+	if s.Config.Concurrency != 0 {
+		opts.Concurrency = s.Config.Concurrency
+	}
+	if s.Config.CheckTimeout != 0 {
+		opts.Timeout = s.Config.CheckTimeout
+	}
+	return opts
+}
+
+// statusError is an error that calls for a response other than 400 Bad
+// Request.
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e statusError) Error() string {
+	return e.err.Error()
+}
+
+// errorStatus returns the status code to respond with when
+// parseCheckOptions returns err.
+func errorStatus(err error) int {
+	if err, ok := err.(statusError); ok {
+		return err.status
+	}
+	return http.StatusBadRequest
+}
+
+// parseCheckOptions returns the check options specified by the query
+// parameters of r, and by the server's configuration. Using the debug
+// parameter requires authorizeDebug's permission.
+func (s *Server) parseCheckOptions(r *http.Request) (checkOptions, error) {
+	opts := s.defaultCheckOptions()
+	if s := r.FormValue("truststores"); s != "" {
+		var err error
+		opts.TrustStores, err = expire.ParseTrustStores(s)
+		if err != nil {
+			return opts, err
+		}
+	}
+	switch proto := r.FormValue("proto"); proto {
+	case "", expire.ProtocolTLS, expire.ProtocolSMTP:
+		opts.Protocol = proto
+	default:
+		return opts, fmt.Errorf("Cannot parse proto parameter: expected tls or smtp")
+	}
+	opts.Follow = r.URL.Query()["follow"] != nil
+	opts.WWW = r.URL.Query()["www"] != nil
+	opts.CAA = r.URL.Query()["caa"] != nil
+	opts.AllIPs = r.URL.Query()["allips"] != nil
+	opts.NoSNI = r.URL.Query()["nosni"] != nil
+	opts.TLSPolicy = r.URL.Query()["tlspolicy"] != nil
+	if r.URL.Query()["debug"] != nil {
+		if status, err := s.authorizeDebug(r); err != nil {
+			return opts, statusError{status: status, err: err}
+		}
+		opts.Debug = true
+	}
+
+	maxConcurrency := defaultMaxConcurrency
+	if s.Config.MaxConcurrency != 0 {
+		maxConcurrency = s.Config.MaxConcurrency
+	}
+	if concurrencyStr := r.FormValue("concurrency"); concurrencyStr != "" {
+		concurrency, err := strconv.Atoi(concurrencyStr)
+		if err != nil || concurrency < 1 || concurrency > maxConcurrency {
+			return opts, fmt.Errorf("Cannot parse concurrency parameter: expected a number between 1 and %d", maxConcurrency)
+		}
+		opts.Concurrency = concurrency
+	}
+	if opts.Concurrency > maxConcurrency {
+		opts.Concurrency = maxConcurrency
+	}
+
+	maxTimeout := defaultMaxCheckTimeout
+	if s.Config.MaxCheckTimeout != 0 {
+		maxTimeout = s.Config.MaxCheckTimeout
+	}
+	if timeoutStr := r.FormValue("timeout"); timeoutStr != "" {
+		timeout, err := parseDuration(timeoutStr)
+		if err != nil || timeout <= 0 || timeout > maxTimeout {
+			return opts, fmt.Errorf("Cannot parse timeout parameter: expected a duration like 30s, up to %s", maxTimeout)
+		}
+		opts.Timeout = timeout
+	}
+	if opts.Timeout > maxTimeout {
+		opts.Timeout = maxTimeout
+	}
+	return opts, nil
+}
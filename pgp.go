@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+const defaultPGPKeyserver = "https://keys.openpgp.org"
+
+// maxKeySize is the largest key we'll download
+const maxKeySize = 1024 * 1024
+
+var pgpClient = &http.Client{Timeout: 10 * time.Second}
+
+// PGPKey describes a primary key or subkey.
+type PGPKey struct {
+	Fingerprint string     `json:"fingerprint"`
+	Subkey      bool       `json:"subkey,omitempty"`
+	Created     time.Time  `json:"created"`
+	Expires     *time.Time `json:"expires,omitempty"` // nil if the key doesn't expire
+}
+
+// checkPGP fetches the key for query, which is an email address or a
+// fingerprint, and reports when the key or any of its subkeys expire.
+func checkPGP(ctx context.Context, query string, opts checkOptions, exp *Expiration) {
+	entities, source, err := fetchPGPKey(ctx, query, opts.PGPKeyserver)
+	if err != nil {
+		exp.CertificateError = err
+		return
+	}
+	exp.Details.PGPKeySource = source
+
+	for _, entity := range entities {
+		keys := pgpKeys(entity)
+		exp.Details.PGPKeys = append(exp.Details.PGPKeys, keys...)
+		for _, key := range keys {
+			if key.Expires == nil {
+				continue
+			}
+			if exp.CertificateExpires.IsZero() || key.Expires.Before(exp.CertificateExpires) {
+				exp.CertificateExpires = *key.Expires
+				exp.CertificateNotBefore = key.Created
+			}
+		}
+	}
+}
+
+// pgpKeys returns the primary key and subkeys of entity. The expiration
+// of a primary key comes from its primary user ID's self signature, and
+// that of a subkey from its binding signature.
+func pgpKeys(entity *openpgp.Entity) []PGPKey {
+	primary := PGPKey{
+		Fingerprint: strings.ToUpper(hex.EncodeToString(entity.PrimaryKey.Fingerprint[:])),
+		Created:     entity.PrimaryKey.CreationTime,
+	}
+	var selfSig *openpgp.Identity
+	for _, identity := range entity.Identities {
+		if identity.SelfSignature == nil {
+			continue
+		}
+		if selfSig == nil || (identity.SelfSignature.IsPrimaryId != nil && *identity.SelfSignature.IsPrimaryId) {
+			selfSig = identity
+		}
+	}
+	if selfSig != nil && selfSig.SelfSignature.KeyLifetimeSecs != nil && *selfSig.SelfSignature.KeyLifetimeSecs != 0 {
+		expires := primary.Created.Add(time.Duration(*selfSig.SelfSignature.KeyLifetimeSecs) * time.Second)
+		primary.Expires = &expires
+	}
+
+	rv := []PGPKey{primary}
+	for _, subkey := range entity.Subkeys {
+		key := PGPKey{
+			Fingerprint: strings.ToUpper(hex.EncodeToString(subkey.PublicKey.Fingerprint[:])),
+			Subkey:      true,
+			Created:     subkey.PublicKey.CreationTime,
+		}
+		if subkey.Sig != nil && subkey.Sig.KeyLifetimeSecs != nil && *subkey.Sig.KeyLifetimeSecs != 0 {
+			expires := key.Created.Add(time.Duration(*subkey.Sig.KeyLifetimeSecs) * time.Second)
+			key.Expires = &expires
+		}
+		rv = append(rv, key)
+	}
+	return rv
+}
+
+// fetchPGPKey returns the key for query and the URL it was fetched from.
+// Keys for email addresses are fetched with WKD (first the advanced and
+// then the direct method) and then from the keyserver; keys for
+// fingerprints and key IDs are fetched from the keyserver.
+func fetchPGPKey(ctx context.Context, query, keyserver string) (openpgp.EntityList, string, error) {
+	if keyserver == "" {
+		keyserver = defaultPGPKeyserver
+	}
+	keyserver = strings.TrimSuffix(keyserver, "/")
+
+	var urls []string
+	if at := strings.LastIndex(query, "@"); at > 0 {
+		local, domain := query[:at], strings.ToLower(query[at+1:])
+		hash := sha1.Sum([]byte(strings.ToLower(local)))
+		hu := zbase32(hash[:]) + "?l=" + url.QueryEscape(local)
+		urls = append(urls,
+			"https://openpgpkey."+domain+"/.well-known/openpgpkey/"+domain+"/hu/"+hu,
+			"https://"+domain+"/.well-known/openpgpkey/hu/"+hu,
+			keyserver+"/vks/v1/by-email/"+url.PathEscape(query))
+	} else {
+		id := strings.ToUpper(strings.TrimPrefix(strings.TrimPrefix(query, "0x"), "0X"))
+		switch len(id) {
+		case 40:
+			urls = append(urls, keyserver+"/vks/v1/by-fingerprint/"+id)
+		case 16:
+			urls = append(urls, keyserver+"/vks/v1/by-keyid/"+id)
+		default:
+			return nil, "", fmt.Errorf("expected an email address, fingerprint or key ID")
+		}
+	}
+
+	var lastErr error
+	for _, u := range urls {
+		entities, err := fetchPGPKeyURL(ctx, u)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return entities, u, nil
+	}
+	return nil, "", lastErr
+}
+
+func fetchPGPKeyURL(ctx context.Context, u string) (openpgp.EntityList, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := pgpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	buf, err := ioutil.ReadAll(&io.LimitedReader{R: resp.Body, N: maxKeySize})
+	if err != nil {
+		return nil, err
+	}
+
+	// WKD serves binary keys and keyservers serve armored ones
+	entities, err := openpgp.ReadKeyRing(bytes.NewReader(buf))
+	if err != nil || len(entities) == 0 {
+		entities, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(buf))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", u, err)
+	}
+	if len(entities) == 0 {
+		return nil, fmt.Errorf("%s: no keys found", u)
+	}
+	return entities, nil
+}
+
+const zbase32Alphabet = "ybndrfg8ejkmcpqxot1uwisza345h769"
+
+// zbase32 encodes buf as z-base-32 (RFC 6189), which is used for the
+// hashed local part in WKD URLs.
+func zbase32(buf []byte) string {
+	var rv []byte
+	var acc uint
+	bits := 0
+	for _, b := range buf {
+		acc = acc<<8 | uint(b)
+		bits += 8
+		for bits >= 5 {
+			rv = append(rv, zbase32Alphabet[(acc>>uint(bits-5))&31])
+			bits -= 5
+		}
+	}
+	if bits > 0 {
+		rv = append(rv, zbase32Alphabet[(acc<<uint(5-bits))&31])
+	}
+	return string(rv)
+}
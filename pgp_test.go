@@ -0,0 +1,14 @@
+package main
+
+import (
+	"crypto/sha1"
+	"testing"
+)
+
+func TestZbase32(t *testing.T) {
+	// the example from the WKD draft: Joe.Doe@Example.ORG
+	hash := sha1.Sum([]byte("joe.doe"))
+	if got, want := zbase32(hash[:]), "iy9q119eutrkn8s1mk4r39qejnbu3n5q"; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
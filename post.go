@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// maxHostsBodySize is the largest JSON body of hosts that may be posted.
+const maxHostsBodySize = 1024 * 1024
+
+// hostsRequest is the body of a POST to /: the hosts to check, and
+// optionally the ttl and lifetime parameters, for inventories too large
+// to list in a URL.
+type hostsRequest struct {
+	Hosts    []string `json:"hosts"`
+	TTL      string   `json:"ttl"`
+	Lifetime string   `json:"lifetime"`
+}
+
+// servePostHostnames checks the hosts in the JSON body of r, and writes
+// the results in the format requested as for a list of hosts in the
+// path. The ttl and lifetime in the body take precedence over the query
+// parameters.
+func (s *Server) servePostHostnames(w http.ResponseWriter, r *http.Request) {
+	var req hostsRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxHostsBodySize)).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, "Cannot parse request:", err.Error())
+		return
+	}
+	var hostnames []string
+	for _, host := range req.Hosts {
+		if host = strings.TrimSpace(host); host != "" {
+			hostnames = append(hostnames, host)
+		}
+	}
+	if len(hostnames) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, `Cannot parse request: expected {"hosts": [...]}`)
+		return
+	}
+
+	query := r.URL.Query()
+	for param, value := range map[string]string{"ttl": req.TTL, "lifetime": req.Lifetime} {
+		if value != "" {
+			query.Set(param, value)
+		}
+	}
+	r.URL.RawQuery = query.Encode()
+	r.Form = nil // parsed again with the parameters from the body
+
+	if !s.checkHostCount(w, len(hostnames)) {
+		return
+	}
+	s.serveHostnames(w, r, hostnames)
+}
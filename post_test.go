@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServePostHostnames(t *testing.T) {
+	now := time.Now()
+	s := NewServer(&Config{})
+	opts := s.defaultCheckOptions()
+	for _, name := range []string{"post1.example.com", "post2.example.com"} {
+		results.Set(opts.cacheKey(name), Expiration{
+			Name:               name,
+			CertificateExpires: now.AddDate(0, 0, 45),
+			Details:            &Details{},
+		}, now)
+	}
+
+	for _, tt := range []struct {
+		url, body string
+		code      int
+	}{
+		{"/json/", `{"hosts":["post1.example.com", "post2.example.com"]}`, 200},
+		{"/json/", `{"hosts":["post1.example.com"],"ttl":"60d"}`, 417},
+		{"/json/?ttl=60d", `{"hosts":["post1.example.com"],"ttl":"30d"}`, 200},
+		{"/", `{"hosts":[]}`, 400},
+		{"/", `post1.example.com`, 400},
+	} {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("POST", tt.url, strings.NewReader(tt.body)))
+		if w.Code != tt.code {
+			t.Errorf("%s %s: expected %d, got %d: %s", tt.url, tt.body, tt.code, w.Code, w.Body.String())
+		}
+		if tt.code != 400 && !strings.Contains(w.Body.String(), `"Name":"post1.example.com"`) {
+			t.Errorf("%s %s: unexpected body %s", tt.url, tt.body, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxPrefetchSize is the largest host list accepted by /prefetch
+const maxPrefetchSize = 1024 * 1024
+
+// maxPrefetchHosts is the most hosts a prefetch may check, counting those
+// that www and follow may add
+const maxPrefetchHosts = 1000
+
+// maxPrefetchesInFlight is how many prefetches may run at once, across all
+// clients, since each keeps checking after its request is answered
+const maxPrefetchesInFlight = 4
+
+// prefetchTimeout bounds how long the checks started by a prefetch may run
+const prefetchTimeout = 10 * time.Minute
+
+// prefetchHostnames returns the hosts in body, which are separated by
+// commas or whitespace.
+func prefetchHostnames(body string) []string {
+	return strings.FieldsFunc(body, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\r' || r == '\n'
+	})
+}
+
+// servePrefetch checks the hosts listed in the request body in the
+// background, so that their results are cached by the time they are
+// requested, and responds immediately with 202 Accepted.
+func (s *Server) servePrefetch(w http.ResponseWriter, r *http.Request) {
+	opts, err := s.parseCheckOptions(r)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		fmt.Fprintln(w, err.Error())
+		return
+	}
+	opts.Debug = false
+
+	buf, err := ioutil.ReadAll(&io.LimitedReader{R: r.Body, N: maxPrefetchSize + 1})
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, err.Error())
+		return
+	}
+	if len(buf) > maxPrefetchSize {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		fmt.Fprintf(w, "host list too large (maximum %d bytes)\n", maxPrefetchSize)
+		return
+	}
+	hostnames := prefetchHostnames(string(buf))
+	if len(hostnames) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, "no hosts to prefetch")
+		return
+	}
+
+	n := checkedHostCount(hostnames, opts)
+	if n > maxPrefetchHosts {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		fmt.Fprintf(w, "Too many hosts: a prefetch may check at most %d, not %d, "+
+			"counting those that www and follow may add.\n", maxPrefetchHosts, n)
+		return
+	}
+
+	select {
+	case s.prefetches <- struct{}{}:
+	default:
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "%d prefetches are already running, try again later\n", maxPrefetchesInFlight)
+		return
+	}
+	if !s.chargeQuota(w, r, n) {
+		<-s.prefetches
+		return
+	}
+
+	go func() {
+		defer func() { <-s.prefetches }()
+		ctx, cancel := context.WithTimeout(context.Background(), prefetchTimeout)
+		defer cancel()
+		start := time.Now()
+		getExpirations(ctx, hostnames, opts)
+		log.Printf("prefetched %d hosts in %s", len(hostnames), time.Since(start))
+	}()
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "prefetching %d hosts\n", len(hostnames))
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+// LoggingConfig controls what is logged.
+type LoggingConfig struct {
+	// Privacy is how hostnames appear in logs: as is (the default),
+	// "hash" to replace them with a hash, or "truncate" to keep only the
+	// registered domain. With either, whois records are never logged.
+	Privacy string `yaml:"privacy"`
+}
+
+const (
+	privacyHash     = "hash"
+	privacyTruncate = "truncate"
+)
+
+// logPrivacy is the privacy mode for logs, set from the configuration at
+// startup.
+var logPrivacy string
+
+// logName returns name, which is a hostname or other target, as it should
+// appear in logs.
+func logName(name string) string {
+	switch logPrivacy {
+	case privacyHash:
+		hash := sha256.Sum256([]byte(name))
+		return "h:" + hex.EncodeToString(hash[:6])
+	case privacyTruncate:
+		return truncateName(name)
+	default:
+		return name
+	}
+}
+
+// logNoExpiration logs a whois record in which no expiration date could be
+// found, so that a format can be added for it.
+func logNoExpiration(domain, record string) {
+	if logPrivacy != "" {
+		log.Printf("cannot determine expiration date for %s from %d byte whois record", logName(domain), len(record))
+	} else {
+		log.Printf("cannot determine expiration date for %s from whois record %q", domain, record)
+	}
+}
+
+// truncateName hides all but the registered domain part of name, e.g.
+// *.example.com for api.internal.example.com, and the local part of email
+// addresses.
+func truncateName(name string) string {
+	prefix := ""
+	if at := strings.LastIndex(name, "@"); at >= 0 {
+		if colon := strings.Index(name, ":"); colon >= 0 && colon < at {
+			prefix = name[:colon+1]
+		}
+		return prefix + "*@" + name[at+1:]
+	}
+	if host, port, err := net.SplitHostPort(name); err == nil && isHost(name) {
+		return net.JoinHostPort(truncateName(host), port)
+	}
+	if colon := strings.Index(name, ":"); colon >= 0 {
+		prefix, name = name[:colon+1], name[colon+1:]
+	}
+	domain, err := expire.EffectiveTLDPlusOne(name)
+	if err != nil {
+		return prefix + "*"
+	}
+	if domain == name {
+		return prefix + name
+	}
+	return prefix + "*." + domain
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLogName(t *testing.T) {
+	defer func() { logPrivacy = "" }()
+
+	logPrivacy = ""
+	if got := logName("api.example.com"); got != "api.example.com" {
+		t.Errorf("expected name unchanged, got %s", got)
+	}
+
+	logPrivacy = privacyHash
+	if got := logName("api.example.com"); !strings.HasPrefix(got, "h:") || strings.Contains(got, "example") {
+		t.Errorf("expected hashed name, got %s", got)
+	}
+	if logName("api.example.com") != logName("api.example.com") {
+		t.Errorf("expected hashes to be stable")
+	}
+
+	logPrivacy = privacyTruncate
+	for name, want := range map[string]string{
+		"api.internal.example.com": "*.example.com",
+		"example.com":              "example.com",
+		"pgp:alice@example.com":    "pgp:*@example.com",
+		"tuf:tuf.example.com":      "tuf:*.example.com",
+		"mail.example.com:8443":    "*.example.com:8443",
+	} {
+		if got := logName(name); got != want {
+			t.Errorf("%s: expected %s, got %s", name, want, got)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// defaultPublishInterval is how often watchlists are published when the
+// configuration doesn't say.
+const defaultPublishInterval = time.Hour
+
+// PublishConfig describes a watchlist that is rendered and uploaded to
+// object storage on a schedule, so that calendar programs can fetch it from
+// cheap static storage instead of from the checker.
+type PublishConfig struct {
+	Watchlist string `yaml:"watchlist"`
+
+	// Format is ical, json, text or csv (default: ical)
+	Format string `yaml:"format"`
+
+	// URL is where to upload the rendered watchlist, as
+	// s3://bucket/key, gs://bucket/key or azure://account/container/blob.
+	// {watchlist} and {format} in the key are replaced by the watchlist
+	// name and format.
+	URL string `yaml:"url"`
+
+	// Region is the AWS region of an S3 bucket (default: $AWS_REGION or
+	// us-east-1)
+	Region string `yaml:"region"`
+
+	// Interval is how often to publish (default: 1h)
+	Interval time.Duration `yaml:"interval"`
+}
+
+// publishContentTypes are the content types of the published formats
+var publishContentTypes = map[string]string{
+	"ical": "text/calendar",
+	"json": "application/json",
+	"text": "text/plain",
+	"csv":  "text/csv",
+}
+
+func (p PublishConfig) format() string {
+	if p.Format == "" {
+		return "ical"
+	}
+	return p.Format
+}
+
+// destination returns the URL to upload to, with the placeholders
+// replaced.
+func (p PublishConfig) destination() string {
+	return strings.NewReplacer(
+		"{watchlist}", p.Watchlist,
+		"{format}", p.format(),
+	).Replace(p.URL)
+}
+
+// publish checks the watchlist for p, renders the results, and uploads
+// them.
+func (s *Server) publish(p PublishConfig) error {
+	wl := s.watchlist(p.Watchlist)
+	if wl == nil {
+		return fmt.Errorf("no such watchlist %q", p.Watchlist)
+	}
+	contentType, ok := publishContentTypes[p.format()]
+	if !ok {
+		return fmt.Errorf("unknown format %q, expected ical, json, text or csv", p.Format)
+	}
+
+	interval := p.Interval
+	if interval == 0 {
+		interval = defaultPublishInterval
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), interval)
+	defer cancel()
+	expirations := getExpirations(ctx, wl.Hostnames(), s.defaultCheckOptions())
+	for i := range expirations {
+		expirations[i].Details = nil
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeExpirations(buf, s.Config, p.format(), expirations); err != nil {
+		return err
+	}
+
+	// the object is replaced every interval, so it can be cached until then
+	cacheControl := fmt.Sprintf("public, max-age=%d", int(interval.Seconds()))
+	return uploadObject(p.destination(), p.Region, contentType, cacheControl, buf.Bytes())
+}
+
+// runPublishing publishes p every interval, forever.
+func (s *Server) runPublishing(p PublishConfig) {
+	interval := p.Interval
+	if interval == 0 {
+		interval = defaultPublishInterval
+	}
+	for {
+		start := time.Now()
+		if err := s.publish(p); err != nil {
+			log.Printf("watchlist %s: cannot publish to %s: %s", p.Watchlist, p.destination(), err)
+		} else {
+			log.Printf("watchlist %s: published to %s in %s", p.Watchlist, p.destination(), time.Since(start))
+		}
+		time.Sleep(interval)
+	}
+}
+
+// StartPublishing starts publishing each of the configured watchlists to
+// object storage.
+func (s *Server) StartPublishing() {
+	for _, p := range s.Config.Publish {
+		go s.runPublishing(p)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Quotas limit how many hosts each API key may check in an hour and in a
+// day, so that one client can't monopolize a shared server. The windows
+// are aligned to the hour and to midnight UTC, so that everyone's quota
+// resets at the same, predictable time.
+
+// quotaUsage is how many hosts a key has checked in the current hour and
+// day.
+type quotaUsage struct {
+	hour   time.Time
+	hourly int
+	day    time.Time
+	daily  int
+}
+
+// current returns u as of now, with any counts from windows that have
+// ended reset.
+func (u quotaUsage) current(now time.Time) quotaUsage {
+	now = now.UTC()
+	if hour := now.Truncate(time.Hour); !u.hour.Equal(hour) {
+		u.hour, u.hourly = hour, 0
+	}
+	if day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC); !u.day.Equal(day) {
+		u.day, u.daily = day, 0
+	}
+	return u
+}
+
+// quotaTracker counts the hosts checked with each API key.
+type quotaTracker struct {
+	mu    sync.Mutex
+	usage map[string]quotaUsage // by key name
+}
+
+func newQuotaTracker() *quotaTracker {
+	return &quotaTracker{usage: map[string]quotaUsage{}}
+}
+
+// QuotaExceededError is returned when checking more hosts would exceed one
+// of an API key's quotas.
+type QuotaExceededError struct {
+	Quota  int
+	Period string // hour or day
+	Reset  time.Time
+}
+
+func (e QuotaExceededError) Error() string {
+	return fmt.Sprintf("quota of %d checks per %s exceeded, resets at %s",
+		e.Quota, e.Period, e.Reset.Format(time.RFC3339))
+}
+
+// Charge adds n hosts to the usage of key at now, unless that would exceed
+// one of its quotas.
+func (q *quotaTracker) Charge(key *APIKey, n int, now time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	u := q.usage[key.Name].current(now)
+	if key.HourlyQuota > 0 && u.hourly+n > key.HourlyQuota {
+		return QuotaExceededError{Quota: key.HourlyQuota, Period: "hour", Reset: u.hour.Add(time.Hour)}
+	}
+	if key.DailyQuota > 0 && u.daily+n > key.DailyQuota {
+		return QuotaExceededError{Quota: key.DailyQuota, Period: "day", Reset: u.day.AddDate(0, 0, 1)}
+	}
+	u.hourly += n
+	u.daily += n
+	q.usage[key.Name] = u
+	return nil
+}
+
+// QuotaStatus is the usage of one of an API key's quotas.
+type QuotaStatus struct {
+	Used  int       `json:"used"`
+	Limit int       `json:"limit,omitempty"` // zero if unlimited
+	Reset time.Time `json:"reset"`
+}
+
+// Status returns the usage of key's hourly and daily quotas at now.
+func (q *quotaTracker) Status(key *APIKey, now time.Time) (hourly, daily QuotaStatus) {
+	q.mu.Lock()
+	u := q.usage[key.Name].current(now)
+	q.mu.Unlock()
+	hourly = QuotaStatus{Used: u.hourly, Limit: key.HourlyQuota, Reset: u.hour.Add(time.Hour)}
+	daily = QuotaStatus{Used: u.daily, Limit: key.DailyQuota, Reset: u.day.AddDate(0, 0, 1)}
+	return hourly, daily
+}
+
+// chargeQuota counts n hosts about to be checked against the quotas of
+// the API key r was made with. If that would exceed a quota, it responds
+// with 429 Too Many Requests and returns false.
+func (s *Server) chargeQuota(w http.ResponseWriter, r *http.Request, n int) bool {
+	key := s.apiKey(r)
+	if key == nil {
+		if s.Config.RequireAPIKey {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "checking hosts requires an API key", http.StatusUnauthorized)
+			return false
+		}
+		return true
+	}
+	now := time.Now()
+	err := s.quotas.Charge(key, n, now)
+	if err == nil {
+		return true
+	}
+	if qerr, ok := err.(QuotaExceededError); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(qerr.Reset.Sub(now).Seconds())+1))
+	}
+	http.Error(w, err.Error(), http.StatusTooManyRequests)
+	return false
+}
+
+// serveQuota responds with the usage of the quotas of the API key r was
+// made with.
+func (s *Server) serveQuota(w http.ResponseWriter, r *http.Request) {
+	key := s.apiKey(r)
+	if key == nil {
+		http.Error(w, "quotas apply only to requests made with an API key", http.StatusUnauthorized)
+		return
+	}
+	hourly, daily := s.quotas.Status(key, time.Now())
+	w.Header().Add("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Key    string      `json:"key"`
+		Hourly QuotaStatus `json:"hourly"`
+		Daily  QuotaStatus `json:"daily"`
+	}{
+		Key:    key.Name,
+		Hourly: hourly,
+		Daily:  daily,
+	})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestQuotaTracker(t *testing.T) {
+	q := newQuotaTracker()
+	key := &APIKey{Name: "web", HourlyQuota: 10, DailyQuota: 15}
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	if err := q.Charge(key, 8, now); err != nil {
+		t.Fatal(err)
+	}
+	err := q.Charge(key, 3, now)
+	qerr, ok := err.(QuotaExceededError)
+	if !ok || qerr.Period != "hour" || !qerr.Reset.Equal(time.Date(2020, 1, 2, 4, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the hourly quota to be exceeded, got %v", err)
+	}
+
+	// the next hour has a fresh hourly quota, but not daily
+	if err := q.Charge(key, 5, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	err = q.Charge(key, 5, now.Add(time.Hour))
+	qerr, ok = err.(QuotaExceededError)
+	if !ok || qerr.Period != "day" || !qerr.Reset.Equal(time.Date(2020, 1, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the daily quota to be exceeded, got %v", err)
+	}
+
+	hourly, daily := q.Status(key, now.Add(time.Hour))
+	if hourly.Used != 5 || daily.Used != 13 {
+		t.Errorf("unexpected usage %#v %#v", hourly, daily)
+	}
+	if err := q.Charge(key, 10, now.AddDate(0, 0, 1)); err != nil {
+		t.Errorf("expected quotas to reset the next day, got %s", err)
+	}
+}
+
+func TestChargeQuota(t *testing.T) {
+	s := NewServer(&Config{
+		Watchlists: []Watchlist{{Name: "prod", Hosts: []WatchlistHost{{Name: "a.example.com"}, {Name: "b.example.com"}}}},
+		APIKeys:    []APIKey{{Name: "web", Key: "web-key", HourlyQuota: 1}},
+	})
+	r := httptest.NewRequest("GET", "/status/prod.json", nil)
+	r.Header.Set("Authorization", "Bearer web-key")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("expected 429 with Retry-After, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// rateLimiter allows each key at most limit events in each window.
+type rateLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu     sync.Mutex
+	counts map[string]*rateWindow
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		limit:  limit,
+		window: window,
+		counts: map[string]*rateWindow{},
+	}
+}
+
+// Allow records an event for key at now and returns false if key has
+// exceeded the limit for the current window.
+func (l *rateLimiter) Allow(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// forget windows that have ended so the map doesn't grow forever
+	for k, w := range l.counts {
+		if now.Sub(w.start) >= l.window {
+			delete(l.counts, k)
+		}
+	}
+
+	w, ok := l.counts[key]
+	if !ok {
+		w = &rateWindow{start: now}
+		l.counts[key] = w
+	}
+	w.count++
+	return w.count <= l.limit
+}
+
+// clientAddress returns the address of the client that made r, which is
+// used as the key for rate limits.
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
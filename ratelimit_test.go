@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(2, time.Minute)
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i, want := range []bool{true, true, false} {
+		if got := l.Allow("a", now); got != want {
+			t.Errorf("%d: expected %v, got %v", i, want, got)
+		}
+	}
+	if !l.Allow("b", now) {
+		t.Errorf("expected other keys to be allowed")
+	}
+	if !l.Allow("a", now.Add(time.Minute)) {
+		t.Errorf("expected a new window to be allowed")
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+// maxRedirects is the number of redirects followed before giving up.
+const maxRedirects = 10
+
+// redirectClient does not follow redirects itself so that each hop can be
+// inspected.
+var redirectClient = &http.Client{
+	Timeout: 10 * time.Second,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// finalHost returns the host that a request to https://hostname/ is
+// eventually redirected to, which is hostname if there are no redirects
+// or they cannot be followed.
+func finalHost(ctx context.Context, hostname string) string {
+	u := &url.URL{Scheme: "https", Host: hostname, Path: "/"}
+	for i := 0; i < maxRedirects; i++ {
+		req, err := http.NewRequest("GET", u.String(), nil)
+		if err != nil {
+			break
+		}
+		resp, err := redirectClient.Do(req.WithContext(ctx))
+		if err != nil {
+			break
+		}
+		resp.Body.Close()
+
+		location, err := resp.Location()
+		if err != nil {
+			break // not a redirect
+		}
+		u = location
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// addRedirectTargets returns hostnames plus the hosts they redirect to, and
+// a map from each added host to the host that redirected to it.
+func addRedirectTargets(ctx context.Context, hostnames []string) ([]string, map[string]string) {
+	seen := map[string]bool{}
+	for _, hostname := range hostnames {
+		seen[strings.ToLower(hostname)] = true
+	}
+
+	rv := append([]string{}, hostnames...)
+	redirectedFrom := map[string]string{}
+	for _, hostname := range hostnames {
+		if !isHost(hostname) {
+			continue
+		}
+		target := expire.ToUnicode(finalHost(ctx, hostname))
+		if target == "" || seen[target] {
+			continue
+		}
+		seen[target] = true
+		rv = append(rv, target)
+		redirectedFrom[target] = hostname
+	}
+	return rv, redirectedFrom
+}
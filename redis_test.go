@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+// fakeRedis is a Redis server that understands AUTH, GET and SET, and
+// ignores expiry.
+type fakeRedis struct {
+	net.Listener
+	password string
+
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeRedis{Listener: l, password: password, values: map[string]string{}}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authorized := s.password == ""
+	for {
+		reply, err := readRedisReply(r)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, arg := range reply.([]interface{}) {
+			args = append(args, string(arg.([]byte)))
+		}
+		switch {
+		case args[0] == "AUTH" && args[1] == s.password:
+			authorized = true
+			fmt.Fprint(conn, "+OK\r\n")
+		case !authorized:
+			fmt.Fprint(conn, "-NOAUTH Authentication required.\r\n")
+		case args[0] == "GET":
+			s.mu.Lock()
+			value, ok := s.values[args[1]]
+			s.mu.Unlock()
+			if !ok {
+				fmt.Fprint(conn, "$-1\r\n")
+				continue
+			}
+			fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(value), value)
+		case args[0] == "SET":
+			s.mu.Lock()
+			s.values[args[1]] = args[2]
+			s.mu.Unlock()
+			fmt.Fprint(conn, "+OK\r\n")
+		default:
+			fmt.Fprintf(conn, "-ERR unknown command '%s'\r\n", args[0])
+		}
+	}
+}
+
+func TestRedisCache(t *testing.T) {
+	server := newFakeRedis(t, "secret")
+	defer server.Close()
+
+	cache, err := openSharedCache(CacheConfig{Type: "redis", Address: server.Addr().String(), Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := cache.Get("missing"); ok || err != nil {
+		t.Errorf("expected a miss, got %v %v", ok, err)
+	}
+	if err := cache.Set("key", []byte("value\r\nwith a newline"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	value, ok, err := cache.Get("key")
+	if !ok || err != nil || string(value) != "value\r\nwith a newline" {
+		t.Errorf("unexpected value %q %v %v", value, ok, err)
+	}
+	server.mu.Lock()
+	_, ok = server.values["expire-sh:key"]
+	server.mu.Unlock()
+	if !ok {
+		t.Errorf("expected the key to be prefixed")
+	}
+
+	cache, _ = openSharedCache(CacheConfig{Type: "redis", Address: server.Addr().String(), Password: "wrong"})
+	if _, _, err := cache.Get("key"); err == nil {
+		t.Errorf("expected an error with the wrong password")
+	}
+}
+
+func TestSharedResults(t *testing.T) {
+	server := newFakeRedis(t, "")
+	defer server.Close()
+	shared, err := openSharedCache(CacheConfig{Type: "redis", Address: server.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// each replica has its own cache, but they share the results
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	a, b := newResultCache(time.Minute), newResultCache(time.Minute)
+	a.shared, b.shared = shared, shared
+	a.Set("example.com", Expiration{
+		Name:               "example.com",
+		CertificateExpires: now.AddDate(0, 3, 0),
+		DomainError:        expire.ExpiryWithheldError{Domain: "example.com"},
+		Details:            &Details{Whois: "record"},
+	}, now)
+	exp, ok := b.Get("example.com", now.Add(30*time.Second))
+	if !ok || !exp.CertificateExpires.Equal(now.AddDate(0, 3, 0)) || exp.Details.Whois != "record" {
+		t.Fatalf("expected the shared result, got %v %+v", ok, exp)
+	}
+	if !expire.IsExpiryWithheld(exp.DomainError) || exp.CertificateError != nil {
+		t.Errorf("expected the errors to survive sharing, got %v %v", exp.CertificateError, exp.DomainError)
+	}
+	if _, ok := b.Get("example.com", now.Add(time.Minute)); ok {
+		t.Errorf("expected the shared result to expire when the original does")
+	}
+
+	da, db := newDomainCache(time.Hour), newDomainCache(time.Hour)
+	da.shared, db.shared = shared, shared
+	da.Set("example.com", expire.DomainResult{Expires: now.AddDate(1, 0, 0), Source: "rdap"}, nil, now)
+	entry, ok := db.Get("example.com", now)
+	if !ok || entry.err != nil || entry.result.Source != "rdap" || !entry.result.Expires.Equal(now.AddDate(1, 0, 0)) {
+		t.Errorf("expected the shared lookup, got %v %+v", ok, entry)
+	}
+}
+
+func TestSharedCacheUnreachable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	shared, _ := openSharedCache(CacheConfig{Type: "redis", Address: addr})
+	c := newResultCache(time.Minute)
+	c.shared = shared
+	now := time.Now()
+	c.Set("example.com", Expiration{Name: "example.com"}, now)
+	if _, ok := c.Get("example.com", now); !ok {
+		t.Errorf("expected results to still be cached locally")
+	}
+	if _, ok := c.Get("example.net", now); ok {
+		t.Errorf("expected a miss")
+	}
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+const reportPrefix = "/report/"
+
+const (
+	// defaultReportHorizon is how far ahead reports look for expirations.
+	defaultReportHorizon = 90 * 24 * time.Hour
+
+	// defaultReportPeriod is how far back reports look for renewals.
+	defaultReportPeriod = 30 * 24 * time.Hour
+
+	// persistentFailure is how long a check must have been failing to be
+	// reported as a persistent failure rather than a blip.
+	persistentFailure = 24 * time.Hour
+)
+
+// ReportConfig describes a report on a watchlist, for management and
+// compliance reviews, that is rendered as HTML and uploaded to object
+// storage on a schedule.
+type ReportConfig struct {
+	Watchlist string `yaml:"watchlist"`
+
+	// Schedule is when to generate the report, as a cron expression, e.g.
+	// "0 8 * * 1" for 08:00 every Monday or "0 8 1 * *" for the first of
+	// each month. Timezone is the IANA name of its timezone (default:
+	// UTC).
+	Schedule string `yaml:"schedule"`
+	Timezone string `yaml:"timezone"`
+
+	// Horizon is how far ahead to look for expirations (default: 90
+	// days), and Period is how far back to look for renewals (default:
+	// 30 days).
+	Horizon time.Duration `yaml:"horizon"`
+	Period  time.Duration `yaml:"period"`
+
+	// URL is where to upload the report, as for PublishConfig. {watchlist}
+	// and {date} in the key are replaced by the watchlist name and the date
+	// of the report.
+	URL    string `yaml:"url"`
+	Region string `yaml:"region"`
+}
+
+// destination returns the URL to upload the report generated at now to.
+func (c ReportConfig) destination(now time.Time) string {
+	return strings.NewReplacer(
+		"{watchlist}", c.Watchlist,
+		"{date}", now.Format("2006-01-02"),
+	).Replace(c.URL)
+}
+
+// Validate returns an error if the report can't be generated.
+func (c ReportConfig) Validate() error {
+	if _, err := parseCronSchedule(c.Schedule); err != nil {
+		return fmt.Errorf("report for %s: %s", c.Watchlist, err)
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("report for %s: %s", c.Watchlist, err)
+	}
+	if c.URL == "" {
+		return fmt.Errorf("report for %s: no url", c.Watchlist)
+	}
+	return nil
+}
+
+// Report is what happened to a watchlist recently, and what is about to.
+type Report struct {
+	Watchlist string
+	Generated time.Time
+	Horizon   time.Time // expirations before this are reported
+	Since     time.Time // renewals after this are reported
+
+	Expiring []ReportExpiration
+	Renewals []ReportRenewal
+	Failures []ReportFailure
+}
+
+// ReportExpiration is a certificate or domain that expires before the
+// report's horizon.
+type ReportExpiration struct {
+	Host          string
+	Check         string // certificate or domain
+	Expires       time.Time
+	DaysRemaining int
+}
+
+// ReportRenewal is a certificate or domain whose expiration moved later
+// since the start of the report's period.
+type ReportRenewal struct {
+	Host            string
+	Check           string
+	Renewed         time.Time // when the renewal was first seen
+	PreviousExpires time.Time
+	Expires         time.Time
+}
+
+// ReportFailure is a check that has been failing for a while.
+type ReportFailure struct {
+	Host  string
+	Check string
+	Since time.Time
+	Error string
+}
+
+// newReport returns the report on wl given the current expirations of its
+// hosts and the history of each, as of now.
+func newReport(wl Watchlist, expirations []Expiration, history func(string) []HistoryEntry, now time.Time, horizon, period time.Duration) Report {
+	report := Report{
+		Watchlist: wl.Name,
+		Generated: now,
+		Horizon:   now.Add(horizon),
+		Since:     now.Add(-period),
+	}
+
+	for _, exp := range expirations {
+		entries := history(exp.Name)
+		for _, check := range []struct {
+			name    string
+			expires time.Time
+			err     error
+			entry   func(HistoryEntry) (time.Time, string)
+		}{
+			{CheckCertificate, exp.CertificateExpires, exp.CertificateError, func(e HistoryEntry) (time.Time, string) {
+				return e.CertificateExpires, e.CertificateError
+			}},
+			{CheckDomain, exp.DomainExpires, exp.DomainError, func(e HistoryEntry) (time.Time, string) {
+				return e.DomainExpires, e.DomainError
+			}},
+		} {
+			if check.err != nil && !expire.IsExpiryWithheld(check.err) {
+				// find when the current run of failures started
+				since := now
+				for i := len(entries) - 1; i >= 0; i-- {
+					if _, errStr := check.entry(entries[i]); errStr == "" {
+						break
+					}
+					since = entries[i].FirstChecked
+				}
+				if now.Sub(since) >= persistentFailure {
+					report.Failures = append(report.Failures, ReportFailure{
+						Host:  exp.Name,
+						Check: check.name,
+						Since: since,
+						Error: check.err.Error(),
+					})
+				}
+				continue
+			}
+
+			if check.err == nil && !check.expires.IsZero() && check.expires.Before(report.Horizon) {
+				report.Expiring = append(report.Expiring, ReportExpiration{
+					Host:          exp.Name,
+					Check:         check.name,
+					Expires:       check.expires,
+					DaysRemaining: daysUntil(now, check.expires),
+				})
+			}
+
+			for _, change := range historyChanges(entries) {
+				if change.Check != check.name || change.Kind != ChangeRenewed || change.Time.Before(report.Since) {
+					continue
+				}
+				report.Renewals = append(report.Renewals, ReportRenewal{
+					Host:            exp.Name,
+					Check:           check.name,
+					Renewed:         change.Time,
+					PreviousExpires: change.PreviousExpires,
+					Expires:         change.Expires,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(report.Expiring, func(i, j int) bool {
+		return report.Expiring[i].Expires.Before(report.Expiring[j].Expires)
+	})
+	sort.SliceStable(report.Renewals, func(i, j int) bool {
+		return report.Renewals[i].Renewed.Before(report.Renewals[j].Renewed)
+	})
+	sort.SliceStable(report.Failures, func(i, j int) bool {
+		return report.Failures[i].Since.Before(report.Failures[j].Since)
+	})
+	return report
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"date": func(t time.Time) string {
+		return t.Format("2006-01-02")
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Watchlist}} expiration report {{date .Generated}} - expire.sh</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { padding: 0.3em 1em; text-align: left; border-bottom: 1px solid #ddd; }
+.errors { color: #b71c1c; }
+</style>
+</head>
+<body>
+<h1>{{.Watchlist}} expiration report</h1>
+<p>Generated {{.Generated.Format "2006-01-02 15:04:05 MST"}}</p>
+
+<h2>Expiring before {{date .Horizon}}</h2>
+{{if .Expiring}}<table>
+<tr><th>Host</th><th>Check</th><th>Expires</th><th>Days</th></tr>
+{{range .Expiring}}<tr><td>{{.Host}}</td><td>{{.Check}}</td><td>{{date .Expires}}</td><td>{{.DaysRemaining}}</td></tr>
+{{end}}</table>
+{{else}}<p>Nothing.</p>
+{{end}}
+<h2>Renewed since {{date .Since}}</h2>
+{{if .Renewals}}<table>
+<tr><th>Host</th><th>Check</th><th>Renewed</th><th>Previously expired</th><th>Now expires</th></tr>
+{{range .Renewals}}<tr><td>{{.Host}}</td><td>{{.Check}}</td><td>{{date .Renewed}}</td><td>{{date .PreviousExpires}}</td><td>{{date .Expires}}</td></tr>
+{{end}}</table>
+{{else}}<p>Nothing.</p>
+{{end}}
+<h2>Persistent failures</h2>
+{{if .Failures}}<table>
+<tr><th>Host</th><th>Check</th><th>Failing since</th><th>Error</th></tr>
+{{range .Failures}}<tr><td>{{.Host}}</td><td>{{.Check}}</td><td>{{date .Since}}</td><td class="errors">{{.Error}}</td></tr>
+{{end}}</table>
+{{else}}<p>Nothing.</p>
+{{end}}
+</body>
+</html>
+`))
+
+// report checks the watchlist named name and returns the report on it.
+func (s *Server) report(ctx context.Context, name string, now time.Time, horizon, period time.Duration) (Report, error) {
+	wl := s.watchlist(name)
+	if wl == nil {
+		return Report{}, fmt.Errorf("no such watchlist %q", name)
+	}
+	if horizon == 0 {
+		horizon = defaultReportHorizon
+	}
+	if period == 0 {
+		period = defaultReportPeriod
+	}
+	expirations := getExpirations(ctx, wl.Hostnames(), s.defaultCheckOptions())
+	return newReport(*wl, expirations, checkHistory.Entries, now, horizon, period), nil
+}
+
+// runReports generates and uploads the report in config on its schedule,
+// forever.
+func (s *Server) runReports(config ReportConfig) {
+	schedule, err := parseCronSchedule(config.Schedule)
+	if err != nil {
+		log.Printf("report for %s: %s", config.Watchlist, err)
+		return
+	}
+	loc, err := time.LoadLocation(config.Timezone)
+	if err != nil {
+		log.Printf("report for %s: %s", config.Watchlist, err)
+		return
+	}
+	for {
+		next := schedule.Next(time.Now().In(loc))
+		if next.IsZero() {
+			log.Printf("report for %s: schedule %q never runs", config.Watchlist, config.Schedule)
+			return
+		}
+		time.Sleep(time.Until(next))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+		now := time.Now().In(loc)
+		report, err := s.report(ctx, config.Watchlist, now, config.Horizon, config.Period)
+		cancel()
+		if err != nil {
+			log.Printf("report for %s: %s", config.Watchlist, err)
+			continue
+		}
+		buf := &bytes.Buffer{}
+		if err := reportTemplate.Execute(buf, report); err != nil {
+			log.Printf("report for %s: %s", config.Watchlist, err)
+			continue
+		}
+		dest := config.destination(now)
+		if err := uploadObject(dest, config.Region, "text/html; charset=utf-8", "no-cache", buf.Bytes()); err != nil {
+			log.Printf("report for %s: cannot upload to %s: %s", config.Watchlist, dest, err)
+			continue
+		}
+		log.Printf("report for %s: uploaded to %s", config.Watchlist, dest)
+	}
+}
+
+// StartReports starts generating each of the configured reports on its
+// schedule.
+func (s *Server) StartReports() {
+	for _, config := range s.Config.Reports {
+		go s.runReports(config)
+	}
+}
+
+// serveReport generates the report on the watchlist named name now. The
+// horizon and period parameters override the defaults of 90 and 30 days.
+func (s *Server) serveReport(w http.ResponseWriter, r *http.Request, name string) {
+	wl := s.watchlist(name)
+	if wl == nil {
+		http.NotFound(w, r)
+		return
+	}
+	var horizon, period time.Duration
+	for param, d := range map[string]*time.Duration{"horizon": &horizon, "period": &period} {
+		if v := r.FormValue(param); v != "" {
+			var err error
+			if *d, err = parseDuration(v); err != nil || *d <= 0 {
+				http.Error(w, fmt.Sprintf("Cannot parse %s parameter: expected a duration like 30d", param), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	if !s.chargeQuota(w, r, len(wl.Hosts)) {
+		return
+	}
+	report, err := s.report(r.Context(), name, time.Now(), horizon, period)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Add("Content-Type", "text/html; charset=utf-8")
+	reportTemplate.Execute(w, report)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+func TestNewReport(t *testing.T) {
+	now := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	days := func(n int) time.Time { return now.Add(time.Duration(n) * 24 * time.Hour) }
+	expirations := []Expiration{
+		{Name: "a.example.com", CertificateExpires: days(60), Domain: "example.com", DomainExpires: days(400)},
+		{Name: "b.example.com", CertificateExpires: days(85), Domain: "example.com", DomainExpires: days(10)},
+		{Name: "c.example.com", CertificateError: fmt.Errorf("dial failed"), Domain: "example.com", DomainExpires: days(400)},
+		{Name: "d.example.com", CertificateError: fmt.Errorf("timeout"), Domain: "example.com", DomainError: expire.ExpiryWithheldError{}},
+	}
+	history := map[string][]HistoryEntry{
+		"b.example.com": {
+			{FirstChecked: days(-60), LastChecked: days(-6), CertificateExpires: days(-5), DomainExpires: days(10)},
+			{FirstChecked: days(-5), LastChecked: now, CertificateExpires: days(85), DomainExpires: days(10)},
+		},
+		"c.example.com": {
+			{FirstChecked: days(-10), LastChecked: days(-4), CertificateExpires: days(20)},
+			{FirstChecked: days(-3), LastChecked: now, CertificateError: "dial failed"},
+		},
+		"d.example.com": {
+			{FirstChecked: now, LastChecked: now, CertificateError: "timeout"},
+		},
+	}
+
+	report := newReport(Watchlist{Name: "prod"}, expirations, func(name string) []HistoryEntry {
+		return history[name]
+	}, now, defaultReportHorizon, defaultReportPeriod)
+
+	var got []string
+	for _, e := range report.Expiring {
+		got = append(got, fmt.Sprintf("expiring %s %s %d", e.Host, e.Check, e.DaysRemaining))
+	}
+	for _, r := range report.Renewals {
+		got = append(got, fmt.Sprintf("renewed %s %s %s", r.Host, r.Check, r.Renewed.Format("2006-01-02")))
+	}
+	for _, f := range report.Failures {
+		got = append(got, fmt.Sprintf("failing %s %s %s", f.Host, f.Check, f.Since.Format("2006-01-02")))
+	}
+	want := []string{
+		"expiring b.example.com domain 10",
+		"expiring a.example.com certificate 60",
+		"expiring b.example.com certificate 85",
+		"renewed b.example.com certificate 2019-05-27",
+		"failing c.example.com certificate 2019-05-29",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	buf := &bytes.Buffer{}
+	if err := reportTemplate.Execute(buf, report); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "<td>c.example.com</td><td>certificate</td><td>2019-05-29</td>") {
+		t.Errorf("expected the failure in the report, got %s", buf.String())
+	}
+}
+
+func TestReportConfig(t *testing.T) {
+	config := ReportConfig{Watchlist: "prod", Schedule: "0 8 1 * *", URL: "s3://reports/{watchlist}/{date}.html"}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2019, 6, 1, 8, 0, 0, 0, time.UTC)
+	if got := config.destination(now); got != "s3://reports/prod/2019-06-01.html" {
+		t.Errorf("unexpected destination %s", got)
+	}
+
+	schedule, _ := parseCronSchedule(config.Schedule)
+	if next := schedule.Next(now); !next.Equal(time.Date(2019, 7, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected next report %s", next)
+	}
+	if never, _ := parseCronSchedule("0 0 31 2 *"); !never.Next(now).IsZero() {
+		t.Errorf("expected a schedule for February 31st never to run")
+	}
+
+	for _, config := range []ReportConfig{
+		{Watchlist: "prod", Schedule: "monthly", URL: "s3://reports/x"},
+		{Watchlist: "prod", Schedule: "0 8 1 * *"},
+		{Watchlist: "prod", Schedule: "0 8 1 * *", URL: "s3://reports/x", Timezone: "Nowhere/Special"},
+	} {
+		if err := config.Validate(); err == nil {
+			t.Errorf("%+v: expected error", config)
+		}
+	}
+}
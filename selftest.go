@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// defaultSelfTestHost is checked by /selftest unless the configuration
+// says otherwise. Its certificate and domain are about as stable as they
+// come.
+const defaultSelfTestHost = "example.com"
+
+// selfTestTimeout bounds how long the self test may take, so that a load
+// balancer isn't left waiting on a hung whois server.
+const selfTestTimeout = 30 * time.Second
+
+// SelfTest is the result of checking the self test host.
+type SelfTest struct {
+	Host                   string   `json:"host"`
+	Pass                   bool     `json:"pass"`
+	CertificateError       string   `json:"certError,omitempty"`
+	DomainError            string   `json:"domainError,omitempty"`
+	CertificateCheckMillis int64    `json:"certCheckMillis"`
+	DomainCheckMillis      int64    `json:"domainCheckMillis"`
+	TotalMillis            int64    `json:"totalMillis"`
+	Trace                  []string `json:"trace,omitempty"` // only if it failed
+}
+
+// selfTest checks host end to end: it is never answered from the cache,
+// and the checks are traced so that a failure can be diagnosed from the
+// result.
+func (s *Server) selfTest(ctx context.Context, host string) SelfTest {
+	opts := s.defaultCheckOptions()
+	opts.Debug = true
+
+	ctx, cancel := context.WithTimeout(ctx, selfTestTimeout)
+	defer cancel()
+	start := time.Now()
+	exp := getExpirations(ctx, []string{host}, opts)[0]
+
+	rv := SelfTest{
+		Host:                   host,
+		Pass:                   !exp.Failed(),
+		CertificateError:       errorString(exp.CertificateError),
+		DomainError:            errorString(exp.DomainError),
+		CertificateCheckMillis: exp.Details.CertificateCheckMillis,
+		DomainCheckMillis:      exp.Details.DomainCheckMillis,
+		TotalMillis:            millisSince(start),
+	}
+	if !rv.Pass {
+		rv.Trace = exp.Details.Trace
+	}
+	return rv
+}
+
+// serveSelfTest checks the self test host and responds with the result,
+// with the status 503 Service Unavailable if it failed, for load balancer
+// and uptime monitor health checks.
+func (s *Server) serveSelfTest(w http.ResponseWriter, r *http.Request) {
+	host := s.Config.SelfTestHost
+	if host == "" {
+		host = defaultSelfTestHost
+	}
+	result := s.selfTest(r.Context(), host)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	if !result.Pass {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(result)
+}
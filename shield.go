@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// shield is the shields.io endpoint badge schema, see
+// https://shields.io/endpoint
+type shield struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Label         string `json:"label"`
+	Message       string `json:"message"`
+	Color         string `json:"color"`
+	IsError       bool   `json:"isError,omitempty"`
+}
+
+func newShield(exp Expiration, now time.Time) shield {
+	rv := shield{
+		SchemaVersion: 1,
+		Label:         "expires",
+	}
+	if exp.Failed() {
+		rv.Message = "error"
+		rv.Color = "lightgrey"
+		rv.IsError = true
+		return rv
+	}
+
+	soonest, ok := exp.Soonest()
+	if !ok {
+		// e.g. a PGP key that never expires
+		rv.Message = "no expiry"
+		rv.Color = "lightgrey"
+		return rv
+	}
+	days := daysUntil(now, soonest)
+	switch {
+	case days < 0:
+		rv.Message = "expired"
+		rv.Color = "red"
+	case days < 7:
+		rv.Message = fmt.Sprintf("%d days", days)
+		rv.Color = "red"
+	case days < 30:
+		rv.Message = fmt.Sprintf("%d days", days)
+		rv.Color = "yellow"
+	default:
+		rv.Message = fmt.Sprintf("%d days", days)
+		rv.Color = "brightgreen"
+	}
+	if days == 1 {
+		rv.Message = "1 day"
+	}
+	return rv
+}
+
+func (s *Server) serveShield(w http.ResponseWriter, r *http.Request, hostname string) {
+	opts, err := s.parseCheckOptions(r)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		fmt.Fprintln(w, err.Error())
+		return
+	}
+	if !s.chargeQuota(w, r, 1) {
+		return
+	}
+	expirations := getExpirations(r.Context(), []string{hostname}, opts)
+
+	// shields.io treats non-200 responses as a failure to fetch the badge,
+	// so errors are reported in the body instead.
+	w.Header().Add("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newShield(expirations[0], time.Now()))
+}
+
+func (s *Server) serveDays(w http.ResponseWriter, r *http.Request, hostname string) {
+	opts, err := s.parseCheckOptions(r)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		fmt.Fprintln(w, err.Error())
+		return
+	}
+	if !s.chargeQuota(w, r, 1) {
+		return
+	}
+	exp := getExpirations(r.Context(), []string{hostname}, opts)[0]
+
+	w.Header().Add("Content-Type", "text/plain")
+	if exp.CertificateError != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprintln(w, "checking certificate:", exp.CertificateError)
+		return
+	}
+	if exp.Failed() {
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprintln(w, "checking domain expiration:", exp.DomainError)
+		return
+	}
+
+	soonest, ok := exp.Soonest()
+	if !ok {
+		// there is no number of days until something that never happens
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	fmt.Fprintln(w, daysUntil(time.Now(), soonest))
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewShield(t *testing.T) {
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	tests := []struct {
+		exp     Expiration
+		message string
+		color   string
+	}{
+		{Expiration{CertificateExpires: now.Add(90 * day), DomainExpires: now.Add(365 * day)}, "90 days", "brightgreen"},
+		{Expiration{CertificateExpires: now.Add(90 * day), DomainExpires: now.Add(10 * day)}, "10 days", "yellow"},
+		{Expiration{CertificateExpires: now.Add(36 * time.Hour), DomainExpires: now.Add(365 * day)}, "1 day", "red"},
+		{Expiration{CertificateExpires: now.Add(-1 * day), DomainExpires: now.Add(365 * day)}, "expired", "red"},
+		{Expiration{CertificateError: fmt.Errorf("dial failed")}, "error", "lightgrey"},
+		{Expiration{Name: "pgp:example.com"}, "no expiry", "lightgrey"},
+	}
+	for _, tt := range tests {
+		s := newShield(tt.exp, now)
+		if s.Message != tt.message || s.Color != tt.color {
+			t.Errorf("expected %s/%s, got %s/%s", tt.message, tt.color, s.Message, s.Color)
+		}
+		if s.SchemaVersion != 1 {
+			t.Errorf("expected schemaVersion 1, got %d", s.SchemaVersion)
+		}
+	}
+}
+
+func TestServeDaysNoExpiry(t *testing.T) {
+	s := NewServer(&Config{})
+	opts := s.defaultCheckOptions()
+	results.Set(opts.cacheKey("noexpiry.example.com"), Expiration{Name: "noexpiry.example.com", Details: &Details{}}, time.Now())
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/days/noexpiry.example.com", nil))
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Errorf("expected 204 with no body, got %d %q", w.Code, w.Body.String())
+	}
+}
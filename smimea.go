@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// smimeaName returns the DNS name of the SMIMEA records for email, as
+// described in RFC 8162: the first 28 octets of the SHA-256 hash of the
+// local part, hex encoded, under _smimecert in the domain.
+func smimeaName(email string) (string, error) {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || at == len(email)-1 {
+		return "", fmt.Errorf("expected an email address")
+	}
+	hash := sha256.Sum256([]byte(email[:at]))
+	return hex.EncodeToString(hash[:28]) + "._smimecert." + email[at+1:], nil
+}
+
+// checkSMIMEA looks up the SMIMEA records for an email address and reports
+// when the certificate they contain expires.
+func checkSMIMEA(ctx context.Context, email string, opts checkOptions, exp *Expiration) {
+	name, err := smimeaName(email)
+	if err != nil {
+		exp.CertificateError = err
+		return
+	}
+	records, err := lookupDNS(ctx, name, dns.TypeSMIMEA)
+	if err != nil {
+		exp.CertificateError = err
+		return
+	}
+	if len(records) == 0 {
+		exp.CertificateError = fmt.Errorf("no SMIMEA records found at %s", name)
+		return
+	}
+
+	for _, rr := range records {
+		record := rr.(*dns.SMIMEA)
+
+		// Only records that contain the full certificate (selector 0,
+		// matching type 0) tell us when it expires. The others contain the
+		// public key, or a hash.
+		if record.Selector != 0 || record.MatchingType != 0 {
+			continue
+		}
+		der, err := hex.DecodeString(record.Certificate)
+		if err != nil {
+			exp.CertificateError = fmt.Errorf("cannot decode SMIMEA record: %s", err)
+			return
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			exp.CertificateError = fmt.Errorf("cannot parse certificate in SMIMEA record: %s", err)
+			return
+		}
+		if exp.CertificateExpires.IsZero() || cert.NotAfter.Before(exp.CertificateExpires) {
+			exp.CertificateExpires = cert.NotAfter
+			exp.CertificateNotBefore = cert.NotBefore
+		}
+	}
+	if exp.CertificateExpires.IsZero() {
+		exp.CertificateError = fmt.Errorf("the SMIMEA records at %s contain only keys or hashes, not certificates", name)
+	}
+}
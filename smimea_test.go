@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestSMIMEAName(t *testing.T) {
+	// the example from RFC 8162, section 3
+	name, err := smimeaName("hugh@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "c93f1e400f26708f98cb19d936620da35eec8f72e57f9eec01c1afd6._smimecert.example.com"
+	if name != want {
+		t.Errorf("expected %s, got %s", want, name)
+	}
+
+	if _, err := smimeaName("example.com"); err == nil {
+		t.Errorf("expected error for a name without a local part")
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+// DefaultCertificate is the certificate a server presents to clients that
+// don't send a hostname with SNI.
+type DefaultCertificate struct {
+	Subject     string    `json:"subject,omitempty"`
+	Expires     time.Time `json:"expires"`
+	Fingerprint string    `json:"fingerprint,omitempty"` // of the leaf, SHA-256
+	Error       string    `json:"error,omitempty"`
+}
+
+// checkDefaultCertificate makes a second handshake with hostname without
+// SNI, and reports the certificate presented. Old clients and some load
+// balancer health checks get this default certificate, which is easily
+// forgotten when it isn't the one for hostname, so it is warned about if
+// it has expired, or expires before the certificate for hostname.
+func checkDefaultCertificate(ctx context.Context, hostname string, opts checkOptions, exp *Expiration, now time.Time) {
+	defaultOpts := opts.Options
+	defaultOpts.OmitSNI = true
+	result, err := expire.CheckCertificate(ctx, hostname, defaultOpts)
+	if err != nil {
+		exp.DefaultCertificate = &DefaultCertificate{Error: err.Error()}
+		return
+	}
+	exp.DefaultCertificate = &DefaultCertificate{Expires: result.Expires}
+	if len(result.Chain) > 0 {
+		exp.DefaultCertificate.Subject = result.Chain[0].Subject
+		exp.DefaultCertificate.Fingerprint = result.Chain[0].Fingerprint
+	}
+
+	if len(exp.CertificateChain) > 0 && exp.CertificateChain[0].Fingerprint == exp.DefaultCertificate.Fingerprint {
+		return
+	}
+	switch {
+	case result.Expires.Before(now):
+		exp.Warnings = append(exp.Warnings, fmt.Sprintf("the default certificate, presented to clients without SNI, expired on %s",
+			result.Expires.Format("2006-01-02")))
+	case exp.CertificateError == nil && result.Expires.Before(exp.CertificateExpires):
+		exp.Warnings = append(exp.Warnings, fmt.Sprintf("the default certificate, presented to clients without SNI, expires on %s, before the certificate for %s",
+			result.Expires.Format("2006-01-02"), exp.Name))
+	}
+}
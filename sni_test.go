@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+func TestCheckDefaultCertificate(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	server := testTLSServer(t, "default.example.com", now.AddDate(0, 0, -1))
+	defer server.Close()
+
+	opts := NewServer(&Config{}).defaultCheckOptions()
+	opts.Dialer = addressDialer{"www.example.com:443": server.Listener.Addr().String()}
+	exp := Expiration{Name: "www.example.com", CertificateExpires: now.AddDate(0, 2, 0)}
+	checkDefaultCertificate(context.Background(), "www.example.com", opts, &exp, now)
+	if exp.DefaultCertificate == nil || exp.DefaultCertificate.Subject != "CN=default.example.com" || !exp.DefaultCertificate.Expires.Equal(now.AddDate(0, 0, -1)) {
+		t.Fatalf("unexpected default certificate %+v", exp.DefaultCertificate)
+	}
+	if len(exp.Warnings) != 1 || !strings.Contains(exp.Warnings[0], "presented to clients without SNI, expired on") {
+		t.Errorf("expected a warning about the expired default certificate, got %q", exp.Warnings)
+	}
+
+	// the same certificate with and without SNI is fine
+	exp = Expiration{
+		Name:               "www.example.com",
+		CertificateExpires: now.AddDate(0, 0, -1),
+		CertificateChain:   []expire.ChainCertificate{{Fingerprint: exp.DefaultCertificate.Fingerprint}},
+	}
+	checkDefaultCertificate(context.Background(), "www.example.com", opts, &exp, now)
+	if len(exp.Warnings) != 0 {
+		t.Errorf("unexpected warnings %q", exp.Warnings)
+	}
+
+	// servers that can't be reached
+	opts.Dialer = addressDialer{}
+	exp = Expiration{Name: "www.example.com"}
+	checkDefaultCertificate(context.Background(), "www.example.com", opts, &exp, now)
+	if exp.DefaultCertificate == nil || exp.DefaultCertificate.Error == "" || len(exp.Warnings) != 0 {
+		t.Errorf("expected an error, got %+v", exp)
+	}
+}
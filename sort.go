@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Orders that results can be sorted in with the sort parameter
+const (
+	SortExpiry = "expiry"
+	SortName   = "name"
+	SortStatus = "status"
+)
+
+// statusRank orders statuses from most to least in need of attention.
+var statusRank = map[string]int{
+	StatusError:        0,
+	StatusExpiring:     1,
+	StatusWithheld:     2,
+	StatusAcknowledged: 3,
+	StatusOK:           4,
+}
+
+// parseSort parses the sort parameter. An empty order leaves results in
+// the order the hosts were given.
+func parseSort(s string) (string, error) {
+	switch s {
+	case "", SortExpiry, SortName, SortStatus:
+		return s, nil
+	}
+	return "", fmt.Errorf("Cannot parse sort parameter: expected expiry, name or status")
+}
+
+// sortExpirations sorts expirations by order: by when the soonest of the
+// certificate and domain expires, with those whose expiration isn't known
+// last, by name, or by status, most in need of attention first. Ties are
+// broken by expiration, then name.
+func sortExpirations(expirations []Expiration, order string, t thresholds) {
+	if order == "" {
+		return
+	}
+	byExpiry := func(a, b Expiration) (less, ok bool) {
+		soonestA, okA := a.Soonest()
+		soonestB, okB := b.Soonest()
+		switch {
+		case okA != okB:
+			return okA, true
+		case okA && !soonestA.Equal(soonestB):
+			return soonestA.Before(soonestB), true
+		}
+		return false, false
+	}
+	sort.SliceStable(expirations, func(i, j int) bool {
+		a, b := expirations[i], expirations[j]
+		if order == SortStatus {
+			if rankA, rankB := statusRank[a.Status(t)], statusRank[b.Status(t)]; rankA != rankB {
+				return rankA < rankB
+			}
+		}
+		if order != SortName {
+			if less, ok := byExpiry(a, b); ok {
+				return less
+			}
+		}
+		return a.Name < b.Name
+	})
+}
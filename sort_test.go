@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSortExpirations(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	th := thresholds{Now: now, Soon: now.AddDate(0, 0, 30)}
+	expirations := []Expiration{
+		{Name: "c.example.com", CertificateExpires: now.AddDate(0, 6, 0)},
+		{Name: "a.example.com", CertificateError: fmt.Errorf("timeout")},
+		{Name: "d.example.com", CertificateExpires: now.AddDate(0, 0, 10)},
+		{Name: "b.example.com", CertificateExpires: now.AddDate(0, 2, 0), DomainExpires: now.AddDate(0, 1, 0)},
+	}
+	for _, tt := range []struct {
+		order string
+		want  string
+	}{
+		{"", "[c a d b]"},
+		{SortName, "[a b c d]"},
+		{SortExpiry, "[d b c a]"},
+		{SortStatus, "[a d b c]"},
+	} {
+		sorted := append([]Expiration{}, expirations...)
+		sortExpirations(sorted, tt.order, th)
+		var got []string
+		for _, exp := range sorted {
+			got = append(got, exp.Name[:1])
+		}
+		if fmt.Sprint(got) != tt.want {
+			t.Errorf("%q: expected %s, got %s", tt.order, tt.want, got)
+		}
+	}
+
+	if _, err := parseSort("soonest"); err == nil {
+		t.Errorf("expected an error for an unknown order")
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+// usageStats counts what the server has been asked to do since it started,
+// for capacity planning.
+type usageStats struct {
+	mu            sync.Mutex
+	started       time.Time
+	requests      map[string]int // by format
+	responses     map[int]int    // by status code
+	checking      int            // checks in flight
+	hostsChecked  int
+	cacheHits     int
+	whoisLookups  int
+	whoisFailures map[string]int // by public suffix
+
+	// registries are the whois lookups by public suffix, and networks
+	// are the TLS checks by the network of the server
+	registries map[string]*UpstreamStats
+	networks   map[string]*UpstreamStats
+}
+
+// maxUpstreams is the most public suffixes, or networks, that are counted
+// separately. The rest are counted together as otherUpstreams, so that
+// checking many hosts doesn't make the statistics, and the metrics
+// labelled with them, grow without bound.
+const maxUpstreams = 100
+
+const otherUpstreams = "other"
+
+// upstreamKey returns the key in m that lookups of key are counted under.
+func upstreamKey(m map[string]*UpstreamStats, key string) string {
+	if _, ok := m[key]; ok || len(m) < maxUpstreams {
+		return key
+	}
+	return otherUpstreams
+}
+
+func newUsageStats() *usageStats {
+	return &usageStats{
+		started:       time.Now(),
+		requests:      map[string]int{},
+		responses:     map[int]int{},
+		whoisFailures: map[string]int{},
+		registries:    map[string]*UpstreamStats{},
+		networks:      map[string]*UpstreamStats{},
+	}
+}
+
+// stats are the usage statistics for this instance
+var stats = newUsageStats()
+
+// formatNames are the names of the formats in the statistics, by content
+// type.
+var formatNames = map[string]string{
+	"text/calendar":    "ical",
+	"application/json": "json",
+	"text/plain":       "text",
+	"text/csv":         "csv",
+	"text/prometheus":  "prometheus",
+	"text/html":        "html",
+
+	"application/graphql": "graphql",
+}
+
+// CountRequest counts a request for results in contentType.
+func (u *usageStats) CountRequest(contentType string) {
+	name, ok := formatNames[contentType]
+	if !ok {
+		name = contentType
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.requests[name]++
+}
+
+// CountResponse counts a response with status.
+func (u *usageStats) CountResponse(status int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.responses[status]++
+}
+
+// CheckStarted counts a check that is in flight until CheckFinished is
+// called.
+func (u *usageStats) CheckStarted() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.checking++
+}
+
+// CheckFinished counts a check started with CheckStarted that is no longer
+// in flight.
+func (u *usageStats) CheckFinished() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.checking--
+}
+
+// CountHost counts a host that was checked, or answered from the cache.
+func (u *usageStats) CountHost(cached bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.hostsChecked++
+	if cached {
+		u.cacheHits++
+	}
+}
+
+// CountWhois counts a whois lookup for domain that took elapsed, and
+// whether it failed. Lookups are counted by public suffix, since that
+// identifies the registry responsible.
+func (u *usageStats) CountWhois(domain string, elapsed time.Duration, failed bool) {
+	suffix := expire.PublicSuffix(domain)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	suffix = upstreamKey(u.registries, suffix)
+	u.whoisLookups++
+	if failed {
+		u.whoisFailures[suffix]++
+	}
+	if u.registries[suffix] == nil {
+		u.registries[suffix] = &UpstreamStats{}
+	}
+	u.registries[suffix].add(elapsed, failed)
+}
+
+// CountTLS counts a TLS check of a server on network that took elapsed,
+// and whether it failed.
+func (u *usageStats) CountTLS(network string, elapsed time.Duration, failed bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	network = upstreamKey(u.networks, network)
+	if u.networks[network] == nil {
+		u.networks[network] = &UpstreamStats{}
+	}
+	u.networks[network].add(elapsed, failed)
+}
+
+// Stats is a snapshot of the usage statistics.
+type Stats struct {
+	Since         time.Time      `json:"since"`
+	Requests      map[string]int `json:"requests"`
+	Responses     map[int]int    `json:"responses"`
+	InFlight      int            `json:"inFlight"`
+	HostsChecked  int            `json:"hostsChecked"`
+	CacheHits     int            `json:"cacheHits"`
+	CacheHitRate  float64        `json:"cacheHitRate"`
+	WhoisLookups  int            `json:"whoisLookups"`
+	WhoisFailures map[string]int `json:"whoisFailures"`
+
+	// Registries are the whois lookups by public suffix, and Networks are
+	// the TLS checks by the network of the server.
+	Registries map[string]UpstreamStats `json:"registries"`
+	Networks   map[string]UpstreamStats `json:"networks"`
+}
+
+// Snapshot returns the current statistics.
+func (u *usageStats) Snapshot() Stats {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	rv := Stats{
+		Since:         u.started,
+		Requests:      map[string]int{},
+		Responses:     map[int]int{},
+		InFlight:      u.checking,
+		HostsChecked:  u.hostsChecked,
+		CacheHits:     u.cacheHits,
+		WhoisLookups:  u.whoisLookups,
+		WhoisFailures: map[string]int{},
+		Registries:    map[string]UpstreamStats{},
+		Networks:      map[string]UpstreamStats{},
+	}
+	for k, v := range u.requests {
+		rv.Requests[k] = v
+	}
+	for k, v := range u.responses {
+		rv.Responses[k] = v
+	}
+	for k, v := range u.whoisFailures {
+		rv.WhoisFailures[k] = v
+	}
+	for k, v := range u.registries {
+		rv.Registries[k] = *v
+	}
+	for k, v := range u.networks {
+		rv.Networks[k] = *v
+	}
+	if u.hostsChecked > 0 {
+		rv.CacheHitRate = float64(u.cacheHits) / float64(u.hostsChecked)
+	}
+	return rv
+}
+
+// serveStats responds with the usage statistics.
+func (s *Server) serveStats(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r, "/stats") {
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats.Snapshot())
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestUsageStats(t *testing.T) {
+	u := newUsageStats()
+	u.CountRequest("application/json")
+	u.CountRequest("application/json")
+	u.CountRequest("text/calendar")
+	u.CountHost(false)
+	u.CountHost(true)
+	u.CountHost(true)
+	u.CountHost(false)
+	u.CountWhois("example.com", time.Second, false)
+	u.CountWhois("example.io", time.Second, true)
+	u.CountWhois("other.io", time.Second, true)
+
+	got := u.Snapshot()
+	if got.Requests["json"] != 2 || got.Requests["ical"] != 1 {
+		t.Errorf("unexpected requests %v", got.Requests)
+	}
+	if got.HostsChecked != 4 || got.CacheHits != 2 || got.CacheHitRate != 0.5 {
+		t.Errorf("unexpected hosts %d, cache hits %d, rate %f", got.HostsChecked, got.CacheHits, got.CacheHitRate)
+	}
+	if got.WhoisLookups != 3 || got.WhoisFailures["io"] != 2 || got.WhoisFailures["com"] != 0 {
+		t.Errorf("unexpected whois lookups %d, failures %v", got.WhoisLookups, got.WhoisFailures)
+	}
+}
+
+func TestUsageStatsUpstreamsAreBounded(t *testing.T) {
+	u := newUsageStats()
+	for i := 0; i < 2*maxUpstreams; i++ {
+		u.CountTLS(fmt.Sprintf("10.0.%d.0/24", i), time.Second, false)
+		u.CountWhois(fmt.Sprintf("example.tld%d", i), time.Second, true)
+	}
+	u.CountTLS("10.0.0.0/24", time.Second, false)
+
+	got := u.Snapshot()
+	if len(got.Networks) != maxUpstreams+1 || got.Networks[otherUpstreams].Checks != maxUpstreams {
+		t.Errorf("expected %d networks and %d other checks, got %d and %d", maxUpstreams+1, maxUpstreams, len(got.Networks), got.Networks[otherUpstreams].Checks)
+	}
+	if got.Networks["10.0.0.0/24"].Checks != 2 {
+		t.Errorf("expected a network already counted to be counted again, got %+v", got.Networks["10.0.0.0/24"])
+	}
+	if len(got.Registries) != maxUpstreams+1 || len(got.WhoisFailures) != maxUpstreams+1 {
+		t.Errorf("expected %d registries, got %d and %d failures", maxUpstreams+1, len(got.Registries), len(got.WhoisFailures))
+	}
+}
+
+func TestUsageStatsInFlight(t *testing.T) {
+	u := newUsageStats()
+	u.CheckStarted()
+	u.CheckStarted()
+	u.CheckFinished()
+	u.CountResponse(200)
+	u.CountResponse(200)
+	u.CountResponse(404)
+
+	got := u.Snapshot()
+	if got.InFlight != 1 {
+		t.Errorf("expected 1 check in flight, got %d", got.InFlight)
+	}
+	if got.Responses[200] != 2 || got.Responses[404] != 1 {
+		t.Errorf("unexpected responses %v", got.Responses)
+	}
+}
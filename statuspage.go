@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// statusPage is the data rendered on a watchlist status page.
+type statusPage struct {
+	Watchlist   string            `json:"watchlist"`
+	Generated   time.Time         `json:"generated"`
+	Maintenance bool              `json:"maintenance,omitempty"` // notifications are suppressed
+	Groups      []statusPageGroup `json:"groups"`
+}
+
+type statusPageGroup struct {
+	Team  string           `json:"team"`
+	Hosts []statusPageHost `json:"hosts"`
+}
+
+type statusPageHost struct {
+	Name               string     `json:"name"`
+	Status             string     `json:"status"`
+	CertificateExpires *time.Time `json:"certExpires,omitempty"`
+	DomainExpires      *time.Time `json:"domainExpires,omitempty"`
+	DaysRemaining      *int       `json:"daysRemaining,omitempty"`
+	Errors             []string   `json:"errors,omitempty"`
+}
+
+func newStatusPage(wl Watchlist, expirations []Expiration, t thresholds) statusPage {
+	page := statusPage{
+		Watchlist:   wl.Name,
+		Generated:   t.Now,
+		Maintenance: wl.InMaintenance(t.Now),
+	}
+
+	teams := map[string]string{}
+	for _, host := range wl.Hosts {
+		teams[host.Name] = host.Team
+	}
+
+	groupIndex := map[string]int{}
+	for _, exp := range expirations {
+		team, ok := teams[exp.Name]
+		if !ok && exp.Details != nil && exp.Details.RedirectedFrom != "" {
+			// hosts added by following redirects belong to the same team as
+			// the host that redirected to them
+			team = teams[exp.Details.RedirectedFrom]
+		} else if !ok {
+			team = teams[strings.TrimPrefix(exp.Name, "www.")]
+		}
+		if _, ok := groupIndex[team]; !ok {
+			groupIndex[team] = len(page.Groups)
+			page.Groups = append(page.Groups, statusPageGroup{Team: team})
+		}
+
+		host := statusPageHost{
+			Name:   exp.Name,
+			Status: exp.Status(t),
+		}
+		if exp.CertificateError != nil {
+			host.Errors = append(host.Errors, "certificate: "+exp.CertificateError.Error())
+		} else if !exp.CertificateExpires.IsZero() {
+			t := exp.CertificateExpires
+			host.CertificateExpires = &t
+		}
+		if exp.DomainError != nil {
+			host.Errors = append(host.Errors, "domain: "+exp.DomainError.Error())
+		} else if !exp.DomainExpires.IsZero() {
+			t := exp.DomainExpires
+			host.DomainExpires = &t
+		}
+		if soonest, ok := exp.Soonest(); ok {
+			days := daysUntil(t.Now, soonest)
+			host.DaysRemaining = &days
+		}
+
+		group := &page.Groups[groupIndex[team]]
+		group.Hosts = append(group.Hosts, host)
+	}
+
+	// hosts without a team sort last
+	sort.SliceStable(page.Groups, func(i, j int) bool {
+		if page.Groups[i].Team == "" || page.Groups[j].Team == "" {
+			return page.Groups[j].Team == ""
+		}
+		return page.Groups[i].Team < page.Groups[j].Team
+	})
+	return page
+}
+
+var statusPageTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"date": func(t *time.Time) string {
+		if t == nil {
+			return "-"
+		}
+		return t.Format("2006-01-02")
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="300">
+<title>{{.Watchlist}} - expire.sh</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { padding: 0.3em 1em; text-align: left; border-bottom: 1px solid #ddd; }
+tr.ok td.status { background: #c8e6c9; }
+tr.expiring td.status { background: #fff59d; }
+tr.error td.status { background: #ef9a9a; }
+tr.withheld td.status { background: #e0e0e0; }
+tr.acknowledged td.status { background: #bbdefb; }
+.errors { color: #b71c1c; font-size: smaller; }
+</style>
+</head>
+<body>
+<h1>{{.Watchlist}}</h1>
+<p>Generated {{.Generated.Format "2006-01-02 15:04:05 MST"}}</p>
+{{if .Maintenance}}<p>In a maintenance window: notifications are suppressed.</p>{{end}}
+{{range .Groups}}
+<h2>{{if .Team}}{{.Team}}{{else}}Other{{end}}</h2>
+<table>
+<tr><th>Host</th><th>Status</th><th>Certificate</th><th>Domain</th><th>Days</th></tr>
+{{range .Hosts}}<tr class="{{.Status}}">
+<td>{{.Name}}</td>
+<td class="status">{{.Status}}</td>
+<td>{{date .CertificateExpires}}</td>
+<td>{{date .DomainExpires}}</td>
+<td>{{if .DaysRemaining}}{{.DaysRemaining}}{{else}}-{{end}}</td>
+</tr>
+{{range .Errors}}<tr><td></td><td colspan="4" class="errors">{{.}}</td></tr>
+{{end}}{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+func (s *Server) serveStatusPage(w http.ResponseWriter, r *http.Request, name string) {
+	asJSON := strings.HasSuffix(name, ".json")
+	name = strings.TrimSuffix(name, ".json")
+
+	wl := s.watchlist(name)
+	if wl == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	t, err := parseThresholds(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, err.Error())
+		return
+	}
+
+	opts, err := s.parseCheckOptions(r)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		fmt.Fprintln(w, err.Error())
+		return
+	}
+
+	if !s.chargeQuota(w, r, len(wl.Hosts)) {
+		return
+	}
+	expirations := getExpirations(r.Context(), wl.Hostnames(), opts)
+	s.applyAcknowledgements(expirations, t.Now)
+	page := newStatusPage(*wl, expirations, t)
+
+	if asJSON {
+		w.Header().Add("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(page)
+		return
+	}
+
+	w.Header().Add("Content-Type", "text/html; charset=utf-8")
+	statusPageTemplate.Execute(w, page)
+}
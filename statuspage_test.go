@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestNewStatusPage(t *testing.T) {
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	wl := Watchlist{
+		Name: "prod",
+		Hosts: []WatchlistHost{
+			{Name: "a.example.com", Team: "web"},
+			{Name: "b.example.com"},
+			{Name: "c.example.com", Team: "mail"},
+			{Name: "d.example.com", Team: "web"},
+		},
+	}
+	expirations := make([]Expiration, len(wl.Hosts))
+	for i, host := range wl.Hosts {
+		expirations[i] = Expiration{
+			Name:               host.Name,
+			CertificateExpires: now.Add(90 * 24 * time.Hour),
+			DomainExpires:      now.Add(365 * 24 * time.Hour),
+		}
+	}
+	expirations[3].CertificateError = fmt.Errorf("dial failed")
+
+	page := newStatusPage(wl, expirations, thresholds{Now: now, Soon: now.Add(30 * 24 * time.Hour)})
+	var got []string
+	for _, g := range page.Groups {
+		for _, h := range g.Hosts {
+			got = append(got, fmt.Sprintf("%s:%s:%s", g.Team, h.Name, h.Status))
+		}
+	}
+	want := "[mail:c.example.com:ok web:a.example.com:ok web:d.example.com:error :b.example.com:ok]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if len(page.Groups[1].Hosts[1].Errors) != 1 {
+		t.Errorf("expected an error for d.example.com")
+	}
+}
+
+func TestNewStatusPageNoExpiry(t *testing.T) {
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	wl := Watchlist{Name: "prod", Hosts: []WatchlistHost{{Name: "example.com"}}}
+	expirations := []Expiration{{Name: "example.com", CertificateExpires: now.Add(90 * 24 * time.Hour)}}
+
+	page := newStatusPage(wl, expirations, thresholds{Now: now, Soon: now.Add(30 * 24 * time.Hour)})
+	host := page.Groups[0].Hosts[0]
+	if host.CertificateExpires == nil || host.DomainExpires != nil {
+		t.Errorf("expected only a certificate expiry, got %v and %v", host.CertificateExpires, host.DomainExpires)
+	}
+}
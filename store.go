@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store is where state that must outlive a request is kept, such as short
+// links. Keys are namespaced by feature, e.g. link/<code>.
+type Store interface {
+	// Get returns the value for key. The second return value is false if
+	// there is no such key.
+	Get(key string) ([]byte, bool, error)
+
+	// Put sets the value for key.
+	Put(key string, value []byte) error
+}
+
+// maxMemoryStoreSize is the most bytes of keys and values a memory store
+// holds, so that what anyone may store, like short links, can't use up
+// the server's memory.
+const maxMemoryStoreSize = 64 * 1024 * 1024
+
+// errStoreFull is returned by Put when the store has no room for the value.
+var errStoreFull = errors.New("the store is full")
+
+// StoreConfig selects the store.
+type StoreConfig struct {
+	// Type is memory (the default), which loses everything on restart, or
+	// file, which keeps each value in a file in the directory Path.
+	Type string `yaml:"type"`
+	Path string `yaml:"path"`
+}
+
+// openStore returns the store described by config.
+func openStore(config StoreConfig) (Store, error) {
+	switch config.Type {
+	case "", "memory":
+		return newMemoryStore(), nil
+	case "file":
+		if config.Path == "" {
+			return nil, fmt.Errorf("a file store requires a path")
+		}
+		if err := os.MkdirAll(config.Path, 0700); err != nil {
+			return nil, err
+		}
+		return fileStore(config.Path), nil
+	default:
+		return nil, fmt.Errorf("unknown store type %q, expected memory or file", config.Type)
+	}
+}
+
+type memoryStore struct {
+	mu     sync.Mutex
+	values map[string][]byte
+	size   int // of the keys and values
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{values: map[string][]byte{}}
+}
+
+func (s *memoryStore) Get(key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.values[key]
+	return value, ok, nil
+}
+
+func (s *memoryStore) Put(key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	size := s.size + len(value)
+	if old, ok := s.values[key]; ok {
+		size -= len(old)
+	} else {
+		size += len(key)
+	}
+	if size > maxMemoryStoreSize {
+		return errStoreFull
+	}
+	s.values[key] = append([]byte{}, value...)
+	s.size = size
+	return nil
+}
+
+// fileStore keeps each value in a file in a directory. File names are the
+// hex encoded keys, so that keys may contain any characters.
+type fileStore string
+
+func (s fileStore) path(key string) string {
+	return filepath.Join(string(s), hex.EncodeToString([]byte(key)))
+}
+
+func (s fileStore) Get(key string) ([]byte, bool, error) {
+	value, err := ioutil.ReadFile(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (s fileStore) Put(key string, value []byte) error {
+	// write to a temporary file and rename it so that readers never see a
+	// partial value
+	f, err := ioutil.TempFile(string(s), ".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(value); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), s.path(key))
+}
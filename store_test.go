@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, config := range []StoreConfig{{}, {Type: "file", Path: dir}} {
+		store, err := openStore(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok, err := store.Get("link/missing"); ok || err != nil {
+			t.Errorf("%s: expected missing key, got %v %v", config.Type, ok, err)
+		}
+		if err := store.Put("link/abc", []byte("/ical/example.com")); err != nil {
+			t.Fatal(err)
+		}
+		value, ok, err := store.Get("link/abc")
+		if !ok || err != nil || string(value) != "/ical/example.com" {
+			t.Errorf("%s: expected value, got %q %v %v", config.Type, value, ok, err)
+		}
+	}
+
+	if _, err := openStore(StoreConfig{Type: "floppy"}); err == nil {
+		t.Errorf("expected error for unknown store type")
+	}
+}
+
+func TestMemoryStoreLimit(t *testing.T) {
+	store := newMemoryStore()
+	big := make([]byte, maxMemoryStoreSize/2)
+	if err := store.Put("a", big); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("a", big); err != nil {
+		t.Errorf("expected replacing a value to fit, got %s", err)
+	}
+	if err := store.Put("b", big); err != errStoreFull {
+		t.Errorf("expected the store to be full, got %v", err)
+	}
+	if err := store.Put("a", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("b", big); err != nil {
+		t.Errorf("expected room after shrinking a value, got %s", err)
+	}
+}
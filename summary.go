@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Values returned by Expiration.Status
+const (
+	StatusOK       = "ok"
+	StatusExpiring = "expiring"
+	StatusError    = "error"
+	StatusWithheld = "withheld"
+
+	// StatusAcknowledged is an error or expiration that someone has said
+	// they know about.
+	StatusAcknowledged = "acknowledged"
+)
+
+// Values of the status parameter, which chooses the status code of
+// responses
+const (
+	StatusCodesStrict    = "strict"
+	StatusCodesAlways200 = "always200"
+)
+
+// parseStatusCodes parses a value of the status parameter, or of its
+// default in the configuration.
+func parseStatusCodes(s string) (string, error) {
+	switch s {
+	case "", StatusCodesStrict:
+		return StatusCodesStrict, nil
+	case StatusCodesAlways200:
+		return StatusCodesAlways200, nil
+	}
+	return "", fmt.Errorf("Cannot parse status parameter: expected strict or always200")
+}
+
+// statusCodes returns how r asks for the status code to be chosen.
+func (s *Server) statusCodes(r *http.Request) (string, error) {
+	if v := r.FormValue("status"); v != "" {
+		return parseStatusCodes(v)
+	}
+	return parseStatusCodes(s.Config.StatusCodes)
+}
+
+// Summary is the aggregate of a set of expirations, which is included in
+// JSON responses, and returned instead of them when the summary parameter
+// is given. Failing and ExpiringSoon repeat the error and expiring counts
+// of Status, and OverallStatus is error if any check failed, expiring if
+// anything expires soon, and ok otherwise, as the status code says.
+type Summary struct {
+	Total          int            `json:"total"`
+	Status         map[string]int `json:"status"`
+	Failing        int            `json:"failing"`
+	ExpiringSoon   int            `json:"expiringSoon"`
+	OverallStatus  string         `json:"overallStatus"`
+	SoonestName    string         `json:"soonestName,omitempty"`
+	SoonestExpires *time.Time     `json:"soonestExpires,omitempty"`
+}
+
+func summarize(expirations []Expiration, t thresholds) *Summary {
+	rv := &Summary{
+		Total: len(expirations),
+		Status: map[string]int{
+			StatusOK:           0,
+			StatusExpiring:     0,
+			StatusError:        0,
+			StatusWithheld:     0,
+			StatusAcknowledged: 0,
+		},
+	}
+	for _, exp := range expirations {
+		rv.Status[exp.Status(t)]++
+
+		soonest, ok := exp.Soonest()
+		if !ok {
+			continue
+		}
+		if rv.SoonestExpires == nil || soonest.Before(*rv.SoonestExpires) {
+			rv.SoonestName = exp.Name
+			rv.SoonestExpires = &soonest
+		}
+	}
+	rv.Failing = rv.Status[StatusError]
+	rv.ExpiringSoon = rv.Status[StatusExpiring]
+	switch {
+	case rv.Failing > 0:
+		rv.OverallStatus = StatusError
+	case rv.ExpiringSoon > 0:
+		rv.OverallStatus = StatusExpiring
+	default:
+		rv.OverallStatus = StatusOK
+	}
+	return rv
+}
+
+func (s *Server) serveSummary(w http.ResponseWriter, r *http.Request, contentType string, summary *Summary) {
+	if contentType == "application/json" {
+		w.Header().Add("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			SchemaVersion string   `json:"schemaVersion"`
+			Summary       *Summary `json:"summary"`
+		}{
+			SchemaVersion: schemaVersion,
+			Summary:       summary,
+		})
+		return
+	}
+
+	w.Header().Add("Content-Type", "text/plain")
+	fmt.Fprintf(w, "total\t%d\n", summary.Total)
+	for _, status := range []string{StatusOK, StatusExpiring, StatusError, StatusWithheld, StatusAcknowledged} {
+		fmt.Fprintf(w, "%s\t%d\n", status, summary.Status[status])
+	}
+	if summary.SoonestExpires != nil {
+		fmt.Fprintf(w, "soonest\t%s\t%s\n", summary.SoonestName, summary.SoonestExpires)
+	}
+}
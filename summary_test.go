@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+func TestSummarize(t *testing.T) {
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	soon := now.Add(30 * 24 * time.Hour)
+	expirations := []Expiration{
+		{
+			Name:               "a.example.com",
+			CertificateExpires: now.Add(365 * 24 * time.Hour),
+			DomainExpires:      now.Add(365 * 24 * time.Hour),
+		},
+		{
+			Name:               "b.example.com",
+			CertificateExpires: now.Add(7 * 24 * time.Hour),
+			DomainExpires:      now.Add(365 * 24 * time.Hour),
+		},
+		{
+			Name:               "d.example.com",
+			CertificateExpires: now.Add(365 * 24 * time.Hour),
+			DomainError:        expire.ExpiryWithheldError{Domain: "example.com"},
+		},
+		{
+			Name:             "c.example.com",
+			CertificateError: fmt.Errorf("dial failed"),
+			DomainExpires:    now.Add(2 * 24 * time.Hour),
+		},
+	}
+
+	summary := summarize(expirations, thresholds{Now: now, Soon: soon})
+	if summary.Total != 4 {
+		t.Errorf("expected 4 total, got %d", summary.Total)
+	}
+	for status, want := range map[string]int{StatusOK: 1, StatusExpiring: 1, StatusError: 1, StatusWithheld: 1} {
+		if got := summary.Status[status]; got != want {
+			t.Errorf("%s: expected %d, got %d", status, want, got)
+		}
+	}
+	if summary.SoonestName != "c.example.com" {
+		t.Errorf("expected soonest c.example.com, got %s", summary.SoonestName)
+	}
+	if !summary.SoonestExpires.Equal(now.Add(2 * 24 * time.Hour)) {
+		t.Errorf("unexpected soonest expiration %s", summary.SoonestExpires)
+	}
+	if summary.Failing != 1 || summary.ExpiringSoon != 1 || summary.OverallStatus != StatusError {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+
+	// withheld domains aren't failures
+	summary = summarize(expirations[:3], thresholds{Now: now, Soon: soon})
+	if summary.OverallStatus != StatusExpiring {
+		t.Errorf("expected expiring, got %s", summary.OverallStatus)
+	}
+	summary = summarize([]Expiration{expirations[0], expirations[2]}, thresholds{Now: now, Soon: soon})
+	if summary.OverallStatus != StatusOK {
+		t.Errorf("expected ok, got %s", summary.OverallStatus)
+	}
+}
+
+func TestJSONSummary(t *testing.T) {
+	now := time.Now()
+	s := NewServer(&Config{})
+	opts := s.defaultCheckOptions()
+	results.Set(opts.cacheKey("summary1.example.com"), Expiration{Name: "summary1.example.com", CertificateExpires: now.AddDate(0, 0, 10), Details: &Details{}}, now)
+	results.Set(opts.cacheKey("summary2.example.com"), Expiration{Name: "summary2.example.com", CertificateExpires: now.AddDate(1, 0, 0), Details: &Details{}}, now)
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/json/summary1.example.com,summary2.example.com?quiet", nil))
+	var resp struct {
+		Summary     Summary
+		Expirations []json.RawMessage
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Summary.Total != 2 || resp.Summary.ExpiringSoon != 1 || resp.Summary.OverallStatus != StatusExpiring || resp.Summary.SoonestName != "summary1.example.com" {
+		t.Errorf("unexpected summary %+v", resp.Summary)
+	}
+	if len(resp.Expirations) != 1 {
+		t.Errorf("expected the quiet parameter to leave out the ok host, got %d", len(resp.Expirations))
+	}
+}
+
+func TestStatusCodes(t *testing.T) {
+	now := time.Now()
+	s := NewServer(&Config{})
+	opts := s.defaultCheckOptions()
+	results.Set(opts.cacheKey("status1.example.com"), Expiration{Name: "status1.example.com", CertificateExpires: now.AddDate(0, 0, 10), Details: &Details{}}, now)
+
+	for _, tc := range []struct {
+		config string
+		query  string
+		code   int
+	}{
+		{"", "", http.StatusExpectationFailed},
+		{"", "?status=strict", http.StatusExpectationFailed},
+		{"", "?status=always200", http.StatusOK},
+		{StatusCodesAlways200, "", http.StatusOK},
+		{StatusCodesAlways200, "?status=strict", http.StatusExpectationFailed},
+		{"", "?status=sometimes", http.StatusBadRequest},
+	} {
+		s.Config.StatusCodes = tc.config
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/json/status1.example.com"+tc.query, nil))
+		if w.Code != tc.code {
+			t.Errorf("%q with %q: expected %d, got %d", tc.config, tc.query, tc.code, w.Code)
+		}
+	}
+}
+
+func TestHead(t *testing.T) {
+	now := time.Now()
+	s := NewServer(&Config{})
+	opts := s.defaultCheckOptions()
+	results.Set(opts.cacheKey("head1.example.com"), Expiration{Name: "head1.example.com", CertificateExpires: now.AddDate(0, 0, 10), Details: &Details{}}, now)
+	results.Set(opts.cacheKey("head2.example.com"), Expiration{Name: "head2.example.com", CertificateExpires: now.AddDate(1, 0, 0), Details: &Details{}}, now)
+
+	// a text response for several hosts would otherwise be streamed
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("HEAD", "/head1.example.com,head2.example.com", nil))
+	if w.Code != http.StatusExpectationFailed {
+		t.Errorf("expected %d, got %d", http.StatusExpectationFailed, w.Code)
+	}
+	if got := w.Header().Get("X-Expires-Soon"); got != "1" {
+		t.Errorf("expected X-Expires-Soon: 1, got %q", got)
+	}
+	if got := w.Header().Get("X-Check-Errors"); got != "0" {
+		t.Errorf("expected X-Check-Errors: 0, got %q", got)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("expected no body, got %q", w.Body.String())
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SweepConfig describes the network ranges and ports scanned for TLS
+// listeners by the sweep command.
+type SweepConfig struct {
+	Ranges      []string      `yaml:"ranges"`      // CIDR ranges, e.g. 10.0.0.0/24
+	Ports       []int         `yaml:"ports"`       // default: 443
+	Interval    time.Duration `yaml:"interval"`    // if non-zero, repeat the sweep this often
+	Concurrency int           `yaml:"concurrency"` // default: 64
+	Timeout     time.Duration `yaml:"timeout"`     // per connection, default: 2s
+}
+
+// maxSweepAddresses limits the size of a single sweep, to avoid
+// accidentally scanning (say) an entire /8.
+const maxSweepAddresses = 1 << 16
+
+// SweepResult is a certificate found by a sweep.
+type SweepResult struct {
+	Address  string    `json:"address"`
+	Subject  string    `json:"subject"`
+	DNSNames []string  `json:"dnsNames,omitempty"`
+	Issuer   string    `json:"issuer"`
+	Expires  time.Time `json:"expires"`
+}
+
+// sweepAddresses returns every address in ranges.
+func sweepAddresses(ranges []string) ([]net.IP, error) {
+	var rv []net.IP
+	for _, r := range ranges {
+		ip, ipnet, err := net.ParseCIDR(r)
+		if err != nil {
+			if ip = net.ParseIP(r); ip == nil {
+				return nil, err
+			}
+			rv = append(rv, ip)
+			continue
+		}
+		for ip := ip.Mask(ipnet.Mask); ipnet.Contains(ip); ip = nextIP(ip) {
+			rv = append(rv, ip)
+			if len(rv) > maxSweepAddresses {
+				return nil, fmt.Errorf("too many addresses to sweep (maximum %d)", maxSweepAddresses)
+			}
+		}
+	}
+	return rv, nil
+}
+
+func nextIP(ip net.IP) net.IP {
+	rv := append(net.IP{}, ip...)
+	for i := len(rv) - 1; i >= 0; i-- {
+		rv[i]++
+		if rv[i] != 0 {
+			break
+		}
+	}
+	return rv
+}
+
+// probeTLS connects to address and returns the certificates it presents.
+// Verification is skipped because we are connecting by IP address and want
+// to know about every certificate, including broken ones.
+func probeTLS(address string, timeout time.Duration) ([]SweepResult, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var rv []SweepResult
+	for _, cert := range conn.ConnectionState().PeerCertificates {
+		rv = append(rv, SweepResult{
+			Address:  address,
+			Subject:  cert.Subject.String(),
+			DNSNames: cert.DNSNames,
+			Issuer:   cert.Issuer.String(),
+			Expires:  cert.NotAfter,
+		})
+	}
+	return rv, nil
+}
+
+// sweep probes every port on every address in config.Ranges and returns
+// the certificates found.
+func sweep(config SweepConfig) ([]SweepResult, error) {
+	addresses, err := sweepAddresses(config.Ranges)
+	if err != nil {
+		return nil, err
+	}
+
+	ports := config.Ports
+	if len(ports) == 0 {
+		ports = []int{443}
+	}
+	concurrency := config.Concurrency
+	if concurrency <= 0 {
+		concurrency = 64
+	}
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = 2 * time.Second
+	}
+
+	targets := make(chan string)
+	go func() {
+		for _, ip := range addresses {
+			for _, port := range ports {
+				targets <- net.JoinHostPort(ip.String(), strconv.Itoa(port))
+			}
+		}
+		close(targets)
+	}()
+
+	var mu sync.Mutex
+	var rv []SweepResult
+	wg := sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for address := range targets {
+				results, err := probeTLS(address, timeout)
+				if err != nil {
+					continue // nothing listening, or not TLS
+				}
+				mu.Lock()
+				rv = append(rv, results...)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(rv, func(i, j int) bool {
+		if rv[i].Expires.Equal(rv[j].Expires) {
+			return rv[i].Address < rv[j].Address
+		}
+		return rv[i].Expires.Before(rv[j].Expires)
+	})
+	return rv, nil
+}
+
+func writeSweepResults(w io.Writer, format string, results []SweepResult) {
+	if format == "json" {
+		json.NewEncoder(w).Encode(struct {
+			Certificates []SweepResult `json:"certificates"`
+		}{results})
+		return
+	}
+	for _, r := range results {
+		fmt.Fprintln(w, strings.Join([]string{
+			r.Address,
+			r.Expires.String(),
+			r.Subject,
+			strings.Join(r.DNSNames, ","),
+			r.Issuer,
+		}, "\t"))
+	}
+}
+
+// sweepCommand implements the sweep subcommand and returns the exit code.
+func sweepCommand(config *Config, args []string) int {
+	sweepConfig := config.Sweep
+
+	flags := flag.NewFlagSet("sweep", flag.ExitOnError)
+	ports := flags.String("ports", "", "comma separated list of ports to probe (default: 443)")
+	format := flags.String("format", "text", "output format, text or json")
+	flags.DurationVar(&sweepConfig.Interval, "interval", sweepConfig.Interval, "repeat the sweep at this interval")
+	flags.IntVar(&sweepConfig.Concurrency, "concurrency", sweepConfig.Concurrency, "number of connections to make at once")
+	flags.DurationVar(&sweepConfig.Timeout, "timeout", sweepConfig.Timeout, "connection timeout")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s sweep [flags] [CIDR ...]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 {
+		sweepConfig.Ranges = flags.Args()
+	}
+	if *ports != "" {
+		sweepConfig.Ports = nil
+		for _, s := range strings.Split(*ports, ",") {
+			port, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "invalid port %q\n", s)
+				return 2
+			}
+			sweepConfig.Ports = append(sweepConfig.Ports, port)
+		}
+	}
+	if len(sweepConfig.Ranges) == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	for {
+		results, err := sweep(sweepConfig)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		writeSweepResults(os.Stdout, *format, results)
+		if sweepConfig.Interval == 0 {
+			return 0
+		}
+		log.Printf("found %d certificates, sweeping again in %s", len(results), sweepConfig.Interval)
+		time.Sleep(sweepConfig.Interval)
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSweepAddresses(t *testing.T) {
+	addresses, err := sweepAddresses([]string{"10.0.0.254/31", "192.168.1.1"})
+	if err != nil {
+		t.Fatalf("sweepAddresses: %s", err)
+	}
+	if got, want := fmt.Sprint(addresses), "[10.0.0.254 10.0.0.255 192.168.1.1]"; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	if _, err := sweepAddresses([]string{"10.0.0.0/8"}); err == nil {
+		t.Errorf("expected error for very large range")
+	}
+	if _, err := sweepAddresses([]string{"not an address"}); err == nil {
+		t.Errorf("expected error for invalid range")
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"strings"
+)
+
+// targetChecker checks a target that isn't a TLS host and fills in exp.
+// The certificate fields of exp hold the expiration of whatever is being
+// checked, and the domain fields are left empty.
+type targetChecker func(ctx context.Context, target string, opts checkOptions, exp *Expiration)
+
+// targetCheckers are the checkers for targets that are not TLS hosts, by
+// the prefix that identifies them, e.g. pgp:alice@example.com
+var targetCheckers = map[string]targetChecker{
+	"pgp:":    checkPGP,
+	"smimea:": checkSMIMEA,
+	"tuf:":    checkTUF,
+}
+
+// lookupTargetChecker returns the checker for name and the part of name
+// following the prefix. If name is a plain hostname, ok is false.
+func lookupTargetChecker(name string) (checker targetChecker, target string, ok bool) {
+	for prefix, checker := range targetCheckers {
+		if strings.HasPrefix(name, prefix) {
+			return checker, strings.TrimPrefix(name, prefix), true
+		}
+	}
+	return nil, "", false
+}
+
+// isHost returns true if name is a TLS host rather than some other kind
+// of target.
+func isHost(name string) bool {
+	_, _, ok := lookupTargetChecker(name)
+	return !ok
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// thresholds determine when a certificate or domain is considered to be
+// expiring soon.
+type thresholds struct {
+	Now time.Time
+
+	// Soon is the time before which an expiration is soon.
+	Soon time.Time
+
+	// Lifetime, if non-zero, is the fraction of a certificate's validity
+	// period after which it is considered to be expiring soon regardless
+	// of Soon. This is more useful than a fixed window for short lived
+	// certificates.
+	Lifetime float64
+}
+
+// CertificateSoon returns true if the certificate in e is expiring soon.
+func (t thresholds) CertificateSoon(e Expiration) bool {
+	if e.CertificateExpires.IsZero() {
+		return false // doesn't expire, e.g. a PGP key without an expiration
+	}
+	if e.CertificateExpires.Before(t.Soon) {
+		return true
+	}
+	if t.Lifetime > 0 {
+		if elapsed, ok := e.LifetimeElapsed(t.Now); ok && elapsed >= t.Lifetime {
+			return true
+		}
+	}
+	return false
+}
+
+// DomainSoon returns true if the domain in e is expiring soon.
+func (t thresholds) DomainSoon(e Expiration) bool {
+	if e.DomainExpires.IsZero() {
+		return false // not checked, e.g. for a PGP key
+	}
+	return e.DomainExpires.Before(t.Soon)
+}
+
+// parseThresholds returns the thresholds specified by the ttl and lifetime
+// query parameters.
+func parseThresholds(r *http.Request) (thresholds, error) {
+	ttl, err := parseTTL(r)
+	if err != nil {
+		return thresholds{}, fmt.Errorf("Cannot parse ttl parameter: %s", err)
+	}
+
+	rv := newThresholds(time.Now(), ttl)
+	if lifetimeStr := r.FormValue("lifetime"); lifetimeStr != "" {
+		rv.Lifetime, err = parseLifetime(lifetimeStr)
+		if err != nil {
+			return thresholds{}, fmt.Errorf("Cannot parse lifetime parameter: %s", err)
+		}
+	}
+	return rv, nil
+}
+
+// newThresholds returns the thresholds at now for which expirations within
+// ttl are soon.
+func newThresholds(now time.Time, ttl time.Duration) thresholds {
+	return thresholds{
+		Now:  now,
+		Soon: now.Add(ttl),
+	}
+}
+
+// parseLifetime parses a percentage of a certificate's validity period,
+// like 80 or 80%, as a fraction.
+func parseLifetime(s string) (float64, error) {
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil || percent <= 0 || percent > 100 {
+		return 0, fmt.Errorf("expected a percentage between 0 and 100")
+	}
+	return percent / 100, nil
+}
+
+// defaultTTL is how soon an expiration must be to be reported, unless
+// the ttl parameter says otherwise.
+const defaultTTL = 30 * 24 * time.Hour
+
+// parseTTL returns the duration specified by the ttl query parameter, e.g.
+// 60d or 1y, or the default of 30 days.
+func parseTTL(r *http.Request) (time.Duration, error) {
+	ttl := defaultTTL
+	if ttlStr := r.FormValue("ttl"); ttlStr != "" {
+		return parseDuration(ttlStr)
+	}
+	return ttl, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThresholdsLifetime(t *testing.T) {
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	// a 90 day certificate with 10 days remaining is 89% of the way through
+	// its lifetime
+	e := Expiration{
+		CertificateNotBefore: now.Add(-80 * day),
+		CertificateExpires:   now.Add(10 * day),
+		DomainExpires:        now.Add(365 * day),
+	}
+
+	th := thresholds{Now: now, Soon: now.Add(7 * day)}
+	if th.CertificateSoon(e) {
+		t.Errorf("expected certificate not to be expiring soon without lifetime")
+	}
+	if got := e.Status(th); got != StatusOK {
+		t.Errorf("expected %s, got %s", StatusOK, got)
+	}
+
+	th.Lifetime = 0.8
+	if !th.CertificateSoon(e) {
+		t.Errorf("expected certificate to be expiring soon with lifetime 80%%")
+	}
+	if got := e.Status(th); got != StatusExpiring {
+		t.Errorf("expected %s, got %s", StatusExpiring, got)
+	}
+
+	th.Lifetime = 0.9
+	if th.CertificateSoon(e) {
+		t.Errorf("expected certificate not to be expiring soon with lifetime 90%%")
+	}
+}
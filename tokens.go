@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const tokenPrefix = "/t/"
+
+// Tokens are an alternative to short links that need no storage: the URL
+// is compressed and encrypted with a key known only to the server, so the
+// token is short-ish, reveals nothing about the hosts it checks, and can't
+// be forged or enumerated.
+
+// tokenKey returns the key used to encrypt tokens, derived from the
+// configured secret or $EXPIRE_TOKEN_SECRET. The second return value is
+// false if neither is set.
+func (s *Server) tokenKey() ([]byte, bool) {
+	secret := s.Config.TokenSecret
+	if secret == "" {
+		secret = os.Getenv("EXPIRE_TOKEN_SECRET")
+	}
+	if secret == "" {
+		return nil, false
+	}
+	key := sha256.Sum256([]byte(secret))
+	return key[:], true
+}
+
+// sealToken returns a token for target.
+func sealToken(key []byte, target string) (string, error) {
+	compressed := &bytes.Buffer{}
+	fw, err := flate.NewWriter(compressed, flate.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	io.WriteString(fw, target)
+	if err := fw.Close(); err != nil {
+		return "", err
+	}
+
+	aead, err := newTokenAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, compressed.Bytes(), nil)
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// openToken returns the target that token was made for.
+func openToken(key []byte, token string) (string, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", fmt.Errorf("invalid token")
+	}
+	aead, err := newTokenAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("invalid token")
+	}
+	compressed, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid token")
+	}
+	target, err := ioutil.ReadAll(&io.LimitedReader{R: flate.NewReader(bytes.NewReader(compressed)), N: maxLinkTargetSize})
+	if err != nil {
+		return "", fmt.Errorf("invalid token")
+	}
+	return string(target), nil
+}
+
+func newTokenAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// serveCreateToken responds with a token URL for the URL in the request
+// body.
+func (s *Server) serveCreateToken(w http.ResponseWriter, r *http.Request) {
+	key, ok := s.tokenKey()
+	if !ok {
+		http.Error(w, "tokens are not enabled on this server", http.StatusNotImplemented)
+		return
+	}
+	buf, err := ioutil.ReadAll(&io.LimitedReader{R: r.Body, N: maxLinkTargetSize + 1})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(buf) > maxLinkTargetSize {
+		http.Error(w, fmt.Sprintf("URL too long (maximum %d bytes)", maxLinkTargetSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	target, err := linkTarget(string(buf))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if strings.HasPrefix(target, tokenPrefix) {
+		http.Error(w, "cannot make a token for another token", http.StatusBadRequest)
+		return
+	}
+
+	token, err := sealToken(key, target)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintln(w, s.baseURL(r)+tokenPrefix+token)
+}
+
+// serveToken serves the URL that token was made for. Unlike short links,
+// tokens are never redirected, since that would reveal the hosts.
+func (s *Server) serveToken(w http.ResponseWriter, r *http.Request, token string) {
+	key, ok := s.tokenKey()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	target, err := openToken(key, strings.TrimSuffix(token, ".ics"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	s.serveTarget(w, r, target, false)
+}
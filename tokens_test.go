@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTokens(t *testing.T) {
+	key := make([]byte, 32)
+	token, err := sealToken(key, "/ical/example.com,example.net?ttl=60d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(token, "example") {
+		t.Errorf("expected the token not to reveal the hosts, got %s", token)
+	}
+	target, err := openToken(key, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != "/ical/example.com,example.net?ttl=60d" {
+		t.Errorf("unexpected target %s", target)
+	}
+
+	otherKey := make([]byte, 32)
+	otherKey[0] = 1
+	if _, err := openToken(otherKey, token); err == nil {
+		t.Errorf("expected a token sealed with another key to be rejected")
+	}
+	if _, err := openToken(key, token[:len(token)-2]+"xx"); err == nil {
+		t.Errorf("expected a modified token to be rejected")
+	}
+}
+
+func TestServeToken(t *testing.T) {
+	s := NewServer(&Config{
+		TokenSecret: "sekrit",
+		Watchlists:  []Watchlist{{Name: "prod"}},
+	})
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/t", strings.NewReader("/status/prod.json")))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	link := strings.TrimSpace(w.Body.String())
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", link[strings.Index(link, tokenPrefix):], nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"watchlist":"prod"`) {
+		t.Errorf("expected the status page, got %d: %s", w.Code, w.Body.String())
+	}
+
+	s = NewServer(&Config{})
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/t", strings.NewReader("/status/prod.json")))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("expected 501 without a secret, got %d", w.Code)
+	}
+}
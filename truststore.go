@@ -0,0 +1,25 @@
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/crewjam/expire-sh/expire"
+)
+
+// loadTrustStores adds the trust stores specified in the config.
+func loadTrustStores(stores []TrustStoreConfig) error {
+	for _, store := range stores {
+		buf, err := ioutil.ReadFile(store.File)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(buf) {
+			return fmt.Errorf("trust store %s: no certificates found in %s", store.Name, store.File)
+		}
+		expire.AddTrustStore(store.Name, pool)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxTUFMetadataSize is the largest TUF metadata file we'll download
+const maxTUFMetadataSize = 4 * 1024 * 1024
+
+// tufRoles are the top-level TUF roles whose metadata we check. Each is
+// fetched from <role>.json in the repository's metadata directory.
+var tufRoles = []string{"root", "timestamp", "snapshot", "targets"}
+
+var tufClient = &http.Client{Timeout: 10 * time.Second}
+
+// TUFRole describes the metadata for one role in a TUF repository.
+type TUFRole struct {
+	Role    string    `json:"role"`
+	Version int       `json:"version"`
+	Expires time.Time `json:"expires"`
+}
+
+// tufMetadata is the part of a TUF metadata file that we care about.
+type tufMetadata struct {
+	Signed struct {
+		Type    string    `json:"_type"`
+		Version int       `json:"version"`
+		Expires time.Time `json:"expires"`
+	} `json:"signed"`
+}
+
+// checkTUF fetches the top-level metadata of the TUF repository whose
+// metadata is at base and reports when the first of it expires. Expired
+// metadata stops clients from updating just as an expired certificate
+// would.
+func checkTUF(ctx context.Context, base string, opts checkOptions, exp *Expiration) {
+	if !strings.Contains(base, "://") {
+		base = "https://" + base
+	}
+	base = strings.TrimSuffix(base, "/")
+
+	for _, role := range tufRoles {
+		md, err := fetchTUFMetadata(ctx, base+"/"+role+".json")
+		if err != nil {
+			exp.CertificateError = err
+			return
+		}
+		if md.Signed.Expires.IsZero() {
+			exp.CertificateError = fmt.Errorf("%s.json: no expiration found", role)
+			return
+		}
+		exp.Details.TUFRoles = append(exp.Details.TUFRoles, TUFRole{
+			Role:    role,
+			Version: md.Signed.Version,
+			Expires: md.Signed.Expires,
+		})
+		if exp.CertificateExpires.IsZero() || md.Signed.Expires.Before(exp.CertificateExpires) {
+			exp.CertificateExpires = md.Signed.Expires
+		}
+	}
+}
+
+func fetchTUFMetadata(ctx context.Context, u string) (*tufMetadata, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := tufClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	return parseTUFMetadata(&io.LimitedReader{R: resp.Body, N: maxTUFMetadataSize})
+}
+
+func parseTUFMetadata(r io.Reader) (*tufMetadata, error) {
+	md := &tufMetadata{}
+	if err := json.NewDecoder(r).Decode(md); err != nil {
+		return nil, fmt.Errorf("cannot parse TUF metadata: %s", err)
+	}
+	return md, nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTUFMetadata(t *testing.T) {
+	md, err := parseTUFMetadata(strings.NewReader(`{
+		"signatures": [{"keyid": "abc", "sig": "def"}],
+		"signed": {
+			"_type": "timestamp",
+			"spec_version": "1.0.0",
+			"version": 42,
+			"expires": "2030-01-02T03:04:05Z",
+			"meta": {"snapshot.json": {"version": 7}}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md.Signed.Type != "timestamp" || md.Signed.Version != 42 {
+		t.Errorf("unexpected metadata: %+v", md.Signed)
+	}
+	if want := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC); !md.Signed.Expires.Equal(want) {
+		t.Errorf("expected %s, got %s", want, md.Signed.Expires)
+	}
+
+	if _, err := parseTUFMetadata(strings.NewReader("<html>")); err == nil {
+		t.Errorf("expected error for invalid metadata")
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// maxZoneSize is the largest zone file that may be uploaded.
+const maxZoneSize = 10 * 1024 * 1024
+
+// zoneHostnames returns the unique owner names of the A, AAAA and CNAME
+// records in a BIND format zone file.
+func zoneHostnames(r io.Reader, origin string) ([]string, error) {
+	if origin != "" {
+		origin = dns.Fqdn(origin)
+	}
+
+	var rv []string
+	seen := map[string]bool{}
+	zp := dns.NewZoneParser(r, origin, "")
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		name, ok := hostnameFromRR(rr)
+		if !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		rv = append(rv, name)
+	}
+	if err := zp.Err(); err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+// hostnameFromRR returns the owner name of rr if it is an A, AAAA or
+// CNAME record. Wildcard names are skipped since they can't be checked.
+func hostnameFromRR(rr dns.RR) (string, bool) {
+	switch rr.Header().Rrtype {
+	case dns.TypeA, dns.TypeAAAA, dns.TypeCNAME:
+	default:
+		return "", false
+	}
+	name := strings.ToLower(strings.TrimSuffix(rr.Header().Name, "."))
+	if name == "" || strings.HasPrefix(name, "*") {
+		return "", false
+	}
+	return name, true
+}
+
+func (s *Server) serveZone(w http.ResponseWriter, r *http.Request) {
+	// determine the origin before reading the body, because parsing the
+	// form would consume it.
+	origin := r.URL.Query().Get("origin")
+
+	hostnames, err := zoneHostnames(http.MaxBytesReader(w, r.Body, maxZoneSize), origin)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, "Cannot parse zone file:", err.Error())
+		return
+	}
+	if len(hostnames) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, "The zone file does not contain any A, AAAA, or CNAME records")
+		return
+	}
+	if !s.checkHostCount(w, len(hostnames)) {
+		return
+	}
+
+	s.serveHostnames(w, r, hostnames)
+}